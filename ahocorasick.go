@@ -5,6 +5,7 @@ package ahocorasick
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"sort"
@@ -16,6 +17,8 @@ const (
 	// This must be <255 since the offsets used are in [0,255]
 	// This should only appear in the Base array since the Check array uses
 	// negative values to represent free states.
+	// The larger alphabets of SymbolMatcher may push leaf+offset into range,
+	// which is harmless as no state ever has a leaf as its parent in Check.
 	leaf = -1867
 )
 
@@ -30,8 +33,34 @@ type Matcher struct {
 	check  []int     // check array in the double array trie
 	fail   []int     // fail function
 	output [][]SWord // output function: originally [state][wordlen], replaced to tuple of {wordlen,workey}
+
+	anchored bool     // only report patterns starting at the beginning of the text
+	anchors  []Anchor // anchors of each pattern by key, nil if there are none
+	maxLen   int      // length of the longest pattern
+
+	boundary     Boundary                   // boundary of the patterns compiled without one
+	boundaries   []Boundary                 // boundary of each pattern by key, nil if there are none
+	boundaryFunc func(prev, next rune) bool // decides CustomBoundary, never serialized
+
+	nextKey   uint64     // one more than the largest key ever given, so Add never gives it again
+	failIndex *failIndex // states by failure state, kept by Add, never serialized
 }
 
+// Optional sections may follow the output table of a serialized Matcher. Each
+// one is written as a tag, a word count and the words themselves. A Matcher
+// which needs none of them serializes exactly as earlier versions did.
+const (
+	sectionFlags      uint64 = iota + 1 // matcher wide flags
+	sectionAnchors                      // anchors of each pattern by key
+	sectionBoundaries                   // default boundary, then the boundary of each pattern by key
+	sectionNextKey                      // the key Add gives next, if beyond the keys of the outputs
+)
+
+// Bits of the sectionFlags section.
+const (
+	flagAnchored uint64 = 1 << iota
+)
+
 // added function for byte serialization of compiled matcher
 func (m *Matcher) Serialize() []byte {
 	var lenBase, lenCheck, lenFail, lenOutput uint64
@@ -98,16 +127,136 @@ func (m *Matcher) Serialize() []byte {
 			}
 		}
 	}
+	err = binary.Write(buf, binary.LittleEndian, m.sections())
+	if err != nil {
+		fmt.Println("binary.Write failed for sections:", err)
+	}
 	return (buf.Bytes())
 }
 
+// sections returns the optional trailer of the serialized form.
+func (m *Matcher) sections() []uint64 {
+	var words []uint64
+	var flags uint64
+	if m.anchored {
+		flags |= flagAnchored
+	}
+	if flags != 0 {
+		words = append(words, sectionFlags, 1, flags)
+	}
+	if m.anchors != nil {
+		words = append(words, sectionAnchors, uint64(len(m.anchors)))
+		for _, a := range m.anchors {
+			words = append(words, uint64(a))
+		}
+	}
+	if m.boundary != NoBoundary || m.boundaries != nil {
+		words = append(words, sectionBoundaries, uint64(1+len(m.boundaries)), uint64(m.boundary))
+		for _, b := range m.boundaries {
+			words = append(words, uint64(b))
+		}
+	}
+	if m.nextKey > m.usedKeys() {
+		words = append(words, sectionNextKey, 1, m.nextKey)
+	}
+	return words
+}
+
+// usedKeys returns one more than the largest key of the outputs, or the size
+// of the anchors or boundaries tables if they are larger.
+func (m *Matcher) usedKeys() uint64 {
+	used := uint64(len(m.anchors))
+	if uint64(len(m.boundaries)) > used {
+		used = uint64(len(m.boundaries))
+	}
+	for _, out := range m.output {
+		for _, item := range out {
+			if item.Key >= used {
+				used = item.Key + 1
+			}
+		}
+	}
+	return used
+}
+
+// readSections restores the optional trailer written by sections. Unknown
+// tags are rejected so that a newer blob is never silently misinterpreted.
+func (m *Matcher) readSections(reader *bytes.Reader) error {
+	for reader.Len() > 0 {
+		var tag, n uint64
+		err := binary.Read(reader, binary.LittleEndian, &tag)
+		if err != nil {
+			return err
+		}
+		err = binary.Read(reader, binary.LittleEndian, &n)
+		if err != nil {
+			return err
+		}
+		if n > uint64(reader.Len()/8) {
+			return &DeserializeError{}
+		}
+		words := make([]uint64, n)
+		err = binary.Read(reader, binary.LittleEndian, words)
+		if err != nil {
+			return err
+		}
+		switch tag {
+		case sectionFlags:
+			if n != 1 {
+				return &DeserializeError{}
+			}
+			m.anchored = words[0]&flagAnchored != 0
+		case sectionAnchors:
+			m.anchors = make([]Anchor, n)
+			for i, w := range words {
+				if w > uint64(anchorMask) {
+					return &DeserializeError{}
+				}
+				m.anchors[i] = Anchor(w)
+			}
+		case sectionBoundaries:
+			if n == 0 {
+				return &DeserializeError{}
+			}
+			for _, w := range words {
+				if w >= uint64(boundaryCount) {
+					return &DeserializeError{}
+				}
+			}
+			m.boundary = Boundary(words[0])
+			if n > 1 {
+				m.boundaries = make([]Boundary, n-1)
+				for i, w := range words[1:] {
+					m.boundaries[i] = Boundary(w)
+				}
+			}
+		case sectionNextKey:
+			if n != 1 {
+				return &DeserializeError{}
+			}
+			m.nextKey = words[0]
+		default:
+			return &DeserializeError{}
+		}
+	}
+	return nil
+}
+
 type DeserializeError struct{}
 
 func (m *DeserializeError) Error() string {
 	return "Finite state machine is corrupted"
 }
 
+// Deserialize restores a Matcher written by Serialize. The lengths in data are
+// checked against its size before anything is allocated, so that corrupted or
+// hostile data fails with a DeserializeError rather than exhausting memory,
+// but the consistency of the arrays themselves is only checked by Validate.
 func Deserialize(data []byte) (m *Matcher, err error) {
+	return deserialize(data, nil)
+}
+
+func deserialize(data []byte, opts *DeserializeOptions) (m *Matcher, err error) {
 	m = new(Matcher)
 
 	totalLength := len(data)
@@ -116,6 +265,10 @@ func Deserialize(data []byte) (m *Matcher, err error) {
 		err = &DeserializeError{}
 		return
 	}
+	if opts != nil && opts.MaxSize > 0 && int64(totalLength) > opts.MaxSize {
+		err = ErrDeserializeLimit
+		return
+	}
 	//reader := bytes.NewReader(data)
 	reader := bytes.NewReader(data)
 
@@ -138,26 +291,32 @@ func Deserialize(data []byte) (m *Matcher, err error) {
 		return
 	}
 
-	lenOutputEach := make([]uint64, lenOutput)
-
-	if totalLength < 8*(4+int(lenOutput)) {
-		err = &DeserializeError{}
+	// Count the words left for each table before reading it, so that no
+	// length may exceed the size of the data and no sum may overflow.
+	words := uint64(totalLength/8) - 4
+	for _, n := range []uint64{lenOutput, lenBase, lenCheck, lenFail} {
+		if n > words {
+			err = &DeserializeError{}
+			return
+		}
+		words -= n
+	}
+	if opts != nil && opts.MaxStates > 0 && lenCheck > uint64(opts.MaxStates) {
+		err = ErrDeserializeLimit
 		return
 	}
 
-	calculatedLength := 8 * (4 + int(lenOutput) + int(lenBase) + int(lenCheck) + int(lenFail))
-
-	for i := 0; i < int(lenOutput); i++ {
+	lenOutputEach := make([]uint64, lenOutput)
+	for i := range lenOutputEach {
 		err = binary.Read(reader, binary.LittleEndian, &(lenOutputEach[i]))
 		if err != nil {
 			return
 		}
-		calculatedLength += 16 * int(lenOutputEach[i])
-	}
-
-	if calculatedLength != totalLength {
-		err = &DeserializeError{}
-		return
+		if lenOutputEach[i] > words/2 {
+			err = &DeserializeError{}
+			return
+		}
+		words -= 2 * lenOutputEach[i]
 	}
 
 	err = readToSlice(reader, lenBase, &m.base)
@@ -172,17 +331,79 @@ func Deserialize(data []byte) (m *Matcher, err error) {
 	if err != nil {
 		return
 	}
+	var maxKey uint64
+	hasKey := false
 	m.output = make([][]SWord, lenOutput)
 	for i, v := range lenOutputEach {
 		err = readToSliceSWord(reader, v, &m.output[i])
 		if err != nil {
 			return
 		}
+		for _, item := range m.output[i] {
+			// A pattern is no longer than the number of states.
+			if item.Len > lenCheck {
+				err = &DeserializeError{}
+				return
+			}
+			if int(item.Len) > m.maxLen {
+				m.maxLen = int(item.Len)
+			}
+			if !hasKey || item.Key > maxKey {
+				maxKey, hasKey = item.Key, true
+			}
+		}
 	}
 
+	err = m.readSections(reader)
+	if err == nil && hasKey && m.anchors != nil && maxKey >= uint64(len(m.anchors)) {
+		err = &DeserializeError{}
+	}
+	if err == nil && hasKey && m.boundaries != nil && maxKey >= uint64(len(m.boundaries)) {
+		err = &DeserializeError{}
+	}
+	if err == nil {
+		// Only a Matcher whose largest keys were removed records the next
+		// one, which cannot be a key still in use.
+		used := m.usedKeys()
+		if m.nextKey != 0 && m.nextKey < used {
+			err = &DeserializeError{}
+		} else if m.nextKey < used {
+			m.nextKey = used
+		}
+	}
 	return
 }
 
+// ErrDeserializeLimit is returned when serialized data exceeds the limits of
+// DeserializeOptions.
+var ErrDeserializeLimit = errors.New("ahocorasick: serialized matcher exceeds limits")
+
+// DeserializeOptions configure DeserializeWithOptions.
+type DeserializeOptions struct {
+	// Validate runs Validate on the Matcher, and fails with its error.
+	Validate bool
+	// MaxSize is the largest size of the data, if not zero. The Matcher
+	// takes at most about three times the size of its serialized form.
+	MaxSize int64
+	// MaxStates is the largest number of slots of the double array, if not
+	// zero.
+	MaxStates int
+}
+
+// DeserializeWithOptions is Deserialize configured by opts, which may be nil.
+func DeserializeWithOptions(data []byte, opts *DeserializeOptions) (*Matcher, error) {
+	m, err := deserialize(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil && opts.Validate {
+		if err := m.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
 func readToSlice(reader *bytes.Reader, len uint64, array *[]int) error {
 	*array = make([]int, len)
 	var item uint64
@@ -230,14 +451,56 @@ func (bss byteSliceSlice) Len() int           { return len(bss) }
 func (bss byteSliceSlice) Less(i, j int) bool { return bytes.Compare(bss[i], bss[j]) < 1 }
 func (bss byteSliceSlice) Swap(i, j int)      { bss[i], bss[j] = bss[j], bss[i] }
 
+// keyedWords sorts words together with the keys reported for them.
+type keyedWords struct {
+	words [][]byte
+	keys  []uint64
+}
+
+func (kw keyedWords) Len() int           { return len(kw.words) }
+func (kw keyedWords) Less(i, j int) bool { return bytes.Compare(kw.words[i], kw.words[j]) < 0 }
+func (kw keyedWords) Swap(i, j int) {
+	kw.words[i], kw.words[j] = kw.words[j], kw.words[i]
+	kw.keys[i], kw.keys[j] = kw.keys[j], kw.keys[i]
+}
+
 func compile(words [][]byte) *Matcher {
+	sort.Sort(byteSliceSlice(words))
+	return build(words, nil)
+}
+
+// compileKeyed compiles words without reordering them, reporting keys[i] for
+// words[i] instead of its position in sorted order.
+func compileKeyed(words [][]byte, keys []uint64) *Matcher {
+	kw := keyedWords{append([][]byte{}, words...), append([]uint64{}, keys...)}
+	sort.Sort(kw)
+	return build(kw.words, kw.keys)
+}
+
+// build constructs the double array trie of the sorted words. The key of
+// words[i] is keys[i], or i if keys is nil. Words are usually bytes, but may
+// also be the codes of a remapped alphabet, see SymbolMatcher.
+func build[S byte | int32](words [][]S, keys []uint64) *Matcher {
 	m := new(Matcher)
 	m.base = make([]int, 2048)[:1]
 	m.check = make([]int, 2048)[:1]
 	m.fail = make([]int, 2048)[:1]
 	m.output = make([][]SWord, 2048)[:1]
 
-	sort.Sort(byteSliceSlice(words))
+	for _, word := range words {
+		if len(word) > m.maxLen {
+			m.maxLen = len(word)
+		}
+	}
+	m.nextKey = uint64(len(words))
+	if keys != nil {
+		m.nextKey = 0
+		for _, key := range keys {
+			if key >= m.nextKey {
+				m.nextKey = key + 1
+			}
+		}
+	}
 
 	// Represents a node in the implicit trie of words
 	type trienode struct {
@@ -258,10 +521,10 @@ func compile(words [][]byte) *Matcher {
 			continue
 		}
 
-		var edges []byte
+		var edges []int
 		for i := node.start; i < node.end; i++ {
-			if len(edges) == 0 || edges[len(edges)-1] != words[i][node.depth] {
-				edges = append(edges, words[i][node.depth])
+			if len(edges) == 0 || edges[len(edges)-1] != int(words[i][node.depth]) {
+				edges = append(edges, int(words[i][node.depth]))
 			}
 		}
 
@@ -271,8 +534,7 @@ func compile(words [][]byte) *Matcher {
 		m.base[node.state] = base
 
 		i := node.start
-		for _, edge := range edges {
-			offset := int(edge)
+		for _, offset := range edges {
 			newState := base + offset
 
 			m.occupyState(newState, node.state)
@@ -287,13 +549,17 @@ func compile(words [][]byte) *Matcher {
 			newnode := trienode{newState, node.depth + 1, i, i}
 			for {
 				if newnode.depth >= len(words[i]) {
-					m.output[newState] = append(m.output[newState], SWord{uint64(len(words[i])), uint64(i)})
+					key := uint64(i)
+					if keys != nil {
+						key = keys[i]
+					}
+					m.output[newState] = append(m.output[newState], SWord{uint64(len(words[i])), key})
 					newnode.start++
 				}
 				newnode.end++
 
 				i++
-				if i >= node.end || words[i][node.depth] != edge {
+				if i >= node.end || int(words[i][node.depth]) != offset {
 					break
 				}
 			}
@@ -381,19 +647,19 @@ func (m *Matcher) unionFailOutput(state, failState int) {
 // base and check (and the fail array for consistency) will be extended just
 // enough to fit each transition.
 // The extension will maintain the bidirectional link of free states.
-func (m *Matcher) findBase(edges []byte) int {
+func (m *Matcher) findBase(edges []int) int {
 	if len(edges) == 0 {
 		return leaf
 	}
 
-	min := int(edges[0])
-	max := int(edges[len(edges)-1])
+	min := edges[0]
+	max := edges[len(edges)-1]
 	width := max - min
 	freeState := m.firstFreeState()
 	for freeState != -1 {
 		valid := true
 		for _, e := range edges[1:] {
-			state := freeState + int(e) - min
+			state := freeState + e - min
 			if state >= len(m.check) {
 				break
 			} else if m.check[state] >= 0 {
@@ -528,6 +794,24 @@ func (m *Matcher) hasEdge(fromState, offset int) bool {
 	return toState > 0 && toState < len(m.check) && m.check[toState] == fromState
 }
 
+// advance returns the state reached from state by reading b, following fail
+// links as needed.
+func (m *Matcher) advance(state int, b byte) int {
+	return m.step(state, int(b))
+}
+
+// step is advance for the offset of any symbol, which lets SymbolMatcher run
+// automata over alphabets larger than bytes.
+func (m *Matcher) step(state, offset int) int {
+	for state != 0 && !m.hasEdge(state, offset) {
+		state = m.fail[state]
+	}
+	if m.hasEdge(state, offset) {
+		state = m.base[state] + offset
+	}
+	return state
+}
+
 // Match represents a matched pattern in the text
 type Match struct {
 	Word  []byte // the matched pattern
@@ -540,7 +824,17 @@ type Matches interface {
 }
 
 func (m *Matcher) findAll(text []byte) []*Match {
+	return m.findEnabled(text, nil)
+}
+
+// findEnabled is findAll reporting only the patterns of enabled, or all of
+// them if enabled is nil.
+func (m *Matcher) findEnabled(text []byte, enabled *EnabledSet) []*Match {
+	if m.anchored {
+		return m.findAnchoredEnabled(text, 0, enabled)
+	}
 	var matches []*Match
+	filtered := m.filtered()
 	state := 0
 	for i, b := range text {
 		offset := int(b)
@@ -552,13 +846,24 @@ func (m *Matcher) findAll(text []byte) []*Match {
 			state = m.base[state] + offset
 		}
 		for _, item := range m.output[state] {
-			matches = append(matches, &Match{text[i-int(item.Len)+1 : i+1], i - int(item.Len) + 1})
+			if enabled != nil && !enabled.has(item.Key) {
+				continue
+			}
+			start := i - int(item.Len) + 1
+			if filtered && !m.acceptAt(item.Key, text, start, i+1) {
+				continue
+			}
+			matches = append(matches, &Match{text[start : i+1], start})
 		}
 	}
 	return matches
 }
 
 func (m *Matcher) findAllReader(reader io.Reader, matches Matches) {
+	if m.anchored {
+		m.findAnchoredReader(reader, matches)
+		return
+	}
 	state := 0
 
 	var bchan = make(chan []byte, 1024)
@@ -578,10 +883,18 @@ func (m *Matcher) findAllReader(reader io.Reader, matches Matches) {
 		close(bchan)
 	}()
 
+	var sf *streamFilter
+	if m.filtered() {
+		sf = m.newStreamFilter()
+	}
+
 	i := 1
 	for bb := range bchan {
 		for j := 0; j < len(bb); j++ {
 			offset := int(bb[j])
+			if sf != nil {
+				sf.next(bb[j], matches)
+			}
 			for state != 0 && !m.hasEdge(state, offset) {
 				state = m.fail[state]
 			}
@@ -590,11 +903,18 @@ func (m *Matcher) findAllReader(reader io.Reader, matches Matches) {
 				state = m.base[state] + offset
 			}
 			for _, item := range m.output[state] {
+				if sf != nil {
+					sf.match(i, item, matches)
+					continue
+				}
 				matches.Append(i, int(item.Key))
 			}
 			i++
 		}
 	}
+	if sf != nil {
+		sf.close(matches)
+	}
 }
 
 // FindAllByteSlice finds all instances of the patterns in the text.
@@ -2,6 +2,7 @@ package ahocorasick
 
 import (
 	"bytes"
+	"encoding/binary"
 	"math/rand"
 	"reflect"
 	"testing"
@@ -89,10 +90,10 @@ func TestFindAllByteSlice(t *testing.T) {
 
 func TestIncreaseSize(t *testing.T) {
 	m := &Matcher{
-		[]int{5, 0, 0},
-		[]int{0, 0, 0},
-		[]int{0, 0, 0},
-		[][]SWord{},
+		base:   []int{5, 0, 0},
+		check:  []int{0, 0, 0},
+		fail:   []int{0, 0, 0},
+		output: [][]SWord{},
 	}
 	m.increaseSize(1)
 	if !reflect.DeepEqual(m.base, []int{5, 0, 0, -3}) {
@@ -119,10 +120,10 @@ func TestIncreaseSize(t *testing.T) {
 	}
 
 	m = &Matcher{
-		[]int{5, 0, 0},
-		[]int{0, 0, 0},
-		[]int{0, 0, 0},
-		[][]SWord{},
+		base:   []int{5, 0, 0},
+		check:  []int{0, 0, 0},
+		fail:   []int{0, 0, 0},
+		output: [][]SWord{},
 	}
 	m.increaseSize(3)
 	if !reflect.DeepEqual(m.base, []int{5, 0, 0, -5, -3, -4}) {
@@ -141,10 +142,10 @@ func TestIncreaseSize(t *testing.T) {
 	}
 
 	m = &Matcher{
-		[]int{0},
-		[]int{0},
-		[]int{0},
-		[][]SWord{},
+		base:   []int{0},
+		check:  []int{0},
+		fail:   []int{0},
+		output: [][]SWord{},
 	}
 	m.increaseSize(5)
 	if !reflect.DeepEqual(m.base, []int{0, -5, -1, -2, -3, -4}) {
@@ -155,10 +156,10 @@ func TestIncreaseSize(t *testing.T) {
 	}
 
 	m = &Matcher{
-		[]int{-103, -1867},
-		[]int{0, 0},
-		[]int{},
-		[][]SWord{},
+		base:   []int{-103, -1867},
+		check:  []int{0, 0},
+		fail:   []int{},
+		output: [][]SWord{},
 	}
 	m.increaseSize(5)
 	if !reflect.DeepEqual(m.base, []int{-103, -1867, -6, -2, -3, -4, -5}) {
@@ -171,10 +172,10 @@ func TestIncreaseSize(t *testing.T) {
 
 func TestNextFreeState(t *testing.T) {
 	m := &Matcher{
-		[]int{5, 0, 0, -3},
-		[]int{-3, 0, 0, -1},
-		[]int{},
-		[][]SWord{},
+		base:   []int{5, 0, 0, -3},
+		check:  []int{-3, 0, 0, -1},
+		fail:   []int{},
+		output: [][]SWord{},
 	}
 	nextState := m.nextFreeState(3)
 	if nextState != -1 {
@@ -190,10 +191,10 @@ func TestNextFreeState(t *testing.T) {
 
 func TestOccupyState(t *testing.T) {
 	m := &Matcher{
-		[]int{5, 0, 0, -3},
-		[]int{-3, 0, 0, -1},
-		[]int{},
-		[][]SWord{},
+		base:   []int{5, 0, 0, -3},
+		check:  []int{-3, 0, 0, -1},
+		fail:   []int{},
+		output: [][]SWord{},
 	}
 	m.increaseSize(5)
 	m.occupyState(3, 1)
@@ -381,3 +382,77 @@ func BenchmarkRandomGen100kAllFoundReader(b *testing.B) {
 		b.Errorf("Got %d matches instead of 1", Ms.Count())
 	}
 }
+
+// serializedWords returns the words as written by Serialize.
+func serializedWords(words ...uint64) []byte {
+	var b bytes.Buffer
+	for _, w := range words {
+		binary.Write(&b, binary.LittleEndian, w)
+	}
+	return b.Bytes()
+}
+
+// hostileData are serialized matchers whose lengths do not fit their size.
+var hostileData = [][]byte{
+	serializedWords(0, 0, 0, 1<<62),                   // output lengths which do not fit
+	serializedWords(0, 0, 0, 1<<61+1),                 // 8*(4+lenOutput) overflows
+	serializedWords(1<<62, 1<<62, 1<<62, 0),           // the sum of the lengths overflows
+	serializedWords(1, 1, 1, 1, 1<<62, 0, 0, 0),       // an output set which does not fit
+	serializedWords(1, 1, 1, 1, 1, 0, 0, 0, 1<<40, 0), // a pattern longer than the states
+	// a section which does not fit
+	append(CompileStrings([]string{"he"}).Serialize(), serializedWords(2, 1<<60)...),
+}
+
+func TestDeserializeHostile(t *testing.T) {
+	valid := CompileStrings([]string{"he", "she"}).Serialize()
+	for i, data := range hostileData {
+		if _, err := Deserialize(data); err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+	}
+
+	if _, err := DeserializeWithOptions(valid, &DeserializeOptions{MaxSize: int64(len(valid) - 8)}); err != ErrDeserializeLimit {
+		t.Errorf("Expected: %v\nGot:      %v", ErrDeserializeLimit, err)
+	}
+	if _, err := DeserializeWithOptions(valid, &DeserializeOptions{MaxStates: 2}); err != ErrDeserializeLimit {
+		t.Errorf("Expected: %v\nGot:      %v", ErrDeserializeLimit, err)
+	}
+	if _, err := DeserializeWithOptions(valid, &DeserializeOptions{MaxSize: int64(len(valid)), MaxStates: 100}); err != nil {
+		t.Errorf("Expected: no error\nGot:      %v", err)
+	}
+}
+
+// FuzzDeserialize checks that Deserialize never panics, and that a Matcher
+// which passes validation can be searched.
+func FuzzDeserialize(f *testing.F) {
+	for _, m := range []*Matcher{
+		CompileStrings([]string{"he", "she", "his", "hers"}),
+		CompileStrings(nil),
+		CompileStringsAnchored([]string{"a", "ab"}),
+		CompilePatterns([]Pattern{{Word: []byte("x"), Anchor: AnchorStartLine}, {Word: []byte("xy"), Boundary: ASCIIWordBoundary}}),
+	} {
+		data := m.Serialize()
+		f.Add(data)
+		f.Add(data[:len(data)-8])
+	}
+	f.Add(make([]byte, 32))
+	for _, data := range hostileData {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := DeserializeWithOptions(data, &DeserializeOptions{MaxSize: 1 << 20})
+		if err != nil {
+			return
+		}
+		if m.Validate() != nil {
+			return
+		}
+		text := []byte("she sells his hers\nxy ab x")
+		m.FindAllByteSlice(text)
+		m.FindAllByteReader(bytes.NewReader(text), &MatchesKeys{})
+		m.Stats()
+		if _, err := Deserialize(m.Serialize()); err != nil {
+			t.Errorf("reserialized: %v", err)
+		}
+	})
+}
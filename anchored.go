@@ -0,0 +1,123 @@
+package ahocorasick
+
+import "io"
+
+// CompileByteSlicesAnchored compiles a Matcher from a slice of byte slices
+// which only reports the patterns starting at the beginning of the text.
+func CompileByteSlicesAnchored(words [][]byte) *Matcher {
+	m := compile(words)
+	m.anchored = true
+	return m
+}
+
+// CompileStringsAnchored compiles a Matcher from a slice of strings which only
+// reports the patterns starting at the beginning of the text.
+func CompileStringsAnchored(words []string) *Matcher {
+	m := CompileStrings(words)
+	m.anchored = true
+	return m
+}
+
+// findAnchored walks the goto function from the root starting at text[p] and
+// returns every pattern which begins at p, shortest first. Fail links are
+// never followed, so the walk stops at the first byte without a transition.
+// Outputs inherited through fail links are told apart by their length, which
+// only equals the walked depth for patterns starting at p.
+func (m *Matcher) findAnchored(text []byte, p int) []*Match {
+	return m.findAnchoredEnabled(text, p, nil)
+}
+
+// findAnchoredEnabled is findAnchored reporting only the patterns of enabled,
+// or all of them if enabled is nil.
+func (m *Matcher) findAnchoredEnabled(text []byte, p int, enabled *EnabledSet) []*Match {
+	if p < 0 || p > len(text) {
+		return nil
+	}
+	var matches []*Match
+	filtered := m.filtered()
+	state := 0
+	for i := p; i < len(text); i++ {
+		offset := int(text[i])
+		if !m.hasEdge(state, offset) {
+			break
+		}
+		state = m.base[state] + offset
+		depth := i - p + 1
+		for _, item := range m.output[state] {
+			if int(item.Len) != depth || enabled != nil && !enabled.has(item.Key) {
+				continue
+			}
+			if filtered && !m.acceptAt(item.Key, text, p, i+1) {
+				continue
+			}
+			matches = append(matches, &Match{text[p : i+1], p})
+		}
+	}
+	return matches
+}
+
+// findAnchoredReader is the streaming counterpart of findAnchored for p = 0.
+// It stops reading as soon as no pattern can start at the beginning of the
+// stream anymore and no match is waiting for the bytes after it.
+func (m *Matcher) findAnchoredReader(reader io.Reader, matches Matches) {
+	var sf *streamFilter
+	if m.filtered() {
+		sf = m.newStreamFilter()
+	}
+
+	b := make([]byte, 512)
+	state := 0
+	i := 1
+	dead := false
+	for {
+		n, err := reader.Read(b)
+		for _, c := range b[:n] {
+			offset := int(c)
+			if sf != nil {
+				sf.next(c, matches)
+			}
+			if dead || !m.hasEdge(state, offset) {
+				if sf == nil || len(sf.pending) == 0 {
+					return
+				}
+				dead = true
+				continue
+			}
+			state = m.base[state] + offset
+			for _, item := range m.output[state] {
+				if int(item.Len) != i {
+					continue
+				}
+				if sf != nil {
+					sf.match(i, item, matches)
+					continue
+				}
+				matches.Append(i, int(item.Key))
+			}
+			i++
+		}
+		if err != nil {
+			break
+		}
+	}
+	if sf != nil {
+		sf.close(matches)
+	}
+}
+
+// FindAtAnchored finds all patterns which start exactly at position p of the
+// text, shortest first. Unlike FindAllByteSlice it never follows fail links,
+// so its cost is bounded by the length of the longest pattern.
+func (m *Matcher) FindAtAnchored(text []byte, p int) []*Match {
+	return m.findAnchored(text, p)
+}
+
+// FindLongestAtAnchored finds the longest pattern which starts exactly at
+// position p of the text. It returns nil if there is none.
+func (m *Matcher) FindLongestAtAnchored(text []byte, p int) *Match {
+	matches := m.findAnchored(text, p)
+	if len(matches) == 0 {
+		return nil
+	}
+	return matches[len(matches)-1]
+}
@@ -0,0 +1,82 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestFindAtAnchored(t *testing.T) {
+	patterns := []string{"he", "she", "his", "hers", "s"}
+	tests := []struct {
+		text     string
+		p        int
+		expected []Match
+	}{
+		{"ushers", 0, nil},
+		{"ushers", 1, []Match{{[]byte("s"), 1}, {[]byte("she"), 1}}},
+		{"ushers", 2, []Match{{[]byte("he"), 2}, {[]byte("hers"), 2}}},
+		{"ushers", 3, nil},
+		{"ushers", 5, []Match{{[]byte("s"), 5}}},
+		{"ushers", 6, nil},
+		{"ushers", -1, nil},
+		{"ushers", 7, nil},
+	}
+	m := CompileStrings(patterns)
+	for _, test := range tests {
+		got := convert(m.FindAtAnchored([]byte(test.text), test.p))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("FindAtAnchored(%q, %d)\nExpected: %v\nGot:      %v", test.text, test.p, test.expected, got)
+		}
+	}
+
+	longest := m.FindLongestAtAnchored([]byte("ushers"), 2)
+	if longest == nil || string(longest.Word) != "hers" || longest.Index != 2 {
+		t.Errorf("Got: %v\n", longest)
+	}
+	if longest := m.FindLongestAtAnchored([]byte("ushers"), 0); longest != nil {
+		t.Errorf("Got: %v\n", longest)
+	}
+}
+
+func TestAnchoredMatcher(t *testing.T) {
+	m := CompileStringsAnchored([]string{"GET", "GET /", "POST", "ET"})
+
+	got := convert(m.FindAllString("GET /index.html GET"))
+	expected := []Match{{[]byte("GET"), 0}, {[]byte("GET /"), 0}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+	if got := m.FindAllString("xGET /"); len(got) != 0 {
+		t.Errorf("Got %d matches", len(got))
+	}
+
+	Ms := &MatchesKeys{}
+	m.FindAllByteReader(bytes.NewReader([]byte("GET /index.html GET")), Ms)
+	if Ms.Count() != 2 || Ms.matches[0].Index != 3 || Ms.matches[1].Index != 5 {
+		t.Errorf("Got: %v\n", Ms.matches)
+	}
+
+	d, err := Deserialize(m.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.anchored {
+		t.Errorf("anchored flag lost by serialization")
+	}
+	if got := d.FindAllString("xGET /"); len(got) != 0 {
+		t.Errorf("Got %d matches", len(got))
+	}
+}
+
+func TestSerializeUnanchoredUnchanged(t *testing.T) {
+	m := CompileStrings([]string{"he", "she"})
+	b := m.Serialize()
+	a := CompileStringsAnchored([]string{"he", "she"}).Serialize()
+	if len(a) != len(b)+24 {
+		t.Errorf("Got %d bytes, expected %d", len(a), len(b)+24)
+	}
+	if _, err := Deserialize(append(b, make([]byte, 16)...)); err == nil {
+		t.Errorf("expected an error for an unknown section")
+	}
+}
@@ -0,0 +1,14 @@
+package ahocorasick
+
+// Anchor restricts where a pattern is allowed to match. Anchors combine, so
+// AnchorStartLine|AnchorEndLine only matches a pattern spanning a whole line.
+type Anchor uint8
+
+const (
+	AnchorStartText Anchor = 1 << iota // match must start at the beginning of the text
+	AnchorEndText                      // match must end at the end of the text
+	AnchorStartLine                    // match must start at the beginning of the text or after a '\n'
+	AnchorEndLine                      // match must end at the end of the text or before a '\n'
+
+	anchorMask = AnchorStartText | AnchorEndText | AnchorStartLine | AnchorEndLine
+)
@@ -0,0 +1,89 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCompilePatternsAnchors(t *testing.T) {
+	patterns := []Pattern{
+		{Word: []byte("foo"), Anchor: AnchorStartText},
+		{Word: []byte("bar"), Anchor: AnchorEndText},
+		{Word: []byte("baz"), Anchor: AnchorStartLine},
+		{Word: []byte("qux"), Anchor: AnchorEndLine},
+		{Word: []byte("line"), Anchor: AnchorStartLine | AnchorEndLine},
+		{Word: []byte("o")},
+	}
+	tests := []struct {
+		text     string
+		expected []MatchKey
+	}{
+		{"foo bar", []MatchKey{{2, 5}, {3, 5}, {3, 0}, {7, 1}}},
+		{"xfoo barx", []MatchKey{{3, 5}, {4, 5}}},
+		{"baz\nbaz xbaz", []MatchKey{{3, 2}, {7, 2}}},
+		{"qux\nqux qux", []MatchKey{{3, 3}, {11, 3}}},
+		{"quxx\nqux\r\n", []MatchKey{}},
+		{"line\nlines\n line\nline", []MatchKey{{4, 4}, {21, 4}}},
+	}
+
+	m := CompilePatterns(patterns)
+	d, err := Deserialize(m.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, matcher := range []*Matcher{m, d} {
+		for _, test := range tests {
+			var got []MatchKey
+			for _, match := range matcher.FindAllString(test.text) {
+				for key, p := range patterns {
+					if bytes.Equal(p.Word, match.Word) {
+						got = append(got, MatchKey{match.Index + len(match.Word), key})
+					}
+				}
+			}
+			if !(len(got) == 0 && len(test.expected) == 0) && !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("FindAllString(%q)\nExpected: %v\nGot:      %v", test.text, test.expected, got)
+			}
+
+			Ms := &MatchesKeys{}
+			matcher.FindAllByteReader(iotest.OneByteReader(bytes.NewReader([]byte(test.text))), Ms)
+			if !(len(Ms.matches) == 0 && len(test.expected) == 0) && !reflect.DeepEqual(Ms.matches, test.expected) {
+				t.Errorf("FindAllByteReader(%q)\nExpected: %v\nGot:      %v", test.text, test.expected, Ms.matches)
+			}
+		}
+	}
+}
+
+func TestCompilePatternsKeys(t *testing.T) {
+	patterns := []Pattern{{Word: []byte("she")}, {Word: []byte("he")}, {Word: []byte("hers")}}
+	m := CompilePatterns(patterns)
+	if m.anchors != nil {
+		t.Errorf("anchors allocated without anchored patterns")
+	}
+	if string(patterns[0].Word) != "she" {
+		t.Errorf("patterns were reordered")
+	}
+	Ms := &MatchesKeys{}
+	m.FindAllByteReader(bytes.NewReader([]byte("ushers")), Ms)
+	expected := []MatchKey{{4, 1}, {4, 0}, {6, 2}}
+	if !reflect.DeepEqual(Ms.matches, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, Ms.matches)
+	}
+}
+
+func TestAnchoredMatcherWithAnchors(t *testing.T) {
+	m := CompilePatterns([]Pattern{{Word: []byte("GET"), Anchor: AnchorEndLine}, {Word: []byte("GE")}})
+	m.anchored = true
+	got := convert(m.FindAllString("GET\n"))
+	expected := []Match{{[]byte("GE"), 0}, {[]byte("GET"), 0}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+	Ms := &MatchesKeys{}
+	m.FindAllByteReader(bytes.NewReader([]byte("GETS")), Ms)
+	if !reflect.DeepEqual(Ms.matches, []MatchKey{{2, 1}}) {
+		t.Errorf("Got: %v\n", Ms.matches)
+	}
+}
@@ -0,0 +1,264 @@
+package ahocorasick
+
+import "sort"
+
+// MaxApproxDistance is the largest Hamming distance FindAllApprox accepts.
+const MaxApproxDistance = 2
+
+// ApproxMatcher finds patterns in a text allowing for a few substituted,
+// inserted or deleted bytes. Every pattern is split into MaxApproxDistance+1
+// pieces compiled into a Matcher: an occurrence with at most that many errors
+// contains one of its pieces unchanged, so only the surroundings of piece hits
+// have to be verified.
+type ApproxMatcher struct {
+	matcher  *Matcher
+	patterns [][]byte
+	pieces   []approxPiece // pieces by key
+	maxLen   int           // length of the longest pattern
+}
+
+type approxPiece struct {
+	pattern int // index of the pattern
+	offset  int // offset of the piece in the pattern
+	len     int
+}
+
+// ApproxMatch represents a pattern found in the text within some distance.
+type ApproxMatch struct {
+	Word     []byte // the matched bytes of the text
+	Index    int    // the start index of the match
+	Key      int    // the index of the pattern
+	Distance int    // the number of errors between the pattern and Word
+}
+
+// CompileApprox compiles an ApproxMatcher from a slice of byte slices.
+func CompileApprox(words [][]byte) *ApproxMatcher {
+	a := &ApproxMatcher{patterns: words}
+	var pieces [][]byte
+	var keys []uint64
+	for i, word := range words {
+		if len(word) > a.maxLen {
+			a.maxLen = len(word)
+		}
+		n := MaxApproxDistance + 1
+		if len(word) < n {
+			n = len(word)
+		}
+		for j := 0; j < n; j++ {
+			from, to := j*len(word)/n, (j+1)*len(word)/n
+			keys = append(keys, uint64(len(a.pieces)))
+			pieces = append(pieces, word[from:to])
+			a.pieces = append(a.pieces, approxPiece{i, from, to - from})
+		}
+	}
+	a.matcher = compileKeyed(pieces, keys)
+	return a
+}
+
+// CompileApproxStrings compiles an ApproxMatcher from a slice of strings.
+func CompileApproxStrings(words []string) *ApproxMatcher {
+	wordByteSlices := make([][]byte, len(words))
+	for i, word := range words {
+		wordByteSlices[i] = []byte(word)
+	}
+	return CompileApprox(wordByteSlices)
+}
+
+// pieceHits calls hit with the key and start index of every piece in text.
+func (a *ApproxMatcher) pieceHits(text []byte, hit func(key uint64, start int)) {
+	state := 0
+	for i, b := range text {
+		state = a.matcher.advance(state, b)
+		for _, item := range a.matcher.output[state] {
+			hit(item.Key, i+1-int(item.Len))
+		}
+	}
+}
+
+// FindAllApprox finds all places where a pattern occurs in the text with at
+// most k substituted bytes, ordered by index then pattern. Patterns not longer
+// than k would match anywhere and are never reported. k must be between 0 and
+// MaxApproxDistance, otherwise nothing is found.
+func (a *ApproxMatcher) FindAllApprox(text []byte, k int) []*ApproxMatch {
+	if k < 0 || k > MaxApproxDistance {
+		return nil
+	}
+	var matches []*ApproxMatch
+	a.pieceHits(text, func(key uint64, pieceStart int) {
+		piece := a.pieces[key]
+		pattern := a.patterns[piece.pattern]
+		start := pieceStart - piece.offset
+		if len(pattern) <= k || start < 0 || start+len(pattern) > len(text) {
+			return
+		}
+		if a.foundByEarlierPiece(key, text[start:]) {
+			return
+		}
+		if d, ok := hammingWithin(pattern, text[start:start+len(pattern)], k); ok {
+			matches = append(matches, &ApproxMatch{text[start : start+len(pattern)], start, piece.pattern, d})
+		}
+	})
+	sortApproxMatches(matches)
+	return matches
+}
+
+// foundByEarlierPiece reports whether a piece of the same pattern preceding
+// the piece of key also occurs unchanged in text, which holds the pattern
+// aligned at its start. That piece was hit first and already verified the
+// alignment.
+func (a *ApproxMatcher) foundByEarlierPiece(key uint64, text []byte) bool {
+	pattern := a.pieces[key].pattern
+	for j := int(key) - 1; j >= 0 && a.pieces[j].pattern == pattern; j-- {
+		p := a.pieces[j]
+		word := a.patterns[pattern][p.offset : p.offset+p.len]
+		if string(text[p.offset:p.offset+p.len]) == string(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindAllApproxString finds all places where a pattern occurs in the text
+// with at most k substituted bytes.
+func (a *ApproxMatcher) FindAllApproxString(text string, k int) []*ApproxMatch {
+	return a.FindAllApprox([]byte(text), k)
+}
+
+// FindAllLevenshtein finds all places where a pattern occurs in the text with
+// at most one substituted, inserted or deleted byte, ordered by index then
+// pattern. Where overlapping places match the same pattern, only the leftmost
+// of those with the smallest distance is kept, and at a given index the
+// longest, so an exact occurrence is not also reported with a byte more or
+// less. Patterns of a single byte are never reported.
+func (a *ApproxMatcher) FindAllLevenshtein(text []byte) []*ApproxMatch {
+	var candidates []*ApproxMatch
+	a.pieceHits(text, func(key uint64, pieceStart int) {
+		piece := a.pieces[key]
+		pattern := a.patterns[piece.pattern]
+		if len(pattern) <= 1 {
+			return
+		}
+		for shift := -1; shift <= 1; shift++ {
+			start := pieceStart - piece.offset + shift
+			if start < 0 {
+				continue
+			}
+			for l := len(pattern) - 1; l <= len(pattern)+1 && start+l <= len(text); l++ {
+				if d, ok := editWithin1(pattern, text[start:start+l]); ok {
+					candidates = append(candidates, &ApproxMatch{text[start : start+l], start, piece.pattern, d})
+				}
+			}
+		}
+	})
+
+	// Keep the closest, then longest, candidate for each start and pattern.
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if ci.Index != cj.Index {
+			return ci.Index < cj.Index
+		}
+		if ci.Key != cj.Key {
+			return ci.Key < cj.Key
+		}
+		if ci.Distance != cj.Distance {
+			return ci.Distance < cj.Distance
+		}
+		return len(ci.Word) > len(cj.Word)
+	})
+	var matches []*ApproxMatch
+	for i, c := range candidates {
+		if i == 0 || c.Index != candidates[i-1].Index || c.Key != candidates[i-1].Key {
+			matches = append(matches, c)
+		}
+	}
+
+	// Drop matches overlapping a closer match of the same pattern. A match
+	// spans at most maxLen+1 bytes, which bounds how far back to look.
+	var closest []*ApproxMatch
+	for i, m := range matches {
+		closer := false
+		for j := i - 1; j >= 0 && matches[j].Index+a.maxLen+1 > m.Index && !closer; j-- {
+			n := matches[j]
+			closer = n.Key == m.Key && n.Index+len(n.Word) > m.Index && n.Distance < m.Distance
+		}
+		for j := i + 1; j < len(matches) && matches[j].Index < m.Index+len(m.Word) && !closer; j++ {
+			n := matches[j]
+			closer = n.Key == m.Key && n.Distance < m.Distance
+		}
+		if !closer {
+			closest = append(closest, m)
+		}
+	}
+
+	// Of the remaining overlapping matches of a pattern, keep the leftmost.
+	var kept []*ApproxMatch
+	end := make(map[int]int) // end of the last match kept by pattern
+	for _, m := range closest {
+		if e, ok := end[m.Key]; ok && e > m.Index {
+			continue
+		}
+		end[m.Key] = m.Index + len(m.Word)
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+// FindAllLevenshteinString finds all places where a pattern occurs in the
+// text with at most one substituted, inserted or deleted byte.
+func (a *ApproxMatcher) FindAllLevenshteinString(text string) []*ApproxMatch {
+	return a.FindAllLevenshtein([]byte(text))
+}
+
+func sortApproxMatches(matches []*ApproxMatch) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Index != matches[j].Index {
+			return matches[i].Index < matches[j].Index
+		}
+		return matches[i].Key < matches[j].Key
+	})
+}
+
+// hammingWithin returns the number of differing bytes of the equally long a
+// and b, unless it exceeds k.
+func hammingWithin(a, b []byte, k int) (int, bool) {
+	d := 0
+	for i := range a {
+		if a[i] != b[i] {
+			d++
+			if d > k {
+				return d, false
+			}
+		}
+	}
+	return d, true
+}
+
+// editWithin1 returns the Levenshtein distance of a and b, unless it exceeds
+// one.
+func editWithin1(a, b []byte) (int, bool) {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(a)-len(b) > 1 {
+		return 0, false
+	}
+	i := 0
+	for i < len(b) && a[i] == b[i] {
+		i++
+	}
+	if i == len(a) {
+		return 0, true
+	}
+	if len(a) == len(b) {
+		// One substitution at i, the rest must be equal.
+		if string(a[i+1:]) == string(b[i+1:]) {
+			return 1, true
+		}
+		return 0, false
+	}
+	// One deletion from a at i.
+	if string(a[i+1:]) == string(b[i:]) {
+		return 1, true
+	}
+	return 0, false
+}
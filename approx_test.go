@@ -0,0 +1,143 @@
+package ahocorasick
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+type approxResult struct {
+	word     string
+	index    int
+	key      int
+	distance int
+}
+
+func convertApprox(got []*ApproxMatch) []approxResult {
+	var converted []approxResult
+	for _, m := range got {
+		converted = append(converted, approxResult{string(m.Word), m.Index, m.Key, m.Distance})
+	}
+	return converted
+}
+
+func TestFindAllApprox(t *testing.T) {
+	a := CompileApproxStrings([]string{"password", "secret", "ab"})
+	tests := []struct {
+		text     string
+		k        int
+		expected []approxResult
+	}{
+		{"my passw0rd is secret", 0, []approxResult{{"secret", 15, 1, 0}}},
+		{"my passw0rd is secret", 1, []approxResult{{"passw0rd", 3, 0, 1}, {"as", 4, 2, 1}, {"secret", 15, 1, 0}}},
+		{"p4ssw0rd s3cr3t", 1, nil},
+		{"p4ssw0rd s3cr3t", 2, []approxResult{{"p4ssw0rd", 0, 0, 2}, {"s3cr3t", 9, 1, 2}}},
+		{"xb", 1, []approxResult{{"xb", 0, 2, 1}}},
+		{"xy", 2, nil},
+		{"secret", 3, nil},
+	}
+	for _, test := range tests {
+		got := convertApprox(a.FindAllApproxString(test.text, test.k))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q, k=%d\nExpected: %v\nGot:      %v", test.text, test.k, test.expected, got)
+		}
+	}
+}
+
+func TestFindAllApproxAgainstBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomBytes := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = "abcd"[r.Intn(4)]
+		}
+		return b
+	}
+	var words [][]byte
+	for i := 0; i < 50; i++ {
+		words = append(words, randomBytes(3+r.Intn(6)))
+	}
+	text := randomBytes(2000)
+	a := CompileApprox(words)
+
+	for k := 0; k <= MaxApproxDistance; k++ {
+		var expected []approxResult
+		for start := range text {
+			for key, word := range words {
+				if len(word) <= k || start+len(word) > len(text) {
+					continue
+				}
+				if d, ok := hammingWithin(word, text[start:start+len(word)], k); ok {
+					expected = append(expected, approxResult{string(text[start : start+len(word)]), start, key, d})
+				}
+			}
+		}
+		got := convertApprox(a.FindAllApprox(text, k))
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("k=%d: got %d matches, expected %d", k, len(got), len(expected))
+		}
+	}
+}
+
+func TestFindAllLevenshtein(t *testing.T) {
+	a := CompileApproxStrings([]string{"resume", "color"})
+	tests := []struct {
+		text     string
+		expected []approxResult
+	}{
+		{"resume", []approxResult{{"resume", 0, 0, 0}}},
+		{"my resme", []approxResult{{"resme", 3, 0, 1}}},
+		{"a colour", []approxResult{{"colour", 2, 1, 1}}},
+		{"xresumex", []approxResult{{"resume", 1, 0, 0}}},
+		{"rsum", nil},
+		{"kolor", []approxResult{{"kolor", 0, 1, 1}}},
+	}
+	for _, test := range tests {
+		got := convertApprox(a.FindAllLevenshteinString(test.text))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q\nExpected: %v\nGot:      %v", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestEditWithin1(t *testing.T) {
+	tests := []struct {
+		a, b string
+		d    int
+		ok   bool
+	}{
+		{"abc", "abc", 0, true},
+		{"abc", "abd", 1, true},
+		{"abc", "ab", 1, true},
+		{"abc", "bc", 1, true},
+		{"abc", "axbc", 1, true},
+		{"abc", "acb", 0, false},
+		{"abc", "a", 0, false},
+	}
+	for _, test := range tests {
+		d, ok := editWithin1([]byte(test.a), []byte(test.b))
+		if d != test.d || ok != test.ok {
+			t.Errorf("%q %q: got %d %v", test.a, test.b, d, ok)
+		}
+	}
+}
+
+func BenchmarkFindAllApprox20k(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	words := make([][]byte, 20000)
+	for i := range words {
+		words[i] = make([]byte, 6+r.Intn(10))
+		for j := range words[i] {
+			words[i][j] = byte('a' + r.Intn(26))
+		}
+	}
+	text := make([]byte, 100000)
+	for i := range text {
+		text[i] = byte('a' + r.Intn(26))
+	}
+	a := CompileApprox(words)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.FindAllApprox(text, 2)
+	}
+}
@@ -0,0 +1,235 @@
+package ahocorasick
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxArchiveDepth is the depth of nested archives opened when
+// ArchiveOptions.MaxDepth is zero.
+const DefaultMaxArchiveDepth = 4
+
+// DefaultMaxZipSize is the largest size of a zip archive, which is read in
+// memory, when ArchiveOptions.MaxMemberSize is zero.
+const DefaultMaxZipSize = 1 << 30
+
+// ArchiveOptions configure FindAllArchive.
+type ArchiveOptions struct {
+	// MaxDepth is the number of archives opened inside one another, the
+	// outermost one included. Deeper archives are scanned as plain data. If
+	// zero, DefaultMaxArchiveDepth is used.
+	MaxDepth int
+	// MaxMemberSize is the largest size of a member, once decompressed, if
+	// not zero. Larger members are skipped with an error. Zip archives are
+	// read in memory, and are also bound by it, or by DefaultMaxZipSize if
+	// it is zero.
+	MaxMemberSize int64
+	// Decompress configures the decompression of the archives and members,
+	// which may be compressed by any of its formats.
+	Decompress DecompressOptions
+}
+
+// ErrMemberTooLarge is the error of members larger than MaxMemberSize.
+var ErrMemberTooLarge = errors.New("ahocorasick: archive member too large")
+
+// ArchiveMatch represents a pattern found in a member of an archive.
+type ArchiveMatch struct {
+	Path  string // the path of the member, nested archives separated by "/"
+	Index int    // the start index of the match in the decompressed member
+	Key   int    // the key of the pattern
+}
+
+// MemberError is the error which stopped the scan of a member.
+type MemberError struct {
+	Path string
+	Err  error
+}
+
+func (e *MemberError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *MemberError) Unwrap() error {
+	return e.Err
+}
+
+// ArchiveResult holds what FindAllArchive found.
+type ArchiveResult struct {
+	Matches []ArchiveMatch
+	Errors  []*MemberError // members which could not be scanned in full
+}
+
+// FindAllArchive finds all instances of the patterns in the members of a tar
+// or zip archive, possibly compressed, and of the archives nested in it. Data
+// which is no archive is scanned as a single member with an empty path. A
+// member which cannot be read is recorded in the Errors of the result and
+// the scan goes on with the next one; the error returned is the one which
+// stopped reading the outermost archive, along with what was found until
+// then.
+func (m *Matcher) FindAllArchive(r io.Reader, opts *ArchiveOptions) (*ArchiveResult, error) {
+	return m.findAllArchive(r, opts, DefaultMaxZipSize)
+}
+
+// findAllArchive is FindAllArchive reading zip archives of at most maxZip
+// bytes when MaxMemberSize is zero.
+func (m *Matcher) findAllArchive(r io.Reader, opts *ArchiveOptions, maxZip int64) (*ArchiveResult, error) {
+	s := &archiveScanner{m: m, result: &ArchiveResult{}, lens: make(map[int]int), maxZip: maxZip}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.MaxDepth == 0 {
+		s.opts.MaxDepth = DefaultMaxArchiveDepth
+	}
+	if s.opts.MaxMemberSize > 0 {
+		s.maxZip = s.opts.MaxMemberSize
+	}
+	for _, out := range m.output {
+		for _, item := range out {
+			s.lens[int(item.Key)] = int(item.Len)
+		}
+	}
+	err := s.scan("", r, 0)
+	return s.result, err
+}
+
+type archiveScanner struct {
+	m      *Matcher
+	opts   ArchiveOptions
+	lens   map[int]int // pattern lengths by key
+	maxZip int64       // the largest size of a zip archive
+	result *ArchiveResult
+}
+
+// scan scans the data of the member at path, which is inside depth archives.
+func (s *archiveScanner) scan(path string, r io.Reader, depth int) error {
+	data, _, err := Decompress(r, &s.opts.Decompress)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewReaderSize(data, 1024)
+	header, err := buffered.Peek(512)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if depth < s.opts.MaxDepth {
+		switch {
+		case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+			return s.scanZip(path, buffered, depth+1)
+		case len(header) >= 262 && string(header[257:262]) == "ustar":
+			return s.scanTar(path, buffered, depth+1)
+		}
+	}
+
+	er := &errReader{r: &memberReader{r: buffered, max: s.opts.MaxMemberSize}}
+	s.m.FindAllByteReader(er, &archiveMatches{s, path})
+	if er.err != io.EOF {
+		return er.err
+	}
+	return nil
+}
+
+func (s *archiveScanner) scanTar(path string, r io.Reader, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		s.member(memberPath(path, hdr.Name), hdr.Size, tr, depth)
+	}
+}
+
+func (s *archiveScanner) scanZip(path string, r io.Reader, depth int) error {
+	data, err := io.ReadAll(&memberReader{r: r, max: s.maxZip})
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		p := memberPath(path, f.Name)
+		rc, err := f.Open()
+		if err != nil {
+			s.fail(p, err)
+			continue
+		}
+		s.member(p, int64(f.UncompressedSize64), rc, depth)
+		rc.Close()
+	}
+	return nil
+}
+
+// member scans a member of an archive, recording why it failed if it did.
+func (s *archiveScanner) member(path string, size int64, r io.Reader, depth int) {
+	if s.opts.MaxMemberSize > 0 && size > s.opts.MaxMemberSize {
+		s.fail(path, ErrMemberTooLarge)
+		return
+	}
+	if err := s.scan(path, r, depth); err != nil {
+		s.fail(path, err)
+	}
+}
+
+func (s *archiveScanner) fail(path string, err error) {
+	s.result.Errors = append(s.result.Errors, &MemberError{path, err})
+}
+
+func memberPath(archive, name string) string {
+	if archive == "" {
+		return name
+	}
+	return archive + "/" + name
+}
+
+// memberReader fails with ErrMemberTooLarge once more than max bytes, if
+// not zero, are read from r.
+type memberReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (m *memberReader) Read(p []byte) (int, error) {
+	if m.max > 0 && m.n > m.max {
+		return 0, ErrMemberTooLarge
+	}
+	if m.max > 0 && int64(len(p)) > m.max-m.n+1 {
+		p = p[:m.max-m.n+1]
+	}
+	n, err := m.r.Read(p)
+	m.n += int64(n)
+	if m.max > 0 && m.n > m.max {
+		return n - int(m.n-m.max), ErrMemberTooLarge
+	}
+	return n, err
+}
+
+// archiveMatches collects the matches in the member at path.
+type archiveMatches struct {
+	s    *archiveScanner
+	path string
+}
+
+func (a *archiveMatches) Append(end int, key int) {
+	a.s.result.Matches = append(a.s.result.Matches, ArchiveMatch{a.path, end - a.s.lens[key], key})
+}
+
+func (a *archiveMatches) Count() int {
+	return len(a.s.result.Matches)
+}
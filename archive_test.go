@@ -0,0 +1,148 @@
+package ahocorasick
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+func tarArchive(t *testing.T, files ...archiveFile) []byte {
+	var b bytes.Buffer
+	w := tar.NewWriter(&b)
+	for _, f := range files {
+		if err := w.WriteHeader(&tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func zipArchive(t *testing.T, files ...archiveFile) []byte {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, f := range files {
+		fw, err := w.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestFindAllArchive(t *testing.T) {
+	m := CompilePatterns([]Pattern{{Word: []byte("secret")}, {Word: []byte("key")}})
+	deep := compressed(t, "gzip", tarArchive(t, archiveFile{"c.txt", []byte("a key")}))
+	archive := compressed(t, "gzip", tarArchive(t,
+		archiveFile{"a.txt", []byte("my secret key")},
+		archiveFile{"nested.zip", zipArchive(t,
+			archiveFile{"b.txt", []byte("no secrets")},
+			archiveFile{"deep.tar.gz", deep},
+		)},
+		archiveFile{"big.txt", []byte(strings.Repeat("key ", 1000))},
+		archiveFile{"truncated.gz", compressed(t, "gzip", []byte("secret"))[:20]},
+	))
+
+	tests := []struct {
+		opts    ArchiveOptions
+		matches []ArchiveMatch
+		errors  []string
+	}{
+		{
+			ArchiveOptions{MaxMemberSize: 2000},
+			[]ArchiveMatch{
+				{"a.txt", 3, 0}, {"a.txt", 10, 1},
+				{"nested.zip/b.txt", 3, 0},
+				{"nested.zip/deep.tar.gz/c.txt", 2, 1},
+			},
+			[]string{"big.txt", "truncated.gz"},
+		},
+		{
+			// deep.tar.gz is only decompressed and scanned as data.
+			ArchiveOptions{MaxDepth: 2, MaxMemberSize: 2000},
+			[]ArchiveMatch{
+				{"a.txt", 3, 0}, {"a.txt", 10, 1},
+				{"nested.zip/b.txt", 3, 0},
+				{"nested.zip/deep.tar.gz", 512 + 2, 1},
+			},
+			// The tar, padded to 10240 bytes, is larger than a member can be.
+			[]string{"nested.zip/deep.tar.gz", "big.txt", "truncated.gz"},
+		},
+	}
+	for _, test := range tests {
+		result, err := m.FindAllArchive(bytes.NewReader(archive), &test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(result.Matches, test.matches) {
+			t.Errorf("%+v\nExpected: %v\nGot:      %v", test.opts, test.matches, result.Matches)
+		}
+		var paths []string
+		for _, e := range result.Errors {
+			paths = append(paths, e.Path)
+		}
+		if !reflect.DeepEqual(paths, test.errors) {
+			t.Errorf("%+v\nExpected errors in: %v\nGot:                %v", test.opts, test.errors, result.Errors)
+		}
+		if len(result.Errors) > 0 && !errors.Is(result.Errors[0], ErrMemberTooLarge) {
+			t.Errorf("Got %v", result.Errors[0])
+		}
+	}
+}
+
+func TestFindAllArchiveZipSize(t *testing.T) {
+	m := CompilePatterns([]Pattern{{Word: []byte("key")}})
+	inner := zipArchive(t, archiveFile{"a.txt", []byte(strings.Repeat("a key ", 1000))})
+	outer := tarArchive(t, archiveFile{"inner.zip", inner}, archiveFile{"b.txt", []byte("b key")})
+
+	// Without MaxMemberSize, the zip archive is bound by the default.
+	result, err := m.findAllArchive(bytes.NewReader(outer), nil, int64(len(inner)-1))
+	expected := []ArchiveMatch{{"b.txt", 2, 0}}
+	if err != nil || !reflect.DeepEqual(result.Matches, expected) {
+		t.Errorf("Expected: %v\nGot:      %v, %v", expected, result.Matches, err)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Path != "inner.zip" || !errors.Is(result.Errors[0], ErrMemberTooLarge) {
+		t.Errorf("Expected: inner.zip too large\nGot:      %v", result.Errors)
+	}
+
+	result, err = m.findAllArchive(bytes.NewReader(outer), nil, int64(len(inner)))
+	if err != nil || len(result.Matches) != 1001 || len(result.Errors) != 0 {
+		t.Errorf("Expected: 1001 matches\nGot:      %d, %v, %v", len(result.Matches), result.Errors, err)
+	}
+}
+
+func TestFindAllArchivePlainData(t *testing.T) {
+	m := CompilePatterns([]Pattern{{Word: []byte("key")}})
+	result, err := m.FindAllArchive(strings.NewReader("just a key"), nil)
+	expected := []ArchiveMatch{{"", 7, 0}}
+	if err != nil || !reflect.DeepEqual(result.Matches, expected) || len(result.Errors) != 0 {
+		t.Errorf("Got %v, %v, %v", result.Matches, result.Errors, err)
+	}
+
+	// A member which starts like a zlib stream.
+	result, err = m.FindAllArchive(bytes.NewReader(tarArchive(t, archiveFile{"a.txt", []byte("x^ key")})), nil)
+	expected = []ArchiveMatch{{"a.txt", 3, 0}}
+	if err != nil || !reflect.DeepEqual(result.Matches, expected) || len(result.Errors) != 0 {
+		t.Errorf("Got %v, %v, %v", result.Matches, result.Errors, err)
+	}
+}
@@ -0,0 +1,280 @@
+package ahocorasick
+
+// Base64Alphabet is a set of base64 alphabets.
+type Base64Alphabet uint8
+
+const (
+	Base64Std Base64Alphabet = 1 << iota // the standard alphabet of RFC 4648, with + and /
+	Base64URL                            // the URL and filename safe alphabet, with - and _
+)
+
+const (
+	base64StdChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	base64URLChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+)
+
+// Base64Matcher finds patterns encoded in base64 inside text, without
+// decoding it. Depending on its offset in the encoded data, a pattern is
+// encoded in one of three ways, one for each of its possible positions in a
+// group of three bytes. The characters which only depend on the pattern in
+// these three encodings are compiled into a Matcher, and the characters at
+// their edges, which also depend on the neighbouring bytes, are decoded to
+// verify every hit. A single byte in the middle of a group has no character
+// of its own: every pair of characters which may hold it is compiled instead.
+//
+// Data is decoded from the start of each run of base64 characters, which
+// follows any other character or the = padding, so a pattern is only found
+// where it is in the data decoded from there.
+type Base64Matcher struct {
+	matcher  *Matcher
+	patterns [][]byte
+	variants []base64Variant // variants by key
+}
+
+// base64Variant is a pattern encoded at some offset in a group of three
+// bytes.
+type base64Variant struct {
+	pattern   int
+	shift     int // offset of the pattern in its first group
+	alphabets Base64Alphabet
+	offset    int // offset of the compiled characters in the encoding of the group
+}
+
+// Base64Match represents a pattern found encoded in base64.
+type Base64Match struct {
+	Word     []byte         // the base64 characters holding bits of the pattern
+	Index    int            // the start index of Word in the text
+	Key      int            // the index of the pattern
+	Alphabet Base64Alphabet // the alphabets Word is written in
+}
+
+// CompileBase64 compiles a Base64Matcher finding the words in base64 written
+// in any of the alphabets. If alphabets is zero, the standard alphabet is
+// used.
+func CompileBase64(words [][]byte, alphabets Base64Alphabet) *Base64Matcher {
+	if alphabets&(Base64Std|Base64URL) == 0 {
+		alphabets = Base64Std
+	}
+	b := &Base64Matcher{patterns: words}
+	var atoms [][]byte
+	var keys []uint64
+	for i, word := range words {
+		for shift := 0; shift < 3; shift++ {
+			// The alphabets each atom is written in: an atom written
+			// in both alphabets is a single variant, so that it is
+			// reported once.
+			var order []string
+			in := make(map[string]Base64Alphabet)
+			offset := 0
+			for _, alphabet := range []Base64Alphabet{Base64Std, Base64URL} {
+				if alphabets&alphabet == 0 {
+					continue
+				}
+				var atomsOf []string
+				atomsOf, offset = base64Atoms(word, shift, alphabet)
+				for _, atom := range atomsOf {
+					if in[atom] == 0 {
+						order = append(order, atom)
+					}
+					in[atom] |= alphabet
+				}
+			}
+			variantOf := make(map[Base64Alphabet]int)
+			for _, atom := range order {
+				key, ok := variantOf[in[atom]]
+				if !ok {
+					key = len(b.variants)
+					variantOf[in[atom]] = key
+					b.variants = append(b.variants, base64Variant{i, shift, in[atom], offset})
+				}
+				keys = append(keys, uint64(key))
+				atoms = append(atoms, []byte(atom))
+			}
+		}
+	}
+	b.matcher = compileKeyed(atoms, keys)
+	return b
+}
+
+// CompileBase64Strings compiles a Base64Matcher from a slice of strings.
+func CompileBase64Strings(words []string, alphabets Base64Alphabet) *Base64Matcher {
+	wordByteSlices := make([][]byte, len(words))
+	for i, word := range words {
+		wordByteSlices[i] = []byte(word)
+	}
+	return CompileBase64(wordByteSlices, alphabets)
+}
+
+// base64Atom returns the base64 characters of word encoded shift bytes after
+// the start of a group which only depend on word.
+func base64Atom(word []byte, shift int, alphabet Base64Alphabet) []byte {
+	chars := base64StdChars
+	if alphabet == Base64URL {
+		chars = base64URLChars
+	}
+	// Character i of the encoding holds bits 6i to 6i+5 of the data.
+	from := (8*shift + 5) / 6
+	to := 8 * (shift + len(word)) / 6
+	var atom []byte
+	for i := from; i < to; i++ {
+		atom = append(atom, chars[base64Bits(word, 6*i-8*shift)])
+	}
+	return atom
+}
+
+// base64Atoms returns the characters of word encoded shift bytes after the
+// start of a group to compile, and their offset in the encoding of the group.
+// They are the atom of word if it has one. Otherwise word is a single byte
+// between the two characters holding it, which also hold two bits of the
+// bytes around it: the 16 pairs of characters they may be are returned.
+func base64Atoms(word []byte, shift int, alphabet Base64Alphabet) ([]string, int) {
+	if len(word) == 0 {
+		return nil, 0
+	}
+	if atom := base64Atom(word, shift, alphabet); len(atom) > 0 {
+		return []string{string(atom)}, (8*shift + 5) / 6
+	}
+	chars := base64StdChars
+	if alphabet == Base64URL {
+		chars = base64URLChars
+	}
+	from := 8 * shift / 6
+	to := (8*(shift+len(word)) + 5) / 6
+	atoms := []string{""}
+	for i := from; i < to; i++ {
+		// The bits of the character from word, and the mask of them.
+		var known, mask byte
+		for j := 0; j < 6; j++ {
+			bit := 6*i + j - 8*shift
+			if bit >= 0 && bit < 8*len(word) {
+				mask |= 0x20 >> j
+				if word[bit/8]&(0x80>>(bit%8)) != 0 {
+					known |= 0x20 >> j
+				}
+			}
+		}
+		var longer []string
+		for _, atom := range atoms {
+			for v := 0; v < 64; v++ {
+				if byte(v)&mask == known {
+					longer = append(longer, atom+string(chars[v]))
+				}
+			}
+		}
+		atoms = longer
+	}
+	return atoms, from
+}
+
+// base64Bits returns the 6 bits of data starting at bit offset bit, which may
+// be negative or reach past data, where bits are zero.
+func base64Bits(data []byte, bit int) byte {
+	var v byte
+	for i := bit; i < bit+6; i++ {
+		v <<= 1
+		if i >= 0 && i/8 < len(data) && data[i/8]&(0x80>>(i%8)) != 0 {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// base64Value returns the value of the character c in one of the alphabets.
+func base64Value(c byte, alphabets Base64Alphabet) (byte, bool) {
+	switch {
+	case c >= 'A' && c <= 'Z':
+		return c - 'A', true
+	case c >= 'a' && c <= 'z':
+		return c - 'a' + 26, true
+	case c >= '0' && c <= '9':
+		return c - '0' + 52, true
+	case c == '+' && alphabets&Base64Std != 0, c == '-' && alphabets&Base64URL != 0:
+		return 62, true
+	case c == '/' && alphabets&Base64Std != 0, c == '_' && alphabets&Base64URL != 0:
+		return 63, true
+	}
+	return 0, false
+}
+
+// verify decodes the characters around the hit of the atom of v starting at
+// atomStart, in the run of base64 characters starting at run, and returns the
+// span of the characters holding the pattern if it is there.
+func (b *Base64Matcher) verify(text []byte, atomStart, run int, v base64Variant) (start, end int, ok bool) {
+	pattern := b.patterns[v.pattern]
+	group := atomStart - v.offset // where the group of the first byte starts
+	start = group + 8*v.shift/6
+	end = group + (8*(v.shift+len(pattern))+5)/6
+	// Groups of 4 characters follow one another from the start of the run.
+	if group < run || (group-run)%4 != 0 || end > len(text) {
+		return 0, 0, false
+	}
+	// Decode the characters into the bits they hold, from bit 6*start.
+	decoded := make([]byte, (6*(end-start)+7)/8)
+	for i := start; i < end; i++ {
+		value, ok := base64Value(text[i], v.alphabets)
+		if !ok {
+			return 0, 0, false
+		}
+		for j := 0; j < 6; j++ {
+			if value&(0x20>>j) != 0 {
+				bit := 6*(i-start) + j
+				decoded[bit/8] |= 0x80 >> (bit % 8)
+			}
+		}
+	}
+	// The pattern starts at bit 8*shift of the group.
+	skip := 8*v.shift - 6*(start-group)
+	for i, c := range pattern {
+		var got byte
+		for j := 0; j < 8; j++ {
+			bit := skip + 8*i + j
+			got <<= 1
+			if decoded[bit/8]&(0x80>>(bit%8)) != 0 {
+				got |= 1
+			}
+		}
+		if got != c {
+			return 0, 0, false
+		}
+	}
+	return start, end, true
+}
+
+// FindAllByteSlice finds all patterns encoded in base64 in the text. The same
+// characters may hold a pattern at several offsets in a group, such as "AA"
+// holding a zero byte at offsets 0 and 2: they are reported once.
+func (b *Base64Matcher) FindAllByteSlice(text []byte) []*Base64Match {
+	var matches []*Base64Match
+	found := make(map[[3]int]*Base64Match)
+	// The start of the current run of characters of each set of alphabets.
+	var runs [Base64Std | Base64URL + 1]int
+	state := 0
+	for i, c := range text {
+		for a := Base64Std; a <= Base64Std|Base64URL; a++ {
+			if _, ok := base64Value(c, a); !ok {
+				runs[a] = i + 1
+			}
+		}
+		state = b.matcher.advance(state, c)
+		for _, item := range b.matcher.output[state] {
+			v := b.variants[item.Key]
+			start, end, ok := b.verify(text, i+1-int(item.Len), runs[v.alphabets], v)
+			if !ok {
+				continue
+			}
+			if match := found[[3]int{start, end, v.pattern}]; match != nil {
+				match.Alphabet |= v.alphabets
+				continue
+			}
+			match := &Base64Match{text[start:end], start, v.pattern, v.alphabets}
+			found[[3]int{start, end, v.pattern}] = match
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// FindAllString finds all patterns encoded in base64 in the text.
+func (b *Base64Matcher) FindAllString(text string) []*Base64Match {
+	return b.FindAllByteSlice([]byte(text))
+}
@@ -0,0 +1,135 @@
+package ahocorasick
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+type base64Result struct {
+	word     string
+	index    int
+	key      int
+	alphabet Base64Alphabet
+}
+
+func convertBase64(got []*Base64Match) []base64Result {
+	var converted []base64Result
+	for _, m := range got {
+		converted = append(converted, base64Result{string(m.Word), m.Index, m.Key, m.Alphabet})
+	}
+	return converted
+}
+
+func TestFindAllBase64(t *testing.T) {
+	words := []string{"secret", "\xfb\xff"}
+	b := CompileBase64Strings(words, Base64Std|Base64URL)
+	encodings := map[Base64Alphabet]*base64.Encoding{
+		Base64Std: base64.StdEncoding,
+		Base64URL: base64.URLEncoding,
+	}
+	for alphabet, enc := range encodings {
+		for key, word := range words {
+			for prefix := 0; prefix < 6; prefix++ {
+				data := append([]byte("xxxxxx"[:prefix]), word...)
+				data = append(data, "yy"...)
+				encoded := enc.EncodeToString(data)
+				text := "token=" + encoded + ";"
+
+				start := len("token=") + 8*prefix/6
+				end := len("token=") + (8*(prefix+len(word))+5)/6
+				got := convertBase64(b.FindAllString(text))
+				if len(got) != 1 || got[0].index != start || got[0].word != text[start:end] || got[0].key != key || got[0].alphabet&alphabet == 0 {
+					t.Errorf("%q at %d in %q: got %v", word, prefix, text, got)
+				}
+			}
+		}
+	}
+}
+
+func TestFindAllBase64Short(t *testing.T) {
+	encodings := map[Base64Alphabet]*base64.Encoding{
+		Base64Std: base64.StdEncoding,
+		Base64URL: base64.URLEncoding,
+	}
+	// A byte after the first of a group has no character of its own.
+	for _, word := range []string{"Z", "\xff", "\x00", "ab", "\xfb\xff"} {
+		b := CompileBase64Strings([]string{word}, Base64Std|Base64URL)
+		for alphabet, enc := range encodings {
+			for prefix := 0; prefix < 3; prefix++ {
+				for _, suffix := range []string{"", "yy"} {
+					data := "xx"[:prefix] + word + suffix
+					text := enc.EncodeToString([]byte(data))
+
+					start := 8 * prefix / 6
+					end := (8*(prefix+len(word)) + 5) / 6
+					got := convertBase64(b.FindAllString(text))
+					if len(got) != 1 || got[0].index != start || got[0].word != text[start:end] || got[0].alphabet&alphabet == 0 {
+						t.Errorf("%q at %d in %q: got %v", word, prefix, text, got)
+					}
+				}
+			}
+		}
+	}
+	if got := CompileBase64Strings([]string{"Z"}, Base64Std).FindAllString("eFo="); len(got) != 1 {
+		t.Errorf("Expected: Z in eFo=\nGot:      %v", convertBase64(got))
+	}
+}
+
+func TestFindAllBase64Alignment(t *testing.T) {
+	// base64("secret") is "c2VjcmV0", which is only the encoding of
+	// "secret" where a group of 4 characters starts.
+	b := CompileBase64Strings([]string{"secret"}, Base64Std|Base64URL)
+	tests := []struct {
+		text  string
+		index int // -1 if not found
+	}{
+		{"c2VjcmV0", 0},
+		{"Ac2VjcmV0", -1},
+		{"AAc2VjcmV0", -1},
+		{"QUJDc2VjcmV0", 4},
+		{"A c2VjcmV0", 2},
+		{"QQ==c2VjcmV0", 4},
+		{"A+c2VjcmV0", -1},
+		{"AA-_c2VjcmV0", 4},
+	}
+	for _, test := range tests {
+		got := convertBase64(b.FindAllString(test.text))
+		if test.index < 0 && len(got) != 0 || test.index >= 0 && (len(got) != 1 || got[0].index != test.index) {
+			t.Errorf("%q\nExpected: %d\nGot:      %v", test.text, test.index, got)
+		}
+	}
+}
+
+func TestFindAllBase64Verify(t *testing.T) {
+	b := CompileBase64Strings([]string{"secre"}, Base64Std)
+	tests := []struct {
+		text     string
+		expected []base64Result
+	}{
+		// base64("secre") is "c2VjcmU=": the "U" also holds two bits of the
+		// next byte, so "X" would do as well, but not "Y".
+		{"c2VjcmU=", []base64Result{{"c2VjcmU", 0, 0, Base64Std}}},
+		{"c2VjcmX", []base64Result{{"c2VjcmX", 0, 0, Base64Std}}},
+		{"c2VjcmY", nil},
+		{"c2Vjcm", nil},
+		{"secre", nil},
+	}
+	for _, test := range tests {
+		got := convertBase64(b.FindAllString(test.text))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q\nExpected: %v\nGot:      %v", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestBase64Alphabets(t *testing.T) {
+	// "\xfb\xff" encodes to "+/8" or "-_8".
+	b := CompileBase64Strings([]string{"\xfb\xff"}, 0)
+	if got := b.FindAllString("-_8="); got != nil {
+		t.Errorf("URL alphabet found with the standard one: %v", convertBase64(got))
+	}
+	if got := convertBase64(b.FindAllString("+/8=")); len(got) != 1 || got[0].alphabet != Base64Std {
+		t.Errorf("Got %v", got)
+	}
+}
@@ -0,0 +1,96 @@
+package ahocorasick
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Boundary selects what has to surround a match for it to be reported. A
+// match is delimited when there is a boundary both between the rune before it
+// and its first rune, and between its last rune and the rune after it.
+type Boundary uint8
+
+const (
+	NoBoundary          Boundary = iota // matches are reported wherever they occur
+	ASCIIWordBoundary                   // like \b of package regexp: word runes are [0-9A-Za-z_]
+	UnicodeWordBoundary                 // like ASCIIWordBoundary, with Unicode letters, digits and marks as word runes
+	CustomBoundary                      // decided by the function given to SetBoundaryFunc
+
+	boundaryCount
+)
+
+// NoRune is passed to a boundary function in place of the rune before the
+// start or after the end of the text.
+const NoRune rune = -1
+
+// SetBoundary sets the boundary of every pattern which was compiled without
+// one. It must not be called while the Matcher is in use.
+func (m *Matcher) SetBoundary(b Boundary) {
+	if b >= boundaryCount {
+		b = NoBoundary
+	}
+	m.boundary = b
+}
+
+// SetBoundaryFunc sets the function deciding whether there is a boundary
+// between prev and next for patterns using CustomBoundary. Functions are not
+// serialized, so it has to be set again on a deserialized Matcher; until then
+// patterns using CustomBoundary never match. It must not be called while the
+// Matcher is in use.
+func (m *Matcher) SetBoundaryFunc(f func(prev, next rune) bool) {
+	m.boundaryFunc = f
+}
+
+// boundaryOf returns the boundary applying to the pattern of key.
+func (m *Matcher) boundaryOf(key uint64) Boundary {
+	if m.boundaries != nil && m.boundaries[key] != NoBoundary {
+		return m.boundaries[key]
+	}
+	return m.boundary
+}
+
+// delimited reports whether word, found between before and after, is
+// delimited on both sides by the boundary b.
+func (m *Matcher) delimited(b Boundary, before, word, after []byte) bool {
+	var isBoundary func(prev, next rune) bool
+	switch b {
+	case ASCIIWordBoundary:
+		isBoundary = asciiWordBoundary
+	case UnicodeWordBoundary:
+		isBoundary = unicodeWordBoundary
+	case CustomBoundary:
+		isBoundary = m.boundaryFunc
+		if isBoundary == nil {
+			return false
+		}
+	default:
+		return true
+	}
+
+	prev, next := NoRune, NoRune
+	if len(before) > 0 {
+		prev, _ = utf8.DecodeLastRune(before)
+	}
+	if len(after) > 0 {
+		next, _ = utf8.DecodeRune(after)
+	}
+	first, _ := utf8.DecodeRune(word)
+	last, _ := utf8.DecodeLastRune(word)
+	return isBoundary(prev, first) && isBoundary(last, next)
+}
+
+func isASCIIWord(r rune) bool {
+	return r == '_' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
+
+func isUnicodeWord(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+func asciiWordBoundary(prev, next rune) bool {
+	return isASCIIWord(prev) != isASCIIWord(next)
+}
+
+func unicodeWordBoundary(prev, next rune) bool {
+	return isUnicodeWord(prev) != isUnicodeWord(next)
+}
@@ -0,0 +1,116 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+// findEnds returns the end positions and keys reported by both search paths,
+// failing the test if they disagree.
+func findEnds(t *testing.T, m *Matcher, text string) []MatchKey {
+	t.Helper()
+	Ms := &MatchesKeys{}
+	m.FindAllByteReader(iotest.OneByteReader(bytes.NewReader([]byte(text))), Ms)
+
+	var ends []int
+	for _, match := range m.FindAllString(text) {
+		ends = append(ends, match.Index+len(match.Word))
+	}
+	var readerEnds []int
+	for _, match := range Ms.matches {
+		readerEnds = append(readerEnds, match.Index)
+	}
+	if !reflect.DeepEqual(ends, readerEnds) {
+		t.Errorf("FindAllString(%q) ends %v, FindAllByteReader ends %v", text, ends, readerEnds)
+	}
+	return Ms.matches
+}
+
+func TestASCIIWordBoundary(t *testing.T) {
+	m := CompileStrings([]string{"cat", "-cat"})
+	m.SetBoundary(ASCIIWordBoundary)
+	tests := []struct {
+		text     string
+		expected []MatchKey
+	}{
+		{"concatenate", nil},
+		{"cat", []MatchKey{{3, 1}}},
+		{"a cat, cats, cat_, (cat)", []MatchKey{{5, 1}, {23, 1}}},
+		{"x-cat", []MatchKey{{5, 1}, {5, 0}}},
+		{"kočcat", []MatchKey{{7, 1}}},
+	}
+	for _, test := range tests {
+		got := findEnds(t, m, test.text)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q\nExpected: %v\nGot:      %v", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestUnicodeWordBoundary(t *testing.T) {
+	m := CompilePatterns([]Pattern{
+		{Word: []byte("кот"), Boundary: UnicodeWordBoundary},
+		{Word: []byte("cat"), Boundary: ASCIIWordBoundary},
+		{Word: []byte("dog")},
+	})
+	tests := []struct {
+		text     string
+		expected []MatchKey
+	}{
+		{"кот котёнок скот", []MatchKey{{6, 0}}},
+		{"éкот кот.", []MatchKey{{15, 0}}},
+		{"écat hotdog", []MatchKey{{5, 1}, {12, 2}}},
+	}
+	for _, test := range tests {
+		got := findEnds(t, m, test.text)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q\nExpected: %v\nGot:      %v", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestCustomBoundary(t *testing.T) {
+	m := CompileStrings([]string{"id"})
+	m.SetBoundary(CustomBoundary)
+	m.SetBoundaryFunc(func(prev, next rune) bool {
+		return prev == NoRune || next == NoRune || prev == ',' || next == ','
+	})
+	got := findEnds(t, m, "id,idx,id,xid,id")
+	expected := []MatchKey{{2, 0}, {9, 0}, {16, 0}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+
+	d, err := Deserialize(m.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.boundary != CustomBoundary {
+		t.Errorf("Got boundary %d", d.boundary)
+	}
+	if got := d.FindAllString("id,idx"); len(got) != 0 {
+		t.Errorf("Got %d matches without a boundary function", len(got))
+	}
+}
+
+func TestBoundarySerialize(t *testing.T) {
+	m := CompilePatterns([]Pattern{
+		{Word: []byte("he"), Boundary: ASCIIWordBoundary},
+		{Word: []byte("she")},
+	})
+	m.SetBoundary(UnicodeWordBoundary)
+	d, err := Deserialize(m.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.boundary != m.boundary || !reflect.DeepEqual(d.boundaries, m.boundaries) {
+		t.Errorf("Got %d %v, expected %d %v", d.boundary, d.boundaries, m.boundary, m.boundaries)
+	}
+	got := findEnds(t, d, "she ushers hé")
+	expected := []MatchKey{{3, 1}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+}
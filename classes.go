@@ -0,0 +1,245 @@
+package ahocorasick
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MaxClassExpansions is the largest number of literal words a single pattern
+// given to CompileClassPatterns may expand to. Every class multiplies the
+// number of words by its size, so "??" alone would already need 65536 words.
+const MaxClassExpansions = 4096
+
+// PatternError describes a pattern which could not be compiled.
+type PatternError struct {
+	Index   int    // index of the pattern in the compiled slice
+	Pattern []byte // the pattern itself
+	Reason  string // why it was rejected
+}
+
+func (e *PatternError) Error() string {
+	return fmt.Sprintf("pattern %d %q: %s", e.Index, e.Pattern, e.Reason)
+}
+
+// CompileClassPatterns compiles a Matcher from patterns whose Word may
+// contain byte classes:
+//
+//	?        any byte
+//	[abc]    one of the listed bytes
+//	[a-z0-9] one of the bytes in the ranges
+//	[^\n]    any byte except the listed ones
+//	\?       the literal byte after the backslash, also inside classes
+//	\n \t \r newline, tab and carriage return, also inside classes
+//	\xHH     the byte with hexadecimal value HH, also inside classes
+//
+// Classes are expanded into every literal word they describe, all reported
+// under the key of their pattern. A pattern expanding to more than
+// MaxClassExpansions words, or to none, is rejected with a *PatternError.
+// Matches carry the actual bytes found in the text.
+func CompileClassPatterns(patterns []Pattern) (*Matcher, error) {
+	var words [][]byte
+	var keys []uint64
+	for i, p := range patterns {
+		classes, err := parseClasses(p.Word)
+		if err == nil {
+			words, err = expandClasses(words, classes)
+		}
+		if err != nil {
+			return nil, &PatternError{i, p.Word, err.Error()}
+		}
+		for len(keys) < len(words) {
+			keys = append(keys, uint64(i))
+		}
+	}
+
+	m := compileKeyed(words, keys)
+	m.setOptions(patterns)
+	return m, nil
+}
+
+// CompileClassStrings compiles a Matcher from strings in the syntax of
+// CompileClassPatterns. The key of a match is the index of its string.
+func CompileClassStrings(words []string) (*Matcher, error) {
+	patterns := make([]Pattern, len(words))
+	for i, word := range words {
+		patterns[i].Word = []byte(word)
+	}
+	return CompileClassPatterns(patterns)
+}
+
+// ByteClass is a set of bytes.
+type ByteClass [4]uint64
+
+// ParseByteClass parses a single class in the syntax of CompileClassPatterns,
+// such as "[ \t._-]" or "[^0-9A-Za-z]".
+func ParseByteClass(s string) (ByteClass, error) {
+	class, n, err := parseClass([]byte(s))
+	if err == nil && n != len(s) {
+		err = fmt.Errorf("trailing bytes after class: %q", s[n:])
+	}
+	return class, err
+}
+
+// Add adds b to the class.
+func (c *ByteClass) Add(b byte) { c[b/64] |= 1 << (b % 64) }
+
+// Has reports whether b is in the class.
+func (c *ByteClass) Has(b byte) bool { return c[b/64]&(1<<(b%64)) != 0 }
+
+// AddRange adds the bytes from lo to hi, inclusive, to the class.
+func (c *ByteClass) AddRange(lo, hi byte) {
+	for b := int(lo); b <= int(hi); b++ {
+		c.Add(byte(b))
+	}
+}
+
+// Negate replaces the class by the bytes it does not hold.
+func (c *ByteClass) Negate() {
+	for i := range c {
+		c[i] = ^c[i]
+	}
+}
+
+// Bytes returns the members of the class in increasing order.
+func (c *ByteClass) Bytes() []byte {
+	var members []byte
+	for b := 0; b < 256; b++ {
+		if c.Has(byte(b)) {
+			members = append(members, byte(b))
+		}
+	}
+	return members
+}
+
+// parseClasses parses a pattern into the list of byte sets matched by each of
+// its positions.
+func parseClasses(pattern []byte) ([][]byte, error) {
+	if len(pattern) == 0 {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	var classes [][]byte
+	for i := 0; i < len(pattern); {
+		class, n, err := parseClass(pattern[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		members := class.Bytes()
+		if len(members) == 0 {
+			return nil, fmt.Errorf("class matches no byte")
+		}
+		classes = append(classes, members)
+	}
+	return classes, nil
+}
+
+// parseClass parses the class at the start of the non-empty pattern and
+// returns it along with the number of bytes consumed.
+func parseClass(pattern []byte) (ByteClass, int, error) {
+	var class ByteClass
+	if len(pattern) == 0 {
+		return class, 0, fmt.Errorf("empty class")
+	}
+	switch pattern[0] {
+	case '?':
+		class.Negate()
+		return class, 1, nil
+	case '[':
+		i := 1
+		negated := i < len(pattern) && pattern[i] == '^'
+		if negated {
+			i++
+		}
+		for first := true; ; first = false {
+			if i >= len(pattern) {
+				return class, 0, fmt.Errorf("missing closing ]")
+			}
+			if pattern[i] == ']' && !first {
+				i++
+				break
+			}
+			lo, n, err := parseClassByte(pattern[i:])
+			if err != nil {
+				return class, 0, err
+			}
+			i += n
+			hi := lo
+			if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+				hi, n, err = parseClassByte(pattern[i+1:])
+				if err != nil {
+					return class, 0, err
+				}
+				if hi < lo {
+					return class, 0, fmt.Errorf("invalid range %q-%q", lo, hi)
+				}
+				i += 1 + n
+			}
+			class.AddRange(lo, hi)
+		}
+		if negated {
+			class.Negate()
+		}
+		return class, i, nil
+	default:
+		b, n, err := parseClassByte(pattern)
+		if err != nil {
+			return class, 0, err
+		}
+		class.Add(b)
+		return class, n, nil
+	}
+}
+
+// parseClassByte parses a literal or escaped byte at the start of s and
+// returns it along with the number of bytes consumed.
+func parseClassByte(s []byte) (byte, int, error) {
+	if s[0] != '\\' {
+		return s[0], 1, nil
+	}
+	if len(s) < 2 {
+		return 0, 0, fmt.Errorf("trailing backslash")
+	}
+	switch s[1] {
+	case 'n':
+		return '\n', 2, nil
+	case 't':
+		return '\t', 2, nil
+	case 'r':
+		return '\r', 2, nil
+	case 'x':
+		if len(s) < 4 {
+			return 0, 0, fmt.Errorf("incomplete \\x escape")
+		}
+		v, err := strconv.ParseUint(string(s[2:4]), 16, 8)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid \\x escape %q", s[:4])
+		}
+		return byte(v), 4, nil
+	}
+	return s[1], 2, nil
+}
+
+// expandClasses appends to words every literal word matched by classes.
+func expandClasses(words [][]byte, classes [][]byte) ([][]byte, error) {
+	total := 1
+	for _, class := range classes {
+		total *= len(class)
+		if total > MaxClassExpansions {
+			return nil, fmt.Errorf("expands to more than %d words", MaxClassExpansions)
+		}
+	}
+
+	first := len(words)
+	for i := 0; i < total; i++ {
+		words = append(words, make([]byte, len(classes)))
+	}
+	// Fill the expansions like an odometer, the last position varying fastest.
+	period := total
+	for pos, class := range classes {
+		period /= len(class)
+		for i := 0; i < total; i++ {
+			words[first+i][pos] = class[(i/period)%len(class)]
+		}
+	}
+	return words, nil
+}
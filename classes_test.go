@@ -0,0 +1,123 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCompileClassStrings(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		text     string
+		expected []Match
+		keys     []MatchKey
+	}{
+		{
+			[]string{"c?t"},
+			"cat cut c\x00t ct",
+			[]Match{{[]byte("cat"), 0}, {[]byte("cut"), 4}, {[]byte("c\x00t"), 8}},
+			[]MatchKey{{3, 0}, {7, 0}, {11, 0}},
+		},
+		{
+			[]string{"[Hh]ello", "id[0-9][0-9]"},
+			"hello Hello HELLO id42 id4x",
+			[]Match{{[]byte("hello"), 0}, {[]byte("Hello"), 6}, {[]byte("id42"), 18}},
+			[]MatchKey{{5, 0}, {11, 0}, {22, 1}},
+		},
+		{
+			[]string{"a[^0-9]c", `\?\[\x41`},
+			"a1c abc ?[A",
+			[]Match{{[]byte("abc"), 4}, {[]byte("?[A"), 8}},
+			[]MatchKey{{7, 0}, {11, 1}},
+		},
+		{
+			[]string{"[]-]x", "[a-]y"},
+			"]x -x -y by",
+			[]Match{{[]byte("]x"), 0}, {[]byte("-x"), 3}, {[]byte("-y"), 6}},
+			[]MatchKey{{2, 0}, {5, 0}, {8, 1}},
+		},
+	}
+	for _, test := range tests {
+		m, err := CompileClassStrings(test.patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := convert(m.FindAllString(test.text))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q in %q\nExpected: %v\nGot:      %v", test.patterns, test.text, test.expected, got)
+		}
+		Ms := &MatchesKeys{}
+		m.FindAllByteReader(bytes.NewReader([]byte(test.text)), Ms)
+		if !reflect.DeepEqual(Ms.matches, test.keys) {
+			t.Errorf("%q in %q\nExpected: %v\nGot:      %v", test.patterns, test.text, test.keys, Ms.matches)
+		}
+	}
+}
+
+func TestCompileClassPatternsErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		reason  string
+	}{
+		{"", "empty pattern"},
+		{"??", "expands to more than 4096 words"},
+		{"[ab", "missing closing ]"},
+		{"[z-a]", `invalid range 'z'-'a'`},
+		{`ab\`, "trailing backslash"},
+		{`\x4`, `incomplete \x escape`},
+		{`\xzz`, `invalid \x escape "\\xzz"`},
+		{"[^\x00-\xff]", "class matches no byte"},
+	}
+	for _, test := range tests {
+		_, err := CompileClassStrings([]string{"ok", test.pattern})
+		var perr *PatternError
+		if !errors.As(err, &perr) {
+			t.Errorf("%q: got %v", test.pattern, err)
+			continue
+		}
+		if perr.Index != 1 || perr.Reason != test.reason {
+			t.Errorf("%q: got %d %q, expected %q", test.pattern, perr.Index, perr.Reason, test.reason)
+		}
+	}
+}
+
+func TestCompileClassPatternsOptions(t *testing.T) {
+	m, err := CompileClassPatterns([]Pattern{{Word: []byte("[Cc]at"), Boundary: ASCIIWordBoundary}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := convert(m.FindAllString("Cat concatenate cat"))
+	expected := []Match{{[]byte("Cat"), 0}, {[]byte("cat"), 16}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+}
+
+func TestParseByteClass(t *testing.T) {
+	tests := []struct {
+		class    string
+		expected string
+		ok       bool
+	}{
+		{"[ ._-]", " -._", true},
+		{"[a-c]", "abc", true},
+		{`\x41`, "A", true},
+		{`[\t\n\r\\]`, "\t\n\r\\", true},
+		{`[^\x00-\x09\x0B-\xff]`, "\n", true},
+		{"[^\x01-\xff]", "\x00", true},
+		{"[ab", "", false},
+		{"[ab]c", "", false},
+	}
+	for _, test := range tests {
+		class, err := ParseByteClass(test.class)
+		if (err == nil) != test.ok {
+			t.Errorf("%q: got error %v", test.class, err)
+			continue
+		}
+		if test.ok && string(class.Bytes()) != test.expected {
+			t.Errorf("%q\nExpected: %q\nGot:      %q", test.class, test.expected, class.Bytes())
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Command acdict compiles, inspects and compares the dictionaries written by
+// Matcher.Serialize.
+//
+// Usage:
+//
+//	acdict compile patterns.txt -o dict.ac
+//	acdict info dict.ac
+//	acdict verify dict.ac
+//	acdict dump [-dot [-fail] | -json] [-depth n] [-states n] dict.ac
+//	acdict diff a.ac b.ac
+//
+// compile reads the patterns one per line, in the format of acgrep, and
+// gives each the key of its index among them. info prints the size of the
+// trie, the number of its patterns, the fill ratio of its double array, its
+// memory and the options of its patterns, as given by Matcher.Stats. verify
+// checks that the dictionary loads and that the invariants of its trie hold,
+// with Matcher.Validate. dump prints the states of the trie, or with -dot a
+// Graphviz graph of it, which only shows the failure links with -fail, or
+// with -json its structure as written by Matcher.WriteJSON. -depth and
+// -states limit the graph and the structure to the states of at most that
+// depth, and to that number of the shallowest states. diff prints the
+// patterns which differ between two dictionaries.
+//
+// The exit status is 0 on success, 1 if verify finds the dictionary corrupted
+// or diff finds differences, and 2 on error.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AlexanderZh/ahocorasick"
+	"github.com/AlexanderZh/ahocorasick/internal/patternfile"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+const usage = `usage:
+	acdict compile patterns.txt -o dict.ac
+	acdict info dict.ac
+	acdict verify dict.ac
+	acdict dump [-dot [-fail] | -json] [-depth n] [-states n] dict.ac
+	acdict diff a.ac b.ac
+`
+
+// errUsage reports invalid arguments, after which the usage is printed.
+var errUsage = errors.New("invalid arguments")
+
+// errFound is returned by the commands which report a finding by their exit
+// status.
+var errFound = errors.New("found")
+
+var commands = map[string]func(args []string, stdout io.Writer) error{
+	"compile": compile,
+	"info":    info,
+	"verify":  verify,
+	"dump":    dump,
+	"diff":    diff,
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "acdict: unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+	err := command(args[1:], stdout)
+	switch {
+	case err == nil:
+		return 0
+	case err == errFound:
+		return 1
+	case err == errUsage:
+		fmt.Fprint(stderr, usage)
+	case !errors.Is(err, flag.ErrHelp):
+		fmt.Fprintln(stderr, "acdict:", err)
+	}
+	return 2
+}
+
+// parseArgs parses the flags of a command, which may come before or after
+// its n arguments, and returns the arguments.
+func parseArgs(flags *flag.FlagSet, args []string, n int) ([]string, error) {
+	flags.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != n {
+		return nil, errUsage
+	}
+	return positional, nil
+}
+
+func compile(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("compile", flag.ContinueOnError)
+	output := flags.String("o", "", "")
+	args, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return errUsage
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	words, err := patternfile.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", args[0], err)
+	}
+	patterns := make([]ahocorasick.Pattern, len(words))
+	for i, word := range words {
+		patterns[i].Word = word
+	}
+	return os.WriteFile(*output, ahocorasick.CompilePatterns(patterns).Serialize(), 0o644)
+}
+
+// load reads a dictionary and checks that it can be inspected. It returns the
+// Matcher and the size of the dictionary.
+func load(path string) (*ahocorasick.Matcher, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	m, err := ahocorasick.DeserializeWithOptions(data, &ahocorasick.DeserializeOptions{Validate: true})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, len(data), nil
+}
+
+func info(args []string, stdout io.Writer) error {
+	args, err := parseArgs(flag.NewFlagSet("info", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	m, size, err := load(args[0])
+	if err != nil {
+		return err
+	}
+	stats := m.Stats()
+	fmt.Fprintf(stdout, "size:        %d bytes\n", size)
+	fmt.Fprintf(stdout, "states:      %d\n", stats.States)
+	fmt.Fprintf(stdout, "free slots:  %d\n", stats.FreeSlots)
+	fmt.Fprintf(stdout, "fill ratio:  %.1f%%\n", 100*stats.LoadFactor)
+	fmt.Fprintf(stdout, "patterns:    %d\n", stats.Patterns)
+	fmt.Fprintf(stdout, "outputs:     %d\n", stats.OutputEntries)
+	fmt.Fprintf(stdout, "max depth:   %d\n", stats.MaxDepth)
+	fmt.Fprintf(stdout, "alphabet:    %d bytes\n", len(stats.Alphabet.Bytes()))
+	fmt.Fprintf(stdout, "memory:      %d bytes (base %d, check %d, fail %d, output %d, tables %d)\n",
+		stats.Memory.Total(), stats.Memory.Base, stats.Memory.Check, stats.Memory.Fail, stats.Memory.Output, stats.Memory.Tables)
+	fmt.Fprintf(stdout, "options:     %s\n", options(stats))
+	return nil
+}
+
+// options describes the options of the patterns of a dictionary.
+func options(stats ahocorasick.Stats) string {
+	var options []string
+	if stats.Anchored {
+		options = append(options, "anchored")
+	}
+	if stats.Anchors > 0 {
+		options = append(options, fmt.Sprintf("%d anchored patterns", stats.Anchors))
+	}
+	if stats.Boundaries > 0 {
+		options = append(options, fmt.Sprintf("%d bounded patterns", stats.Boundaries))
+	}
+	if stats.Boundary != ahocorasick.NoBoundary {
+		options = append(options, fmt.Sprintf("boundary %d", stats.Boundary))
+	}
+	if len(options) == 0 {
+		return "none"
+	}
+	return strings.Join(options, ", ")
+}
+
+func verify(args []string, stdout io.Writer) error {
+	args, err := parseArgs(flag.NewFlagSet("verify", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(args[0]); err != nil {
+		return err
+	}
+	if _, _, err := load(args[0]); err != nil {
+		fmt.Fprintln(stdout, err)
+		return errFound
+	}
+	fmt.Fprintf(stdout, "%s: ok\n", args[0])
+	return nil
+}
+
+func dump(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	dot := flags.Bool("dot", false, "")
+	asJSON := flags.Bool("json", false, "")
+	var opts ahocorasick.GraphOptions
+	flags.BoolVar(&opts.FailLinks, "fail", false, "")
+	flags.IntVar(&opts.MaxDepth, "depth", 0, "")
+	flags.IntVar(&opts.MaxStates, "states", 0, "")
+	args, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	m, _, err := load(args[0])
+	if err != nil {
+		return err
+	}
+	switch {
+	case *dot:
+		return m.WriteDOT(stdout, &opts)
+	case *asJSON:
+		return m.WriteJSON(stdout, &opts)
+	}
+	t, err := readTrie(m)
+	if err != nil {
+		return err
+	}
+	for _, s := range t.States {
+		fmt.Fprintf(stdout, "state %d depth %d fail %d\n", s.State, s.Depth, s.Fail)
+		for _, e := range s.Edges {
+			fmt.Fprintf(stdout, "\t%s -> %d\n", patternfile.Escape([]byte{byte(e.Byte)}), e.To)
+		}
+		path := t.paths[s.State]
+		for _, o := range s.Outputs {
+			fmt.Fprintf(stdout, "\toutput %d %s\n", o.Key, patternfile.Escape(path[len(path)-o.Length:]))
+		}
+	}
+	return nil
+}
+
+func diff(args []string, stdout io.Writer) error {
+	args, err := parseArgs(flag.NewFlagSet("diff", flag.ContinueOnError), args, 2)
+	if err != nil {
+		return err
+	}
+	ma, _, err := load(args[0])
+	if err != nil {
+		return err
+	}
+	mb, _, err := load(args[1])
+	if err != nil {
+		return err
+	}
+	a, err := readTrie(ma)
+	if err != nil {
+		return err
+	}
+	b, err := readTrie(mb)
+	if err != nil {
+		return err
+	}
+	differ := false
+	if oa, ob := options(ma.Stats()), options(mb.Stats()); oa != ob {
+		fmt.Fprintf(stdout, "- options %s\n+ options %s\n", oa, ob)
+		differ = true
+	}
+	pa, pb := a.patterns(), b.patterns()
+	for _, k := range keys(pa) {
+		if p, ok := pb[k]; !ok || string(p) != string(pa[k]) {
+			fmt.Fprintf(stdout, "- %d %s\n", k, patternfile.Escape(pa[k]))
+			differ = true
+		}
+	}
+	for _, k := range keys(pb) {
+		if p, ok := pa[k]; !ok || string(p) != string(pb[k]) {
+			fmt.Fprintf(stdout, "+ %d %s\n", k, patternfile.Escape(pb[k]))
+			differ = true
+		}
+	}
+	if differ {
+		return errFound
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlexanderZh/ahocorasick"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a, b := filepath.Join(dir, "a.ac"), filepath.Join(dir, "b.ac")
+	run := func(args ...string) (int, string) {
+		var stdout, stderr bytes.Buffer
+		status := run(args, &stdout, &stderr)
+		return status, stdout.String()
+	}
+	if status, _ := run("compile", write("a.txt", "he\nshe\nhis\nhers\n"), "-o", a); status != 0 {
+		t.Fatalf("compile: status %d", status)
+	}
+	if status, _ := run("compile", "-o", b, write("b.txt", "he\nshe\nhis\nhim\\x00\n")); status != 0 {
+		t.Fatalf("compile: status %d", status)
+	}
+
+	options := write("o.ac", string(ahocorasick.CompilePatterns([]ahocorasick.Pattern{
+		{Word: []byte("he"), Anchor: ahocorasick.AnchorStartLine},
+		{Word: []byte("she"), Boundary: ahocorasick.ASCIIWordBoundary},
+	}).Serialize()))
+
+	tests := []struct {
+		args     []string
+		status   int
+		contains []string
+	}{
+		{[]string{"info", a}, 0, []string{"states:      10\n", "patterns:    4\n", "max depth:   4\n", "options:     none\n"}},
+		{[]string{"info", options}, 0, []string{"options:     1 anchored patterns, 1 bounded patterns\n"}},
+		{[]string{"diff", a, options}, 1, []string{"- options none\n+ options 1 anchored patterns, 1 bounded patterns\n"}},
+		{[]string{"verify", a}, 0, []string{"ok"}},
+		{[]string{"dump", a}, 0, []string{"state 8 depth 4 fail 12\n", "output 1 she\n"}},
+		{[]string{"dump", "-dot", "-fail", a}, 0, []string{"digraph trie {", `[label="s"]`, "[style=dashed, color=gray]", `\n3: \"hers\"`}},
+		{[]string{"dump", "-json", "-states", "2", a}, 0, []string{`{"state":0,"depth":0,"fail":0,"edges":[{"byte":104,"to":1}]}`, `"truncated":true`}},
+		{[]string{"diff", a, a}, 0, nil},
+		{[]string{"diff", a, b}, 1, []string{"- 3 hers\n+ 3 him\\x00\n"}},
+		{[]string{"info"}, 2, nil},
+		{[]string{"compile", "a.txt"}, 2, nil},
+		{[]string{"unknown"}, 2, nil},
+	}
+	for _, test := range tests {
+		status, out := run(test.args...)
+		if status != test.status {
+			t.Errorf("%q\nExpected: %d\nGot:      %d", test.args, test.status, status)
+		}
+		for _, s := range test.contains {
+			if !strings.Contains(out, s) {
+				t.Errorf("%q: %q not in\n%s", test.args, s, out)
+			}
+		}
+	}
+}
+
+func TestVerifyCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	patterns := filepath.Join(dir, "p.txt")
+	if err := os.WriteFile(patterns, []byte("he\nshe\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "d.ac")
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"compile", patterns, "-o", path}, &stdout, &stderr); status != 0 {
+		t.Fatalf("compile: status %d, %s", status, stderr.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Make the parent of the last state the state itself, which Deserialize
+	// only rejects when it validates the Matcher. The data starts with the
+	// lengths of base, check, fail and output, then those of the output sets,
+	// base and check.
+	header := func(i int) int { return int(binary.LittleEndian.Uint64(data[8*i:])) }
+	last := header(1) - 1
+	offset := 8 * (4 + header(3) + header(0) + last)
+	corrupted := append([]byte{}, data...)
+	binary.LittleEndian.PutUint64(corrupted[offset:], uint64(last))
+	if err := os.WriteFile(path, corrupted, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	if status := run([]string{"verify", path}, &stdout, &stderr); status != 1 {
+		t.Errorf("Expected: 1\nGot:      %d, %s", status, stdout.String())
+	}
+	if status := run([]string{"info", path}, &stdout, &stderr); status != 2 {
+		t.Errorf("Expected: 2\nGot:      %d", status)
+	}
+}
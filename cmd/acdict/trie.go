@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/AlexanderZh/ahocorasick"
+)
+
+// trie is the structure of a dictionary as written by Matcher.WriteJSON.
+type trie struct {
+	States []struct {
+		State int `json:"state"`
+		Depth int `json:"depth"`
+		Fail  int `json:"fail"`
+		Edges []struct {
+			Byte int `json:"byte"`
+			To   int `json:"to"`
+		} `json:"edges"`
+		Outputs []struct {
+			Key    uint64 `json:"key"`
+			Length int    `json:"length"`
+		} `json:"outputs"`
+	} `json:"states"`
+
+	paths map[int][]byte // the path from the root of each state
+}
+
+// readTrie returns the whole trie of m.
+func readTrie(m *ahocorasick.Matcher) (*trie, error) {
+	var buf bytes.Buffer
+	if err := m.WriteJSON(&buf, nil); err != nil {
+		return nil, err
+	}
+	t := &trie{}
+	if err := json.Unmarshal(buf.Bytes(), t); err != nil {
+		return nil, err
+	}
+
+	// States are listed breadth first, so a parent comes before its
+	// children.
+	t.paths = map[int][]byte{0: nil}
+	for _, s := range t.States {
+		for _, e := range s.Edges {
+			path := append([]byte{}, t.paths[s.State]...)
+			t.paths[e.To] = append(path, byte(e.Byte))
+		}
+	}
+	return t, nil
+}
+
+// patterns returns the pattern of each key, from the outputs of the states
+// where the patterns end.
+func (t *trie) patterns() map[uint64][]byte {
+	patterns := make(map[uint64][]byte)
+	for _, s := range t.States {
+		for _, o := range s.Outputs {
+			if o.Length == s.Depth {
+				patterns[o.Key] = t.paths[s.State]
+			}
+		}
+	}
+	return patterns
+}
+
+// keys returns the keys of patterns in increasing order.
+func keys(patterns map[uint64][]byte) []uint64 {
+	keys := make([]uint64, 0, len(patterns))
+	for k := range patterns {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
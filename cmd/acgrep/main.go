@@ -0,0 +1,402 @@
+// Command acgrep searches files for many literal patterns at once.
+//
+// Usage:
+//
+//	acgrep [flags] -f patterns.txt [file or directory ...]
+//
+// Patterns are read one per line from the patterns file, where \\, \t, \n,
+// \r and \xHH stand for a backslash, a tab, a newline, a carriage return and
+// the byte of hexadecimal value HH. Empty lines are ignored. Files are read
+// from standard input when none are given or for "-", and directories are
+// searched recursively.
+//
+// Every match is printed as
+//
+//	file:line:column:pattern
+//
+// where line and column count from 1, the column in bytes.
+//
+// The flags are:
+//
+//	-f file   read the patterns from file
+//	-i        ignore case, as Unicode simple case folding does
+//	-w        only match whole words
+//	-c        print the number of matches of each file instead
+//	-l        print the names of the files with matches instead
+//	-o        print the matched text instead of the pattern
+//	-json     print JSON objects, one per line
+//	-j n      search n files in parallel
+//
+// The exit status is 0 if a match was found, 1 if none was, and 2 on error.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/AlexanderZh/ahocorasick"
+	"github.com/AlexanderZh/ahocorasick/internal/patternfile"
+)
+
+const stdinName = "(standard input)"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type options struct {
+	ignoreCase bool
+	words      bool
+	count      bool
+	list       bool
+	only       bool
+	json       bool
+	workers    int
+}
+
+// searcher holds the compiled patterns and how to report their matches.
+type searcher struct {
+	opts     options
+	matcher  *ahocorasick.Matcher
+	patterns [][]byte
+	lens     []int // the lengths of the patterns as compiled
+}
+
+// result is the output of the search of one file.
+type result struct {
+	out     []byte
+	matched bool
+	err     error
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("acgrep", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var opts options
+	patternsFile := flags.String("f", "", "read the patterns from `file`")
+	flags.BoolVar(&opts.ignoreCase, "i", false, "ignore case")
+	flags.BoolVar(&opts.words, "w", false, "only match whole words")
+	flags.BoolVar(&opts.count, "c", false, "print the number of matches of each file")
+	flags.BoolVar(&opts.list, "l", false, "print the names of the files with matches")
+	flags.BoolVar(&opts.only, "o", false, "print the matched text instead of the pattern")
+	flags.BoolVar(&opts.json, "json", false, "print JSON objects, one per line")
+	flags.IntVar(&opts.workers, "j", runtime.NumCPU(), "search `n` files in parallel")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *patternsFile == "" {
+		fmt.Fprintln(stderr, "acgrep: missing -f patterns file")
+		flags.Usage()
+		return 2
+	}
+	if opts.workers < 1 {
+		opts.workers = 1
+	}
+
+	data, err := os.ReadFile(*patternsFile)
+	if err != nil {
+		fmt.Fprintln(stderr, "acgrep:", err)
+		return 2
+	}
+	patterns, err := patternfile.Parse(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "acgrep: %s: %v\n", *patternsFile, err)
+		return 2
+	}
+	s := newSearcher(patterns, opts)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	files, walkErrs := expand(paths)
+	for _, err := range walkErrs {
+		fmt.Fprintln(stderr, "acgrep:", err)
+	}
+
+	status := 1
+	if len(walkErrs) > 0 {
+		status = 2
+	}
+	s.searchAll(files, stdin, func(r result) {
+		stdout.Write(r.out)
+		if r.err != nil {
+			fmt.Fprintln(stderr, "acgrep:", r.err)
+			status = 2
+		} else if r.matched && status == 1 {
+			status = 0
+		}
+	})
+	return status
+}
+
+func newSearcher(patterns [][]byte, opts options) *searcher {
+	s := &searcher{opts: opts, patterns: patterns, lens: make([]int, len(patterns))}
+	compiled := make([]ahocorasick.Pattern, len(patterns))
+	for i, p := range patterns {
+		if opts.ignoreCase {
+			p, _ = foldCase(p)
+		}
+		compiled[i].Word = p
+		s.lens[i] = len(p)
+	}
+	s.matcher = ahocorasick.CompilePatterns(compiled)
+	if opts.words {
+		s.matcher.SetBoundary(ahocorasick.UnicodeWordBoundary)
+	}
+	return s
+}
+
+// foldCase returns b with every rune replaced by the smallest rune folding
+// to it, such as 'K' for 'k' and the Kelvin sign, which may be encoded in
+// fewer bytes. If it is, the offset in b of every offset in the folded text
+// is returned as well.
+func foldCase(b []byte) ([]byte, []int) {
+	folded := make([]byte, 0, len(b))
+	var offsets []int
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size <= 1 {
+			r = -1
+		} else {
+			r = foldRune(r)
+		}
+		if offsets == nil && r >= 0 && utf8.RuneLen(r) != size {
+			offsets = make([]int, len(folded), len(b)+1)
+			for j := range offsets {
+				offsets[j] = j
+			}
+		}
+		if r < 0 {
+			folded = append(folded, b[i])
+		} else {
+			folded = utf8.AppendRune(folded, r)
+		}
+		for offsets != nil && len(offsets) < len(folded) {
+			offsets = append(offsets, i)
+		}
+		i += size
+	}
+	if offsets != nil {
+		offsets = append(offsets, len(b))
+	}
+	return folded, offsets
+}
+
+// foldRune returns the smallest rune of the orbit of r under
+// unicode.SimpleFold.
+func foldRune(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+// expand lists the files to search, walking directories. The paths given are
+// followed if they are symbolic links, and searched whatever their type, so
+// that pipes such as <(cmd) are read; only the entries found while walking
+// must be regular files.
+func expand(paths []string) ([]string, []error) {
+	var files []string
+	var errs []error
+	for _, path := range paths {
+		if path == "-" {
+			files = append(files, path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		root := path
+		if link, err := os.Lstat(path); err == nil && link.Mode()&fs.ModeSymlink != 0 {
+			// WalkDir does not enter a link to a directory, but
+			// enters it followed by a separator.
+			root += string(filepath.Separator)
+		}
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				errs = append(errs, err)
+				return nil
+			}
+			if d.Type().IsRegular() {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return files, errs
+}
+
+// searchAll searches the files with opts.workers workers, and calls report
+// with their results in order.
+func (s *searcher) searchAll(files []string, stdin io.Reader, report func(result)) {
+	results := make([]chan result, len(files))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	jobs := make(chan int)
+	for w := 0; w < s.opts.workers; w++ {
+		go func() {
+			for i := range jobs {
+				results[i] <- s.searchFile(files[i], stdin)
+			}
+		}()
+	}
+	go func() {
+		for i := range files {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+	for _, r := range results {
+		report(<-r)
+	}
+}
+
+func (s *searcher) searchFile(path string, stdin io.Reader) result {
+	var data []byte
+	var err error
+	name := path
+	if path == "-" {
+		name = stdinName
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return result{err: err}
+	}
+	matches := s.search(data)
+	return result{out: s.format(name, data, matches), matched: len(matches) > 0}
+}
+
+// match is a pattern found at data[start:end].
+type match struct {
+	start, end int
+	key        int
+}
+
+type keyedMatches []match
+
+func (k *keyedMatches) Append(end int, key int) {
+	*k = append(*k, match{end: end, key: key})
+}
+
+func (k *keyedMatches) Count() int {
+	return len(*k)
+}
+
+func (s *searcher) search(data []byte) []match {
+	text := data
+	var offsets []int
+	if s.opts.ignoreCase {
+		text, offsets = foldCase(data)
+	}
+	var matches keyedMatches
+	s.matcher.FindAllByteReader(bytes.NewReader(text), &matches)
+	for i := range matches {
+		m := &matches[i]
+		m.start = m.end - s.lens[m.key]
+		if offsets != nil {
+			m.start, m.end = offsets[m.start], offsets[m.end]
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	return matches
+}
+
+type jsonMatch struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Pattern string `json:"pattern"`
+	Match   string `json:"match"`
+}
+
+type jsonCount struct {
+	File  string `json:"file"`
+	Count int    `json:"count"`
+}
+
+type jsonFile struct {
+	File string `json:"file"`
+}
+
+func (s *searcher) format(name string, data []byte, matches []match) []byte {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	switch {
+	case s.opts.list:
+		if len(matches) == 0 {
+			break
+		}
+		if s.opts.json {
+			enc.Encode(jsonFile{name})
+		} else {
+			fmt.Fprintln(&out, name)
+		}
+	case s.opts.count:
+		if s.opts.json {
+			enc.Encode(jsonCount{name, len(matches)})
+		} else {
+			fmt.Fprintf(&out, "%s:%d\n", name, len(matches))
+		}
+	default:
+		lines := newLineIndex(data)
+		for _, m := range matches {
+			line, col := lines.position(m.start)
+			pattern := string(s.patterns[m.key])
+			text := string(data[m.start:m.end])
+			if s.opts.json {
+				enc.Encode(jsonMatch{name, line, col, pattern, text})
+				continue
+			}
+			shown := pattern
+			if s.opts.only {
+				shown = text
+			}
+			fmt.Fprintf(&out, "%s:%d:%d:%s\n", name, line, col, shown)
+		}
+	}
+	return out.Bytes()
+}
+
+// lineIndex maps offsets to line and column numbers.
+type lineIndex []int // offsets of the line starts
+
+func newLineIndex(data []byte) lineIndex {
+	starts := lineIndex{0}
+	for i, b := range data {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+func (l lineIndex) position(offset int) (line, col int) {
+	line = sort.Search(len(l), func(i int) bool { return l[i] > offset })
+	return line, offset - l[line-1] + 1
+}
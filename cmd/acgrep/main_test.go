@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	patterns := write("patterns.txt", "hello\nWorld\n")
+	a := write("src/a.txt", "hello world\nsay Hello\n")
+	b := write("src/sub/b.txt", "helloWorld\n")
+	write("src/sub/c.txt", "nothing\n")
+	src := filepath.Join(dir, "src")
+
+	tests := []struct {
+		args   []string
+		stdin  string
+		out    string
+		status int
+	}{
+		{
+			[]string{src}, "",
+			a + ":1:1:hello\n" + b + ":1:1:hello\n" + b + ":1:6:World\n", 0,
+		},
+		{
+			[]string{"-i", "-w", src}, "",
+			a + ":1:1:hello\n" + a + ":1:7:World\n" + a + ":2:5:hello\n", 0,
+		},
+		{
+			[]string{"-i", "-o", a}, "",
+			a + ":1:1:hello\n" + a + ":1:7:world\n" + a + ":2:5:Hello\n", 0,
+		},
+		{
+			[]string{"-c", "-j", "1", src}, "",
+			a + ":1\n" + b + ":2\n" + filepath.Join(src, "sub", "c.txt") + ":0\n", 0,
+		},
+		{
+			[]string{"-l", src}, "",
+			a + "\n" + b + "\n", 0,
+		},
+		{
+			[]string{"-json", "-i"}, "HELLO",
+			`{"file":"(standard input)","line":1,"column":1,"pattern":"hello","match":"HELLO"}` + "\n", 0,
+		},
+		{
+			[]string{"-"}, "nothing here",
+			"", 1,
+		},
+		{
+			[]string{filepath.Join(dir, "missing")}, "",
+			"", 2,
+		},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"-f", patterns}, test.args...)
+		status := run(args, strings.NewReader(test.stdin), &stdout, &stderr)
+		if status != test.status || stdout.String() != test.out {
+			t.Errorf("%q\nExpected: %d %q\nGot:      %d %q (%s)", test.args, test.status, test.out, status, stdout.String(), stderr.String())
+		}
+	}
+}
+
+func TestRunIgnoreCaseWidths(t *testing.T) {
+	patterns := filepath.Join(t.TempDir(), "patterns.txt")
+	if err := os.WriteFile(patterns, []byte("ask\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The long s and the Kelvin sign fold to S and K, which are encoded
+	// in fewer bytes.
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"-f", patterns, "-i", "-o"}, strings.NewReader("A\u017F\u212A ASK"), &stdout, &stderr)
+	expected := "(standard input):1:1:A\u017F\u212A\n(standard input):1:8:ASK\n"
+	if status != 0 || stdout.String() != expected {
+		t.Errorf("Expected: %q\nGot:      %d %q (%s)", expected, status, stdout.String(), stderr.String())
+	}
+}
+
+func TestRunSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	patterns := filepath.Join(dir, "patterns.txt")
+	if err := os.WriteFile(patterns, []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "a.txt"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, src := filepath.Join(dir, "file.txt"), filepath.Join(dir, "dir")
+	if err := os.Symlink(filepath.Join(dir, "src", "a.txt"), file); err != nil {
+		t.Skip(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "src"), src); err != nil {
+		t.Skip(err)
+	}
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"-f", patterns, "-l", file, src}, strings.NewReader(""), &stdout, &stderr)
+	expected := file + "\n" + filepath.Join(src, "a.txt") + "\n"
+	if status != 0 || stdout.String() != expected {
+		t.Errorf("Expected: %q\nGot:      %d %q (%s)", expected, status, stdout.String(), stderr.String())
+	}
+}
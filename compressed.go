@@ -0,0 +1,223 @@
+package ahocorasick
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CompressionFormat is a compression format recognized by the first bytes of
+// the compressed data.
+type CompressionFormat struct {
+	Name string
+	// Magic is the prefix of the compressed data. Formats without a fixed
+	// prefix set Detect instead, which is given the first bytes of the data.
+	// As text may pass Detect, the first bytes must also decode without
+	// error for the format to be detected.
+	Magic  []byte
+	Detect func(header []byte) bool
+	// NewReader returns a reader of the data decompressed from r.
+	NewReader func(r io.Reader) (io.Reader, error)
+}
+
+// CompressionFormats returns the formats recognized by default: gzip, zlib
+// and bzip2.
+func CompressionFormats() []CompressionFormat {
+	return []CompressionFormat{
+		{
+			Name:      "gzip",
+			Magic:     []byte{0x1f, 0x8b},
+			NewReader: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		},
+		{
+			Name:      "bzip2",
+			Magic:     []byte("BZh"),
+			NewReader: func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil },
+		},
+		{
+			// RFC 1950: deflate with a window of at most 32K, no
+			// preset dictionary, and a header which is a multiple of
+			// 31. Text may start so, as in "x^", so the header is
+			// also decoded before the stream is taken for zlib.
+			Name: "zlib",
+			Detect: func(header []byte) bool {
+				return len(header) >= 2 && header[0]&0x0f == 8 && header[0]>>4 <= 7 &&
+					header[1]&0x20 == 0 && (uint(header[0])<<8|uint(header[1]))%31 == 0
+			},
+			NewReader: func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+		},
+	}
+}
+
+// maxHeader is the number of bytes formats are detected on.
+const maxHeader = 64
+
+// ratioFloor is the size of decompressed data below which the decompression
+// ratio is not checked, since small inputs compress poorly.
+const ratioFloor = 1 << 20
+
+// DefaultMaxDecompressedSize is the largest number of decompressed bytes read
+// when DecompressOptions.MaxSize is zero.
+const DefaultMaxDecompressedSize = 1 << 32
+
+// DefaultMaxDecompressionRatio is the largest number of decompressed bytes
+// read per compressed byte when DecompressOptions.MaxRatio is zero. Text
+// rarely compresses beyond it, while a deflate bomb reaches about 1000.
+const DefaultMaxDecompressionRatio = 200
+
+// DecompressOptions configure the detection and the decompression of
+// compressed data.
+type DecompressOptions struct {
+	// Formats are tried in order before the formats of CompressionFormats.
+	Formats []CompressionFormat
+	// MaxSize is the largest number of decompressed bytes read. If zero,
+	// DefaultMaxDecompressedSize is used, and if negative, there is no
+	// limit.
+	MaxSize int64
+	// MaxRatio is the largest number of decompressed bytes read per
+	// compressed byte. It is only checked once more than 1 MiB has been
+	// decompressed. If zero, DefaultMaxDecompressionRatio is used, and if
+	// negative, there is no limit.
+	MaxRatio float64
+}
+
+// ErrDecompressionLimit is returned when decompressed data exceeds MaxSize or
+// MaxRatio.
+var ErrDecompressionLimit = errors.New("ahocorasick: decompression limit exceeded")
+
+// Decompress detects the compression format of the data read from r and
+// returns a reader of the decompressed data along with the name of the
+// format. A format with a magic is detected by it alone: data which starts
+// with the magic but is not valid in the format is not scanned as is, and the
+// errors of the decoder are returned by Decompress or the reader. A format
+// without one is only detected if its decoder also accepts the first bytes of
+// the data. Data in no known format is returned as is, with an empty name.
+// The reader fails with ErrDecompressionLimit once the limits of opts, which
+// may be nil for the default limits, are exceeded.
+func Decompress(r io.Reader, opts *DecompressOptions) (io.Reader, string, error) {
+	limits := DecompressOptions{}
+	if opts != nil {
+		limits = *opts
+	}
+	if limits.MaxSize == 0 {
+		limits.MaxSize = DefaultMaxDecompressedSize
+	}
+	if limits.MaxRatio == 0 {
+		limits.MaxRatio = DefaultMaxDecompressionRatio
+	}
+	opts = &limits
+	compressed := &countingReader{r: r}
+	buffered := bufio.NewReaderSize(compressed, maxHeader)
+	header, err := buffered.Peek(maxHeader)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+
+	formats := append(append([]CompressionFormat{}, opts.Formats...), CompressionFormats()...)
+	for _, f := range formats {
+		detected := f.Detect != nil && f.Detect(header)
+		if f.Magic != nil && bytes.HasPrefix(header, f.Magic) {
+			detected = true
+		}
+		if !detected {
+			continue
+		}
+		if f.Magic == nil && !decodes(f, header, len(header) < maxHeader) {
+			continue
+		}
+		decompressed, err := f.NewReader(buffered)
+		if err != nil {
+			return nil, f.Name, fmt.Errorf("ahocorasick: %s: %w", f.Name, err)
+		}
+		return &limitedReader{r: decompressed, compressed: compressed, opts: opts}, f.Name, nil
+	}
+	return buffered, "", nil
+}
+
+// decodes reports whether the decoder of f accepts header, the first bytes of
+// the data, which are all of it if complete.
+func decodes(f CompressionFormat, header []byte, complete bool) bool {
+	r, err := f.NewReader(bytes.NewReader(header))
+	if err != nil {
+		return false
+	}
+	// The output of a few bytes is small, but bound it all the same.
+	_, err = io.Copy(io.Discard, io.LimitReader(r, 1<<16))
+	return err == nil || err == io.ErrUnexpectedEOF && !complete
+}
+
+// FindAllCompressedReader detects the compression format of the stream,
+// finds all instances of the patterns in the decompressed data, and appends
+// the end position in the decompressed data and the key of each of them to
+// matches. It returns the name of the format, empty for uncompressed data,
+// and the error which ended the scan, if it was not the end of the data.
+func (m *Matcher) FindAllCompressedReader(reader io.Reader, matches Matches, opts *DecompressOptions) (string, error) {
+	decompressed, format, err := Decompress(reader, opts)
+	if err != nil {
+		return format, err
+	}
+	r := &errReader{r: decompressed}
+	m.FindAllByteReader(r, matches)
+	if r.err != io.EOF {
+		return format, r.err
+	}
+	return format, nil
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedReader enforces the limits of opts on the data decompressed from
+// compressed.
+type limitedReader struct {
+	r          io.Reader
+	compressed *countingReader
+	opts       *DecompressOptions
+	n          int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.opts.MaxSize > 0 && l.n > l.opts.MaxSize {
+		return 0, ErrDecompressionLimit
+	}
+	if l.opts.MaxSize > 0 && int64(len(p)) > l.opts.MaxSize-l.n+1 {
+		p = p[:l.opts.MaxSize-l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.opts.MaxSize > 0 && l.n > l.opts.MaxSize {
+		return n - int(l.n-l.opts.MaxSize), ErrDecompressionLimit
+	}
+	if l.opts.MaxRatio > 0 && l.n > ratioFloor && float64(l.n) > l.opts.MaxRatio*float64(l.compressed.n) {
+		return n, ErrDecompressionLimit
+	}
+	return n, err
+}
+
+// errReader remembers the error which ended the reads from r.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return n, err
+}
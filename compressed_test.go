@@ -0,0 +1,162 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func compressed(t *testing.T, format string, data []byte) []byte {
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch format {
+	case "gzip":
+		w = gzip.NewWriter(&b)
+	case "zlib":
+		w = zlib.NewWriter(&b)
+	default:
+		return data
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// bzip2Hello is "hello world\n" compressed with bzip2, which the standard
+// library can only decompress.
+var bzip2Hello = []byte("BZh91AY&SYN\xec\xe86\x00\x00\x02Q\x80\x00\x10@\x00\x06D\x90\x80 \x001\x06LA\x01\xa7\xa9\xa5\x80\xbb\x941\xf8\xbb\x92)\xc2\x84\x82wgA\xb0")
+
+func TestFindAllCompressedReader(t *testing.T) {
+	m := CompileStrings([]string{"world", "hello"})
+	text := []byte("hello world\n")
+	tests := []struct {
+		format string
+		data   []byte
+	}{
+		{"gzip", compressed(t, "gzip", text)},
+		{"zlib", compressed(t, "zlib", text)},
+		{"bzip2", bzip2Hello},
+		{"", text},
+		// Text which could start a zlib stream.
+		{"", []byte("x hello world\n")},
+		{"", []byte("x^ hello world\n")},
+		{"", []byte("HK hello world\n")},
+	}
+	for _, test := range tests {
+		matches := &MatchesKeys{}
+		format, err := m.FindAllCompressedReader(iotest.OneByteReader(bytes.NewReader(test.data)), matches, nil)
+		if err != nil || format != test.format {
+			t.Errorf("%q: got format %q, error %v", test.format, format, err)
+		}
+		if matches.Count() != 2 {
+			t.Errorf("%q: got %v", test.format, matches.matches)
+		}
+	}
+}
+
+func TestFindAllCompressedReaderLongHeader(t *testing.T) {
+	// A gzip header with a name longer than the bytes formats are detected
+	// on.
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Name = strings.Repeat("name", 100)
+	w.Write([]byte("hello world\n"))
+	w.Close()
+	matches := &MatchesKeys{}
+	format, err := CompileStrings([]string{"hello"}).FindAllCompressedReader(&b, matches, nil)
+	if err != nil || format != "gzip" || matches.Count() != 1 {
+		t.Errorf("Expected: 1 match in gzip\nGot:      %v in %q, error %v", matches.matches, format, err)
+	}
+}
+
+func TestFindAllCompressedReaderCorrupted(t *testing.T) {
+	m := CompileStrings([]string{"hello"})
+	truncated := compressed(t, "gzip", []byte("hello world\n"))
+	truncated = truncated[:len(truncated)-4]
+	// A zlib stream longer than the header decoded to detect it.
+	random := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(random)
+	long := compressed(t, "zlib", random)
+	truncatedZlib := long[:len(long)-10]
+	tests := []struct {
+		format string
+		data   []byte
+	}{
+		{"gzip", []byte("\x1f\x8b hello")},
+		{"gzip", truncated},
+		{"zlib", truncatedZlib},
+	}
+	for _, test := range tests {
+		format, err := m.FindAllCompressedReader(bytes.NewReader(test.data), &MatchesKeys{}, nil)
+		if err == nil || format != test.format {
+			t.Errorf("%q: Expected: an error from %s\nGot:      format %q, error %v", test.data, test.format, format, err)
+		}
+	}
+}
+
+func TestFindAllCompressedReaderCustomFormat(t *testing.T) {
+	rot13 := CompressionFormat{
+		Name:  "rot13",
+		Magic: []byte("ROT13:"),
+		NewReader: func(r io.Reader) (io.Reader, error) {
+			b, err := io.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			return strings.NewReader(strings.Map(func(r rune) rune {
+				if r >= 'a' && r <= 'z' {
+					return 'a' + (r-'a'+13)%26
+				}
+				return r
+			}, string(b[len("ROT13:"):]))), nil
+		},
+	}
+	m := CompileStrings([]string{"hello"})
+	matches := &MatchesKeys{}
+	format, err := m.FindAllCompressedReader(strings.NewReader("ROT13:uryyb"), matches, &DecompressOptions{Formats: []CompressionFormat{rot13}})
+	expected := []MatchKey{{5, 0}}
+	if err != nil || format != "rot13" || !reflect.DeepEqual(matches.matches, expected) {
+		t.Errorf("Got %q, %v, %v", format, err, matches.matches)
+	}
+}
+
+func TestDecompressLimits(t *testing.T) {
+	bomb := compressed(t, "gzip", make([]byte, 4<<20))
+	tests := []struct {
+		opts DecompressOptions
+		size int64
+		err  error
+	}{
+		{DecompressOptions{}, -1, ErrDecompressionLimit},
+		{DecompressOptions{MaxSize: -1, MaxRatio: -1}, 4 << 20, nil},
+		{DecompressOptions{MaxSize: 1000}, 1000, ErrDecompressionLimit},
+		{DecompressOptions{MaxSize: 4 << 20, MaxRatio: -1}, 4 << 20, nil},
+		{DecompressOptions{MaxRatio: 100}, -1, ErrDecompressionLimit},
+		{DecompressOptions{MaxRatio: 2000}, 4 << 20, nil},
+	}
+	for _, test := range tests {
+		r, _, err := Decompress(bytes.NewReader(bomb), &test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := io.Copy(io.Discard, r)
+		if !errors.Is(err, test.err) || test.size >= 0 && n != test.size {
+			t.Errorf("%+v: got %d bytes, error %v", test.opts, n, err)
+		}
+	}
+	// Without options, the default limits apply.
+	if _, err := CompileStrings([]string{"a"}).FindAllCompressedReader(bytes.NewReader(bomb), &MatchesKeys{}, nil); !errors.Is(err, ErrDecompressionLimit) {
+		t.Errorf("Expected: %v\nGot:      %v", ErrDecompressionLimit, err)
+	}
+}
@@ -0,0 +1,6336 @@
+// Code generated by gen_confusables.go; DO NOT EDIT.
+//
+// Source header:
+//
+// confusables.txt
+// Date: 2020-02-13, 01:38:49 GMT
+// © 2020 Unicode®, Inc.
+// Unicode and the Unicode Logo are registered trademarks of Unicode, Inc. in the U.S. and other countries.
+// For terms of use, see http://www.unicode.org/terms_of_use.html
+//
+// Unicode Security Mechanisms for UTS #39
+// Version: 13.0.0
+//
+// For documentation and usage, see http://www.unicode.org/reports/tr39
+//
+
+package ahocorasick
+
+// confusables maps runes to their prototype in confusables.txt, decomposed
+// to NFD.
+var confusables = []struct {
+	from rune
+	to   string
+}{
+	{0x0022, "''"},
+	{0x0025, "\u00ba/\u2080"},
+	{0x0030, "O"},
+	{0x0031, "l"},
+	{0x0049, "l"},
+	{0x0060, "'"},
+	{0x006D, "rn"},
+	{0x007C, "l"},
+	{0x00A0, " "},
+	{0x00A2, "c\u0338"},
+	{0x00A5, "Y\u0335"},
+	{0x00AF, "\u02c9"},
+	{0x00B4, "'"},
+	{0x00B5, "\u03bc"},
+	{0x00B8, ","},
+	{0x00C6, "AE"},
+	{0x00C7, "C\u0326"},
+	{0x00D0, "D\u0335"},
+	{0x00D7, "x"},
+	{0x00D8, "O\u0338"},
+	{0x00E6, "ae"},
+	{0x00E7, "c\u0326"},
+	{0x00F0, "\u2202\u0335"},
+	{0x00F6, "\u0629"},
+	{0x00F8, "o\u0338"},
+	{0x0110, "D\u0335"},
+	{0x0111, "d\u0335"},
+	{0x011A, "E\u0306"},
+	{0x011B, "e\u0306"},
+	{0x0126, "H\u0335"},
+	{0x0127, "h\u0335"},
+	{0x0131, "i"},
+	{0x0132, "lJ"},
+	{0x0133, "ij"},
+	{0x013F, "l\u00b7"},
+	{0x0140, "l\u00b7"},
+	{0x0141, "L\u0338"},
+	{0x0142, "l\u0338"},
+	{0x0146, "\u0272"},
+	{0x0149, "'n"},
+	{0x0150, "O\u0308"},
+	{0x0152, "OE"},
+	{0x0153, "oe"},
+	{0x0163, "\u01ab"},
+	{0x0166, "T\u0335"},
+	{0x0167, "t\u0335"},
+	{0x017F, "f"},
+	{0x0180, "b\u0335"},
+	{0x0181, "'B"},
+	{0x0182, "b\u0304"},
+	{0x0183, "b\u0304"},
+	{0x0184, "b"},
+	{0x0187, "C'"},
+	{0x0189, "D\u0335"},
+	{0x018A, "'D"},
+	{0x018C, "d\u0304"},
+	{0x018D, "g"},
+	{0x0191, "F\u0326"},
+	{0x0192, "f\u0326"},
+	{0x0193, "G'"},
+	{0x0196, "l"},
+	{0x0197, "l\u0335"},
+	{0x0198, "K'"},
+	{0x0199, "k\u0314"},
+	{0x019A, "l\u0335"},
+	{0x019D, "N\u0326"},
+	{0x019E, "n\u0329"},
+	{0x019F, "O\u0335"},
+	{0x01A0, "O'"},
+	{0x01A1, "o'"},
+	{0x01A4, "'P"},
+	{0x01A5, "p\u0314"},
+	{0x01A6, "R"},
+	{0x01A7, "2"},
+	{0x01AC, "'T"},
+	{0x01AD, "t\u0314"},
+	{0x01AE, "T\u0328"},
+	{0x01B3, "'Y"},
+	{0x01B4, "y\u0314"},
+	{0x01B5, "Z\u0335"},
+	{0x01B6, "z\u0335"},
+	{0x01B7, "3"},
+	{0x01BB, "2\u0335"},
+	{0x01BC, "5"},
+	{0x01BD, "s"},
+	{0x01BF, "\u00fe"},
+	{0x01C0, "l"},
+	{0x01C1, "ll"},
+	{0x01C3, "!"},
+	{0x01C4, "DZ\u030c"},
+	{0x01C5, "Dz\u030c"},
+	{0x01C6, "dz\u030c"},
+	{0x01C7, "LJ"},
+	{0x01C8, "Lj"},
+	{0x01C9, "lj"},
+	{0x01CA, "NJ"},
+	{0x01CB, "Nj"},
+	{0x01CC, "nj"},
+	{0x01CD, "A\u0306"},
+	{0x01CE, "a\u0306"},
+	{0x01CF, "I\u0306"},
+	{0x01D0, "i\u0306"},
+	{0x01D1, "O\u0306"},
+	{0x01D2, "o\u0306"},
+	{0x01D3, "U\u0306"},
+	{0x01D4, "u\u0306"},
+	{0x01E4, "G\u0335"},
+	{0x01E5, "g\u0335"},
+	{0x01E6, "G\u0306"},
+	{0x01E7, "g\u0306"},
+	{0x01F1, "DZ"},
+	{0x01F2, "Dz"},
+	{0x01F3, "dz"},
+	{0x01F5, "g\u0327"},
+	{0x01FE, "O\u0338\u0301"},
+	{0x021A, "T\u0327"},
+	{0x021B, "\u01ab"},
+	{0x021C, "3"},
+	{0x0222, "8"},
+	{0x0223, "8"},
+	{0x0224, "Z\u0326"},
+	{0x0225, "z\u0326"},
+	{0x0226, "A\u030a"},
+	{0x0227, "a\u030a"},
+	{0x023C, "c\u0338"},
+	{0x023E, "T\u0338"},
+	{0x0241, "?"},
+	{0x0244, "U\u0335"},
+	{0x0246, "E\u0338"},
+	{0x0247, "e\u0338"},
+	{0x0248, "J\u0335"},
+	{0x0249, "j\u0335"},
+	{0x024D, "r\u0335"},
+	{0x024E, "Y\u0335"},
+	{0x024F, "y\u0335"},
+	{0x0251, "a"},
+	{0x0253, "b\u0314"},
+	{0x0256, "d\u0328"},
+	{0x0257, "d\u0314"},
+	{0x0259, "\u01dd"},
+	{0x025A, "\u01dd\u02de"},
+	{0x025B, "\ua793"},
+	{0x0260, "g\u0314"},
+	{0x0261, "g"},
+	{0x0263, "y"},
+	{0x0266, "h\u0314"},
+	{0x0268, "i\u0335"},
+	{0x0269, "i"},
+	{0x026A, "i"},
+	{0x026B, "l\u0334"},
+	{0x026D, "l\u0328"},
+	{0x026E, "l\u021d"},
+	{0x026F, "w"},
+	{0x0271, "rn\u0326"},
+	{0x0273, "n\u0328"},
+	{0x0275, "o\u0335"},
+	{0x0276, "o\u1d07"},
+	{0x027C, "r\u0329"},
+	{0x027D, "r\u0328"},
+	{0x0282, "s\u0328"},
+	{0x028B, "u"},
+	{0x028F, "y"},
+	{0x0290, "z\u0328"},
+	{0x0292, "\u021d"},
+	{0x0294, "?"},
+	{0x02A0, "q\u0314"},
+	{0x02A3, "dz"},
+	{0x02A4, "d\u021d"},
+	{0x02A5, "d\u0291"},
+	{0x02A6, "ts"},
+	{0x02A7, "t\u0283"},
+	{0x02A8, "t\u0255"},
+	{0x02A9, "f\u014b"},
+	{0x02AA, "ls"},
+	{0x02AB, "lz"},
+	{0x02B3, "\u18f4"},
+	{0x02B9, "'"},
+	{0x02BA, "''"},
+	{0x02BB, "'"},
+	{0x02BC, "'"},
+	{0x02BD, "'"},
+	{0x02BE, "'"},
+	{0x02BF, "\u0559"},
+	{0x02C2, "<"},
+	{0x02C3, ">"},
+	{0x02C4, "^"},
+	{0x02C6, "^"},
+	{0x02C8, "'"},
+	{0x02CA, "'"},
+	{0x02CB, "'"},
+	{0x02D0, ":"},
+	{0x02D3, "\u0559"},
+	{0x02D7, "-"},
+	{0x02D8, "\u02c7"},
+	{0x02D9, "\u0971"},
+	{0x02DA, "\u00b0"},
+	{0x02DB, "i"},
+	{0x02DC, "~"},
+	{0x02DD, "''"},
+	{0x02E1, "\u18f3"},
+	{0x02E2, "\u18f5"},
+	{0x02E4, "\u02c1"},
+	{0x02EE, "''"},
+	{0x02F4, "'"},
+	{0x02F6, "''"},
+	{0x02F8, ":"},
+	{0x02FB, "\u02ea"},
+	{0x0305, "\u0304"},
+	{0x030C, "\u0306"},
+	{0x030D, "\u0670"},
+	{0x0310, "\u0306\u0307"},
+	{0x0311, "\u0302"},
+	{0x0315, "\u0313"},
+	{0x0317, "\u0650"},
+	{0x0320, "\u0331"},
+	{0x0321, "\u0326"},
+	{0x0322, "\u0328"},
+	{0x0327, "\u0326"},
+	{0x0336, "\u0335"},
+	{0x0337, "\u0338"},
+	{0x0339, "\u0326"},
+	{0x0340, "\u0300"},
+	{0x0341, "\u0301"},
+	{0x0342, "\u0303"},
+	{0x0343, "\u0313"},
+	{0x0345, "\u0328"},
+	{0x0347, "\u0333"},
+	{0x0357, "\u0350"},
+	{0x0358, "\u0307"},
+	{0x0366, "\u030a"},
+	{0x036E, "\u0306"},
+	{0x0370, "\u2c75"},
+	{0x0374, "'"},
+	{0x0375, "\u02cf"},
+	{0x0376, "\u0418"},
+	{0x0377, "\u1d0e"},
+	{0x037A, "i"},
+	{0x037B, "\u0254"},
+	{0x037D, "\ua73f"},
+	{0x037E, ";"},
+	{0x037F, "J"},
+	{0x0384, "'"},
+	{0x0387, "\u00b7"},
+	{0x0391, "A"},
+	{0x0392, "B"},
+	{0x0395, "E"},
+	{0x0396, "Z"},
+	{0x0397, "H"},
+	{0x0398, "O\u0335"},
+	{0x0399, "l"},
+	{0x039A, "K"},
+	{0x039B, "\u0245"},
+	{0x039C, "M"},
+	{0x039D, "N"},
+	{0x039F, "O"},
+	{0x03A1, "P"},
+	{0x03A3, "\u01a9"},
+	{0x03A4, "T"},
+	{0x03A5, "Y"},
+	{0x03A7, "X"},
+	{0x03B1, "a"},
+	{0x03B2, "\u00df"},
+	{0x03B3, "y"},
+	{0x03B4, "\u1e9f"},
+	{0x03B5, "\ua793"},
+	{0x03B7, "n\u0329"},
+	{0x03B8, "O\u0335"},
+	{0x03B9, "i"},
+	{0x03BA, "\u0138"},
+	{0x03BD, "v"},
+	{0x03BF, "o"},
+	{0x03C1, "p"},
+	{0x03C3, "o"},
+	{0x03C4, "\u1d1b"},
+	{0x03C5, "u"},
+	{0x03C6, "\u0278"},
+	{0x03D0, "\u00df"},
+	{0x03D1, "O\u0335"},
+	{0x03D2, "Y"},
+	{0x03D5, "\u0278"},
+	{0x03D6, "\u03c0"},
+	{0x03DB, "\u03c2"},
+	{0x03DC, "F"},
+	{0x03E8, "2"},
+	{0x03E9, "\u01a8"},
+	{0x03F0, "\u0138"},
+	{0x03F1, "p"},
+	{0x03F2, "c"},
+	{0x03F3, "j"},
+	{0x03F4, "O\u0335"},
+	{0x03F5, "\ua793"},
+	{0x03F7, "\u00de"},
+	{0x03F8, "\u00fe"},
+	{0x03F9, "C"},
+	{0x03FA, "M"},
+	{0x03FD, "\u0186"},
+	{0x03FF, "\ua73e"},
+	{0x0404, "\ua792"},
+	{0x0405, "S"},
+	{0x0406, "l"},
+	{0x0408, "J"},
+	{0x0410, "A"},
+	{0x0411, "b\u0304"},
+	{0x0412, "B"},
+	{0x0413, "\u0393"},
+	{0x0415, "E"},
+	{0x0417, "3"},
+	{0x0419, "\u0418\u0300"},
+	{0x041A, "K"},
+	{0x041B, "\u0245"},
+	{0x041C, "M"},
+	{0x041D, "H"},
+	{0x041E, "O"},
+	{0x041F, "\u03a0"},
+	{0x0420, "P"},
+	{0x0421, "C"},
+	{0x0422, "T"},
+	{0x0423, "Y"},
+	{0x0424, "\u03a6"},
+	{0x0425, "X"},
+	{0x042B, "bl"},
+	{0x042C, "b"},
+	{0x042E, "lO"},
+	{0x0430, "a"},
+	{0x0431, "6"},
+	{0x0432, "\u0299"},
+	{0x0433, "r"},
+	{0x0435, "e"},
+	{0x0437, "\u025c"},
+	{0x0438, "\u1d0e"},
+	{0x043A, "\u0138"},
+	{0x043C, "\u028d"},
+	{0x043D, "\u029c"},
+	{0x043E, "o"},
+	{0x043F, "\u03c0"},
+	{0x0440, "p"},
+	{0x0441, "c"},
+	{0x0442, "\u1d1b"},
+	{0x0443, "y"},
+	{0x0444, "\u0278"},
+	{0x0445, "x"},
+	{0x044A, "\u02c9b"},
+	{0x044B, "\u0185i"},
+	{0x044C, "\u0185"},
+	{0x044F, "\u1d19"},
+	{0x0454, "\ua793"},
+	{0x0455, "s"},
+	{0x0456, "i"},
+	{0x0458, "j"},
+	{0x045B, "h\u0335"},
+	{0x045D, "\u0438\u0306"},
+	{0x0461, "w"},
+	{0x0462, "b\u0335"},
+	{0x0463, "b\u0335"},
+	{0x0470, "\u03a8"},
+	{0x0471, "\u03c8"},
+	{0x0472, "O\u0335"},
+	{0x0473, "o\u0335"},
+	{0x0474, "V"},
+	{0x0475, "v"},
+	{0x047C, "\u0460\u0486\u0487"},
+	{0x047D, "w\u0486\u0487"},
+	{0x048A, "\u0418\u0326\u0300"},
+	{0x048B, "\u0438\u0326\u0306"},
+	{0x048C, "b\u0335"},
+	{0x048D, "b\u0335"},
+	{0x0490, "\u0393'"},
+	{0x0491, "r'"},
+	{0x0492, "\u0393\u0335"},
+	{0x0493, "r\u0335"},
+	{0x0496, "\u0416\u0329"},
+	{0x0497, "\u0436\u0329"},
+	{0x0498, "3\u0326"},
+	{0x0499, "\u025c\u0326"},
+	{0x049A, "K\u0329"},
+	{0x049B, "\u0138\u0329"},
+	{0x049E, "K\u0335"},
+	{0x049F, "\u0138\u0335"},
+	{0x04A2, "H\u0329"},
+	{0x04A3, "\u029c\u0329"},
+	{0x04AA, "C\u0326"},
+	{0x04AB, "c\u0326"},
+	{0x04AC, "T\u0329"},
+	{0x04AD, "\u1d1b\u0329"},
+	{0x04AE, "Y"},
+	{0x04AF, "y"},
+	{0x04B0, "Y\u0335"},
+	{0x04B1, "y\u0335"},
+	{0x04B2, "X\u0329"},
+	{0x04BB, "h"},
+	{0x04BD, "e"},
+	{0x04BE, "\u04bc\u0328"},
+	{0x04BF, "e\u0328"},
+	{0x04C0, "l"},
+	{0x04C5, "\u0245\u0326"},
+	{0x04C6, "\u043b\u0326"},
+	{0x04C7, "H\u0326"},
+	{0x04C8, "\u029c\u0326"},
+	{0x04C9, "H\u0326"},
+	{0x04CA, "\u029c\u0326"},
+	{0x04CB, "\u04b6"},
+	{0x04CC, "\u04b7"},
+	{0x04CD, "M\u0326"},
+	{0x04CE, "\u028d\u0326"},
+	{0x04CF, "i"},
+	{0x04D4, "AE"},
+	{0x04D5, "ae"},
+	{0x04D8, "\u018f"},
+	{0x04D9, "\u01dd"},
+	{0x04E0, "3"},
+	{0x04E1, "\u021d"},
+	{0x04E8, "O\u0335"},
+	{0x04E9, "o\u0335"},
+	{0x0501, "d"},
+	{0x050A, "\u01f6"},
+	{0x050C, "G"},
+	{0x050D, "\u0262"},
+	{0x0510, "\u0190"},
+	{0x0511, "\ua793"},
+	{0x051B, "q"},
+	{0x051C, "W"},
+	{0x051D, "w"},
+	{0x053B, "\u12ae"},
+	{0x0544, "\u1206"},
+	{0x054A, "\u1323"},
+	{0x054C, "\u1261"},
+	{0x054D, "U"},
+	{0x054F, "S"},
+	{0x0553, "\u03a6"},
+	{0x0555, "O"},
+	{0x055A, "'"},
+	{0x055D, "'"},
+	{0x0561, "w"},
+	{0x0563, "q"},
+	{0x0566, "q"},
+	{0x056E, "\u1e9f"},
+	{0x0570, "h"},
+	{0x0575, "\u0237"},
+	{0x0578, "n"},
+	{0x057A, "\u0270"},
+	{0x057C, "n"},
+	{0x057D, "u"},
+	{0x0581, "g"},
+	{0x0584, "f"},
+	{0x0585, "o"},
+	{0x0587, "\u0565\u0582"},
+	{0x0589, ":"},
+	{0x059C, "\u0301"},
+	{0x059D, "\u0301"},
+	{0x05A4, "\u059a"},
+	{0x05A8, "\u0599"},
+	{0x05AD, "\u0596"},
+	{0x05AE, "\u0598"},
+	{0x05AF, "\u030a"},
+	{0x05B4, "\u0323"},
+	{0x05B9, "\u0307"},
+	{0x05BA, "\u0307"},
+	{0x05C0, "l"},
+	{0x05C1, "\u0307"},
+	{0x05C2, "\u0307"},
+	{0x05C3, ":"},
+	{0x05C4, "\u0307"},
+	{0x05C5, "\u0323"},
+	{0x05D5, "l"},
+	{0x05D8, "v"},
+	{0x05D9, "'"},
+	{0x05DF, "l"},
+	{0x05E1, "o"},
+	{0x05F0, "ll"},
+	{0x05F1, "l'"},
+	{0x05F2, "''"},
+	{0x05F3, "'"},
+	{0x05F4, "''"},
+	{0x0609, "\u00ba/\u2080\u2080"},
+	{0x060A, "\u00ba/\u2080\u2080\u2080"},
+	{0x060D, ","},
+	{0x060F, "\u0639"},
+	{0x0618, "\u0301"},
+	{0x0619, "\u0313"},
+	{0x061A, "\u0650"},
+	{0x0623, "l\u0674"},
+	{0x0624, "\u0648\u0674"},
+	{0x0625, "l\u0655"},
+	{0x0626, "\u0649\u0674"},
+	{0x0627, "l"},
+	{0x062B, "\u0649\u06db"},
+	{0x0634, "\u0633\u06db"},
+	{0x063D, "\u0649\u0302"},
+	{0x063F, "\u0649\u06db"},
+	{0x0647, "o"},
+	{0x064A, "\u0649"},
+	{0x064B, "\u030b"},
+	{0x064E, "\u0301"},
+	{0x064F, "\u0313"},
+	{0x0652, "\u030a"},
+	{0x0653, "\u0303"},
+	{0x0656, "\u0329"},
+	{0x0657, "\u0312"},
+	{0x0658, "\u0306"},
+	{0x0659, "\u0304"},
+	{0x065A, "\u0306"},
+	{0x065B, "\u0302"},
+	{0x065C, "\u0323"},
+	{0x065D, "\u0314"},
+	{0x065F, "\u0655"},
+	{0x0660, "."},
+	{0x0661, "l"},
+	{0x0665, "o"},
+	{0x0667, "V"},
+	{0x0668, "\u0245"},
+	{0x066A, "\u00ba/\u2080"},
+	{0x066B, ","},
+	{0x066C, "\u060c"},
+	{0x066D, "*"},
+	{0x066E, "\u0649"},
+	{0x066F, "\u06a1"},
+	{0x0672, "l\u0674"},
+	{0x0673, "l\u0655"},
+	{0x0675, "l\u0674"},
+	{0x0676, "\u0648\u0674"},
+	{0x0677, "\u0648\u0313\u0674"},
+	{0x0678, "\u0649\u0674"},
+	{0x0679, "\u0649\u0615"},
+	{0x067E, "\u0649\u06db"},
+	{0x0681, "\u062d\u0654"},
+	{0x0685, "\u062d\u06db"},
+	{0x0688, "\u062f\u0615"},
+	{0x068B, "\u068a\u0615"},
+	{0x068E, "\u062f\u06db"},
+	{0x0691, "\u0631\u0615"},
+	{0x0692, "\u0631\u0306"},
+	{0x0698, "\u0631\u06db"},
+	{0x069E, "\u0635\u06db"},
+	{0x069F, "\u0637\u06db"},
+	{0x06A4, "\u06a1\u06db"},
+	{0x06A7, "\u0641"},
+	{0x06A8, "\u06a1\u06db"},
+	{0x06A9, "\u0643"},
+	{0x06AA, "\u0643"},
+	{0x06AD, "\u0643\u06db"},
+	{0x06B4, "\u06af\u06db"},
+	{0x06B5, "\u0644\u0306"},
+	{0x06B7, "\u0644\u06db"},
+	{0x06BA, "\u0649"},
+	{0x06BB, "\u0649\u0615"},
+	{0x06BD, "\u0649\u06db"},
+	{0x06BE, "o"},
+	{0x06C1, "o"},
+	{0x06C2, "\u06d5\u0654"},
+	{0x06C3, "\u0629"},
+	{0x06C6, "\u0648\u0306"},
+	{0x06C7, "\u0648\u0313"},
+	{0x06C8, "\u0648\u0670"},
+	{0x06C9, "\u0648\u0302"},
+	{0x06CB, "\u0648\u06db"},
+	{0x06CC, "\u0649"},
+	{0x06CE, "\u0649\u0306"},
+	{0x06D0, "\u067b"},
+	{0x06D1, "\u0649\u06db"},
+	{0x06D2, "\u0649"},
+	{0x06D4, "-"},
+	{0x06D5, "o"},
+	{0x06DF, "\u030a"},
+	{0x06E8, "\u0306\u0307"},
+	{0x06EC, "\u0307"},
+	{0x06EE, "\u062f\u0302"},
+	{0x06EF, "\u0631\u0302"},
+	{0x06F0, "."},
+	{0x06F1, "l"},
+	{0x06F2, "\u0662"},
+	{0x06F3, "\u0663"},
+	{0x06F4, "\u0664"},
+	{0x06F5, "o"},
+	{0x06F6, "\u0666"},
+	{0x06F7, "V"},
+	{0x06F8, "\u0245"},
+	{0x06F9, "\u0669"},
+	{0x06FD, "\u0621\u0348"},
+	{0x06FE, "\u0645\u0348"},
+	{0x06FF, "o\u0302"},
+	{0x0701, "."},
+	{0x0702, "."},
+	{0x0703, ":"},
+	{0x0704, ":"},
+	{0x0740, "\u0307"},
+	{0x0741, "\u0307"},
+	{0x0742, "\u073c"},
+	{0x0747, "\u0301"},
+	{0x0751, "\u0628\u06db"},
+	{0x0756, "\u0649\u0306"},
+	{0x0762, "\u06ac"},
+	{0x0763, "\u0643\u06db"},
+	{0x0767, "\u0754"},
+	{0x0768, "\u0646\u0615"},
+	{0x0769, "\u0646\u0306"},
+	{0x076C, "\u0631\u0654"},
+	{0x0771, "\u0697\u0615"},
+	{0x0772, "\u062d\u0654"},
+	{0x077E, "\u0633\u0302"},
+	{0x07C0, "O"},
+	{0x07CA, "l"},
+	{0x07EB, "\u0304"},
+	{0x07ED, "\u0307"},
+	{0x07EE, "\u0302"},
+	{0x07F3, "\u0308"},
+	{0x07F4, "'"},
+	{0x07F5, "'"},
+	{0x07FA, "_"},
+	{0x08A1, "\u0628\u0654"},
+	{0x08A4, "\u06a2\u06db"},
+	{0x08A7, "\u0645\u06db"},
+	{0x08A8, "\u0649\u0654"},
+	{0x08A9, "\u0754"},
+	{0x08AE, "\u062f\u0324\u0323"},
+	{0x08AF, "\u0635\u0324\u0323"},
+	{0x08B0, "\u06af"},
+	{0x08B1, "\u0648"},
+	{0x08B2, "\u0632\u0302"},
+	{0x08B6, "\u0628\u06e2"},
+	{0x08B7, "\u0649\u06db\u06e2"},
+	{0x08B9, "\u0631\u0306\u0307"},
+	{0x08BA, "\u0649\u0306\u0307"},
+	{0x08BB, "\u06a1"},
+	{0x08BC, "\u06a1"},
+	{0x08BD, "\u0649"},
+	{0x08E5, "\u064c"},
+	{0x08E8, "\u064c"},
+	{0x08EA, "\u0307"},
+	{0x08EB, "\u0308"},
+	{0x08ED, "\u0323"},
+	{0x08EE, "\u0324"},
+	{0x08F0, "\u030b"},
+	{0x08F1, "\u064c"},
+	{0x08F2, "\u064d"},
+	{0x08F3, "\u0313"},
+	{0x08F8, "\u0350"},
+	{0x08F9, "\u0354"},
+	{0x08FA, "\u0355"},
+	{0x08FF, "\u0350"},
+	{0x0900, "\u0352"},
+	{0x0901, "\u0306\u0307"},
+	{0x0902, "\u0307"},
+	{0x0903, ":"},
+	{0x0904, "\u0905\u0946"},
+	{0x0906, "\u0905\u093e"},
+	{0x0908, "\u0930\u094d\u0907"},
+	{0x090D, "\u090f\u0945"},
+	{0x090E, "\u090f\u0946"},
+	{0x0910, "\u090f\u0947"},
+	{0x0911, "\u0905\u0949"},
+	{0x0912, "\u0905\u093e\u0946"},
+	{0x0913, "\u0905\u093e\u0947"},
+	{0x0914, "\u0905\u093e\u0948"},
+	{0x093C, "\u0323"},
+	{0x0952, "\u0331"},
+	{0x0953, "\u0300"},
+	{0x0954, "\u0301"},
+	{0x0965, "\u0964\u0964"},
+	{0x0966, "o"},
+	{0x0967, "\u0669"},
+	{0x097D, "?"},
+	{0x0981, "\u0306\u0307"},
+	{0x0986, "\u0985\u09be"},
+	{0x09BC, "\u0323"},
+	{0x09E0, "\u098b\u09c3"},
+	{0x09E1, "\u098b\u09c3"},
+	{0x09E6, "O"},
+	{0x09EA, "8"},
+	{0x09ED, "9"},
+	{0x0A02, "\u0307"},
+	{0x0A03, "\u0983"},
+	{0x0A06, "\u0a05\u0a3e"},
+	{0x0A07, "\u0a72\u0a3f"},
+	{0x0A08, "\u0a72\u0a40"},
+	{0x0A09, "\u0a73\u0a41"},
+	{0x0A0A, "\u0a73\u0a42"},
+	{0x0A0F, "\u0a72\u0a47"},
+	{0x0A10, "\u0a05\u0a48"},
+	{0x0A14, "\u0a05\u0a4c"},
+	{0x0A3C, "\u0323"},
+	{0x0A4B, "\u0946"},
+	{0x0A4D, "\u094d"},
+	{0x0A66, "o"},
+	{0x0A67, "9"},
+	{0x0A6A, "8"},
+	{0x0A81, "\u0306\u0307"},
+	{0x0A82, "\u0307"},
+	{0x0A83, ":"},
+	{0x0A86, "\u0a85\u0abe"},
+	{0x0A8D, "\u0a85\u0ac5"},
+	{0x0A8F, "\u0a85\u0ac7"},
+	{0x0A90, "\u0a85\u0ac8"},
+	{0x0A91, "\u0a85\u0abe\u0ac5"},
+	{0x0A93, "\u0a85\u0abe\u0ac7"},
+	{0x0A94, "\u0a85\u0abe\u0ac8"},
+	{0x0ABC, "\u0323"},
+	{0x0ABD, "\u093d"},
+	{0x0AC1, "\u0941"},
+	{0x0AC2, "\u0942"},
+	{0x0ACD, "\u094d"},
+	{0x0AE6, "o"},
+	{0x0AE8, "\u0968"},
+	{0x0AE9, "\u0969"},
+	{0x0AEA, "\u096a"},
+	{0x0AEE, "\u096e"},
+	{0x0AF0, "\u0970"},
+	{0x0B01, "\u0306\u0307"},
+	{0x0B03, "8"},
+	{0x0B06, "\u0b05\u0b3e"},
+	{0x0B20, "O"},
+	{0x0B3C, "\u0323"},
+	{0x0B66, "O"},
+	{0x0B68, "9"},
+	{0x0B82, "\u030a"},
+	{0x0B8A, "\u0b89\u0bb3"},
+	{0x0B9C, "\u0b90"},
+	{0x0BB0, "\u0b88"},
+	{0x0BBE, "\u0b88"},
+	{0x0BC8, "\u0ba9"},
+	{0x0BCA, "\u0bc6\u0b88"},
+	{0x0BCB, "\u0bc7\u0b88"},
+	{0x0BCC, "\u0bc6\u0bb3"},
+	{0x0BCD, "\u0307"},
+	{0x0BD7, "\u0bb3"},
+	{0x0BE6, "o"},
+	{0x0BE7, "\u0b95"},
+	{0x0BE8, "\u0b89"},
+	{0x0BEA, "\u0b9a"},
+	{0x0BEB, "\u0b88\u0bc1"},
+	{0x0BEC, "\u0b9a\u0bc1"},
+	{0x0BED, "\u0b8e"},
+	{0x0BEE, "\u0b85"},
+	{0x0BF0, "\u0baf"},
+	{0x0BF2, "\u0b9a\u0bc2"},
+	{0x0BF4, "\u0bae\u0bc0"},
+	{0x0BF5, "\u0bf3"},
+	{0x0BF7, "\u0b8e\u0bb5"},
+	{0x0BF8, "\u0bb7"},
+	{0x0BFA, "\u0ba8\u0bc0"},
+	{0x0C00, "\u0306\u0307"},
+	{0x0C02, "o"},
+	{0x0C03, "\u0983"},
+	{0x0C13, "\u0c12\u0c55"},
+	{0x0C14, "\u0c12\u0c4c"},
+	{0x0C20, "\u0c30\u05bc"},
+	{0x0C22, "\u0c21\u0323"},
+	{0x0C25, "\u0c27\u05bc"},
+	{0x0C2D, "\u0c2c\u0323"},
+	{0x0C2E, "\u0c35\u0c41"},
+	{0x0C37, "\u0c35\u0323"},
+	{0x0C39, "\u0c35\u0c3e"},
+	{0x0C42, "\u0c41\u0c3e"},
+	{0x0C44, "\u0c43\u0c3e"},
+	{0x0C60, "\u0c0b\u0c3e"},
+	{0x0C61, "\u0c0c\u0c3e"},
+	{0x0C66, "o"},
+	{0x0C81, "\u0306\u0307"},
+	{0x0C82, "o"},
+	{0x0C83, "\u0983"},
+	{0x0C85, "\u0c05"},
+	{0x0C86, "\u0c06"},
+	{0x0C87, "\u0c07"},
+	{0x0C92, "\u0c12"},
+	{0x0C93, "\u0c12\u0c55"},
+	{0x0C94, "\u0c12\u0c4c"},
+	{0x0C9C, "\u0c1c"},
+	{0x0C9E, "\u0c1e"},
+	{0x0CA3, "\u0c23"},
+	{0x0CAF, "\u0c2f"},
+	{0x0CB1, "\u0c31"},
+	{0x0CB2, "\u0c32"},
+	{0x0CE1, "\u0c8c\u0cbe"},
+	{0x0CE6, "o"},
+	{0x0CE7, "\u0c67"},
+	{0x0CE8, "\u0c68"},
+	{0x0CEF, "\u0c6f"},
+	{0x0D01, "\u0306\u0307"},
+	{0x0D02, "o"},
+	{0x0D03, "\u0983"},
+	{0x0D08, "\u0d07\u0d57"},
+	{0x0D09, "\u0b89"},
+	{0x0D0A, "\u0b89\u0d57"},
+	{0x0D0C, "\u0d28\u0d41"},
+	{0x0D10, "\u0d0e\u0d46"},
+	{0x0D13, "\u0d12\u0d3e"},
+	{0x0D14, "\u0d12\u0d57"},
+	{0x0D19, "\u0d28\u0d41"},
+	{0x0D1C, "\u0b90"},
+	{0x0D20, "o"},
+	{0x0D23, "\u0ba3"},
+	{0x0D31, "\u0d30"},
+	{0x0D34, "\u0bb4"},
+	{0x0D36, "\u0bb6"},
+	{0x0D3A, "\u0b9f\u0bbf"},
+	{0x0D3F, "\u0bbf"},
+	{0x0D40, "\u0bbf"},
+	{0x0D42, "\u0d41"},
+	{0x0D43, "\u0d41"},
+	{0x0D48, "\u0d46\u0d46"},
+	{0x0D4E, "\u0971"},
+	{0x0D5A, "\u0d28\u0d4d\u0d2e"},
+	{0x0D5F, "o\u0d30o"},
+	{0x0D61, "\u0d1e"},
+	{0x0D66, "o"},
+	{0x0D6A, "\u0d30\u0d4d"},
+	{0x0D6B, "\u0d26\u0d4d\u0d30"},
+	{0x0D6C, "\u0d28\u0d4d\u0d28"},
+	{0x0D6D, "9"},
+	{0x0D6E, "\u0d35\u0d4d\u0d30"},
+	{0x0D6F, "\u0d28\u0d4d"},
+	{0x0D76, "\u0d39\u0d4d\u0d2e"},
+	{0x0D79, "\u0d28\u0d41"},
+	{0x0D7B, "\u0d28\u0d4d"},
+	{0x0D7C, "\u0d30\u0d4d"},
+	{0x0D82, "o"},
+	{0x0D83, "\u0983"},
+	{0x0DE9, "\u0de8\u0dcf"},
+	{0x0DEA, "\u0da2"},
+	{0x0DEB, "\u0daf"},
+	{0x0DEF, "\u0de8\u0dd3"},
+	{0x0E03, "\u0e02"},
+	{0x0E0B, "\u0e0a"},
+	{0x0E0F, "\u0e0e"},
+	{0x0E14, "\u0e04"},
+	{0x0E15, "\u0e04"},
+	{0x0E17, "\u0e11"},
+	{0x0E21, "\u0e06"},
+	{0x0E26, "\u0e20"},
+	{0x0E33, "\u030a\u0e32"},
+	{0x0E41, "\u0e40\u0e40"},
+	{0x0E45, "\u0e32"},
+	{0x0E4D, "\u030a"},
+	{0x0E50, "o"},
+	{0x0E88, "\u0e08"},
+	{0x0E8D, "\u0e22"},
+	{0x0E9A, "\u0e1a"},
+	{0x0E9B, "\u0e1b"},
+	{0x0E9D, "\u0e1d"},
+	{0x0E9E, "\u0e1e"},
+	{0x0E9F, "\u0e1f"},
+	{0x0EB3, "\u030a\u0eb2"},
+	{0x0EB8, "\u0e38"},
+	{0x0EB9, "\u0e39"},
+	{0x0EC8, "\u0e48"},
+	{0x0EC9, "\u0e49"},
+	{0x0ECA, "\u0e4a"},
+	{0x0ECB, "\u0e4b"},
+	{0x0ECD, "\u030a"},
+	{0x0ED0, "o"},
+	{0x0EDC, "\u0eab\u0e99"},
+	{0x0EDD, "\u0eab\u0ea1"},
+	{0x0F00, "\u0f68\u0f7c\u0f7e"},
+	{0x0F02, "\u0f60\u0f74\u0f82\u0f7f"},
+	{0x0F03, "\u0f60\u0f74\u0f82\u0f14"},
+	{0x0F0C, "\u0f0b"},
+	{0x0F0E, "\u0f0d\u0f0d"},
+	{0x0F1B, "\u0f1a\u0f1a"},
+	{0x0F1E, "\u0f1d\u0f1d"},
+	{0x0F1F, "\u0f1a\u0f1d"},
+	{0x0F37, "\u0325"},
+	{0x0F6A, "\u0f62"},
+	{0x0F77, "\u0fb2\u0f71\u0f80"},
+	{0x0F79, "\u0fb3\u0f71\u0f80"},
+	{0x0FCE, "\u0f1d\u0f1a"},
+	{0x0FD5, "\u5350"},
+	{0x0FD6, "\u534d"},
+	{0x1000, "\u1002\u102c"},
+	{0x1010, "o\u102c"},
+	{0x101D, "o"},
+	{0x101F, "\u1015\u102c"},
+	{0x1029, "\u101e\u103c"},
+	{0x102A, "\u101e\u103c\u1031\u102c\u103a"},
+	{0x1036, "\u030a"},
+	{0x1038, "\u0983"},
+	{0x1040, "o"},
+	{0x104B, "\u104a\u104a"},
+	{0x1065, "\u1041"},
+	{0x1066, "\u1015\u103e"},
+	{0x106F, "\u1015\u102c\u103e"},
+	{0x1070, "\u1003\u103e"},
+	{0x107E, "\u107d\u103e"},
+	{0x1081, "\u1002\u103e"},
+	{0x109E, "\u1083\u030a"},
+	{0x10A0, "\ua786"},
+	{0x10E7, "y"},
+	{0x10F3, "\u021d"},
+	{0x10FF, "o"},
+	{0x1101, "\u1100\u1100"},
+	{0x1104, "\u1103\u1103"},
+	{0x1108, "\u1107\u1107"},
+	{0x110A, "\u1109\u1109"},
+	{0x110D, "\u110c\u110c"},
+	{0x1113, "\u1102\u1100"},
+	{0x1114, "\u1102\u1102"},
+	{0x1115, "\u1102\u1103"},
+	{0x1116, "\u1102\u1107"},
+	{0x1117, "\u1103\u1100"},
+	{0x1118, "\u1105\u1102"},
+	{0x1119, "\u1105\u1105"},
+	{0x111A, "\u1105\u1112"},
+	{0x111B, "\u1105\u110b"},
+	{0x111C, "\u1106\u1107"},
+	{0x111D, "\u1106\u110b"},
+	{0x111E, "\u1107\u1100"},
+	{0x111F, "\u1107\u1102"},
+	{0x1120, "\u1107\u1103"},
+	{0x1121, "\u1107\u1109"},
+	{0x1122, "\u1107\u1109\u1100"},
+	{0x1123, "\u1107\u1109\u1103"},
+	{0x1124, "\u1107\u1109\u1107"},
+	{0x1125, "\u1107\u1109\u1109"},
+	{0x1126, "\u1107\u1109\u110c"},
+	{0x1127, "\u1107\u110c"},
+	{0x1128, "\u1107\u110e"},
+	{0x1129, "\u1107\u1110"},
+	{0x112A, "\u1107\u1111"},
+	{0x112B, "\u1107\u110b"},
+	{0x112C, "\u1107\u1107\u110b"},
+	{0x112D, "\u1109\u1100"},
+	{0x112E, "\u1109\u1102"},
+	{0x112F, "\u1109\u1103"},
+	{0x1130, "\u1109\u1105"},
+	{0x1131, "\u1109\u1106"},
+	{0x1132, "\u1109\u1107"},
+	{0x1133, "\u1109\u1107\u1100"},
+	{0x1134, "\u1109\u1109\u1109"},
+	{0x1135, "\u1109\u110b"},
+	{0x1136, "\u1109\u110c"},
+	{0x1137, "\u1109\u110e"},
+	{0x1138, "\u1109\u110f"},
+	{0x1139, "\u1109\u1110"},
+	{0x113A, "\u1109\u1111"},
+	{0x113B, "\u1105\u1112"},
+	{0x113D, "\u113c\u113c"},
+	{0x113F, "\u113e\u113e"},
+	{0x1141, "\u110b\u1100"},
+	{0x1142, "\u110b\u1103"},
+	{0x1143, "\u110b\u1106"},
+	{0x1144, "\u110b\u1107"},
+	{0x1145, "\u110b\u1109"},
+	{0x1146, "\u110b\u1140"},
+	{0x1147, "\u110b\u110b"},
+	{0x1148, "\u110b\u110c"},
+	{0x1149, "\u110b\u110e"},
+	{0x114A, "\u110b\u1110"},
+	{0x114B, "\u110b\u1111"},
+	{0x114D, "\u110c\u110b"},
+	{0x114F, "\u114e\u114e"},
+	{0x1151, "\u1150\u1150"},
+	{0x1152, "\u110e\u110f"},
+	{0x1153, "\u110e\u1112"},
+	{0x1156, "\u1111\u1107"},
+	{0x1157, "\u1111\u110b"},
+	{0x1158, "\u1112\u1112"},
+	{0x115A, "\u1100\u1103"},
+	{0x115B, "\u1102\u1109"},
+	{0x115C, "\u1102\u110c"},
+	{0x115D, "\u1102\u1112"},
+	{0x115E, "\u1103\u1105"},
+	{0x1162, "\u1161\u4e28"},
+	{0x1164, "\u1163\u4e28"},
+	{0x1166, "\u1165\u4e28"},
+	{0x1168, "\u1167\u4e28"},
+	{0x116A, "\u1169\u1161"},
+	{0x116B, "\u1169\u1161\u4e28"},
+	{0x116C, "\u1169\u4e28"},
+	{0x116F, "\u116e\u1165"},
+	{0x1170, "\u116e\u1165\u4e28"},
+	{0x1171, "\u116e\u4e28"},
+	{0x1173, "\u30fc"},
+	{0x1174, "\u30fc\u4e28"},
+	{0x1175, "\u4e28"},
+	{0x1176, "\u1161\u1169"},
+	{0x1177, "\u1161\u116e"},
+	{0x1178, "\u1163\u1169"},
+	{0x1179, "\u1163\u116d"},
+	{0x117A, "\u1165\u1169"},
+	{0x117B, "\u1165\u116e"},
+	{0x117C, "\u1165\u30fc"},
+	{0x117D, "\u1167\u1169"},
+	{0x117E, "\u1167\u116e"},
+	{0x117F, "\u1169\u1165"},
+	{0x1180, "\u1169\u1165\u4e28"},
+	{0x1181, "\u1169\u1167\u4e28"},
+	{0x1182, "\u1169\u1169"},
+	{0x1183, "\u1169\u116e"},
+	{0x1184, "\u116d\u1163"},
+	{0x1185, "\u116d\u1163\u4e28"},
+	{0x1186, "\u116d\u1163"},
+	{0x1187, "\u116d\u1169"},
+	{0x1188, "\u116d\u4e28"},
+	{0x1189, "\u116e\u1161"},
+	{0x118A, "\u116e\u1161\u4e28"},
+	{0x118B, "\u116e\u1165\u30fc"},
+	{0x118C, "\u116e\u1167\u4e28"},
+	{0x118D, "\u116e\u116e"},
+	{0x118E, "\u1172\u1161"},
+	{0x118F, "\u1172\u1165"},
+	{0x1190, "\u1172\u1165\u4e28"},
+	{0x1191, "\u1172\u1167"},
+	{0x1192, "\u1172\u1167\u4e28"},
+	{0x1193, "\u1172\u116e"},
+	{0x1194, "\u1172\u4e28"},
+	{0x1195, "\u30fc\u116e"},
+	{0x1196, "\u30fc\u30fc"},
+	{0x1197, "\u30fc\u4e28\u116e"},
+	{0x1198, "\u4e28\u1161"},
+	{0x1199, "\u4e28\u1163"},
+	{0x119A, "\u4e28\u1169"},
+	{0x119B, "\u4e28\u116e"},
+	{0x119C, "\u4e28\u30fc"},
+	{0x119D, "\u4e28\u119e"},
+	{0x119F, "\u119e\u1165"},
+	{0x11A0, "\u119e\u116e"},
+	{0x11A1, "\u119e\u4e28"},
+	{0x11A2, "\u119e\u119e"},
+	{0x11A3, "\u1161\u30fc"},
+	{0x11A4, "\u1163\u116e"},
+	{0x11A5, "\u1167\u1163"},
+	{0x11A6, "\u1169\u1163"},
+	{0x11A7, "\u1169\u1163\u4e28"},
+	{0x11A8, "\u1100"},
+	{0x11A9, "\u1100\u1100"},
+	{0x11AA, "\u1100\u1109"},
+	{0x11AB, "\u1102"},
+	{0x11AC, "\u1102\u110c"},
+	{0x11AD, "\u1102\u1112"},
+	{0x11AE, "\u1103"},
+	{0x11AF, "\u1105"},
+	{0x11B0, "\u1105\u1100"},
+	{0x11B1, "\u1105\u1106"},
+	{0x11B2, "\u1105\u1107"},
+	{0x11B3, "\u1105\u1109"},
+	{0x11B4, "\u1105\u1110"},
+	{0x11B5, "\u1105\u1111"},
+	{0x11B6, "\u1105\u1112"},
+	{0x11B7, "\u1106"},
+	{0x11B8, "\u1107"},
+	{0x11B9, "\u1107\u1109"},
+	{0x11BA, "\u1109"},
+	{0x11BB, "\u1109\u1109"},
+	{0x11BC, "\u110b"},
+	{0x11BD, "\u110c"},
+	{0x11BE, "\u110e"},
+	{0x11BF, "\u110f"},
+	{0x11C0, "\u1110"},
+	{0x11C1, "\u1111"},
+	{0x11C2, "\u1112"},
+	{0x11C3, "\u1100\u1105"},
+	{0x11C4, "\u1100\u1109\u1100"},
+	{0x11C5, "\u1102\u1100"},
+	{0x11C6, "\u1102\u1103"},
+	{0x11C7, "\u1102\u1109"},
+	{0x11C8, "\u1102\u1140"},
+	{0x11C9, "\u1102\u1110"},
+	{0x11CA, "\u1103\u1100"},
+	{0x11CB, "\u1103\u1105"},
+	{0x11CC, "\u1105\u1100\u1109"},
+	{0x11CD, "\u1105\u1102"},
+	{0x11CE, "\u1105\u1103"},
+	{0x11CF, "\u1105\u1103\u1112"},
+	{0x11D0, "\u1105\u1105"},
+	{0x11D1, "\u1105\u1106\u1100"},
+	{0x11D2, "\u1105\u1106\u1109"},
+	{0x11D3, "\u1105\u1107\u1109"},
+	{0x11D4, "\u1105\u1107\u1112"},
+	{0x11D5, "\u1105\u1107\u110b"},
+	{0x11D6, "\u1105\u1109\u1109"},
+	{0x11D7, "\u1105\u1140"},
+	{0x11D8, "\u1105\u110f"},
+	{0x11D9, "\u1105\u1159"},
+	{0x11DA, "\u1106\u1100"},
+	{0x11DB, "\u1106\u1105"},
+	{0x11DC, "\u1106\u1107"},
+	{0x11DD, "\u1106\u1109"},
+	{0x11DE, "\u1106\u1109\u1109"},
+	{0x11DF, "\u1106\u1140"},
+	{0x11E0, "\u1106\u110e"},
+	{0x11E1, "\u1106\u1112"},
+	{0x11E2, "\u1106\u110b"},
+	{0x11E3, "\u1107\u1105"},
+	{0x11E4, "\u1107\u1111"},
+	{0x11E5, "\u1107\u1112"},
+	{0x11E6, "\u1107\u110b"},
+	{0x11E7, "\u1109\u1100"},
+	{0x11E8, "\u1109\u1103"},
+	{0x11E9, "\u1109\u1105"},
+	{0x11EA, "\u1109\u1107"},
+	{0x11EB, "\u1140"},
+	{0x11EC, "\u110b\u1100"},
+	{0x11ED, "\u110b\u1100\u1100"},
+	{0x11EE, "\u110b\u110b"},
+	{0x11EF, "\u110b\u110f"},
+	{0x11F0, "\u114c"},
+	{0x11F1, "\u110b\u1109"},
+	{0x11F2, "\u110b\u1140"},
+	{0x11F3, "\u1111\u1107"},
+	{0x11F4, "\u1111\u110b"},
+	{0x11F5, "\u1112\u1102"},
+	{0x11F6, "\u1112\u1105"},
+	{0x11F7, "\u1112\u1106"},
+	{0x11F8, "\u1112\u1107"},
+	{0x11F9, "\u1159"},
+	{0x11FA, "\u1100\u1102"},
+	{0x11FB, "\u1100\u1107"},
+	{0x11FC, "\u1100\u110e"},
+	{0x11FD, "\u1100\u110f"},
+	{0x11FE, "\u1100\u1112"},
+	{0x11FF, "\u1102\u1102"},
+	{0x1200, "U"},
+	{0x1223, "\u0270"},
+	{0x1240, "\u03a6"},
+	{0x1260, "\u0548"},
+	{0x1294, "\u0571"},
+	{0x12D0, "O"},
+	{0x13A0, "D"},
+	{0x13A1, "R"},
+	{0x13A2, "T"},
+	{0x13A4, "O'"},
+	{0x13A5, "i"},
+	{0x13A8, "\u2c75"},
+	{0x13A9, "Y"},
+	{0x13AA, "A"},
+	{0x13AB, "J"},
+	{0x13AC, "E"},
+	{0x13AE, "?"},
+	{0x13B0, "\u2c75"},
+	{0x13B1, "\u0393"},
+	{0x13B3, "W"},
+	{0x13B7, "M"},
+	{0x13BB, "H"},
+	{0x13BD, "Y"},
+	{0x13BE, "O\u0335"},
+	{0x13BF, "\u01ab"},
+	{0x13C0, "G"},
+	{0x13C2, "h"},
+	{0x13C3, "Z"},
+	{0x13C7, "\u0460"},
+	{0x13CB, "\u0190"},
+	{0x13CC, "U\u0335"},
+	{0x13CE, "4"},
+	{0x13CF, "b"},
+	{0x13D2, "R"},
+	{0x13D4, "W"},
+	{0x13D5, "S"},
+	{0x13D9, "V"},
+	{0x13DA, "S"},
+	{0x13DE, "L"},
+	{0x13DF, "C"},
+	{0x13E2, "P"},
+	{0x13E6, "K"},
+	{0x13E7, "d"},
+	{0x13EB, "O\u0335"},
+	{0x13EE, "6"},
+	{0x13F0, "\u00df"},
+	{0x13F2, "h\u0314"},
+	{0x13F3, "G"},
+	{0x13F4, "B"},
+	{0x13FB, "\u0262"},
+	{0x13FC, "\u0299"},
+	{0x1400, "="},
+	{0x1403, "\u0394"},
+	{0x140C, "\u00b7\u1401"},
+	{0x140D, "\u1401\u00b7"},
+	{0x140E, "\u00b7\u0394"},
+	{0x140F, "\u0394\u00b7"},
+	{0x1410, "\u00b7\u1404"},
+	{0x1411, "\u1404\u00b7"},
+	{0x1412, "\u00b7\u1405"},
+	{0x1413, "\u1405\u00b7"},
+	{0x1414, "\u00b7\u1406"},
+	{0x1415, "\u1406\u00b7"},
+	{0x1417, "\u00b7\u140a"},
+	{0x1418, "\u140a\u00b7"},
+	{0x1419, "\u00b7\u140b"},
+	{0x141A, "\u140b\u00b7"},
+	{0x1427, "\u00b7"},
+	{0x142B, "\u1401\u1420"},
+	{0x142C, "\u0394\u1420"},
+	{0x142D, "\u1405\u1420"},
+	{0x142E, "\u140a\u1420"},
+	{0x142F, "V"},
+	{0x1431, "\u0245"},
+	{0x1433, ">"},
+	{0x1437, "\u00b7>"},
+	{0x1438, "<"},
+	{0x143A, "\u00b7V"},
+	{0x143B, "V\u00b7"},
+	{0x143C, "\u00b7\u0245"},
+	{0x143D, "\u0245\u00b7"},
+	{0x143E, "\u00b7\u1432"},
+	{0x143F, "\u1432\u00b7"},
+	{0x1440, "\u00b7>"},
+	{0x1441, ">\u00b7"},
+	{0x1442, "\u00b7\u1434"},
+	{0x1443, "\u1434\u00b7"},
+	{0x1444, "\u00b7<"},
+	{0x1445, "<\u00b7"},
+	{0x1446, "\u00b7\u1439"},
+	{0x1447, "\u1439\u00b7"},
+	{0x144A, "'"},
+	{0x144C, "U"},
+	{0x144E, "\u0548"},
+	{0x1454, "\u00b7\u1450"},
+	{0x1457, "\u00b7U"},
+	{0x1458, "U\u00b7"},
+	{0x1459, "\u00b7\u0548"},
+	{0x145A, "\u0548\u00b7"},
+	{0x145B, "\u00b7\u144f"},
+	{0x145C, "\u144f\u00b7"},
+	{0x145D, "\u00b7\u1450"},
+	{0x145E, "\u1450\u00b7"},
+	{0x145F, "\u00b7\u1451"},
+	{0x1460, "\u1451\u00b7"},
+	{0x1461, "\u00b7\u1455"},
+	{0x1462, "\u1455\u00b7"},
+	{0x1463, "\u00b7\u1456"},
+	{0x1464, "\u1456\u00b7"},
+	{0x1467, "U'"},
+	{0x1468, "\u0548'"},
+	{0x1469, "\u1450'"},
+	{0x146A, "\u1455'"},
+	{0x146D, "P"},
+	{0x146F, "d"},
+	{0x1472, "b"},
+	{0x1473, "b\u0307"},
+	{0x1474, "\u00b7\u146b"},
+	{0x1475, "\u146b\u00b7"},
+	{0x1476, "\u00b7P"},
+	{0x1477, "p\u00b7"},
+	{0x1478, "\u00b7\u146e"},
+	{0x1479, "\u146e\u00b7"},
+	{0x147A, "\u00b7d"},
+	{0x147B, "d\u00b7"},
+	{0x147C, "\u00b7\u1470"},
+	{0x147D, "\u1470\u00b7"},
+	{0x147E, "\u00b7b"},
+	{0x147F, "b\u00b7"},
+	{0x1480, "\u00b7b\u0307"},
+	{0x1481, "b\u0307\u00b7"},
+	{0x1485, "\u146b'"},
+	{0x1486, "P'"},
+	{0x1487, "d'"},
+	{0x1488, "b'"},
+	{0x148D, "J"},
+	{0x1492, "\u00b7\u1489"},
+	{0x1493, "\u1489\u00b7"},
+	{0x1494, "\u00b7\u148b"},
+	{0x1495, "\u148b\u00b7"},
+	{0x1496, "\u00b7\u148c"},
+	{0x1497, "\u148c\u00b7"},
+	{0x1498, "\u00b7J"},
+	{0x1499, "J\u00b7"},
+	{0x149A, "\u00b7\u148e"},
+	{0x149B, "\u148e\u00b7"},
+	{0x149C, "\u00b7\u1490"},
+	{0x149D, "\u1490\u00b7"},
+	{0x149E, "\u00b7\u1491"},
+	{0x149F, "\u1491\u00b7"},
+	{0x14A5, "\u0393"},
+	{0x14AA, "L"},
+	{0x14AC, "\u00b7\u14a3"},
+	{0x14AD, "\u14a3\u00b7"},
+	{0x14AE, "\u00b7\u0393"},
+	{0x14AF, "\u0393\u00b7"},
+	{0x14B0, "\u00b7\u14a6"},
+	{0x14B1, "\u14a6\u00b7"},
+	{0x14B2, "\u00b7\u14a7"},
+	{0x14B3, "\u14a7\u00b7"},
+	{0x14B4, "\u00b7\u14a8"},
+	{0x14B5, "\u14a8\u00b7"},
+	{0x14B6, "\u00b7L"},
+	{0x14B7, "l\u00b7"},
+	{0x14B8, "\u00b7\u14ab"},
+	{0x14B9, "\u14ab\u00b7"},
+	{0x14BF, "2"},
+	{0x14C9, "\u00b7\u14c0"},
+	{0x14CA, "\u14c0\u00b7"},
+	{0x14CB, "\u00b7\u14c7"},
+	{0x14CC, "\u14c7\u00b7"},
+	{0x14CD, "\u00b7\u14c8"},
+	{0x14CE, "\u14c8\u00b7"},
+	{0x14D1, "\u1421"},
+	{0x14DC, "\u00b7\u14d3"},
+	{0x14DD, "\u14d3\u00b7"},
+	{0x14DE, "\u00b7\u14d5"},
+	{0x14DF, "\u14d5\u00b7"},
+	{0x14E0, "\u00b7\u14d6"},
+	{0x14E1, "\u14d6\u00b7"},
+	{0x14E2, "\u00b7\u14d7"},
+	{0x14E3, "\u14d7\u00b7"},
+	{0x14E4, "\u00b7\u14d8"},
+	{0x14E5, "\u14d8\u00b7"},
+	{0x14E6, "\u00b7\u14da"},
+	{0x14E7, "\u14da\u00b7"},
+	{0x14E8, "\u00b7\u14db"},
+	{0x14E9, "\u14db\u00b7"},
+	{0x14F6, "\u00b7\u14ed"},
+	{0x14F7, "\u14ed\u00b7"},
+	{0x14F8, "\u00b7\u14ef"},
+	{0x14F9, "\u14ef\u00b7"},
+	{0x14FA, "\u00b7\u14f0"},
+	{0x14FB, "\u14f0\u00b7"},
+	{0x14FC, "\u00b7\u14f1"},
+	{0x14FD, "\u14f1\u00b7"},
+	{0x14FE, "\u00b7\u14f2"},
+	{0x14FF, "\u14f2\u00b7"},
+	{0x1500, "\u00b7\u14f4"},
+	{0x1501, "\u14f4\u00b7"},
+	{0x1502, "\u00b7\u14f5"},
+	{0x1503, "\u14f5\u00b7"},
+	{0x150C, "\u150b<"},
+	{0x150D, "\u150b\u1455"},
+	{0x150E, "\u150bb"},
+	{0x150F, "\u150b\u1490"},
+	{0x1517, "\u00b7\u1510"},
+	{0x1518, "\u1510\u00b7"},
+	{0x1519, "\u00b7\u1511"},
+	{0x151A, "\u1511\u00b7"},
+	{0x151B, "\u00b7\u1512"},
+	{0x151C, "\u1512\u00b7"},
+	{0x151D, "\u00b7\u1513"},
+	{0x151E, "\u1513\u00b7"},
+	{0x151F, "\u00b7\u1514"},
+	{0x1520, "\u1514\u00b7"},
+	{0x1521, "\u00b7\u1515"},
+	{0x1522, "\u1515\u00b7"},
+	{0x1523, "\u00b7\u1516"},
+	{0x1524, "\u1516\u00b7"},
+	{0x152F, "\u00b74"},
+	{0x1530, "4\u00b7"},
+	{0x1531, "\u00b7\u1528"},
+	{0x1532, "\u1528\u00b7"},
+	{0x1533, "\u00b7\u1529"},
+	{0x1534, "\u1529\u00b7"},
+	{0x1535, "\u00b7\u152a"},
+	{0x1536, "\u152a\u00b7"},
+	{0x1537, "\u00b7\u152b"},
+	{0x1538, "\u152b\u00b7"},
+	{0x1539, "\u00b7\u152d"},
+	{0x153A, "\u152d\u00b7"},
+	{0x153B, "\u00b7\u152e"},
+	{0x153C, "\u152e\u00b7"},
+	{0x1540, "\u1429"},
+	{0x1541, "x"},
+	{0x154E, "\u00b7\u154c"},
+	{0x154F, "\u154c\u00b7"},
+	{0x155B, "\u00b7\u155a"},
+	{0x155C, "\u155a\u00b7"},
+	{0x1568, "\u00b7\u1567"},
+	{0x1569, "\u1567\u00b7"},
+	{0x1577, "\u1e9f"},
+	{0x157C, "H"},
+	{0x157D, "x"},
+	{0x157E, "\u1550\u146c"},
+	{0x157F, "\u1550P"},
+	{0x1580, "\u1550\u146e"},
+	{0x1581, "\u1550d"},
+	{0x1582, "\u1550\u1470"},
+	{0x1583, "\u1550b"},
+	{0x1584, "\u1550b\u0307"},
+	{0x1585, "\u1550\u1483"},
+	{0x1587, "R"},
+	{0x158E, "\u1595\u148a"},
+	{0x158F, "\u1595\u148b"},
+	{0x1590, "\u1595\u148c"},
+	{0x1591, "\u1595J"},
+	{0x1592, "\u1595\u148e"},
+	{0x1593, "\u1595\u1490"},
+	{0x1594, "\u1595\u1491"},
+	{0x15AF, "b"},
+	{0x15B4, "F"},
+	{0x15B5, "\u2132"},
+	{0x15B7, "\ua7fb"},
+	{0x15C4, "\u2c6f"},
+	{0x15C5, "A"},
+	{0x15DE, "D"},
+	{0x15EA, "D"},
+	{0x15EF, "\u0460"},
+	{0x15F0, "M"},
+	{0x15F7, "B"},
+	{0x1602, "\u1490"},
+	{0x1603, "\u1489"},
+	{0x1604, "\u14d3"},
+	{0x1607, "\u14da"},
+	{0x1622, "\u1543"},
+	{0x1623, "\u1546"},
+	{0x1624, "\u154a"},
+	{0x162E, "\u01b1"},
+	{0x162F, "\u03a9"},
+	{0x1634, "\u01b1"},
+	{0x1635, "\u03a9"},
+	{0x166D, "X"},
+	{0x166E, "x"},
+	{0x166F, "\u1550\u146b"},
+	{0x1670, "\u1595\u1489"},
+	{0x1671, "\u1596\u148b"},
+	{0x1672, "\u1596\u148c"},
+	{0x1673, "\u1596J"},
+	{0x1674, "\u1596\u148e"},
+	{0x1675, "\u1596\u1490"},
+	{0x1676, "\u1596\u1491"},
+	{0x1677, "\u15a7\u00b7"},
+	{0x1678, "\u15a8\u00b7"},
+	{0x1679, "\u15a9\u00b7"},
+	{0x167A, "\u15aa\u00b7"},
+	{0x167B, "\u15ab\u00b7"},
+	{0x167C, "\u15ac\u00b7"},
+	{0x167D, "\u15ad\u00b7"},
+	{0x1680, " "},
+	{0x16B2, "<"},
+	{0x16B7, "X"},
+	{0x16C1, "l"},
+	{0x16C2, "\u16bd"},
+	{0x16CC, "'"},
+	{0x16D5, "K"},
+	{0x16D6, "M"},
+	{0x16D8, "\u03a8"},
+	{0x16E1, "\u16bc"},
+	{0x16EB, "\u00b7"},
+	{0x16EC, ":"},
+	{0x16ED, "+"},
+	{0x16F0, "\u03a6"},
+	{0x1735, "/"},
+	{0x17A3, "\u17a2"},
+	{0x17B7, "\u0e34"},
+	{0x17B8, "\u0e35"},
+	{0x17B9, "\u0e36"},
+	{0x17BA, "\u0e37"},
+	{0x17C6, "\u030a"},
+	{0x17CB, "\u0e48"},
+	{0x17D3, "\u030a"},
+	{0x17D4, "\u0e2f"},
+	{0x17D5, "\u0e5a"},
+	{0x17D9, "\u0e4f"},
+	{0x17DA, "\u0e5b"},
+	{0x1803, ":"},
+	{0x1809, ":"},
+	{0x1855, "\u1835"},
+	{0x1896, "\u185c"},
+	{0x18B3, "\u00b7\u18b1"},
+	{0x18B6, "\u00b7\u18b4"},
+	{0x18B9, "\u00b7\u18b8"},
+	{0x18C2, "\u00b7\u18c0"},
+	{0x18C6, "\u00b7\u14c2"},
+	{0x18C7, "\u14c2\u00b7"},
+	{0x18C8, "\u00b7\u14c3"},
+	{0x18C9, "\u14c3\u00b7"},
+	{0x18CA, "\u00b7\u14c4"},
+	{0x18CB, "\u14c4\u00b7"},
+	{0x18CC, "\u00b7\u14c5"},
+	{0x18CD, "\u14c5\u00b7"},
+	{0x18CE, "\u00b7\u1543"},
+	{0x18CF, "\u00b7\u1546"},
+	{0x18D0, "\u00b7\u1547"},
+	{0x18D1, "\u00b7\u1548"},
+	{0x18D2, "\u00b7\u1549"},
+	{0x18D3, "\u00b7\u154b"},
+	{0x18DB, "\u18f5"},
+	{0x18DC, "\u18df\u141e"},
+	{0x18DD, "\u141e\u18df"},
+	{0x18E0, "\u1543\u00b7"},
+	{0x18E3, "\u155e\u00b7"},
+	{0x18E4, "\u1566\u00b7"},
+	{0x18E5, "\u156b\u00b7"},
+	{0x18E8, "\u1586\u00b7"},
+	{0x18EA, "\u1597\u00b7"},
+	{0x18ED, "\u0460\u00b7"},
+	{0x18F0, "\u15f4\u00b7"},
+	{0x18F2, "\u161b\u00b7"},
+	{0x19D0, "\u199e"},
+	{0x19D1, "\u19b1"},
+	{0x1A80, "\u1a45"},
+	{0x1A90, "\u1a45"},
+	{0x1AA9, "\u1aa8\u1aa8"},
+	{0x1AAB, "\u1aaa\u1aa8"},
+	{0x1AB4, "\u06db"},
+	{0x1AB7, "\u0328"},
+	{0x1B52, "\u1b0d"},
+	{0x1B53, "\u1b11"},
+	{0x1B58, "\u1b28"},
+	{0x1B5C, "\u1b50"},
+	{0x1B5F, "\u1b5e\u1b5e"},
+	{0x1C3C, "\u1c3b\u1c3b"},
+	{0x1C7F, "\u1c7e\u1c7e"},
+	{0x1CD0, "\u0302"},
+	{0x1CD2, "\u0304"},
+	{0x1CD3, "''"},
+	{0x1CD5, "\u032b"},
+	{0x1CD8, "\u032e"},
+	{0x1CD9, "\u032d"},
+	{0x1CDA, "\u030e"},
+	{0x1CDC, "\u0329"},
+	{0x1CDD, "\u0323"},
+	{0x1CDE, "\u0324"},
+	{0x1CED, "\u0316"},
+	{0x1D04, "c"},
+	{0x1D08, "\u025c"},
+	{0x1D0B, "\u0138"},
+	{0x1D0D, "\u028d"},
+	{0x1D0F, "o"},
+	{0x1D10, "\u0254"},
+	{0x1D11, "o"},
+	{0x1D14, "\u01ddo"},
+	{0x1D1C, "u"},
+	{0x1D20, "v"},
+	{0x1D21, "w"},
+	{0x1D22, "z"},
+	{0x1D24, "\u01a8"},
+	{0x1D26, "r"},
+	{0x1D27, "\u028c"},
+	{0x1D28, "\u03c0"},
+	{0x1D29, "\u1d18"},
+	{0x1D2B, "\u043b"},
+	{0x1D3E, "\u18d6"},
+	{0x1D52, "\u00ba"},
+	{0x1D6B, "ue"},
+	{0x1D6E, "f\u0334"},
+	{0x1D6F, "rn\u0334"},
+	{0x1D70, "n\u0334"},
+	{0x1D72, "r\u0334"},
+	{0x1D73, "\u027e\u0334"},
+	{0x1D74, "s\u0334"},
+	{0x1D75, "t\u0334"},
+	{0x1D76, "z\u0334"},
+	{0x1D78, "\u1d34"},
+	{0x1D7B, "i\u0335"},
+	{0x1D7C, "i\u0335"},
+	{0x1D7D, "p\u0335"},
+	{0x1D7E, "u\u0335"},
+	{0x1D7F, "\u028a\u0335"},
+	{0x1D83, "g"},
+	{0x1D8C, "y"},
+	{0x1D90, "\u024b"},
+	{0x1D9F, "\u1d4b"},
+	{0x1DA2, "\u1d4d"},
+	{0x1DBA, "\u18d4"},
+	{0x1DBB, "\u1646"},
+	{0x1DEE, "\u2dec"},
+	{0x1E43, "\uab51"},
+	{0x1E9A, "a\u0309"},
+	{0x1E9D, "f"},
+	{0x1EFF, "y"},
+	{0x1F7D, "\u03c9\u0301\u0345"},
+	{0x1FBD, "'"},
+	{0x1FBE, "i"},
+	{0x1FBF, "'"},
+	{0x1FC0, "~"},
+	{0x1FEF, "'"},
+	{0x1FF6, "\u13ef"},
+	{0x1FFD, "'"},
+	{0x1FFE, "'"},
+	{0x2000, " "},
+	{0x2001, " "},
+	{0x2002, " "},
+	{0x2003, " "},
+	{0x2004, " "},
+	{0x2005, " "},
+	{0x2006, " "},
+	{0x2007, " "},
+	{0x2008, " "},
+	{0x2009, " "},
+	{0x200A, " "},
+	{0x2010, "-"},
+	{0x2011, "-"},
+	{0x2012, "-"},
+	{0x2013, "-"},
+	{0x2014, "\u30fc"},
+	{0x2015, "\u30fc"},
+	{0x2016, "ll"},
+	{0x2018, "'"},
+	{0x2019, "'"},
+	{0x201A, ","},
+	{0x201B, "'"},
+	{0x201C, "''"},
+	{0x201D, "''"},
+	{0x201F, "''"},
+	{0x2022, "\u00b7"},
+	{0x2024, "."},
+	{0x2025, ".."},
+	{0x2026, "..."},
+	{0x2027, "\u00b7"},
+	{0x2028, " "},
+	{0x2029, " "},
+	{0x202F, " "},
+	{0x2030, "\u00ba/\u2080\u2080"},
+	{0x2031, "\u00ba/\u2080\u2080\u2080"},
+	{0x2032, "'"},
+	{0x2033, "''"},
+	{0x2034, "'''"},
+	{0x2035, "'"},
+	{0x2036, "''"},
+	{0x2037, "'''"},
+	{0x2039, "<"},
+	{0x203A, ">"},
+	{0x203C, "!!"},
+	{0x203E, "\u02c9"},
+	{0x2041, "/"},
+	{0x2043, "-"},
+	{0x2044, "/"},
+	{0x2047, "??"},
+	{0x2048, "?!"},
+	{0x2049, "!?"},
+	{0x204E, "*"},
+	{0x2052, "\u00ba/\u2080"},
+	{0x2053, "~"},
+	{0x2057, "''''"},
+	{0x205A, ":"},
+	{0x205D, "\u2d57"},
+	{0x205E, "\u2d42"},
+	{0x205F, " "},
+	{0x2070, "\u00ba"},
+	{0x2079, "\ua770"},
+	{0x20A1, "C\u20eb"},
+	{0x20A4, "\u00a3"},
+	{0x20A5, "rn\u0338"},
+	{0x20A8, "Rs"},
+	{0x20A9, "W\u0335"},
+	{0x20AB, "d\u0335\u0331"},
+	{0x20AC, "\ua792"},
+	{0x20AD, "K\u0335"},
+	{0x20AE, "T\u20eb"},
+	{0x20B6, "lt"},
+	{0x20BD, "\u0554"},
+	{0x20DB, "\u06db"},
+	{0x2100, "a/c"},
+	{0x2101, "a/s"},
+	{0x2102, "C"},
+	{0x2103, "\u00b0C"},
+	{0x2105, "c/o"},
+	{0x2106, "c/u"},
+	{0x2107, "\u0190"},
+	{0x2108, "\u042d"},
+	{0x2109, "\u00b0F"},
+	{0x210A, "g"},
+	{0x210B, "H"},
+	{0x210C, "H"},
+	{0x210D, "H"},
+	{0x210E, "h"},
+	{0x210F, "h\u0335"},
+	{0x2110, "l"},
+	{0x2111, "l"},
+	{0x2112, "L"},
+	{0x2113, "l"},
+	{0x2115, "N"},
+	{0x2116, "No"},
+	{0x2119, "P"},
+	{0x211A, "Q"},
+	{0x211B, "R"},
+	{0x211C, "R"},
+	{0x211D, "R"},
+	{0x2121, "TEL"},
+	{0x2124, "Z"},
+	{0x2126, "\u03a9"},
+	{0x2127, "\u01b1"},
+	{0x2128, "Z"},
+	{0x2129, "\u027f"},
+	{0x212A, "K"},
+	{0x212C, "B"},
+	{0x212D, "C"},
+	{0x212E, "e"},
+	{0x212F, "e"},
+	{0x2130, "E"},
+	{0x2131, "F"},
+	{0x2133, "M"},
+	{0x2134, "o"},
+	{0x2135, "\u05d0"},
+	{0x2136, "\u05d1"},
+	{0x2137, "\u05d2"},
+	{0x2138, "\u05d3"},
+	{0x2139, "i"},
+	{0x213B, "FAX"},
+	{0x213C, "\u03c0"},
+	{0x213D, "y"},
+	{0x213E, "\u0393"},
+	{0x213F, "\u03a0"},
+	{0x2140, "\u01a9"},
+	{0x2141, "\ua4e8"},
+	{0x2142, "\ua4f6"},
+	{0x2143, "\U00016f00"},
+	{0x2145, "D"},
+	{0x2146, "d"},
+	{0x2147, "e"},
+	{0x2148, "i"},
+	{0x2149, "j"},
+	{0x2160, "l"},
+	{0x2161, "ll"},
+	{0x2162, "lll"},
+	{0x2163, "lV"},
+	{0x2164, "V"},
+	{0x2165, "Vl"},
+	{0x2166, "Vll"},
+	{0x2167, "Vlll"},
+	{0x2168, "lX"},
+	{0x2169, "X"},
+	{0x216A, "Xl"},
+	{0x216B, "Xll"},
+	{0x216C, "L"},
+	{0x216D, "C"},
+	{0x216E, "D"},
+	{0x216F, "M"},
+	{0x2170, "i"},
+	{0x2171, "ii"},
+	{0x2172, "iii"},
+	{0x2173, "iv"},
+	{0x2174, "v"},
+	{0x2175, "vi"},
+	{0x2176, "vii"},
+	{0x2177, "viii"},
+	{0x2178, "ix"},
+	{0x2179, "x"},
+	{0x217A, "xi"},
+	{0x217B, "xii"},
+	{0x217C, "l"},
+	{0x217D, "c"},
+	{0x217E, "d"},
+	{0x217F, "rn"},
+	{0x2183, "\u0186"},
+	{0x2184, "\u0254"},
+	{0x2191, "\u16cf"},
+	{0x2195, "\u16e8"},
+	{0x21B5, "\u21b2"},
+	{0x21BA, "\U0001f10e"},
+	{0x21BE, "\u16da"},
+	{0x21BF, "\u16d0"},
+	{0x2200, "\u2c6f"},
+	{0x2203, "\u018e"},
+	{0x2206, "\u0394"},
+	{0x220F, "\u03a0"},
+	{0x2211, "\u01a9"},
+	{0x2212, "-"},
+	{0x2214, "+\u0307"},
+	{0x2215, "/"},
+	{0x2216, "\\"},
+	{0x2217, "*"},
+	{0x2218, "\u00b0"},
+	{0x2219, "\u00b7"},
+	{0x221E, "oo"},
+	{0x2223, "l"},
+	{0x2225, "ll"},
+	{0x2228, "v"},
+	{0x2229, "\u0548"},
+	{0x222A, "U"},
+	{0x222B, "\u0283"},
+	{0x222C, "\u0283\u0283"},
+	{0x222D, "\u0283\u0283\u0283"},
+	{0x222F, "\u222e\u222e"},
+	{0x2230, "\u222e\u222e\u222e"},
+	{0x2236, ":"},
+	{0x2238, "-\u0307"},
+	{0x223C, "~"},
+	{0x2250, "=\u0307"},
+	{0x2251, "=\u0323\u0307"},
+	{0x2257, "=\u030a"},
+	{0x2259, "=\u0302"},
+	{0x225A, "=\u0306"},
+	{0x225E, "=\u036b"},
+	{0x2263, "\u2261"},
+	{0x226A, "<<"},
+	{0x226B, ">>"},
+	{0x2282, "\u1455"},
+	{0x2283, "\u1450"},
+	{0x2295, "\U000102a8"},
+	{0x2296, "O\u0335"},
+	{0x2299, "\u0298"},
+	{0x229D, "O\u0335"},
+	{0x22A4, "T"},
+	{0x22A5, "\ua4d5"},
+	{0x22C0, "\u2227"},
+	{0x22C1, "v"},
+	{0x22C2, "\u0548"},
+	{0x22C3, "U"},
+	{0x22C4, "\u16dc"},
+	{0x22C5, "\u00b7"},
+	{0x22C8, "\u16de"},
+	{0x22D6, "<\u00b7"},
+	{0x22D7, "\u00b7>"},
+	{0x22D8, "<<<"},
+	{0x22D9, ">>>"},
+	{0x22EE, "\u2d57"},
+	{0x22EF, "\u00b7\u00b7\u00b7"},
+	{0x22F4, "\ua793"},
+	{0x22FF, "E"},
+	{0x2300, "\u2205"},
+	{0x2325, "\u2324"},
+	{0x2329, "\u276c"},
+	{0x232A, "\u276d"},
+	{0x2341, "\u303c"},
+	{0x2359, "\u0394\u0332"},
+	{0x235A, "\u16dc\u0332"},
+	{0x235C, "\u00b0\u0332"},
+	{0x235F, "\u229b"},
+	{0x2361, "T\u0308"},
+	{0x2362, "\u2207\u0308"},
+	{0x2363, "\u22c6\u0308"},
+	{0x2364, "\u00b0\u0308"},
+	{0x2365, "\u0629"},
+	{0x2368, "~\u0308"},
+	{0x2369, "\u1435"},
+	{0x236B, "\u2207\u0334"},
+	{0x236C, "O\u0335"},
+	{0x2373, "i"},
+	{0x2374, "p"},
+	{0x2375, "\u03c9"},
+	{0x2376, "a\u0332"},
+	{0x2377, "\ua793\u0332"},
+	{0x2378, "i\u0332"},
+	{0x2379, "\u03c9\u0332"},
+	{0x237A, "a"},
+	{0x237F, "\u16bd"},
+	{0x239C, "\u4e28"},
+	{0x239F, "\u4e28"},
+	{0x23A2, "\u4e28"},
+	{0x23A5, "\u4e28"},
+	{0x23AA, "\u4e28"},
+	{0x23AE, "\u4e28"},
+	{0x23C1, "\u2355"},
+	{0x23C2, "\u234e"},
+	{0x23C3, "\u234b"},
+	{0x23C6, "\u236d"},
+	{0x23E8, "\u2081\u2080"},
+	{0x23FC, "\u23fb"},
+	{0x23FD, "l"},
+	{0x23FE, "\u263e"},
+	{0x244A, "\\\\"},
+	{0x2460, "\u2780"},
+	{0x2461, "\u2781"},
+	{0x2462, "\u2782"},
+	{0x2463, "\u2783"},
+	{0x2464, "\u2784"},
+	{0x2465, "\u2785"},
+	{0x2466, "\u2786"},
+	{0x2467, "\u2787"},
+	{0x2468, "\u2788"},
+	{0x2469, "\u2789"},
+	{0x2474, "(l)"},
+	{0x2475, "(2)"},
+	{0x2476, "(3)"},
+	{0x2477, "(4)"},
+	{0x2478, "(5)"},
+	{0x2479, "(6)"},
+	{0x247A, "(7)"},
+	{0x247B, "(8)"},
+	{0x247C, "(9)"},
+	{0x247D, "(lO)"},
+	{0x247E, "(ll)"},
+	{0x247F, "(l2)"},
+	{0x2480, "(l3)"},
+	{0x2481, "(l4)"},
+	{0x2482, "(l5)"},
+	{0x2483, "(l6)"},
+	{0x2484, "(l7)"},
+	{0x2485, "(l8)"},
+	{0x2486, "(l9)"},
+	{0x2487, "(2O)"},
+	{0x2488, "l."},
+	{0x2489, "2."},
+	{0x248A, "3."},
+	{0x248B, "4."},
+	{0x248C, "5."},
+	{0x248D, "6."},
+	{0x248E, "7."},
+	{0x248F, "8."},
+	{0x2490, "9."},
+	{0x2491, "lO."},
+	{0x2492, "ll."},
+	{0x2493, "l2."},
+	{0x2494, "l3."},
+	{0x2495, "l4."},
+	{0x2496, "l5."},
+	{0x2497, "l6."},
+	{0x2498, "l7."},
+	{0x2499, "l8."},
+	{0x249A, "l9."},
+	{0x249B, "2O."},
+	{0x249C, "(a)"},
+	{0x249D, "(b)"},
+	{0x249E, "(c)"},
+	{0x249F, "(d)"},
+	{0x24A0, "(e)"},
+	{0x24A1, "(f)"},
+	{0x24A2, "(g)"},
+	{0x24A3, "(h)"},
+	{0x24A4, "(i)"},
+	{0x24A5, "(j)"},
+	{0x24A6, "(k)"},
+	{0x24A7, "(l)"},
+	{0x24A8, "(rn)"},
+	{0x24A9, "(n)"},
+	{0x24AA, "(o)"},
+	{0x24AB, "(p)"},
+	{0x24AC, "(q)"},
+	{0x24AD, "(r)"},
+	{0x24AE, "(s)"},
+	{0x24AF, "(t)"},
+	{0x24B0, "(u)"},
+	{0x24B1, "(v)"},
+	{0x24B2, "(w)"},
+	{0x24B3, "(x)"},
+	{0x24B4, "(y)"},
+	{0x24B5, "(z)"},
+	{0x24B8, "\u00a9"},
+	{0x24C5, "\u2117"},
+	{0x24C7, "\u00ae"},
+	{0x24DB, "\u24be"},
+	{0x24EA, "\U0001f10d"},
+	{0x2500, "\u30fc"},
+	{0x2501, "\u30fc"},
+	{0x2503, "\u2502"},
+	{0x250F, "\u250c"},
+	{0x2523, "\u251c"},
+	{0x2571, "/"},
+	{0x2573, "X"},
+	{0x2588, "\u220e"},
+	{0x2590, "\u258c"},
+	{0x2594, "\u02c9"},
+	{0x2597, "\u2596"},
+	{0x259D, "\u2598"},
+	{0x25A0, "\u220e"},
+	{0x25B1, "\u23e5"},
+	{0x25B3, "\u0394"},
+	{0x25B7, "\u22b3"},
+	{0x25B8, "\u25b6"},
+	{0x25BA, "\u25b6"},
+	{0x25BD, "\U000102bc"},
+	{0x25C1, "\u22b2"},
+	{0x25C7, "\u16dc"},
+	{0x25CA, "\u16dc"},
+	{0x25CB, "\u00b0"},
+	{0x25CE, "\u233e"},
+	{0x25E0, "\u2312"},
+	{0x25E6, "\u00b0"},
+	{0x2609, "\u0298"},
+	{0x2610, "\u25a1"},
+	{0x2625, "\U0001099e"},
+	{0x2630, "\u2cb6"},
+	{0x2638, "\u2388"},
+	{0x264E, "\u224f"},
+	{0x2662, "\u16dc"},
+	{0x2669, "\U0001d158\U0001d165"},
+	{0x266A, "\U0001d158\U0001d165\U0001d16e"},
+	{0x26AC, "\u0970"},
+	{0x2768, "("},
+	{0x2769, ")"},
+	{0x276E, "<"},
+	{0x276F, ">"},
+	{0x2772, "("},
+	{0x2773, ")"},
+	{0x2774, "{"},
+	{0x2775, "}"},
+	{0x2795, "+"},
+	{0x2796, "-"},
+	{0x2797, "\u00f7"},
+	{0x27C2, "\ua4d5"},
+	{0x27C8, "\\\u1455"},
+	{0x27C9, "\u1450/"},
+	{0x27CB, "/"},
+	{0x27CD, "\\"},
+	{0x27D9, "T"},
+	{0x27E8, "\u276c"},
+	{0x27E9, "\u276d"},
+	{0x292B, "x"},
+	{0x292C, "x"},
+	{0x2963, "\u16d0\u16da"},
+	{0x2965, "\u21c3\u21c2"},
+	{0x296E, "\u16d0\u21c2"},
+	{0x296F, "\u21c3\u16da"},
+	{0x2999, "\u2d42"},
+	{0x29B0, "\u2349"},
+	{0x29BE, "\u233e"},
+	{0x29C4, "\u303c"},
+	{0x29C5, "\u2342"},
+	{0x29C7, "\u233b"},
+	{0x29D6, "\U000102c0"},
+	{0x29D9, "\u299a"},
+	{0x29F4, ":\u2192"},
+	{0x29F5, "\\"},
+	{0x29F6, "/\u0304"},
+	{0x29F8, "/"},
+	{0x29F9, "\\"},
+	{0x2A00, "\u0298"},
+	{0x2A01, "\U000102a8"},
+	{0x2A02, "\u2297"},
+	{0x2A03, "\u228d"},
+	{0x2A04, "\u228e"},
+	{0x2A05, "\u2293"},
+	{0x2A06, "\u2294"},
+	{0x2A0C, "\u0283\u0283\u0283\u0283"},
+	{0x2A1D, "\u16de"},
+	{0x2A20, ">>"},
+	{0x2A21, "\u16da"},
+	{0x2A22, "+\u030a"},
+	{0x2A23, "+\u0302"},
+	{0x2A24, "+\u0303"},
+	{0x2A25, "+\u0323"},
+	{0x2A26, "+\u0330"},
+	{0x2A27, "+\u2082"},
+	{0x2A29, "-\u0313"},
+	{0x2A2A, "-\u0323"},
+	{0x2A2F, "x"},
+	{0x2A30, "x\u0307"},
+	{0x2A3D, "\u2319"},
+	{0x2A3E, "\u2a1f"},
+	{0x2A3F, "\u2210"},
+	{0x2A6A, "~\u0307"},
+	{0x2A6E, "=\u20f0"},
+	{0x2A74, "::="},
+	{0x2A75, "=="},
+	{0x2A76, "==="},
+	{0x2AA5, "><"},
+	{0x2AAA, "\u15d5"},
+	{0x2AAB, "\u15d2"},
+	{0x2AD7, "\u1450\u1455"},
+	{0x2AFB, "///"},
+	{0x2AFD, "//"},
+	{0x2BEC, "\u219e"},
+	{0x2BED, "\u219f"},
+	{0x2BEE, "\u21a0"},
+	{0x2BEF, "\u21a1"},
+	{0x2C67, "H\u0329"},
+	{0x2C69, "K\u0329"},
+	{0x2C84, "\u0393"},
+	{0x2C85, "r"},
+	{0x2C86, "\u0394"},
+	{0x2C88, "\ua792"},
+	{0x2C89, "\ua793"},
+	{0x2C8E, "H"},
+	{0x2C92, "l"},
+	{0x2C94, "K"},
+	{0x2C95, "\u0138"},
+	{0x2C96, "\u03bb"},
+	{0x2C98, "M"},
+	{0x2C9A, "N"},
+	{0x2C9E, "O"},
+	{0x2C9F, "o"},
+	{0x2CA0, "\u03a0"},
+	{0x2CA2, "P"},
+	{0x2CA3, "p"},
+	{0x2CA4, "C"},
+	{0x2CA5, "c"},
+	{0x2CA6, "T"},
+	{0x2CA8, "Y"},
+	{0x2CAA, "\u03a6"},
+	{0x2CAB, "\u0278"},
+	{0x2CAC, "X"},
+	{0x2CAD, "\u03c7"},
+	{0x2CAE, "\u03a8"},
+	{0x2CB1, "\u03c9"},
+	{0x2CB4, "<\u00b7"},
+	{0x2CBA, "-"},
+	{0x2CBC, "\u0428"},
+	{0x2CBD, "\u0448"},
+	{0x2CC6, "/"},
+	{0x2CCA, "9"},
+	{0x2CCC, "3"},
+	{0x2CCD, "\u021d"},
+	{0x2CD0, "L"},
+	{0x2CD1, "\u029f"},
+	{0x2CD2, "6"},
+	{0x2CDC, "\u03ec"},
+	{0x2CE4, "\u03d7"},
+	{0x2CE9, "\u2627"},
+	{0x2CF9, "\\\\"},
+	{0x2D31, "O\u0335"},
+	{0x2D37, "\u0245"},
+	{0x2D38, "V"},
+	{0x2D39, "E"},
+	{0x2D3A, "\u018e"},
+	{0x2D41, "O\u0338"},
+	{0x2D48, "\u00b7\u00b7\u00b7"},
+	{0x2D49, "\u01a9"},
+	{0x2D4F, "l"},
+	{0x2D51, "!"},
+	{0x2D54, "O"},
+	{0x2D55, "Q"},
+	{0x2D59, "\u0298"},
+	{0x2D5D, "X"},
+	{0x2D60, "\u0394"},
+	{0x2D63, "\u16ef"},
+	{0x2DE8, "\u1ddf"},
+	{0x2DEA, "\u030a"},
+	{0x2DED, "\u0368"},
+	{0x2DEF, "\u036f"},
+	{0x2DF6, "\u0363"},
+	{0x2DF7, "\u0364"},
+	{0x2E1A, "-\u0308"},
+	{0x2E1E, "~\u0307"},
+	{0x2E1F, "~\u0323"},
+	{0x2E26, "\u1455"},
+	{0x2E27, "\u1450"},
+	{0x2E28, "(("},
+	{0x2E29, "))"},
+	{0x2E2A, "\u2235"},
+	{0x2E2B, "\u2234"},
+	{0x2E2C, "\u2237"},
+	{0x2E2E, "\u061f"},
+	{0x2E30, "\u00b0"},
+	{0x2E31, "\u00b7"},
+	{0x2E32, "\u060c"},
+	{0x2E35, "\u061b"},
+	{0x2E39, "\u1e9f"},
+	{0x2E3D, "\u2d42"},
+	{0x2E3F, "\u00b6"},
+	{0x2E40, "="},
+	{0x2E82, "\u4e5b"},
+	{0x2E83, "\u4e5a"},
+	{0x2E85, "\u4ebb"},
+	{0x2E89, "\u5202"},
+	{0x2E8B, "\u353e"},
+	{0x2E8E, "\u5140"},
+	{0x2E8F, "\u5c23"},
+	{0x2E90, "\u5c22"},
+	{0x2E92, "\u5df3"},
+	{0x2E93, "\u5e7a"},
+	{0x2E94, "\u5f51"},
+	{0x2E96, "\u5fc4"},
+	{0x2E97, "\u38fa"},
+	{0x2E98, "\u624c"},
+	{0x2E99, "\u6535"},
+	{0x2E9B, "\u65e1"},
+	{0x2E9E, "\u6b7a"},
+	{0x2E9F, "\u6bcd"},
+	{0x2EA0, "\u6c11"},
+	{0x2EA1, "\u6c35"},
+	{0x2EA2, "\u6c3a"},
+	{0x2EA3, "\u706c"},
+	{0x2EA4, "\u722b"},
+	{0x2EA6, "\u4e2c"},
+	{0x2EA8, "\u72ad"},
+	{0x2EAB, "\u7f52"},
+	{0x2EAD, "\u793b"},
+	{0x2EAF, "\u7cf9"},
+	{0x2EB1, "\u7f53"},
+	{0x2EB2, "\u7f52"},
+	{0x2EB9, "\u8002"},
+	{0x2EBA, "\u8080"},
+	{0x2EBE, "\u8279"},
+	{0x2EBF, "\u8279"},
+	{0x2EC0, "\u8279"},
+	{0x2EC1, "\u864e"},
+	{0x2EC2, "\u8864"},
+	{0x2EC3, "\u8980"},
+	{0x2EC4, "\u897f"},
+	{0x2EC5, "\u89c1"},
+	{0x2EC8, "\u8ba0"},
+	{0x2EC9, "\u8d1d"},
+	{0x2ECB, "\u8f66"},
+	{0x2ECC, "\u8fb6"},
+	{0x2ECD, "\u8fb6"},
+	{0x2ECF, "\u961d"},
+	{0x2ED0, "\u9485"},
+	{0x2ED1, "\u9577"},
+	{0x2ED2, "\u9578"},
+	{0x2ED3, "\u957f"},
+	{0x2ED4, "\u95e8"},
+	{0x2ED6, "\u961d"},
+	{0x2ED8, "\u9752"},
+	{0x2ED9, "\u97e6"},
+	{0x2EDA, "\u9875"},
+	{0x2EDB, "\u98ce"},
+	{0x2EDC, "\u98de"},
+	{0x2EDD, "\u98df"},
+	{0x2EDF, "\u98e0"},
+	{0x2EE0, "\u9963"},
+	{0x2EE2, "\u9a6c"},
+	{0x2EE4, "\u9b3c"},
+	{0x2EE5, "\u9c7c"},
+	{0x2EE8, "\u9ea6"},
+	{0x2EE9, "\u9ec4"},
+	{0x2EEB, "\u6589"},
+	{0x2EEC, "\u9f50"},
+	{0x2EED, "\u6b6f"},
+	{0x2EEE, "\u9f7f"},
+	{0x2EEF, "\u7adc"},
+	{0x2EF0, "\u9f99"},
+	{0x2EF2, "\u4e80"},
+	{0x2EF3, "\u9f9f"},
+	{0x2F00, "\u30fc"},
+	{0x2F01, "\u4e28"},
+	{0x2F02, "\\"},
+	{0x2F03, "/"},
+	{0x2F04, "\u4e59"},
+	{0x2F05, "\u4e85"},
+	{0x2F06, "\u4e8c"},
+	{0x2F07, "\u4ea0"},
+	{0x2F08, "\u4eba"},
+	{0x2F09, "\u513f"},
+	{0x2F0A, "\u5165"},
+	{0x2F0B, "\u516b"},
+	{0x2F0C, "\u5182"},
+	{0x2F0D, "\u5196"},
+	{0x2F0E, "\u51ab"},
+	{0x2F0F, "\u51e0"},
+	{0x2F10, "\u51f5"},
+	{0x2F11, "\u5200"},
+	{0x2F12, "\u529b"},
+	{0x2F13, "\u52f9"},
+	{0x2F14, "\u5315"},
+	{0x2F15, "\u531a"},
+	{0x2F16, "\u5338"},
+	{0x2F17, "\u5341"},
+	{0x2F18, "\u535c"},
+	{0x2F19, "\u5369"},
+	{0x2F1A, "\u5382"},
+	{0x2F1B, "\u53b6"},
+	{0x2F1C, "\u53c8"},
+	{0x2F1D, "\u53e3"},
+	{0x2F1E, "\u53e3"},
+	{0x2F1F, "\u571f"},
+	{0x2F20, "\u571f"},
+	{0x2F21, "\u5902"},
+	{0x2F22, "\u590a"},
+	{0x2F23, "\u5915"},
+	{0x2F24, "\u5927"},
+	{0x2F25, "\u5973"},
+	{0x2F26, "\u5b50"},
+	{0x2F27, "\u5b80"},
+	{0x2F28, "\u5bf8"},
+	{0x2F29, "\u5c0f"},
+	{0x2F2A, "\u5c22"},
+	{0x2F2B, "\u5c38"},
+	{0x2F2C, "\u5c6e"},
+	{0x2F2D, "\u5c71"},
+	{0x2F2E, "\u5ddb"},
+	{0x2F2F, "\u5de5"},
+	{0x2F30, "\u5df1"},
+	{0x2F31, "\u5dfe"},
+	{0x2F32, "\u5e72"},
+	{0x2F33, "\u5e7a"},
+	{0x2F34, "\u5e7f"},
+	{0x2F35, "\u5ef4"},
+	{0x2F36, "\u5efe"},
+	{0x2F37, "\u5f0b"},
+	{0x2F38, "\u5f13"},
+	{0x2F39, "\u5f50"},
+	{0x2F3A, "\u5f61"},
+	{0x2F3B, "\u5f73"},
+	{0x2F3C, "\u5fc3"},
+	{0x2F3D, "\u6208"},
+	{0x2F3E, "\u6236"},
+	{0x2F3F, "\u624b"},
+	{0x2F40, "\u652f"},
+	{0x2F41, "\u6534"},
+	{0x2F42, "\u6587"},
+	{0x2F43, "\u6597"},
+	{0x2F44, "\u65a4"},
+	{0x2F45, "\u65b9"},
+	{0x2F46, "\u65e0"},
+	{0x2F47, "\u65e5"},
+	{0x2F48, "\u66f0"},
+	{0x2F49, "\u6708"},
+	{0x2F4A, "\u6728"},
+	{0x2F4B, "\u6b20"},
+	{0x2F4C, "\u6b62"},
+	{0x2F4D, "\u6b79"},
+	{0x2F4E, "\u6bb3"},
+	{0x2F4F, "\u6bcb"},
+	{0x2F50, "\u6bd4"},
+	{0x2F51, "\u6bdb"},
+	{0x2F52, "\u6c0f"},
+	{0x2F53, "\u6c14"},
+	{0x2F54, "\u6c34"},
+	{0x2F55, "\u706b"},
+	{0x2F56, "\u722a"},
+	{0x2F57, "\u7236"},
+	{0x2F58, "\u723b"},
+	{0x2F59, "\u723f"},
+	{0x2F5A, "\u7247"},
+	{0x2F5B, "\u7259"},
+	{0x2F5C, "\u725b"},
+	{0x2F5D, "\u72ac"},
+	{0x2F5E, "\u7384"},
+	{0x2F5F, "\u7389"},
+	{0x2F60, "\u74dc"},
+	{0x2F61, "\u74e6"},
+	{0x2F62, "\u7518"},
+	{0x2F63, "\u751f"},
+	{0x2F64, "\u7528"},
+	{0x2F65, "\u7530"},
+	{0x2F66, "\u758b"},
+	{0x2F67, "\u7592"},
+	{0x2F68, "\u7676"},
+	{0x2F69, "\u767d"},
+	{0x2F6A, "\u76ae"},
+	{0x2F6B, "\u76bf"},
+	{0x2F6C, "\u76ee"},
+	{0x2F6D, "\u77db"},
+	{0x2F6E, "\u77e2"},
+	{0x2F6F, "\u77f3"},
+	{0x2F70, "\u793a"},
+	{0x2F71, "\u79b8"},
+	{0x2F72, "\u79be"},
+	{0x2F73, "\u7a74"},
+	{0x2F74, "\u7acb"},
+	{0x2F75, "\u7af9"},
+	{0x2F76, "\u7c73"},
+	{0x2F77, "\u7cf8"},
+	{0x2F78, "\u7f36"},
+	{0x2F79, "\u7f51"},
+	{0x2F7A, "\u7f8a"},
+	{0x2F7B, "\u7fbd"},
+	{0x2F7C, "\u8001"},
+	{0x2F7D, "\u800c"},
+	{0x2F7E, "\u8012"},
+	{0x2F7F, "\u8033"},
+	{0x2F80, "\u807f"},
+	{0x2F81, "\u8089"},
+	{0x2F82, "\u81e3"},
+	{0x2F83, "\u81ea"},
+	{0x2F84, "\u81f3"},
+	{0x2F85, "\u81fc"},
+	{0x2F86, "\u820c"},
+	{0x2F87, "\u821b"},
+	{0x2F88, "\u821f"},
+	{0x2F89, "\u826e"},
+	{0x2F8A, "\u8272"},
+	{0x2F8B, "\u8278"},
+	{0x2F8C, "\u864d"},
+	{0x2F8D, "\u866b"},
+	{0x2F8E, "\u8840"},
+	{0x2F8F, "\u884c"},
+	{0x2F90, "\u8863"},
+	{0x2F91, "\u897e"},
+	{0x2F92, "\u898b"},
+	{0x2F93, "\u89d2"},
+	{0x2F94, "\u8a00"},
+	{0x2F95, "\u8c37"},
+	{0x2F96, "\u8c46"},
+	{0x2F97, "\u8c55"},
+	{0x2F98, "\u8c78"},
+	{0x2F99, "\u8c9d"},
+	{0x2F9A, "\u8d64"},
+	{0x2F9B, "\u8d70"},
+	{0x2F9C, "\u8db3"},
+	{0x2F9D, "\u8eab"},
+	{0x2F9E, "\u8eca"},
+	{0x2F9F, "\u8f9b"},
+	{0x2FA0, "\u8fb0"},
+	{0x2FA1, "\u8fb5"},
+	{0x2FA2, "\u9091"},
+	{0x2FA3, "\u9149"},
+	{0x2FA4, "\u91c6"},
+	{0x2FA5, "\u91cc"},
+	{0x2FA6, "\u91d1"},
+	{0x2FA7, "\u9577"},
+	{0x2FA8, "\u9580"},
+	{0x2FA9, "\u961c"},
+	{0x2FAA, "\u96b6"},
+	{0x2FAB, "\u96b9"},
+	{0x2FAC, "\u96e8"},
+	{0x2FAD, "\u9751"},
+	{0x2FAE, "\u975e"},
+	{0x2FAF, "\u9762"},
+	{0x2FB0, "\u9769"},
+	{0x2FB1, "\u97cb"},
+	{0x2FB2, "\u97ed"},
+	{0x2FB3, "\u97f3"},
+	{0x2FB4, "\u9801"},
+	{0x2FB5, "\u98a8"},
+	{0x2FB6, "\u98db"},
+	{0x2FB7, "\u98df"},
+	{0x2FB8, "\u9996"},
+	{0x2FB9, "\u9999"},
+	{0x2FBA, "\u99ac"},
+	{0x2FBB, "\u9aa8"},
+	{0x2FBC, "\u9ad8"},
+	{0x2FBD, "\u9adf"},
+	{0x2FBE, "\u9b25"},
+	{0x2FBF, "\u9b2f"},
+	{0x2FC0, "\u9b32"},
+	{0x2FC1, "\u9b3c"},
+	{0x2FC2, "\u9b5a"},
+	{0x2FC3, "\u9ce5"},
+	{0x2FC4, "\u9e75"},
+	{0x2FC5, "\u9e7f"},
+	{0x2FC6, "\u9ea5"},
+	{0x2FC7, "\u9ebb"},
+	{0x2FC8, "\u9ec3"},
+	{0x2FC9, "\u9ecd"},
+	{0x2FCA, "\u9ed1"},
+	{0x2FCB, "\u9ef9"},
+	{0x2FCC, "\u9efd"},
+	{0x2FCD, "\u9f0e"},
+	{0x2FCE, "\u9f13"},
+	{0x2FCF, "\u9f20"},
+	{0x2FD0, "\u9f3b"},
+	{0x2FD1, "\u9f4a"},
+	{0x2FD2, "\u9f52"},
+	{0x2FD3, "\u9f8d"},
+	{0x2FD4, "\u9f9c"},
+	{0x2FD5, "\u9fa0"},
+	{0x3002, "\u02f3"},
+	{0x3003, "''"},
+	{0x3007, "O"},
+	{0x3008, "\u276c"},
+	{0x3009, "\u276d"},
+	{0x3012, "\u20b8"},
+	{0x3014, "("},
+	{0x3015, ")"},
+	{0x301A, "\u27e6"},
+	{0x301B, "\u27e7"},
+	{0x302C, "\u0309"},
+	{0x302D, "\u0325"},
+	{0x3033, "/"},
+	{0x3036, "\u20b8"},
+	{0x3038, "\u5341"},
+	{0x3039, "\u5344"},
+	{0x303A, "\u5345"},
+	{0x304F, "\u276c"},
+	{0x309A, "\u030a"},
+	{0x309B, "\uff9e"},
+	{0x309C, "\uff9f"},
+	{0x30A0, "="},
+	{0x30A4, "\u4ebb"},
+	{0x30A8, "\u5de5"},
+	{0x30AB, "\u529b"},
+	{0x30BF, "\u5915"},
+	{0x30C8, "\u535c"},
+	{0x30CB, "\u4e8c"},
+	{0x30CE, "/"},
+	{0x30CF, "\u516b"},
+	{0x30D8, "\u3078"},
+	{0x30ED, "\u53e3"},
+	{0x30FB, "\u00b7"},
+	{0x3131, "\u1100"},
+	{0x3132, "\u1100\u1100"},
+	{0x3133, "\u1100\u1109"},
+	{0x3134, "\u1102"},
+	{0x3135, "\u1102\u110c"},
+	{0x3136, "\u1102\u1112"},
+	{0x3137, "\u1103"},
+	{0x3138, "\u1103\u1103"},
+	{0x3139, "\u1105"},
+	{0x313A, "\u1105\u1100"},
+	{0x313B, "\u1105\u1106"},
+	{0x313C, "\u1105\u1107"},
+	{0x313D, "\u1105\u1109"},
+	{0x313E, "\u1105\u1110"},
+	{0x313F, "\u1105\u1111"},
+	{0x3140, "\u1105\u1112"},
+	{0x3141, "\u1106"},
+	{0x3142, "\u1107"},
+	{0x3143, "\u1107\u1107"},
+	{0x3144, "\u1107\u1109"},
+	{0x3145, "\u1109"},
+	{0x3146, "\u1109\u1109"},
+	{0x3147, "\u110b"},
+	{0x3148, "\u110c"},
+	{0x3149, "\u110c\u110c"},
+	{0x314A, "\u110e"},
+	{0x314B, "\u110f"},
+	{0x314C, "\u1110"},
+	{0x314D, "\u1111"},
+	{0x314E, "\u1112"},
+	{0x314F, "\u1161"},
+	{0x3150, "\u1161\u4e28"},
+	{0x3151, "\u1163"},
+	{0x3152, "\u1163\u4e28"},
+	{0x3153, "\u1165"},
+	{0x3154, "\u1165\u4e28"},
+	{0x3155, "\u1167"},
+	{0x3156, "\u1167\u4e28"},
+	{0x3157, "\u1169"},
+	{0x3158, "\u1169\u1161"},
+	{0x3159, "\u1169\u1161\u4e28"},
+	{0x315A, "\u1169\u4e28"},
+	{0x315B, "\u116d"},
+	{0x315C, "\u116e"},
+	{0x315D, "\u116e\u1165"},
+	{0x315E, "\u116e\u1165\u4e28"},
+	{0x315F, "\u116e\u4e28"},
+	{0x3160, "\u1172"},
+	{0x3161, "\u30fc"},
+	{0x3162, "\u30fc\u4e28"},
+	{0x3163, "\u4e28"},
+	{0x3164, "\u1160"},
+	{0x3165, "\u1102\u1102"},
+	{0x3166, "\u1102\u1103"},
+	{0x3167, "\u1102\u1109"},
+	{0x3168, "\u1102\u1140"},
+	{0x3169, "\u1105\u1100\u1109"},
+	{0x316A, "\u1105\u1103"},
+	{0x316B, "\u1105\u1107\u1109"},
+	{0x316C, "\u1105\u1140"},
+	{0x316D, "\u1105\u1159"},
+	{0x316E, "\u1106\u1107"},
+	{0x316F, "\u1106\u1109"},
+	{0x3170, "\u1106\u1140"},
+	{0x3171, "\u1106\u110b"},
+	{0x3172, "\u1107\u1100"},
+	{0x3173, "\u1107\u1103"},
+	{0x3174, "\u1107\u1109\u1100"},
+	{0x3175, "\u1107\u1109\u1103"},
+	{0x3176, "\u1107\u110c"},
+	{0x3177, "\u1107\u1110"},
+	{0x3178, "\u1107\u110b"},
+	{0x3179, "\u1107\u1107\u110b"},
+	{0x317A, "\u1109\u1100"},
+	{0x317B, "\u1109\u1102"},
+	{0x317C, "\u1109\u1103"},
+	{0x317D, "\u1109\u1107"},
+	{0x317E, "\u1109\u110c"},
+	{0x317F, "\u1140"},
+	{0x3180, "\u110b\u110b"},
+	{0x3181, "\u114c"},
+	{0x3182, "\u110b\u1109"},
+	{0x3183, "\u110b\u1140"},
+	{0x3184, "\u1111\u110b"},
+	{0x3185, "\u1112\u1112"},
+	{0x3186, "\u1159"},
+	{0x3187, "\u116d\u1163"},
+	{0x3188, "\u116d\u1163\u4e28"},
+	{0x3189, "\u116d\u4e28"},
+	{0x318A, "\u1172\u1167"},
+	{0x318B, "\u1172\u1167\u4e28"},
+	{0x318C, "\u1172\u4e28"},
+	{0x318D, "\u119e"},
+	{0x318E, "\u119e\u4e28"},
+	{0x31D0, "\u30fc"},
+	{0x31D1, "\u4e28"},
+	{0x31D3, "/"},
+	{0x31D4, "\\"},
+	{0x31D6, "\u4e5b"},
+	{0x31DA, "\u4e85"},
+	{0x31DB, "\u276c"},
+	{0x31DF, "\u4e5a"},
+	{0x31E0, "\u4e59"},
+	{0x3200, "(\u1100)"},
+	{0x3201, "(\u1102)"},
+	{0x3202, "(\u1103)"},
+	{0x3203, "(\u1105)"},
+	{0x3204, "(\u1106)"},
+	{0x3205, "(\u1107)"},
+	{0x3206, "(\u1109)"},
+	{0x3207, "(\u110b)"},
+	{0x3208, "(\u110c)"},
+	{0x3209, "(\u110e)"},
+	{0x320A, "(\u110f)"},
+	{0x320B, "(\u1110)"},
+	{0x320C, "(\u1111)"},
+	{0x320D, "(\u1112)"},
+	{0x320E, "(\u1100\u1161)"},
+	{0x320F, "(\u1102\u1161)"},
+	{0x3210, "(\u1103\u1161)"},
+	{0x3211, "(\u1105\u1161)"},
+	{0x3212, "(\u1106\u1161)"},
+	{0x3213, "(\u1107\u1161)"},
+	{0x3214, "(\u1109\u1161)"},
+	{0x3215, "(\u110b\u1161)"},
+	{0x3216, "(\u110c\u1161)"},
+	{0x3217, "(\u110e\u1161)"},
+	{0x3218, "(\u110f\u1161)"},
+	{0x3219, "(\u1110\u1161)"},
+	{0x321A, "(\u1111\u1161)"},
+	{0x321B, "(\u1112\u1161)"},
+	{0x321C, "(\u110c\u116e)"},
+	{0x321D, "(\u110b\u1169\u110c\u1165\u11ab)"},
+	{0x321E, "(\u110b\u1169\u1112\u116e)"},
+	{0x3220, "(\u30fc)"},
+	{0x3221, "(\u4e8c)"},
+	{0x3222, "(\u4e09)"},
+	{0x3223, "(\u56db)"},
+	{0x3224, "(\u4e94)"},
+	{0x3225, "(\u516d)"},
+	{0x3226, "(\u4e03)"},
+	{0x3227, "(\u516b)"},
+	{0x3228, "(\u4e5d)"},
+	{0x3229, "(\u5341)"},
+	{0x322A, "(\u6708)"},
+	{0x322B, "(\u706b)"},
+	{0x322C, "(\u6c34)"},
+	{0x322D, "(\u6728)"},
+	{0x322E, "(\u91d1)"},
+	{0x322F, "(\u571f)"},
+	{0x3230, "(\u65e5)"},
+	{0x3231, "(\u682a)"},
+	{0x3232, "(\u6709)"},
+	{0x3233, "(\u793e)"},
+	{0x3234, "(\u540d)"},
+	{0x3235, "(\u7279)"},
+	{0x3236, "(\u8ca1)"},
+	{0x3237, "(\u795d)"},
+	{0x3238, "(\u52b4)"},
+	{0x3239, "(\u4ee3)"},
+	{0x323A, "(\u547c)"},
+	{0x323B, "(\u5b66)"},
+	{0x323C, "(\u76e3)"},
+	{0x323D, "(\u4f01)"},
+	{0x323E, "(\u8cc7)"},
+	{0x323F, "(\u5354)"},
+	{0x3240, "(\u796d)"},
+	{0x3241, "(\u4f11)"},
+	{0x3242, "(\u81ea)"},
+	{0x3243, "(\u81f3)"},
+	{0x32C0, "l\u6708"},
+	{0x32C1, "2\u6708"},
+	{0x32C2, "3\u6708"},
+	{0x32C3, "4\u6708"},
+	{0x32C4, "5\u6708"},
+	{0x32C5, "6\u6708"},
+	{0x32C6, "7\u6708"},
+	{0x32C7, "8\u6708"},
+	{0x32C8, "9\u6708"},
+	{0x32C9, "lO\u6708"},
+	{0x32CA, "ll\u6708"},
+	{0x32CB, "l2\u6708"},
+	{0x3358, "O\u70b9"},
+	{0x3359, "l\u70b9"},
+	{0x335A, "2\u70b9"},
+	{0x335B, "3\u70b9"},
+	{0x335C, "4\u70b9"},
+	{0x335D, "5\u70b9"},
+	{0x335E, "6\u70b9"},
+	{0x335F, "7\u70b9"},
+	{0x3360, "8\u70b9"},
+	{0x3361, "9\u70b9"},
+	{0x3362, "lO\u70b9"},
+	{0x3363, "ll\u70b9"},
+	{0x3364, "l2\u70b9"},
+	{0x3365, "l3\u70b9"},
+	{0x3366, "l4\u70b9"},
+	{0x3367, "l5\u70b9"},
+	{0x3368, "l6\u70b9"},
+	{0x3369, "l7\u70b9"},
+	{0x336A, "l8\u70b9"},
+	{0x336B, "l9\u70b9"},
+	{0x336C, "2O\u70b9"},
+	{0x336D, "2l\u70b9"},
+	{0x336E, "22\u70b9"},
+	{0x336F, "23\u70b9"},
+	{0x3370, "24\u70b9"},
+	{0x33E0, "l\u65e5"},
+	{0x33E1, "2\u65e5"},
+	{0x33E2, "3\u65e5"},
+	{0x33E3, "4\u65e5"},
+	{0x33E4, "5\u65e5"},
+	{0x33E5, "6\u65e5"},
+	{0x33E6, "7\u65e5"},
+	{0x33E7, "8\u65e5"},
+	{0x33E8, "9\u65e5"},
+	{0x33E9, "lO\u65e5"},
+	{0x33EA, "ll\u65e5"},
+	{0x33EB, "l2\u65e5"},
+	{0x33EC, "l3\u65e5"},
+	{0x33ED, "l4\u65e5"},
+	{0x33EE, "l5\u65e5"},
+	{0x33EF, "l6\u65e5"},
+	{0x33F0, "l7\u65e5"},
+	{0x33F1, "l8\u65e5"},
+	{0x33F2, "l9\u65e5"},
+	{0x33F3, "2O\u65e5"},
+	{0x33F4, "2l\u65e5"},
+	{0x33F5, "22\u65e5"},
+	{0x33F6, "23\u65e5"},
+	{0x33F7, "24\u65e5"},
+	{0x33F8, "25\u65e5"},
+	{0x33F9, "26\u65e5"},
+	{0x33FA, "27\u65e5"},
+	{0x33FB, "28\u65e5"},
+	{0x33FC, "29\u65e5"},
+	{0x33FD, "3O\u65e5"},
+	{0x33FE, "3l\u65e5"},
+	{0x39B3, "\u363d"},
+	{0x439B, "\u3588"},
+	{0x4420, "\u3b3b"},
+	{0x4E00, "\u30fc"},
+	{0x4E36, "\\"},
+	{0x4E3F, "/"},
+	{0x5002, "\u4f75"},
+	{0x503C, "\u5024"},
+	{0x555F, "\u5553"},
+	{0x56D7, "\u53e3"},
+	{0x586B, "\u5861"},
+	{0x58EB, "\u571f"},
+	{0x58FF, "\u58ab"},
+	{0x5B00, "\u5aaf"},
+	{0x5E32, "\u5e21"},
+	{0x5E50, "\u3b3a"},
+	{0x6238, "\u6236"},
+	{0x6409, "\u3a41"},
+	{0x6663, "\u403f"},
+	{0x6669, "\u665a"},
+	{0x66F6, "\u3ada"},
+	{0x6726, "\u4443"},
+	{0x67FF, "\u676e"},
+	{0x69E9, "\u3ba3"},
+	{0x6A27, "\u699d"},
+	{0x6F59, "\u6e88"},
+	{0x784F, "\u7814"},
+	{0x7D76, "\u7d55"},
+	{0x80A6, "\u670c"},
+	{0x80CA, "\u6710"},
+	{0x80D0, "\u670f"},
+	{0x80F6, "\u3b35"},
+	{0x8101, "\u6713"},
+	{0x8127, "\u6718"},
+	{0x8141, "\u80fc"},
+	{0x81A7, "\u6723"},
+	{0x853F, "\u848d"},
+	{0x8641, "\u8637"},
+	{0x8A1E, "\u46b6"},
+	{0x8A7D, "\u8a2e"},
+	{0x8B8F, "\u8b86"},
+	{0x8C63, "\u8c5c"},
+	{0x8D86, "\u8d7f"},
+	{0x8DFA, "\u8de5"},
+	{0x8E9B, "\u8e97"},
+	{0x8F27, "\u8eff"},
+	{0x90DE, "\u90ce"},
+	{0x93AE, "\u93ad"},
+	{0x96B8, "\u96b7"},
+	{0x9E43, "\u9e42"},
+	{0x9ED2, "\u9ed1"},
+	{0x9FC3, "\u4039"},
+	{0xA494, "\ua2cd"},
+	{0xA49C, "\ua0c0"},
+	{0xA49E, "\ua04a"},
+	{0xA4A7, "\ua458"},
+	{0xA4A8, "\ua132"},
+	{0xA4AC, "\ua050"},
+	{0xA4B0, "\ua3c2"},
+	{0xA4BA, "\ua3bf"},
+	{0xA4BE, "\ua2b1"},
+	{0xA4BF, "\ua259"},
+	{0xA4C0, "\ua3ab"},
+	{0xA4C2, "\ua3b5"},
+	{0xA4D0, "B"},
+	{0xA4D1, "P"},
+	{0xA4D2, "d"},
+	{0xA4D3, "D"},
+	{0xA4D4, "T"},
+	{0xA4D6, "G"},
+	{0xA4D7, "K"},
+	{0xA4D9, "J"},
+	{0xA4DA, "C"},
+	{0xA4DB, "\u0186"},
+	{0xA4DC, "Z"},
+	{0xA4DD, "F"},
+	{0xA4DE, "\u2132"},
+	{0xA4DF, "M"},
+	{0xA4E0, "N"},
+	{0xA4E1, "L"},
+	{0xA4E2, "S"},
+	{0xA4E3, "R"},
+	{0xA4E5, "\u0245"},
+	{0xA4E6, "V"},
+	{0xA4E7, "H"},
+	{0xA4EA, "W"},
+	{0xA4EB, "X"},
+	{0xA4EC, "Y"},
+	{0xA4ED, "\u1660"},
+	{0xA4EE, "A"},
+	{0xA4EF, "\u2c6f"},
+	{0xA4F0, "E"},
+	{0xA4F1, "\u018e"},
+	{0xA4F2, "l"},
+	{0xA4F3, "O"},
+	{0xA4F4, "U"},
+	{0xA4F5, "\u0548"},
+	{0xA4F7, "\u15e1"},
+	{0xA4F8, "."},
+	{0xA4F9, ","},
+	{0xA4FA, ".."},
+	{0xA4FB, ".,"},
+	{0xA4FD, ":"},
+	{0xA4FE, "-."},
+	{0xA4FF, "="},
+	{0xA60E, "."},
+	{0xA644, "2"},
+	{0xA645, "\u01a8"},
+	{0xA647, "i"},
+	{0xA64D, "\u03c9"},
+	{0xA650, "\u042al"},
+	{0xA651, "\u02c9bi"},
+	{0xA668, "\u0298"},
+	{0xA66F, "\u20e9"},
+	{0xA67C, "\u0306"},
+	{0xA67E, "\u02c7"},
+	{0xA695, "h\u0314"},
+	{0xA698, "OO"},
+	{0xA699, "oo"},
+	{0xA69A, "\U000102a8"},
+	{0xA6A1, "\u0418"},
+	{0xA6B0, "\u16b9"},
+	{0xA6B1, "\u2c75"},
+	{0xA6CD, "\u02a1"},
+	{0xA6CE, "\u0245"},
+	{0xA6DB, "\u03a0"},
+	{0xA6DF, "V"},
+	{0xA6EB, "?"},
+	{0xA6EF, "2"},
+	{0xA6F0, "\u0302"},
+	{0xA6F1, "\u0304"},
+	{0xA6F4, "\ua6f3\ua6f3"},
+	{0xA714, "\u02eb"},
+	{0xA716, "\u02ea"},
+	{0xA728, "T3"},
+	{0xA729, "t\u021d"},
+	{0xA731, "s"},
+	{0xA732, "AA"},
+	{0xA733, "aa"},
+	{0xA734, "AO"},
+	{0xA735, "ao"},
+	{0xA736, "AU"},
+	{0xA737, "au"},
+	{0xA738, "AV"},
+	{0xA739, "av"},
+	{0xA73A, "AV"},
+	{0xA73B, "av"},
+	{0xA73C, "AY"},
+	{0xA73D, "ay"},
+	{0xA740, "K\u0335"},
+	{0xA74A, "O\u0335"},
+	{0xA74B, "o\u0335"},
+	{0xA74E, "OO"},
+	{0xA74F, "oo"},
+	{0xA75A, "2"},
+	{0xA761, "w\u0326"},
+	{0xA76A, "3"},
+	{0xA76B, "\u021d"},
+	{0xA76E, "9"},
+	{0xA777, "tf"},
+	{0xA778, "&"},
+	{0xA77A, "\ua779"},
+	{0xA789, ":"},
+	{0xA78C, "'"},
+	{0xA78F, "\u00b7"},
+	{0xA795, "\ua727"},
+	{0xA798, "F"},
+	{0xA799, "f"},
+	{0xA79A, "\U00010412"},
+	{0xA79B, "\U0001043a"},
+	{0xA79D, "\u029a"},
+	{0xA79E, "\ua4e4"},
+	{0xA79F, "u"},
+	{0xA7AB, "3"},
+	{0xA7B1, "\ua4d5"},
+	{0xA7B2, "J"},
+	{0xA7B3, "X"},
+	{0xA7B4, "B"},
+	{0xA7B5, "\u00df"},
+	{0xA7B6, "\ua64c"},
+	{0xA7B7, "\u03c9"},
+	{0xA7F7, "\u30fc"},
+	{0xA830, "\u0964"},
+	{0xA960, "\u1103\u1106"},
+	{0xA961, "\u1103\u1107"},
+	{0xA962, "\u1103\u1109"},
+	{0xA963, "\u1103\u110c"},
+	{0xA964, "\u1105\u1100"},
+	{0xA965, "\u1105\u1100\u1100"},
+	{0xA966, "\u1105\u1103"},
+	{0xA967, "\u1105\u1103\u1103"},
+	{0xA968, "\u1105\u1106"},
+	{0xA969, "\u1105\u1107"},
+	{0xA96A, "\u1105\u1107\u1107"},
+	{0xA96B, "\u1105\u1107\u110b"},
+	{0xA96C, "\u1105\u1109"},
+	{0xA96D, "\u1105\u110c"},
+	{0xA96E, "\u1105\u110f"},
+	{0xA96F, "\u1106\u1100"},
+	{0xA970, "\u1106\u1103"},
+	{0xA971, "\u1106\u1109"},
+	{0xA972, "\u1107\u1109\u1110"},
+	{0xA973, "\u1107\u110f"},
+	{0xA974, "\u1107\u1112"},
+	{0xA975, "\u1109\u1109\u1107"},
+	{0xA976, "\u110b\u1105"},
+	{0xA977, "\u110b\u1112"},
+	{0xA978, "\u110c\u110c\u1112"},
+	{0xA979, "\u1110\u1110"},
+	{0xA97A, "\u1111\u1112"},
+	{0xA97B, "\u1112\u1109"},
+	{0xA97C, "\u1159\u1159"},
+	{0xA992, "\u2c3f"},
+	{0xA9A3, "\ua99d"},
+	{0xA9C6, "\ua9d0"},
+	{0xA9CF, "\u0662"},
+	{0xAA53, "\uaa01"},
+	{0xAA56, "\uaa23"},
+	{0xAB32, "e"},
+	{0xAB35, "f"},
+	{0xAB3D, "o"},
+	{0xAB3E, "o\u0338"},
+	{0xAB3F, "\u0254\u0338"},
+	{0xAB41, "\u01ddo\u0338"},
+	{0xAB42, "\u01ddo\u0335"},
+	{0xAB47, "r"},
+	{0xAB48, "r"},
+	{0xAB4D, "\u0283"},
+	{0xAB4E, "u"},
+	{0xAB52, "u"},
+	{0xAB53, "\u03c7"},
+	{0xAB55, "\u03c7"},
+	{0xAB5A, "y"},
+	{0xAB60, "\u0459"},
+	{0xAB62, "\u0254e"},
+	{0xAB63, "uo"},
+	{0xAB70, "\u1d05"},
+	{0xAB71, "\u0280"},
+	{0xAB72, "\u1d1b"},
+	{0xAB74, "o\u031b"},
+	{0xAB75, "i"},
+	{0xAB7A, "\u1d00"},
+	{0xAB7B, "\u1d0a"},
+	{0xAB7C, "\u1d07"},
+	{0xAB7E, "\u0242"},
+	{0xAB80, "\u2c76"},
+	{0xAB81, "r"},
+	{0xAB83, "w"},
+	{0xAB87, "\u028d"},
+	{0xAB8B, "\u029c"},
+	{0xAB8E, "o\u0335"},
+	{0xAB90, "\u0262"},
+	{0xAB93, "z"},
+	{0xAB9B, "\ua793"},
+	{0xAB9C, "u\u0335"},
+	{0xAB9F, "\u0185"},
+	{0xABA2, "\u0280"},
+	{0xABA9, "v"},
+	{0xABAA, "s"},
+	{0xABAE, "\u029f"},
+	{0xABAF, "c"},
+	{0xABB2, "\u1d18"},
+	{0xABB6, "\u0138"},
+	{0xABBB, "o\u0335"},
+	{0xD7B0, "\u1169\u1167"},
+	{0xD7B1, "\u1169\u1169\u4e28"},
+	{0xD7B2, "\u116d\u1161"},
+	{0xD7B3, "\u116d\u1161\u4e28"},
+	{0xD7B4, "\u116d\u1165"},
+	{0xD7B5, "\u116e\u1167"},
+	{0xD7B6, "\u116e\u4e28\u4e28"},
+	{0xD7B7, "\u1172\u1161\u4e28"},
+	{0xD7B8, "\u1172\u1169"},
+	{0xD7B9, "\u30fc\u1161"},
+	{0xD7BA, "\u30fc\u1165"},
+	{0xD7BB, "\u30fc\u1165\u4e28"},
+	{0xD7BC, "\u30fc\u1169"},
+	{0xD7BD, "\u4e28\u1163\u1169"},
+	{0xD7BE, "\u4e28\u1163\u4e28"},
+	{0xD7BF, "\u4e28\u1167"},
+	{0xD7C0, "\u4e28\u1167\u4e28"},
+	{0xD7C1, "\u4e28\u1169\u4e28"},
+	{0xD7C2, "\u4e28\u116d"},
+	{0xD7C3, "\u4e28\u1172"},
+	{0xD7C4, "\u4e28\u4e28"},
+	{0xD7C5, "\u119e\u1161"},
+	{0xD7C6, "\u119e\u1165\u4e28"},
+	{0xD7CB, "\u1102\u1105"},
+	{0xD7CC, "\u1102\u110e"},
+	{0xD7CD, "\u1103\u1103"},
+	{0xD7CE, "\u1103\u1103\u1107"},
+	{0xD7CF, "\u1103\u1107"},
+	{0xD7D0, "\u1103\u1109"},
+	{0xD7D1, "\u1103\u1109\u1100"},
+	{0xD7D2, "\u1103\u110c"},
+	{0xD7D3, "\u1103\u110e"},
+	{0xD7D4, "\u1103\u1110"},
+	{0xD7D5, "\u1105\u1100\u1100"},
+	{0xD7D6, "\u1105\u1100\u1112"},
+	{0xD7D7, "\u1105\u1105\u110f"},
+	{0xD7D8, "\u1105\u1106\u1112"},
+	{0xD7D9, "\u1105\u1107\u1103"},
+	{0xD7DA, "\u1105\u1107\u1111"},
+	{0xD7DB, "\u1105\u114c"},
+	{0xD7DC, "\u1105\u1159\u1112"},
+	{0xD7DD, "\u1105\u110b"},
+	{0xD7DE, "\u1106\u1102"},
+	{0xD7DF, "\u1106\u1102\u1102"},
+	{0xD7E0, "\u1106\u1106"},
+	{0xD7E1, "\u1106\u1107\u1109"},
+	{0xD7E2, "\u1106\u110c"},
+	{0xD7E3, "\u1107\u1103"},
+	{0xD7E4, "\u1107\u1105\u1111"},
+	{0xD7E5, "\u1107\u1106"},
+	{0xD7E6, "\u1107\u1107"},
+	{0xD7E7, "\u1107\u1109\u1103"},
+	{0xD7E8, "\u1107\u110c"},
+	{0xD7E9, "\u1107\u110e"},
+	{0xD7EA, "\u1109\u1106"},
+	{0xD7EB, "\u1109\u1107\u110b"},
+	{0xD7EC, "\u1109\u1109\u1100"},
+	{0xD7ED, "\u1109\u1109\u1103"},
+	{0xD7EE, "\u1109\u1140"},
+	{0xD7EF, "\u1109\u110c"},
+	{0xD7F0, "\u1109\u110e"},
+	{0xD7F1, "\u1109\u1110"},
+	{0xD7F2, "\u1105\u1112"},
+	{0xD7F3, "\u1140\u1107"},
+	{0xD7F4, "\u1140\u1107\u110b"},
+	{0xD7F5, "\u114c\u1106"},
+	{0xD7F6, "\u114c\u1112"},
+	{0xD7F7, "\u110c\u1107"},
+	{0xD7F8, "\u110c\u1107\u1107"},
+	{0xD7F9, "\u110c\u110c"},
+	{0xD7FA, "\u1111\u1109"},
+	{0xD7FB, "\u1111\u1110"},
+	{0xF900, "\u8c48"},
+	{0xF901, "\u66f4"},
+	{0xF902, "\u8eca"},
+	{0xF903, "\u8cc8"},
+	{0xF904, "\u6ed1"},
+	{0xF905, "\u4e32"},
+	{0xF906, "\u53e5"},
+	{0xF907, "\u9f9c"},
+	{0xF908, "\u9f9c"},
+	{0xF909, "\u5951"},
+	{0xF90A, "\u91d1"},
+	{0xF90B, "\u5587"},
+	{0xF90C, "\u5948"},
+	{0xF90D, "\u61f6"},
+	{0xF90E, "\u7669"},
+	{0xF90F, "\u7f85"},
+	{0xF910, "\u863f"},
+	{0xF911, "\u87ba"},
+	{0xF912, "\u88f8"},
+	{0xF913, "\u908f"},
+	{0xF914, "\u6a02"},
+	{0xF915, "\u6d1b"},
+	{0xF916, "\u70d9"},
+	{0xF917, "\u73de"},
+	{0xF918, "\u843d"},
+	{0xF919, "\u916a"},
+	{0xF91A, "\u99f1"},
+	{0xF91B, "\u4e82"},
+	{0xF91C, "\u5375"},
+	{0xF91D, "\u6b04"},
+	{0xF91E, "\u721b"},
+	{0xF91F, "\u862d"},
+	{0xF920, "\u9e1e"},
+	{0xF921, "\u5d50"},
+	{0xF922, "\u6feb"},
+	{0xF923, "\u85cd"},
+	{0xF924, "\u8964"},
+	{0xF925, "\u62c9"},
+	{0xF926, "\u81d8"},
+	{0xF927, "\u881f"},
+	{0xF928, "\u5eca"},
+	{0xF929, "\u6717"},
+	{0xF92A, "\u6d6a"},
+	{0xF92B, "\u72fc"},
+	{0xF92C, "\u90ce"},
+	{0xF92D, "\u4f86"},
+	{0xF92E, "\u51b7"},
+	{0xF92F, "\u52de"},
+	{0xF930, "\u64c4"},
+	{0xF931, "\u6ad3"},
+	{0xF932, "\u7210"},
+	{0xF933, "\u76e7"},
+	{0xF934, "\u8001"},
+	{0xF935, "\u8606"},
+	{0xF936, "\u865c"},
+	{0xF937, "\u8def"},
+	{0xF938, "\u9732"},
+	{0xF939, "\u9b6f"},
+	{0xF93A, "\u9dfa"},
+	{0xF93B, "\u788c"},
+	{0xF93C, "\u797f"},
+	{0xF93D, "\u7da0"},
+	{0xF93E, "\u83c9"},
+	{0xF93F, "\u9304"},
+	{0xF940, "\u9e7f"},
+	{0xF941, "\u8ad6"},
+	{0xF942, "\u58df"},
+	{0xF943, "\u5f04"},
+	{0xF944, "\u7c60"},
+	{0xF945, "\u807e"},
+	{0xF946, "\u7262"},
+	{0xF947, "\u78ca"},
+	{0xF948, "\u8cc2"},
+	{0xF949, "\u96f7"},
+	{0xF94A, "\u58d8"},
+	{0xF94B, "\u5c62"},
+	{0xF94C, "\u6a13"},
+	{0xF94D, "\u6dda"},
+	{0xF94E, "\u6f0f"},
+	{0xF94F, "\u7d2f"},
+	{0xF950, "\u7e37"},
+	{0xF951, "\u964b"},
+	{0xF952, "\u52d2"},
+	{0xF953, "\u808b"},
+	{0xF954, "\u51dc"},
+	{0xF955, "\u51cc"},
+	{0xF956, "\u7a1c"},
+	{0xF957, "\u7dbe"},
+	{0xF958, "\u83f1"},
+	{0xF959, "\u9675"},
+	{0xF95A, "\u8b80"},
+	{0xF95B, "\u62cf"},
+	{0xF95C, "\u6a02"},
+	{0xF95D, "\u8afe"},
+	{0xF95E, "\u4e39"},
+	{0xF95F, "\u5be7"},
+	{0xF960, "\u6012"},
+	{0xF961, "\u7387"},
+	{0xF962, "\u7570"},
+	{0xF963, "\u5317"},
+	{0xF964, "\u78fb"},
+	{0xF965, "\u4fbf"},
+	{0xF966, "\u5fa9"},
+	{0xF967, "\u4e0d"},
+	{0xF968, "\u6ccc"},
+	{0xF969, "\u6578"},
+	{0xF96A, "\u7d22"},
+	{0xF96B, "\u53c3"},
+	{0xF96C, "\u585e"},
+	{0xF96D, "\u7701"},
+	{0xF96E, "\u8449"},
+	{0xF96F, "\u8aaa"},
+	{0xF970, "\u6bba"},
+	{0xF971, "\u8fb0"},
+	{0xF972, "\u6c88"},
+	{0xF973, "\u62fe"},
+	{0xF974, "\u82e5"},
+	{0xF975, "\u63a0"},
+	{0xF976, "\u7565"},
+	{0xF977, "\u4eae"},
+	{0xF978, "\u5169"},
+	{0xF979, "\u51c9"},
+	{0xF97A, "\u6881"},
+	{0xF97B, "\u7ce7"},
+	{0xF97C, "\u826f"},
+	{0xF97D, "\u8ad2"},
+	{0xF97E, "\u91cf"},
+	{0xF97F, "\u52f5"},
+	{0xF980, "\u5442"},
+	{0xF981, "\u5973"},
+	{0xF982, "\u5eec"},
+	{0xF983, "\u65c5"},
+	{0xF984, "\u6ffe"},
+	{0xF985, "\u792a"},
+	{0xF986, "\u95ad"},
+	{0xF987, "\u9a6a"},
+	{0xF988, "\u9e97"},
+	{0xF989, "\u9ece"},
+	{0xF98A, "\u529b"},
+	{0xF98B, "\u66c6"},
+	{0xF98C, "\u6b77"},
+	{0xF98D, "\u8f62"},
+	{0xF98E, "\u5e74"},
+	{0xF98F, "\u6190"},
+	{0xF990, "\u6200"},
+	{0xF991, "\u649a"},
+	{0xF992, "\u6f23"},
+	{0xF993, "\u7149"},
+	{0xF994, "\u7489"},
+	{0xF995, "\u79ca"},
+	{0xF996, "\u7df4"},
+	{0xF997, "\u806f"},
+	{0xF998, "\u8f26"},
+	{0xF999, "\u84ee"},
+	{0xF99A, "\u9023"},
+	{0xF99B, "\u934a"},
+	{0xF99C, "\u5217"},
+	{0xF99D, "\u52a3"},
+	{0xF99E, "\u54bd"},
+	{0xF99F, "\u70c8"},
+	{0xF9A0, "\u88c2"},
+	{0xF9A1, "\u8aaa"},
+	{0xF9A2, "\u5ec9"},
+	{0xF9A3, "\u5ff5"},
+	{0xF9A4, "\u637b"},
+	{0xF9A5, "\u6bae"},
+	{0xF9A6, "\u7c3e"},
+	{0xF9A7, "\u7375"},
+	{0xF9A8, "\u4ee4"},
+	{0xF9A9, "\u56f9"},
+	{0xF9AA, "\u5be7"},
+	{0xF9AB, "\u5dba"},
+	{0xF9AC, "\u601c"},
+	{0xF9AD, "\u73b2"},
+	{0xF9AE, "\u7469"},
+	{0xF9AF, "\u7f9a"},
+	{0xF9B0, "\u8046"},
+	{0xF9B1, "\u9234"},
+	{0xF9B2, "\u96f6"},
+	{0xF9B3, "\u9748"},
+	{0xF9B4, "\u9818"},
+	{0xF9B5, "\u4f8b"},
+	{0xF9B6, "\u79ae"},
+	{0xF9B7, "\u91b4"},
+	{0xF9B8, "\u96b7"},
+	{0xF9B9, "\u60e1"},
+	{0xF9BA, "\u4e86"},
+	{0xF9BB, "\u50da"},
+	{0xF9BC, "\u5bee"},
+	{0xF9BD, "\u5c3f"},
+	{0xF9BE, "\u6599"},
+	{0xF9BF, "\u6a02"},
+	{0xF9C0, "\u71ce"},
+	{0xF9C1, "\u7642"},
+	{0xF9C2, "\u84fc"},
+	{0xF9C3, "\u907c"},
+	{0xF9C4, "\u9f8d"},
+	{0xF9C5, "\u6688"},
+	{0xF9C6, "\u962e"},
+	{0xF9C7, "\u5289"},
+	{0xF9C8, "\u677b"},
+	{0xF9C9, "\u67f3"},
+	{0xF9CA, "\u6d41"},
+	{0xF9CB, "\u6e9c"},
+	{0xF9CC, "\u7409"},
+	{0xF9CD, "\u7559"},
+	{0xF9CE, "\u786b"},
+	{0xF9CF, "\u7d10"},
+	{0xF9D0, "\u985e"},
+	{0xF9D1, "\u516d"},
+	{0xF9D2, "\u622e"},
+	{0xF9D3, "\u9678"},
+	{0xF9D4, "\u502b"},
+	{0xF9D5, "\u5d19"},
+	{0xF9D6, "\u6dea"},
+	{0xF9D7, "\u8f2a"},
+	{0xF9D8, "\u5f8b"},
+	{0xF9D9, "\u6144"},
+	{0xF9DA, "\u6817"},
+	{0xF9DB, "\u7387"},
+	{0xF9DC, "\u9686"},
+	{0xF9DD, "\u5229"},
+	{0xF9DE, "\u540f"},
+	{0xF9DF, "\u5c65"},
+	{0xF9E0, "\u6613"},
+	{0xF9E1, "\u674e"},
+	{0xF9E2, "\u68a8"},
+	{0xF9E3, "\u6ce5"},
+	{0xF9E4, "\u7406"},
+	{0xF9E5, "\u75e2"},
+	{0xF9E6, "\u7f79"},
+	{0xF9E7, "\u88cf"},
+	{0xF9E8, "\u88e1"},
+	{0xF9E9, "\u91cc"},
+	{0xF9EA, "\u96e2"},
+	{0xF9EB, "\u533f"},
+	{0xF9EC, "\u6eba"},
+	{0xF9ED, "\u541d"},
+	{0xF9EE, "\u71d0"},
+	{0xF9EF, "\u7498"},
+	{0xF9F0, "\u85fa"},
+	{0xF9F1, "\u96a3"},
+	{0xF9F2, "\u9c57"},
+	{0xF9F3, "\u9e9f"},
+	{0xF9F4, "\u6797"},
+	{0xF9F5, "\u6dcb"},
+	{0xF9F6, "\u81e8"},
+	{0xF9F7, "\u7acb"},
+	{0xF9F8, "\u7b20"},
+	{0xF9F9, "\u7c92"},
+	{0xF9FA, "\u72c0"},
+	{0xF9FB, "\u7099"},
+	{0xF9FC, "\u8b58"},
+	{0xF9FD, "\u4ec0"},
+	{0xF9FE, "\u8336"},
+	{0xF9FF, "\u523a"},
+	{0xFA00, "\u5207"},
+	{0xFA01, "\u5ea6"},
+	{0xFA02, "\u62d3"},
+	{0xFA03, "\u7cd6"},
+	{0xFA04, "\u5b85"},
+	{0xFA05, "\u6d1e"},
+	{0xFA06, "\u66b4"},
+	{0xFA07, "\u8f3b"},
+	{0xFA08, "\u884c"},
+	{0xFA09, "\u964d"},
+	{0xFA0A, "\u898b"},
+	{0xFA0B, "\u5ed3"},
+	{0xFA0C, "\u5140"},
+	{0xFA0D, "\u55c0"},
+	{0xFA10, "\u585a"},
+	{0xFA12, "\u6674"},
+	{0xFA15, "\u51de"},
+	{0xFA16, "\u732a"},
+	{0xFA17, "\u76ca"},
+	{0xFA18, "\u793c"},
+	{0xFA19, "\u795e"},
+	{0xFA1A, "\u7965"},
+	{0xFA1B, "\u798f"},
+	{0xFA1C, "\u9756"},
+	{0xFA1D, "\u7cbe"},
+	{0xFA1E, "\u7fbd"},
+	{0xFA20, "\u8612"},
+	{0xFA22, "\u8af8"},
+	{0xFA25, "\u9038"},
+	{0xFA26, "\u90fd"},
+	{0xFA2A, "\u98ef"},
+	{0xFA2B, "\u98fc"},
+	{0xFA2C, "\u9928"},
+	{0xFA2D, "\u9db4"},
+	{0xFA2E, "\u90ce"},
+	{0xFA2F, "\u96b7"},
+	{0xFA30, "\u4fae"},
+	{0xFA31, "\u50e7"},
+	{0xFA32, "\u514d"},
+	{0xFA33, "\u52c9"},
+	{0xFA34, "\u52e4"},
+	{0xFA35, "\u5351"},
+	{0xFA36, "\u559d"},
+	{0xFA37, "\u5606"},
+	{0xFA38, "\u5668"},
+	{0xFA39, "\u5840"},
+	{0xFA3A, "\u58a8"},
+	{0xFA3B, "\u5c64"},
+	{0xFA3C, "\u5c6e"},
+	{0xFA3D, "\u6094"},
+	{0xFA3E, "\u6168"},
+	{0xFA3F, "\u618e"},
+	{0xFA40, "\u61f2"},
+	{0xFA41, "\u654f"},
+	{0xFA42, "\u65e2"},
+	{0xFA43, "\u6691"},
+	{0xFA44, "\u6885"},
+	{0xFA45, "\u6d77"},
+	{0xFA46, "\u6e1a"},
+	{0xFA47, "\u6f22"},
+	{0xFA48, "\u716e"},
+	{0xFA49, "\u722b"},
+	{0xFA4A, "\u7422"},
+	{0xFA4B, "\u7891"},
+	{0xFA4C, "\u793e"},
+	{0xFA4D, "\u7949"},
+	{0xFA4E, "\u7948"},
+	{0xFA4F, "\u7950"},
+	{0xFA50, "\u7956"},
+	{0xFA51, "\u795d"},
+	{0xFA52, "\u798d"},
+	{0xFA53, "\u798e"},
+	{0xFA54, "\u7a40"},
+	{0xFA55, "\u7a81"},
+	{0xFA56, "\u7bc0"},
+	{0xFA57, "\u7df4"},
+	{0xFA58, "\u7e09"},
+	{0xFA59, "\u7e41"},
+	{0xFA5A, "\u7f72"},
+	{0xFA5B, "\u8005"},
+	{0xFA5C, "\u81ed"},
+	{0xFA5D, "\u8279"},
+	{0xFA5E, "\u8279"},
+	{0xFA5F, "\u8457"},
+	{0xFA60, "\u8910"},
+	{0xFA61, "\u8996"},
+	{0xFA62, "\u8b01"},
+	{0xFA63, "\u8b39"},
+	{0xFA64, "\u8cd3"},
+	{0xFA65, "\u8d08"},
+	{0xFA66, "\u8fb6"},
+	{0xFA67, "\u9038"},
+	{0xFA68, "\u96e3"},
+	{0xFA69, "\u97ff"},
+	{0xFA6A, "\u983b"},
+	{0xFA6B, "\u6075"},
+	{0xFA6C, "\U000242ee"},
+	{0xFA6D, "\u8218"},
+	{0xFA70, "\u4e26"},
+	{0xFA71, "\u51b5"},
+	{0xFA72, "\u5168"},
+	{0xFA73, "\u4f80"},
+	{0xFA74, "\u5145"},
+	{0xFA75, "\u5180"},
+	{0xFA76, "\u52c7"},
+	{0xFA77, "\u52fa"},
+	{0xFA78, "\u559d"},
+	{0xFA79, "\u5555"},
+	{0xFA7A, "\u5599"},
+	{0xFA7B, "\u55e2"},
+	{0xFA7C, "\u585a"},
+	{0xFA7D, "\u58b3"},
+	{0xFA7E, "\u5944"},
+	{0xFA7F, "\u5954"},
+	{0xFA80, "\u5a62"},
+	{0xFA81, "\u5b28"},
+	{0xFA82, "\u5ed2"},
+	{0xFA83, "\u5ed9"},
+	{0xFA84, "\u5f69"},
+	{0xFA85, "\u5fad"},
+	{0xFA86, "\u60d8"},
+	{0xFA87, "\u614e"},
+	{0xFA88, "\u6108"},
+	{0xFA89, "\u618e"},
+	{0xFA8A, "\u6160"},
+	{0xFA8B, "\u61f2"},
+	{0xFA8C, "\u6234"},
+	{0xFA8D, "\u63c4"},
+	{0xFA8E, "\u641c"},
+	{0xFA8F, "\u6452"},
+	{0xFA90, "\u6556"},
+	{0xFA91, "\u6674"},
+	{0xFA92, "\u6717"},
+	{0xFA93, "\u671b"},
+	{0xFA94, "\u6756"},
+	{0xFA95, "\u6b79"},
+	{0xFA96, "\u6bba"},
+	{0xFA97, "\u6d41"},
+	{0xFA98, "\u6edb"},
+	{0xFA99, "\u6ecb"},
+	{0xFA9A, "\u6f22"},
+	{0xFA9B, "\u701e"},
+	{0xFA9C, "\u716e"},
+	{0xFA9D, "\u77a7"},
+	{0xFA9E, "\u7235"},
+	{0xFA9F, "\u72af"},
+	{0xFAA0, "\u732a"},
+	{0xFAA1, "\u7471"},
+	{0xFAA2, "\u7506"},
+	{0xFAA3, "\u753b"},
+	{0xFAA4, "\u761d"},
+	{0xFAA5, "\u761f"},
+	{0xFAA6, "\u76ca"},
+	{0xFAA7, "\u76db"},
+	{0xFAA8, "\u76f4"},
+	{0xFAA9, "\u774a"},
+	{0xFAAA, "\u7740"},
+	{0xFAAB, "\u78cc"},
+	{0xFAAC, "\u7ab1"},
+	{0xFAAD, "\u7bc0"},
+	{0xFAAE, "\u7c7b"},
+	{0xFAAF, "\u7d5b"},
+	{0xFAB0, "\u7df4"},
+	{0xFAB1, "\u7f3e"},
+	{0xFAB2, "\u8005"},
+	{0xFAB3, "\u8352"},
+	{0xFAB4, "\u83ef"},
+	{0xFAB5, "\u8779"},
+	{0xFAB6, "\u8941"},
+	{0xFAB7, "\u8986"},
+	{0xFAB8, "\u8996"},
+	{0xFAB9, "\u8abf"},
+	{0xFABA, "\u8af8"},
+	{0xFABB, "\u8acb"},
+	{0xFABC, "\u8b01"},
+	{0xFABD, "\u8afe"},
+	{0xFABE, "\u8aed"},
+	{0xFABF, "\u8b39"},
+	{0xFAC0, "\u8b8a"},
+	{0xFAC1, "\u8d08"},
+	{0xFAC2, "\u8f38"},
+	{0xFAC3, "\u9072"},
+	{0xFAC4, "\u9199"},
+	{0xFAC5, "\u9276"},
+	{0xFAC6, "\u967c"},
+	{0xFAC7, "\u96e3"},
+	{0xFAC8, "\u9756"},
+	{0xFAC9, "\u97db"},
+	{0xFACA, "\u97ff"},
+	{0xFACB, "\u980b"},
+	{0xFACC, "\u983b"},
+	{0xFACD, "\u9b12"},
+	{0xFACE, "\u9f9c"},
+	{0xFACF, "\U0002284a"},
+	{0xFAD0, "\U00022844"},
+	{0xFAD1, "\U000233d5"},
+	{0xFAD2, "\u3b9d"},
+	{0xFAD3, "\u4018"},
+	{0xFAD4, "\u4039"},
+	{0xFAD5, "\U00025249"},
+	{0xFAD6, "\U00025cd0"},
+	{0xFAD7, "\U00027ed3"},
+	{0xFAD8, "\u9f43"},
+	{0xFAD9, "\u9f8e"},
+	{0xFB00, "ff"},
+	{0xFB01, "fi"},
+	{0xFB02, "fl"},
+	{0xFB03, "ffi"},
+	{0xFB04, "ffl"},
+	{0xFB06, "st"},
+	{0xFB13, "\u0574\u0576"},
+	{0xFB14, "\u0574\u0565"},
+	{0xFB15, "\u0574\u056b"},
+	{0xFB16, "\u057e\u0576"},
+	{0xFB17, "\u0574\u056d"},
+	{0xFB20, "\u05e2"},
+	{0xFB21, "\u05d0"},
+	{0xFB22, "\u05d3"},
+	{0xFB23, "\u05d4"},
+	{0xFB24, "\u05db"},
+	{0xFB25, "\u05dc"},
+	{0xFB26, "\u05dd"},
+	{0xFB27, "\u05e8"},
+	{0xFB28, "\u05ea"},
+	{0xFB29, "-\u0307"},
+	{0xFB2B, "\u05e9\u05c1"},
+	{0xFB2D, "\u05e9\u05bc\u05c1"},
+	{0xFB2F, "\u05d0\u05b7"},
+	{0xFB30, "\u05d0\u05b7"},
+	{0xFB39, "\u05d9\u05b4"},
+	{0xFB49, "\u05e9\u05c1"},
+	{0xFB4F, "\u05d0\u05dc"},
+	{0xFB50, "\u0671"},
+	{0xFB51, "\u0671"},
+	{0xFB52, "\u067b"},
+	{0xFB53, "\u067b"},
+	{0xFB54, "\u067b"},
+	{0xFB55, "\u067b"},
+	{0xFB56, "\u0649\u06db"},
+	{0xFB57, "\u0649\u06db"},
+	{0xFB58, "\u0649\u06db"},
+	{0xFB59, "\u0649\u06db"},
+	{0xFB5A, "\u0680"},
+	{0xFB5B, "\u0680"},
+	{0xFB5C, "\u0680"},
+	{0xFB5D, "\u0680"},
+	{0xFB5E, "\u067a"},
+	{0xFB5F, "\u067a"},
+	{0xFB60, "\u067a"},
+	{0xFB61, "\u067a"},
+	{0xFB62, "\u067f"},
+	{0xFB63, "\u067f"},
+	{0xFB64, "\u067f"},
+	{0xFB65, "\u067f"},
+	{0xFB66, "\u0649\u0615"},
+	{0xFB67, "\u0649\u0615"},
+	{0xFB68, "\u0649\u0615"},
+	{0xFB69, "\u0649\u0615"},
+	{0xFB6A, "\u06a1\u06db"},
+	{0xFB6B, "\u06a1\u06db"},
+	{0xFB6C, "\u06a1\u06db"},
+	{0xFB6D, "\u06a1\u06db"},
+	{0xFB6E, "\u06a6"},
+	{0xFB6F, "\u06a6"},
+	{0xFB70, "\u06a6"},
+	{0xFB71, "\u06a6"},
+	{0xFB72, "\u0684"},
+	{0xFB73, "\u0684"},
+	{0xFB74, "\u0684"},
+	{0xFB75, "\u0684"},
+	{0xFB76, "\u0683"},
+	{0xFB77, "\u0683"},
+	{0xFB78, "\u0683"},
+	{0xFB79, "\u0683"},
+	{0xFB7A, "\u0686"},
+	{0xFB7B, "\u0686"},
+	{0xFB7C, "\u0686"},
+	{0xFB7D, "\u0686"},
+	{0xFB7E, "\u0687"},
+	{0xFB7F, "\u0687"},
+	{0xFB80, "\u0687"},
+	{0xFB81, "\u0687"},
+	{0xFB82, "\u068d"},
+	{0xFB83, "\u068d"},
+	{0xFB84, "\u068c"},
+	{0xFB85, "\u068c"},
+	{0xFB86, "\u062f\u06db"},
+	{0xFB87, "\u062f\u06db"},
+	{0xFB88, "\u062f\u0615"},
+	{0xFB89, "\u062f\u0615"},
+	{0xFB8A, "\u0631\u06db"},
+	{0xFB8B, "\u0631\u06db"},
+	{0xFB8C, "\u0631\u0615"},
+	{0xFB8D, "\u0631\u0615"},
+	{0xFB8E, "\u0643"},
+	{0xFB8F, "\u0643"},
+	{0xFB90, "\u0643"},
+	{0xFB91, "\u0643"},
+	{0xFB92, "\u06af"},
+	{0xFB93, "\u06af"},
+	{0xFB94, "\u06af"},
+	{0xFB95, "\u06af"},
+	{0xFB96, "\u06b3"},
+	{0xFB97, "\u06b3"},
+	{0xFB98, "\u06b3"},
+	{0xFB99, "\u06b3"},
+	{0xFB9A, "\u06b1"},
+	{0xFB9B, "\u06b1"},
+	{0xFB9C, "\u06b1"},
+	{0xFB9D, "\u06b1"},
+	{0xFB9E, "\u0649"},
+	{0xFB9F, "\u0649"},
+	{0xFBA0, "\u0649\u0615"},
+	{0xFBA1, "\u0649\u0615"},
+	{0xFBA2, "\u0649\u0615"},
+	{0xFBA3, "\u0649\u0615"},
+	{0xFBA4, "\u06d5\u0654"},
+	{0xFBA5, "\u06d5\u0654"},
+	{0xFBA6, "o"},
+	{0xFBA7, "o"},
+	{0xFBA8, "o"},
+	{0xFBA9, "o"},
+	{0xFBAA, "o"},
+	{0xFBAB, "o"},
+	{0xFBAC, "o"},
+	{0xFBAD, "o"},
+	{0xFBAE, "\u0649"},
+	{0xFBAF, "\u0649"},
+	{0xFBB0, "\u06d2\u0654"},
+	{0xFBB1, "\u06d2\u0654"},
+	{0xFBD3, "\u0643\u06db"},
+	{0xFBD4, "\u0643\u06db"},
+	{0xFBD5, "\u0643\u06db"},
+	{0xFBD6, "\u0643\u06db"},
+	{0xFBD7, "\u0648\u0313"},
+	{0xFBD8, "\u0648\u0313"},
+	{0xFBD9, "\u0648\u0306"},
+	{0xFBDA, "\u0648\u0306"},
+	{0xFBDB, "\u0648\u0670"},
+	{0xFBDC, "\u0648\u0670"},
+	{0xFBDD, "\u0648\u0313\u0674"},
+	{0xFBDE, "\u0648\u06db"},
+	{0xFBDF, "\u0648\u06db"},
+	{0xFBE0, "\u06c5"},
+	{0xFBE1, "\u06c5"},
+	{0xFBE2, "\u0648\u0302"},
+	{0xFBE3, "\u0648\u0302"},
+	{0xFBE4, "\u067b"},
+	{0xFBE5, "\u067b"},
+	{0xFBE6, "\u067b"},
+	{0xFBE7, "\u067b"},
+	{0xFBE8, "\u0649"},
+	{0xFBE9, "\u0649"},
+	{0xFBEA, "\u0649\u0674l"},
+	{0xFBEB, "\u0649\u0674l"},
+	{0xFBEC, "\u0649\u0674o"},
+	{0xFBED, "\u0649\u0674o"},
+	{0xFBEE, "\u0649\u0674\u0648"},
+	{0xFBEF, "\u0649\u0674\u0648"},
+	{0xFBF0, "\u0649\u0674\u0648\u0313"},
+	{0xFBF1, "\u0649\u0674\u0648\u0313"},
+	{0xFBF2, "\u0649\u0674\u0648\u0306"},
+	{0xFBF3, "\u0649\u0674\u0648\u0306"},
+	{0xFBF4, "\u0649\u0674\u0648\u0670"},
+	{0xFBF5, "\u0649\u0674\u0648\u0670"},
+	{0xFBF6, "\u0649\u0674\u067b"},
+	{0xFBF7, "\u0649\u0674\u067b"},
+	{0xFBF8, "\u0649\u0674\u067b"},
+	{0xFBF9, "\u0649\u0674\u0649"},
+	{0xFBFA, "\u0649\u0674\u0649"},
+	{0xFBFB, "\u0649\u0674\u0649"},
+	{0xFBFC, "\u0649"},
+	{0xFBFD, "\u0649"},
+	{0xFBFE, "\u0649"},
+	{0xFBFF, "\u0649"},
+	{0xFC00, "\u0649\u0674\u062c"},
+	{0xFC01, "\u0649\u0674\u062d"},
+	{0xFC02, "\u0649\u0674\u0645"},
+	{0xFC03, "\u0649\u0674\u0649"},
+	{0xFC04, "\u0649\u0674\u0649"},
+	{0xFC05, "\u0628\u062c"},
+	{0xFC06, "\u0628\u062d"},
+	{0xFC07, "\u0628\u062e"},
+	{0xFC08, "\u0628\u0645"},
+	{0xFC09, "\u0628\u0649"},
+	{0xFC0A, "\u0628\u0649"},
+	{0xFC0B, "\u062a\u062c"},
+	{0xFC0C, "\u062a\u062d"},
+	{0xFC0D, "\u062a\u062e"},
+	{0xFC0E, "\u062a\u0645"},
+	{0xFC0F, "\u062a\u0649"},
+	{0xFC10, "\u062a\u0649"},
+	{0xFC11, "\u0649\u06db\u062c"},
+	{0xFC12, "\u0649\u06db\u0645"},
+	{0xFC13, "\u0649\u06db\u0649"},
+	{0xFC14, "\u0649\u06db\u0649"},
+	{0xFC15, "\u062c\u062d"},
+	{0xFC16, "\u062c\u0645"},
+	{0xFC17, "\u062d\u062c"},
+	{0xFC18, "\u062d\u0645"},
+	{0xFC19, "\u062e\u062c"},
+	{0xFC1A, "\u062e\u062d"},
+	{0xFC1B, "\u062e\u0645"},
+	{0xFC1C, "\u0633\u062c"},
+	{0xFC1D, "\u0633\u062d"},
+	{0xFC1E, "\u0633\u062e"},
+	{0xFC1F, "\u0633\u0645"},
+	{0xFC20, "\u0635\u062d"},
+	{0xFC21, "\u0635\u0645"},
+	{0xFC22, "\u0636\u062c"},
+	{0xFC23, "\u0636\u062d"},
+	{0xFC24, "\u0636\u062e"},
+	{0xFC25, "\u0636\u0645"},
+	{0xFC26, "\u0637\u062d"},
+	{0xFC27, "\u0637\u0645"},
+	{0xFC28, "\u0638\u0645"},
+	{0xFC29, "\u0639\u062c"},
+	{0xFC2A, "\u0639\u0645"},
+	{0xFC2B, "\u063a\u062c"},
+	{0xFC2C, "\u063a\u0645"},
+	{0xFC2D, "\u0641\u062c"},
+	{0xFC2E, "\u0641\u062d"},
+	{0xFC2F, "\u0641\u062e"},
+	{0xFC30, "\u0641\u0645"},
+	{0xFC31, "\u0641\u0649"},
+	{0xFC32, "\u0641\u0649"},
+	{0xFC33, "\u0642\u062d"},
+	{0xFC34, "\u0642\u0645"},
+	{0xFC35, "\u0642\u0649"},
+	{0xFC36, "\u0642\u0649"},
+	{0xFC37, "\u0643l"},
+	{0xFC38, "\u0643\u062c"},
+	{0xFC39, "\u0643\u062d"},
+	{0xFC3A, "\u0643\u062e"},
+	{0xFC3B, "\u0643\u0644"},
+	{0xFC3C, "\u0643\u0645"},
+	{0xFC3D, "\u0643\u0649"},
+	{0xFC3E, "\u0643\u0649"},
+	{0xFC3F, "\u0644\u062c"},
+	{0xFC40, "\u0644\u062d"},
+	{0xFC41, "\u0644\u062e"},
+	{0xFC42, "\u0644\u0645"},
+	{0xFC43, "\u0644\u0649"},
+	{0xFC44, "\u0644\u0649"},
+	{0xFC45, "\u0645\u062c"},
+	{0xFC46, "\u0645\u062d"},
+	{0xFC47, "\u0645\u062e"},
+	{0xFC48, "\u0645\u0645"},
+	{0xFC49, "\u0645\u0649"},
+	{0xFC4A, "\u0645\u0649"},
+	{0xFC4B, "\u0628\u062e"},
+	{0xFC4C, "\u0646\u062d"},
+	{0xFC4D, "\u0646\u062e"},
+	{0xFC4E, "\u0646\u0645"},
+	{0xFC4F, "\u0646\u0649"},
+	{0xFC50, "\u0646\u0649"},
+	{0xFC51, "o\u062c"},
+	{0xFC52, "o\u0645"},
+	{0xFC53, "o\u0649"},
+	{0xFC54, "o\u0649"},
+	{0xFC55, "\u0649\u062c"},
+	{0xFC56, "\u0649\u062d"},
+	{0xFC57, "\u0649\u062e"},
+	{0xFC58, "\u0649\u0645"},
+	{0xFC59, "\u0649\u0649"},
+	{0xFC5A, "\u0649\u0649"},
+	{0xFC5B, "\u0630\u0670"},
+	{0xFC5C, "\u0631\u0670"},
+	{0xFC5D, "\u0649\u0670"},
+	{0xFC5E, "\ufe72\u0651"},
+	{0xFC5F, "\ufe74\u0651"},
+	{0xFC60, "\ufe76\u0651"},
+	{0xFC61, "\ufe78\u0651"},
+	{0xFC62, "\ufe7a\u0651"},
+	{0xFC63, "\ufe7c\u0670"},
+	{0xFC64, "\u0649\u0674\u0631"},
+	{0xFC65, "\u0649\u0674\u0632"},
+	{0xFC66, "\u0649\u0674\u0645"},
+	{0xFC67, "\u0649\u0674\u0646"},
+	{0xFC68, "\u0649\u0674\u0649"},
+	{0xFC69, "\u0649\u0674\u0649"},
+	{0xFC6A, "\u0628\u0631"},
+	{0xFC6B, "\u0628\u0632"},
+	{0xFC6C, "\u0628\u0645"},
+	{0xFC6D, "\u0628\u0646"},
+	{0xFC6E, "\u0628\u0649"},
+	{0xFC6F, "\u0628\u0649"},
+	{0xFC70, "\u062a\u0631"},
+	{0xFC71, "\u062a\u0632"},
+	{0xFC72, "\u062a\u0645"},
+	{0xFC73, "\u062a\u0646"},
+	{0xFC74, "\u062a\u0649"},
+	{0xFC75, "\u062a\u0649"},
+	{0xFC76, "\u0649\u06db\u0631"},
+	{0xFC77, "\u0649\u06db\u0632"},
+	{0xFC78, "\u0649\u06db\u0645"},
+	{0xFC79, "\u0649\u06db\u0646"},
+	{0xFC7A, "\u0649\u06db\u0649"},
+	{0xFC7B, "\u0649\u06db\u0649"},
+	{0xFC7C, "\u0641\u0649"},
+	{0xFC7D, "\u0641\u0649"},
+	{0xFC7E, "\u0642\u0649"},
+	{0xFC7F, "\u0642\u0649"},
+	{0xFC80, "\u0643l"},
+	{0xFC81, "\u0643\u0644"},
+	{0xFC82, "\u0643\u0645"},
+	{0xFC83, "\u0643\u0649"},
+	{0xFC84, "\u0643\u0649"},
+	{0xFC85, "\u0644\u0645"},
+	{0xFC86, "\u0644\u0649"},
+	{0xFC87, "\u0644\u0649"},
+	{0xFC88, "\u0645l"},
+	{0xFC89, "\u0645\u0645"},
+	{0xFC8A, "\u0646\u0631"},
+	{0xFC8B, "\u0646\u0632"},
+	{0xFC8C, "\u0646\u0645"},
+	{0xFC8D, "\u0646\u0646"},
+	{0xFC8E, "\u0646\u0649"},
+	{0xFC8F, "\u0646\u0649"},
+	{0xFC90, "\u0649\u0670"},
+	{0xFC91, "\u0649\u0631"},
+	{0xFC92, "\u0649\u0632"},
+	{0xFC93, "\u0649\u0645"},
+	{0xFC94, "\u0649\u0646"},
+	{0xFC95, "\u0649\u0649"},
+	{0xFC96, "\u0649\u0649"},
+	{0xFC97, "\u0649\u0674\u062c"},
+	{0xFC98, "\u0649\u0674\u062d"},
+	{0xFC99, "\u0649\u0674\u062e"},
+	{0xFC9A, "\u0649\u0674\u0645"},
+	{0xFC9B, "\u0649\u0674o"},
+	{0xFC9C, "\u0628\u062c"},
+	{0xFC9D, "\u0628\u062d"},
+	{0xFC9E, "\u0628\u062e"},
+	{0xFC9F, "\u0628\u0645"},
+	{0xFCA0, "\u0628o"},
+	{0xFCA1, "\u062a\u062c"},
+	{0xFCA2, "\u062a\u062d"},
+	{0xFCA3, "\u062a\u062e"},
+	{0xFCA4, "\u062a\u0645"},
+	{0xFCA5, "\u062ao"},
+	{0xFCA6, "\u0649\u06db\u0645"},
+	{0xFCA7, "\u062c\u062d"},
+	{0xFCA8, "\u062c\u0645"},
+	{0xFCA9, "\u062d\u062c"},
+	{0xFCAA, "\u062d\u0645"},
+	{0xFCAB, "\u062e\u062c"},
+	{0xFCAC, "\u062e\u0645"},
+	{0xFCAD, "\u0633\u062c"},
+	{0xFCAE, "\u0633\u062d"},
+	{0xFCAF, "\u0633\u062e"},
+	{0xFCB0, "\u0633\u0645"},
+	{0xFCB1, "\u0635\u062d"},
+	{0xFCB2, "\u0635\u062e"},
+	{0xFCB3, "\u0635\u0645"},
+	{0xFCB4, "\u0636\u062c"},
+	{0xFCB5, "\u0636\u062d"},
+	{0xFCB6, "\u0636\u062e"},
+	{0xFCB7, "\u0636\u0645"},
+	{0xFCB8, "\u0637\u062d"},
+	{0xFCB9, "\u0638\u0645"},
+	{0xFCBA, "\u0639\u062c"},
+	{0xFCBB, "\u0639\u0645"},
+	{0xFCBC, "\u063a\u062c"},
+	{0xFCBD, "\u063a\u0645"},
+	{0xFCBE, "\u0641\u062c"},
+	{0xFCBF, "\u0641\u062d"},
+	{0xFCC0, "\u0641\u062e"},
+	{0xFCC1, "\u0641\u0645"},
+	{0xFCC2, "\u0642\u062d"},
+	{0xFCC3, "\u0642\u0645"},
+	{0xFCC4, "\u0643\u062c"},
+	{0xFCC5, "\u0643\u062d"},
+	{0xFCC6, "\u0643\u062e"},
+	{0xFCC7, "\u0643\u0644"},
+	{0xFCC8, "\u0643\u0645"},
+	{0xFCC9, "\u0644\u062c"},
+	{0xFCCA, "\u0644\u062d"},
+	{0xFCCB, "\u0644\u062e"},
+	{0xFCCC, "\u0644\u0645"},
+	{0xFCCD, "\u0644o"},
+	{0xFCCE, "\u0645\u062c"},
+	{0xFCCF, "\u0645\u062d"},
+	{0xFCD0, "\u0645\u062e"},
+	{0xFCD1, "\u0645\u0645"},
+	{0xFCD2, "\u0628\u062e"},
+	{0xFCD3, "\u0646\u062d"},
+	{0xFCD4, "\u0646\u062e"},
+	{0xFCD5, "\u0646\u0645"},
+	{0xFCD6, "\u0646o"},
+	{0xFCD7, "o\u062c"},
+	{0xFCD8, "o\u0645"},
+	{0xFCD9, "o\u0670"},
+	{0xFCDA, "\u0649\u062c"},
+	{0xFCDB, "\u0649\u062d"},
+	{0xFCDC, "\u0649\u062e"},
+	{0xFCDD, "\u0649\u0645"},
+	{0xFCDE, "\u0649o"},
+	{0xFCDF, "\u0649\u0674\u0645"},
+	{0xFCE0, "\u0649\u0674o"},
+	{0xFCE1, "\u0628\u0645"},
+	{0xFCE2, "\u0628o"},
+	{0xFCE3, "\u062a\u0645"},
+	{0xFCE4, "\u062ao"},
+	{0xFCE5, "\u0649\u06db\u0645"},
+	{0xFCE6, "\u0649\u06dbo"},
+	{0xFCE7, "\u0633\u0645"},
+	{0xFCE8, "\u0633o"},
+	{0xFCE9, "\u0633\u06db\u0645"},
+	{0xFCEA, "\u0633\u06dbo"},
+	{0xFCEB, "\u0643\u0644"},
+	{0xFCEC, "\u0643\u0645"},
+	{0xFCED, "\u0644\u0645"},
+	{0xFCEE, "\u0646\u0645"},
+	{0xFCEF, "\u0646o"},
+	{0xFCF0, "\u0649\u0645"},
+	{0xFCF1, "\u0649o"},
+	{0xFCF2, "\ufe77\u0651"},
+	{0xFCF3, "\ufe79\u0651"},
+	{0xFCF4, "\ufe7b\u0651"},
+	{0xFCF5, "\u0637\u0649"},
+	{0xFCF6, "\u0637\u0649"},
+	{0xFCF7, "\u0639\u0649"},
+	{0xFCF8, "\u0639\u0649"},
+	{0xFCF9, "\u063a\u0649"},
+	{0xFCFA, "\u063a\u0649"},
+	{0xFCFB, "\u0633\u0649"},
+	{0xFCFC, "\u0633\u0649"},
+	{0xFCFD, "\u0633\u06db\u0649"},
+	{0xFCFE, "\u0633\u06db\u0649"},
+	{0xFCFF, "\u062d\u0649"},
+	{0xFD00, "\u062d\u0649"},
+	{0xFD01, "\u062c\u0649"},
+	{0xFD02, "\u062c\u0649"},
+	{0xFD03, "\u062e\u0649"},
+	{0xFD04, "\u062e\u0649"},
+	{0xFD05, "\u0635\u0649"},
+	{0xFD06, "\u0635\u0649"},
+	{0xFD07, "\u0636\u0649"},
+	{0xFD08, "\u0636\u0649"},
+	{0xFD09, "\u0633\u06db\u062c"},
+	{0xFD0A, "\u0633\u06db\u062d"},
+	{0xFD0B, "\u0633\u06db\u062e"},
+	{0xFD0C, "\u0633\u06db\u0645"},
+	{0xFD0D, "\u0633\u06db\u0631"},
+	{0xFD0E, "\u0633\u0631"},
+	{0xFD0F, "\u0635\u0631"},
+	{0xFD10, "\u0636\u0631"},
+	{0xFD11, "\u0637\u0649"},
+	{0xFD12, "\u0637\u0649"},
+	{0xFD13, "\u0639\u0649"},
+	{0xFD14, "\u0639\u0649"},
+	{0xFD15, "\u063a\u0649"},
+	{0xFD16, "\u063a\u0649"},
+	{0xFD17, "\u0633\u0649"},
+	{0xFD18, "\u0633\u0649"},
+	{0xFD19, "\u0633\u06db\u0649"},
+	{0xFD1A, "\u0633\u06db\u0649"},
+	{0xFD1B, "\u062d\u0649"},
+	{0xFD1C, "\u062d\u0649"},
+	{0xFD1D, "\u062c\u0649"},
+	{0xFD1E, "\u062c\u0649"},
+	{0xFD1F, "\u062e\u0649"},
+	{0xFD20, "\u062e\u0649"},
+	{0xFD21, "\u0635\u0649"},
+	{0xFD22, "\u0635\u0649"},
+	{0xFD23, "\u0636\u0649"},
+	{0xFD24, "\u0636\u0649"},
+	{0xFD25, "\u0633\u06db\u062c"},
+	{0xFD26, "\u0633\u06db\u062d"},
+	{0xFD27, "\u0633\u06db\u062e"},
+	{0xFD28, "\u0633\u06db\u0645"},
+	{0xFD29, "\u0633\u06db\u0631"},
+	{0xFD2A, "\u0633\u0631"},
+	{0xFD2B, "\u0635\u0631"},
+	{0xFD2C, "\u0636\u0631"},
+	{0xFD2D, "\u0633\u06db\u062c"},
+	{0xFD2E, "\u0633\u06db\u062d"},
+	{0xFD2F, "\u0633\u06db\u062e"},
+	{0xFD30, "\u0633\u06db\u0645"},
+	{0xFD31, "\u0633o"},
+	{0xFD32, "\u0633\u06dbo"},
+	{0xFD33, "\u0637\u0645"},
+	{0xFD34, "\u0633\u062c"},
+	{0xFD35, "\u0633\u062d"},
+	{0xFD36, "\u0633\u062e"},
+	{0xFD37, "\u0633\u06db\u062c"},
+	{0xFD38, "\u0633\u06db\u062d"},
+	{0xFD39, "\u0633\u06db\u062e"},
+	{0xFD3A, "\u0637\u0645"},
+	{0xFD3B, "\u0638\u0645"},
+	{0xFD3C, "l\u030b"},
+	{0xFD3D, "l\u030b"},
+	{0xFD3E, "("},
+	{0xFD3F, ")"},
+	{0xFD50, "\u062a\u062c\u0645"},
+	{0xFD51, "\u062a\u062d\u062c"},
+	{0xFD52, "\u062a\u062d\u062c"},
+	{0xFD53, "\u062a\u062d\u0645"},
+	{0xFD54, "\u062a\u062e\u0645"},
+	{0xFD55, "\u062a\u0645\u062c"},
+	{0xFD56, "\u062a\u0645\u062d"},
+	{0xFD57, "\u062a\u0645\u062e"},
+	{0xFD58, "\u062c\u0645\u062d"},
+	{0xFD59, "\u062c\u0645\u062d"},
+	{0xFD5A, "\u062d\u0645\u0649"},
+	{0xFD5B, "\u062d\u0645\u0649"},
+	{0xFD5C, "\u0633\u062d\u062c"},
+	{0xFD5D, "\u0633\u062c\u062d"},
+	{0xFD5E, "\u0633\u062c\u0649"},
+	{0xFD5F, "\u0633\u0645\u062d"},
+	{0xFD60, "\u0633\u0645\u062d"},
+	{0xFD61, "\u0633\u0645\u062c"},
+	{0xFD62, "\u0633\u0645\u0645"},
+	{0xFD63, "\u0633\u0645\u0645"},
+	{0xFD64, "\u0635\u062d\u062d"},
+	{0xFD65, "\u0635\u062d\u062d"},
+	{0xFD66, "\u0635\u0645\u0645"},
+	{0xFD67, "\u0633\u06db\u062d\u0645"},
+	{0xFD68, "\u0633\u06db\u062d\u0645"},
+	{0xFD69, "\u0633\u06db\u062c\u0649"},
+	{0xFD6A, "\u0633\u06db\u0645\u062e"},
+	{0xFD6B, "\u0633\u06db\u0645\u062e"},
+	{0xFD6C, "\u0633\u06db\u0645\u0645"},
+	{0xFD6D, "\u0633\u06db\u0645\u0645"},
+	{0xFD6E, "\u0636\u062d\u0649"},
+	{0xFD6F, "\u0636\u062e\u0645"},
+	{0xFD70, "\u0636\u062e\u0645"},
+	{0xFD71, "\u0637\u0645\u062d"},
+	{0xFD72, "\u0637\u0645\u062d"},
+	{0xFD73, "\u0637\u0645\u0645"},
+	{0xFD74, "\u0637\u0645\u0649"},
+	{0xFD75, "\u0639\u062c\u0645"},
+	{0xFD76, "\u0639\u0645\u0645"},
+	{0xFD77, "\u0639\u0645\u0645"},
+	{0xFD78, "\u0639\u0645\u0649"},
+	{0xFD79, "\u063a\u0645\u0645"},
+	{0xFD7A, "\u063a\u0645\u0649"},
+	{0xFD7B, "\u063a\u0645\u0649"},
+	{0xFD7C, "\u0641\u062e\u0645"},
+	{0xFD7D, "\u0641\u062e\u0645"},
+	{0xFD7E, "\u0642\u0645\u062d"},
+	{0xFD7F, "\u0642\u0645\u0645"},
+	{0xFD80, "\u0644\u062d\u0645"},
+	{0xFD81, "\u0644\u062d\u0649"},
+	{0xFD82, "\u0644\u062d\u0649"},
+	{0xFD83, "\u0644\u062c\u062c"},
+	{0xFD84, "\u0644\u062c\u062c"},
+	{0xFD85, "\u0644\u062e\u0645"},
+	{0xFD86, "\u0644\u062e\u0645"},
+	{0xFD87, "\u0644\u0645\u062d"},
+	{0xFD88, "\u0644\u0645\u062d"},
+	{0xFD89, "\u0645\u062d\u062c"},
+	{0xFD8A, "\u0645\u062d\u0645"},
+	{0xFD8B, "\u0645\u062d\u0649"},
+	{0xFD8C, "\u0645\u062c\u062d"},
+	{0xFD8D, "\u0645\u062c\u0645"},
+	{0xFD8E, "\u0645\u062e\u062c"},
+	{0xFD8F, "\u0645\u062e\u0645"},
+	{0xFD92, "\u0645\u062c\u062e"},
+	{0xFD93, "o\u0645\u062c"},
+	{0xFD94, "o\u0645\u0645"},
+	{0xFD95, "\u0646\u062d\u0645"},
+	{0xFD96, "\u0646\u062d\u0649"},
+	{0xFD97, "\u0646\u062c\u0645"},
+	{0xFD98, "\u0646\u062c\u0645"},
+	{0xFD99, "\u0646\u062c\u0649"},
+	{0xFD9A, "\u0646\u0645\u0649"},
+	{0xFD9B, "\u0646\u0645\u0649"},
+	{0xFD9C, "\u0649\u0645\u0645"},
+	{0xFD9D, "\u0649\u0645\u0645"},
+	{0xFD9E, "\u0628\u062e\u0649"},
+	{0xFD9F, "\u062a\u062c\u0649"},
+	{0xFDA0, "\u062a\u062c\u0649"},
+	{0xFDA1, "\u062a\u062e\u0649"},
+	{0xFDA2, "\u062a\u062e\u0649"},
+	{0xFDA3, "\u062a\u0645\u0649"},
+	{0xFDA4, "\u062a\u0645\u0649"},
+	{0xFDA5, "\u062c\u0645\u0649"},
+	{0xFDA6, "\u062c\u062d\u0649"},
+	{0xFDA7, "\u062c\u0645\u0649"},
+	{0xFDA8, "\u0633\u062e\u0649"},
+	{0xFDA9, "\u0635\u062d\u0649"},
+	{0xFDAA, "\u0633\u06db\u062d\u0649"},
+	{0xFDAB, "\u0636\u062d\u0649"},
+	{0xFDAC, "\u0644\u062c\u0649"},
+	{0xFDAD, "\u0644\u0645\u0649"},
+	{0xFDAE, "\u0649\u062d\u0649"},
+	{0xFDAF, "\u0649\u062c\u0649"},
+	{0xFDB0, "\u0649\u0645\u0649"},
+	{0xFDB1, "\u0645\u0645\u0649"},
+	{0xFDB2, "\u0642\u0645\u0649"},
+	{0xFDB3, "\u0646\u062d\u0649"},
+	{0xFDB4, "\u0642\u0645\u062d"},
+	{0xFDB5, "\u0644\u062d\u0645"},
+	{0xFDB6, "\u0639\u0645\u0649"},
+	{0xFDB7, "\u0643\u0645\u0649"},
+	{0xFDB8, "\u0646\u062c\u062d"},
+	{0xFDB9, "\u0645\u062e\u0649"},
+	{0xFDBA, "\u0644\u062c\u0645"},
+	{0xFDBB, "\u0643\u0645\u0645"},
+	{0xFDBC, "\u0644\u062c\u0645"},
+	{0xFDBD, "\u0646\u062c\u062d"},
+	{0xFDBE, "\u062c\u062d\u0649"},
+	{0xFDBF, "\u062d\u062c\u0649"},
+	{0xFDC0, "\u0645\u062c\u0649"},
+	{0xFDC1, "\u0641\u0645\u0649"},
+	{0xFDC2, "\u0628\u062d\u0649"},
+	{0xFDC3, "\u0643\u0645\u0645"},
+	{0xFDC4, "\u0639\u062c\u0645"},
+	{0xFDC5, "\u0635\u0645\u0645"},
+	{0xFDC6, "\u0633\u062e\u0649"},
+	{0xFDC7, "\u0646\u062c\u0649"},
+	{0xFDF0, "\u0635\u0644\u0649"},
+	{0xFDF1, "\u0642\u0644\u0649"},
+	{0xFDF2, "l\u0644\u0644\u0651\u0670o"},
+	{0xFDF3, "l\u0643\u0628\u0631"},
+	{0xFDF4, "\u0645\u062d\u0645\u062f"},
+	{0xFDF5, "\u0635\u0644\u0639\u0645"},
+	{0xFDF6, "\u0631\u0633\u0648\u0644"},
+	{0xFDF7, "\u0639\u0644\u0649o"},
+	{0xFDF8, "\u0648\u0633\u0644\u0645"},
+	{0xFDF9, "\u0635\u0644\u0649"},
+	{0xFDFA, "\u0635\u0644\u0649 l\u0644\u0644o \u0639\u0644\u0649o \u0648\u0633\u0644\u0645"},
+	{0xFDFB, "\u062c\u0644 \u062c\u0644l\u0644o"},
+	{0xFDFC, "\u0631\u0649l\u0644"},
+	{0xFE19, "\u2d57"},
+	{0xFE30, ":"},
+	{0xFE31, "\u2502"},
+	{0xFE34, "\u2307"},
+	{0xFE35, "\u23dc"},
+	{0xFE36, "\u23dd"},
+	{0xFE37, "\u23de"},
+	{0xFE38, "\u23df"},
+	{0xFE39, "\u23e0"},
+	{0xFE3A, "\u23e1"},
+	{0xFE49, "\u02c9"},
+	{0xFE4A, "\u02c9"},
+	{0xFE4B, "\u02c9"},
+	{0xFE4C, "\u02c9"},
+	{0xFE4D, "_"},
+	{0xFE4E, "_"},
+	{0xFE4F, "_"},
+	{0xFE58, "-"},
+	{0xFE68, "\\"},
+	{0xFE80, "\u0621"},
+	{0xFE81, "\u0627\u0653"},
+	{0xFE82, "\u0627\u0653"},
+	{0xFE83, "l\u0674"},
+	{0xFE84, "l\u0674"},
+	{0xFE85, "\u0648\u0674"},
+	{0xFE86, "\u0648\u0674"},
+	{0xFE87, "l\u0655"},
+	{0xFE88, "l\u0655"},
+	{0xFE89, "\u0649\u0674"},
+	{0xFE8A, "\u0649\u0674"},
+	{0xFE8B, "\u0649\u0674"},
+	{0xFE8C, "\u0649\u0674"},
+	{0xFE8D, "l"},
+	{0xFE8E, "l"},
+	{0xFE8F, "\u0628"},
+	{0xFE90, "\u0628"},
+	{0xFE91, "\u0628"},
+	{0xFE92, "\u0628"},
+	{0xFE93, "\u0629"},
+	{0xFE94, "\u0629"},
+	{0xFE95, "\u062a"},
+	{0xFE96, "\u062a"},
+	{0xFE97, "\u062a"},
+	{0xFE98, "\u062a"},
+	{0xFE99, "\u0649\u06db"},
+	{0xFE9A, "\u0649\u06db"},
+	{0xFE9B, "\u0649\u06db"},
+	{0xFE9C, "\u0649\u06db"},
+	{0xFE9D, "\u062c"},
+	{0xFE9E, "\u062c"},
+	{0xFE9F, "\u062c"},
+	{0xFEA0, "\u062c"},
+	{0xFEA1, "\u062d"},
+	{0xFEA2, "\u062d"},
+	{0xFEA3, "\u062d"},
+	{0xFEA4, "\u062d"},
+	{0xFEA5, "\u062e"},
+	{0xFEA6, "\u062e"},
+	{0xFEA7, "\u062e"},
+	{0xFEA8, "\u062e"},
+	{0xFEA9, "\u062f"},
+	{0xFEAA, "\u062f"},
+	{0xFEAB, "\u0630"},
+	{0xFEAC, "\u0630"},
+	{0xFEAD, "\u0631"},
+	{0xFEAE, "\u0631"},
+	{0xFEAF, "\u0632"},
+	{0xFEB0, "\u0632"},
+	{0xFEB1, "\u0633"},
+	{0xFEB2, "\u0633"},
+	{0xFEB3, "\u0633"},
+	{0xFEB4, "\u0633"},
+	{0xFEB5, "\u0633\u06db"},
+	{0xFEB6, "\u0633\u06db"},
+	{0xFEB7, "\u0633\u06db"},
+	{0xFEB8, "\u0633\u06db"},
+	{0xFEB9, "\u0635"},
+	{0xFEBA, "\u0635"},
+	{0xFEBB, "\u0635"},
+	{0xFEBC, "\u0635"},
+	{0xFEBD, "\u0636"},
+	{0xFEBE, "\u0636"},
+	{0xFEBF, "\u0636"},
+	{0xFEC0, "\u0636"},
+	{0xFEC1, "\u0637"},
+	{0xFEC2, "\u0637"},
+	{0xFEC3, "\u0637"},
+	{0xFEC4, "\u0637"},
+	{0xFEC5, "\u0638"},
+	{0xFEC6, "\u0638"},
+	{0xFEC7, "\u0638"},
+	{0xFEC8, "\u0638"},
+	{0xFEC9, "\u0639"},
+	{0xFECA, "\u0639"},
+	{0xFECB, "\u0639"},
+	{0xFECC, "\u0639"},
+	{0xFECD, "\u063a"},
+	{0xFECE, "\u063a"},
+	{0xFECF, "\u063a"},
+	{0xFED0, "\u063a"},
+	{0xFED1, "\u0641"},
+	{0xFED2, "\u0641"},
+	{0xFED3, "\u0641"},
+	{0xFED4, "\u0641"},
+	{0xFED5, "\u0642"},
+	{0xFED6, "\u0642"},
+	{0xFED7, "\u0642"},
+	{0xFED8, "\u0642"},
+	{0xFED9, "\u0643"},
+	{0xFEDA, "\u0643"},
+	{0xFEDB, "\u0643"},
+	{0xFEDC, "\u0643"},
+	{0xFEDD, "\u0644"},
+	{0xFEDE, "\u0644"},
+	{0xFEDF, "\u0644"},
+	{0xFEE0, "\u0644"},
+	{0xFEE1, "\u0645"},
+	{0xFEE2, "\u0645"},
+	{0xFEE3, "\u0645"},
+	{0xFEE4, "\u0645"},
+	{0xFEE5, "\u0646"},
+	{0xFEE6, "\u0646"},
+	{0xFEE7, "\u0646"},
+	{0xFEE8, "\u0646"},
+	{0xFEE9, "o"},
+	{0xFEEA, "o"},
+	{0xFEEB, "o"},
+	{0xFEEC, "o"},
+	{0xFEED, "\u0648"},
+	{0xFEEE, "\u0648"},
+	{0xFEEF, "\u0649"},
+	{0xFEF0, "\u0649"},
+	{0xFEF1, "\u0649"},
+	{0xFEF2, "\u0649"},
+	{0xFEF3, "\u0649"},
+	{0xFEF4, "\u0649"},
+	{0xFEF5, "\u0644\u0627\u0653"},
+	{0xFEF6, "\u0644\u0627\u0653"},
+	{0xFEF7, "\u0644l\u0674"},
+	{0xFEF8, "\u0644l\u0674"},
+	{0xFEF9, "\u0644l\u0655"},
+	{0xFEFA, "\u0644l\u0655"},
+	{0xFEFB, "\u0644l"},
+	{0xFEFC, "\u0644l"},
+	{0xFF01, "!"},
+	{0xFF02, "''"},
+	{0xFF07, "'"},
+	{0xFF0D, "\u30fc"},
+	{0xFF1A, ":"},
+	{0xFF21, "A"},
+	{0xFF22, "B"},
+	{0xFF23, "C"},
+	{0xFF25, "E"},
+	{0xFF28, "H"},
+	{0xFF29, "l"},
+	{0xFF2A, "J"},
+	{0xFF2B, "K"},
+	{0xFF2D, "M"},
+	{0xFF2E, "N"},
+	{0xFF2F, "O"},
+	{0xFF30, "P"},
+	{0xFF33, "S"},
+	{0xFF34, "T"},
+	{0xFF38, "X"},
+	{0xFF39, "Y"},
+	{0xFF3A, "Z"},
+	{0xFF3B, "("},
+	{0xFF3C, "\\"},
+	{0xFF3D, ")"},
+	{0xFF3E, "\ufe3f"},
+	{0xFF40, "'"},
+	{0xFF41, "a"},
+	{0xFF43, "c"},
+	{0xFF45, "e"},
+	{0xFF47, "g"},
+	{0xFF48, "h"},
+	{0xFF49, "i"},
+	{0xFF4A, "j"},
+	{0xFF4C, "l"},
+	{0xFF4F, "o"},
+	{0xFF50, "p"},
+	{0xFF53, "s"},
+	{0xFF56, "v"},
+	{0xFF58, "x"},
+	{0xFF59, "y"},
+	{0xFF5C, "\u2502"},
+	{0xFF5E, "\u301c"},
+	{0xFF65, "\u00b7"},
+	{0xFFE3, "\u02c9"},
+	{0xFFE8, "l"},
+	{0xFFED, "\u25aa"},
+	{0x10101, "\u00b7"},
+	{0x1018E, "N\u030a"},
+	{0x10196, "X\u0335"},
+	{0x10197, "V\u0335"},
+	{0x10198, "l\u0335l\u0335S\u0335"},
+	{0x10199, "l\u0335l\u0335"},
+	{0x101A0, "\u2ce8"},
+	{0x10282, "B"},
+	{0x10285, "\u0394"},
+	{0x10286, "E"},
+	{0x10287, "F"},
+	{0x1028A, "l"},
+	{0x1028D, "\u0245"},
+	{0x10290, "X"},
+	{0x10292, "O"},
+	{0x10294, "\u16dc"},
+	{0x10295, "P"},
+	{0x10296, "S"},
+	{0x10297, "T"},
+	{0x1029B, "+"},
+	{0x102A0, "A"},
+	{0x102A1, "B"},
+	{0x102A2, "C"},
+	{0x102A3, "\u0394"},
+	{0x102A5, "F"},
+	{0x102AB, "O"},
+	{0x102AD, "\u03d8"},
+	{0x102B0, "M"},
+	{0x102B1, "T"},
+	{0x102B2, "Y"},
+	{0x102B3, "\u03a6"},
+	{0x102B4, "X"},
+	{0x102B5, "\u03a8"},
+	{0x102B6, "\u03a9"},
+	{0x102B8, "\u2d40"},
+	{0x102CF, "H"},
+	{0x102E1, "\u062f"},
+	{0x102E4, "\u0648"},
+	{0x102E8, "\u0637"},
+	{0x102F2, "\u0635"},
+	{0x102F5, "Z"},
+	{0x10301, "B"},
+	{0x10302, "C"},
+	{0x10309, "l"},
+	{0x10311, "M"},
+	{0x10312, "\u03d8"},
+	{0x10315, "T"},
+	{0x10317, "X"},
+	{0x1031A, "8"},
+	{0x1031F, "*"},
+	{0x10320, "l"},
+	{0x10322, "X"},
+	{0x103D1, "\U00010382"},
+	{0x103D3, "\U00010393"},
+	{0x10401, "\u0190"},
+	{0x10404, "O"},
+	{0x10411, "\ua4f6"},
+	{0x10415, "C"},
+	{0x1041B, "L"},
+	{0x1041F, "\u2c70"},
+	{0x10420, "S"},
+	{0x10423, "\u0186"},
+	{0x10425, "\u0418"},
+	{0x10429, "\ua793"},
+	{0x1042A, "\u029a"},
+	{0x1042C, "o"},
+	{0x1043D, "c"},
+	{0x1043F, "\u0277"},
+	{0x10442, "\u025e"},
+	{0x10443, "\u029f"},
+	{0x10448, "s"},
+	{0x1044B, "\u0254"},
+	{0x1044D, "\u1d0e"},
+	{0x104A0, "\U00010486"},
+	{0x104B0, "\u0245"},
+	{0x104B4, "R"},
+	{0x104BC, "\u04c3"},
+	{0x104C2, "O"},
+	{0x104C3, "\u0298"},
+	{0x104C4, "\u00de"},
+	{0x104CD, "\u040b"},
+	{0x104CE, "U"},
+	{0x104D0, "\u16e6"},
+	{0x104D1, "\u03a8"},
+	{0x104D2, "7"},
+	{0x104D8, "\u028c"},
+	{0x104DB, "\u03bb"},
+	{0x104EA, "o"},
+	{0x104EB, "\ua669"},
+	{0x104F6, "u"},
+	{0x104F9, "\u03c8"},
+	{0x10513, "N"},
+	{0x10516, "O"},
+	{0x10518, "K"},
+	{0x1051C, "C"},
+	{0x1051D, "V"},
+	{0x10525, "F"},
+	{0x10526, "L"},
+	{0x10527, "X"},
+	{0x10A3A, "\u0323"},
+	{0x10A50, "."},
+	{0x10A57, "\U00010a56\U00010a56"},
+	{0x10CFA, "\U00010ca5"},
+	{0x10CFC, "\U00010c82"},
+	{0x110BB, "\u0970"},
+	{0x111C7, "\u0970"},
+	{0x111CA, "\u0323"},
+	{0x111CB, "\u093a"},
+	{0x111DB, "\ua8fc"},
+	{0x111DC, "\ua8fb"},
+	{0x111DE, "\u2248"},
+	{0x11300, "\u030a"},
+	{0x11413, "\U00011434\U00011442\U00011412"},
+	{0x11419, "\U00011434\U00011442\U00011418"},
+	{0x11424, "\U00011434\U00011442\U00011423"},
+	{0x1142A, "\U00011434\U00011442\U00011429"},
+	{0x1142D, "\U00011434\U00011442\U0001142c"},
+	{0x1142F, "\U00011434\U00011442\U0001142e"},
+	{0x1144C, "\U0001144b\U0001144b"},
+	{0x11492, "\u0998"},
+	{0x11494, "\u099a"},
+	{0x11496, "\u099c"},
+	{0x11498, "\u099e"},
+	{0x11499, "\u099f"},
+	{0x1149B, "\u09a1"},
+	{0x1149D, "\u09b2"},
+	{0x1149E, "\u09a4"},
+	{0x1149F, "\u09a5"},
+	{0x114A0, "\u09a6"},
+	{0x114A1, "\u09a7"},
+	{0x114A2, "\u09a8"},
+	{0x114A3, "\u09aa"},
+	{0x114A7, "\u09ae"},
+	{0x114A8, "\u09af"},
+	{0x114A9, "\u09ac"},
+	{0x114AA, "\u09a3"},
+	{0x114AB, "\u09b0"},
+	{0x114AD, "\u09b7"},
+	{0x114AE, "\u09b8"},
+	{0x114B0, "\u09be"},
+	{0x114B1, "\u09bf"},
+	{0x114B9, "\u09c7"},
+	{0x114BC, "\u09c7\u09be"},
+	{0x114BD, "\u09d7"},
+	{0x114BE, "\u09c7\u09d7"},
+	{0x114BF, "\u0306\u0307"},
+	{0x114C1, "\u0983"},
+	{0x114C2, "\u09cd"},
+	{0x114C3, "\u0323"},
+	{0x114C4, "\u09bd"},
+	{0x114C5, "w\u0307"},
+	{0x114D0, "O"},
+	{0x114D1, "\u09e7"},
+	{0x114D2, "\u09e8"},
+	{0x114D6, "\u09ec"},
+	{0x115D8, "\U00011582"},
+	{0x115D9, "\U00011582"},
+	{0x115DA, "\U00011583"},
+	{0x115DB, "\U00011584"},
+	{0x115DC, "\U000115b2"},
+	{0x115DD, "\U000115b3"},
+	{0x11642, "\U00011641\U00011641"},
+	{0x11700, "rn"},
+	{0x11706, "v"},
+	{0x1170A, "w"},
+	{0x1170E, "w"},
+	{0x1170F, "w"},
+	{0x118A0, "V"},
+	{0x118A2, "F"},
+	{0x118A3, "L"},
+	{0x118A4, "Y"},
+	{0x118A6, "E"},
+	{0x118A8, "\u2207"},
+	{0x118A9, "Z"},
+	{0x118AC, "9"},
+	{0x118AE, "E"},
+	{0x118AF, "4"},
+	{0x118B2, "L"},
+	{0x118B5, "O"},
+	{0x118B7, "\u16dc"},
+	{0x118B8, "U"},
+	{0x118BB, "5"},
+	{0x118BC, "T"},
+	{0x118C0, "v"},
+	{0x118C1, "s"},
+	{0x118C2, "F"},
+	{0x118C3, "i"},
+	{0x118C4, "z"},
+	{0x118C6, "7"},
+	{0x118C8, "o"},
+	{0x118CA, "3"},
+	{0x118CC, "9"},
+	{0x118CE, "\ua793"},
+	{0x118D5, "6"},
+	{0x118D6, "9"},
+	{0x118D7, "o"},
+	{0x118D8, "u"},
+	{0x118DC, "y"},
+	{0x118E0, "O"},
+	{0x118E3, "rn"},
+	{0x118E4, "\u0669"},
+	{0x118E5, "Z"},
+	{0x118E6, "W"},
+	{0x118E9, "C"},
+	{0x118EC, "X"},
+	{0x118EF, "W"},
+	{0x118F2, "C"},
+	{0x11AE6, "\U00011ae5\U00011aef"},
+	{0x11AE7, "\U00011ae5\U00011af0"},
+	{0x11AE8, "\U00011ae5\U00011ae5"},
+	{0x11AE9, "\U00011ae5\U00011ae5\U00011aef"},
+	{0x11AEA, "\U00011ae5\U00011ae5\U00011af0"},
+	{0x11AEC, "\U00011aeb\U00011aef"},
+	{0x11AED, "\U00011aeb\U00011aeb"},
+	{0x11AEE, "\U00011aeb\U00011aeb\U00011aef"},
+	{0x11AF4, "\U00011af3\U00011aef"},
+	{0x11AF5, "\U00011af3\U00011af0"},
+	{0x11AF6, "\U00011af3\U00011af3"},
+	{0x11AF7, "\U00011af3\U00011af3\U00011aef"},
+	{0x11AF8, "\U00011af3\U00011af3\U00011af0"},
+	{0x11C42, "\U00011c41\U00011c41"},
+	{0x11CB2, "\U00011caa"},
+	{0x12038, "\U0001039a"},
+	{0x132F9, "\U0001099e"},
+	{0x16F07, "\u0393"},
+	{0x16F08, "V"},
+	{0x16F0A, "T"},
+	{0x16F16, "L"},
+	{0x16F1A, "\u0394"},
+	{0x16F1C, "\ua658"},
+	{0x16F26, "\ua4f6"},
+	{0x16F28, "l"},
+	{0x16F2D, "\u0190"},
+	{0x16F35, "R"},
+	{0x16F3A, "S"},
+	{0x16F3B, "3"},
+	{0x16F3D, "\u0245"},
+	{0x16F3F, ">"},
+	{0x16F40, "A"},
+	{0x16F42, "U"},
+	{0x16F43, "Y"},
+	{0x16F51, "'"},
+	{0x16F52, "'"},
+	{0x1D114, "{"},
+	{0x1D16D, "."},
+	{0x1D202, "\u04fe"},
+	{0x1D206, "3"},
+	{0x1D20B, "\u0418"},
+	{0x1D20D, "V"},
+	{0x1D20F, "\\"},
+	{0x1D212, "7"},
+	{0x1D213, "F"},
+	{0x1D214, "\U000102bc"},
+	{0x1D215, "\ua4f6"},
+	{0x1D216, "R"},
+	{0x1D217, "\u2c6f"},
+	{0x1D21A, "O\u0335"},
+	{0x1D21B, "\u2144"},
+	{0x1D21C, "\ua4d5"},
+	{0x1D221, "\u0190"},
+	{0x1D222, "\u0460"},
+	{0x1D22A, "L"},
+	{0x1D22B, "\ua4f6"},
+	{0x1D230, "\ua7fb"},
+	{0x1D236, "<"},
+	{0x1D237, ">"},
+	{0x1D238, "\u228f"},
+	{0x1D239, "\u2290"},
+	{0x1D23A, "/"},
+	{0x1D23B, "\\"},
+	{0x1D23F, "\u16cb"},
+	{0x1D245, "\u0548"},
+	{0x1D400, "A"},
+	{0x1D401, "B"},
+	{0x1D402, "C"},
+	{0x1D403, "D"},
+	{0x1D404, "E"},
+	{0x1D405, "F"},
+	{0x1D406, "G"},
+	{0x1D407, "H"},
+	{0x1D408, "l"},
+	{0x1D409, "J"},
+	{0x1D40A, "K"},
+	{0x1D40B, "L"},
+	{0x1D40C, "M"},
+	{0x1D40D, "N"},
+	{0x1D40E, "O"},
+	{0x1D40F, "P"},
+	{0x1D410, "Q"},
+	{0x1D411, "R"},
+	{0x1D412, "S"},
+	{0x1D413, "T"},
+	{0x1D414, "U"},
+	{0x1D415, "V"},
+	{0x1D416, "W"},
+	{0x1D417, "X"},
+	{0x1D418, "Y"},
+	{0x1D419, "Z"},
+	{0x1D41A, "a"},
+	{0x1D41B, "b"},
+	{0x1D41C, "c"},
+	{0x1D41D, "d"},
+	{0x1D41E, "e"},
+	{0x1D41F, "f"},
+	{0x1D420, "g"},
+	{0x1D421, "h"},
+	{0x1D422, "i"},
+	{0x1D423, "j"},
+	{0x1D424, "k"},
+	{0x1D425, "l"},
+	{0x1D426, "rn"},
+	{0x1D427, "n"},
+	{0x1D428, "o"},
+	{0x1D429, "p"},
+	{0x1D42A, "q"},
+	{0x1D42B, "r"},
+	{0x1D42C, "s"},
+	{0x1D42D, "t"},
+	{0x1D42E, "u"},
+	{0x1D42F, "v"},
+	{0x1D430, "w"},
+	{0x1D431, "x"},
+	{0x1D432, "y"},
+	{0x1D433, "z"},
+	{0x1D434, "A"},
+	{0x1D435, "B"},
+	{0x1D436, "C"},
+	{0x1D437, "D"},
+	{0x1D438, "E"},
+	{0x1D439, "F"},
+	{0x1D43A, "G"},
+	{0x1D43B, "H"},
+	{0x1D43C, "l"},
+	{0x1D43D, "J"},
+	{0x1D43E, "K"},
+	{0x1D43F, "L"},
+	{0x1D440, "M"},
+	{0x1D441, "N"},
+	{0x1D442, "O"},
+	{0x1D443, "P"},
+	{0x1D444, "Q"},
+	{0x1D445, "R"},
+	{0x1D446, "S"},
+	{0x1D447, "T"},
+	{0x1D448, "U"},
+	{0x1D449, "V"},
+	{0x1D44A, "W"},
+	{0x1D44B, "X"},
+	{0x1D44C, "Y"},
+	{0x1D44D, "Z"},
+	{0x1D44E, "a"},
+	{0x1D44F, "b"},
+	{0x1D450, "c"},
+	{0x1D451, "d"},
+	{0x1D452, "e"},
+	{0x1D453, "f"},
+	{0x1D454, "g"},
+	{0x1D456, "i"},
+	{0x1D457, "j"},
+	{0x1D458, "k"},
+	{0x1D459, "l"},
+	{0x1D45A, "rn"},
+	{0x1D45B, "n"},
+	{0x1D45C, "o"},
+	{0x1D45D, "p"},
+	{0x1D45E, "q"},
+	{0x1D45F, "r"},
+	{0x1D460, "s"},
+	{0x1D461, "t"},
+	{0x1D462, "u"},
+	{0x1D463, "v"},
+	{0x1D464, "w"},
+	{0x1D465, "x"},
+	{0x1D466, "y"},
+	{0x1D467, "z"},
+	{0x1D468, "A"},
+	{0x1D469, "B"},
+	{0x1D46A, "C"},
+	{0x1D46B, "D"},
+	{0x1D46C, "E"},
+	{0x1D46D, "F"},
+	{0x1D46E, "G"},
+	{0x1D46F, "H"},
+	{0x1D470, "l"},
+	{0x1D471, "J"},
+	{0x1D472, "K"},
+	{0x1D473, "L"},
+	{0x1D474, "M"},
+	{0x1D475, "N"},
+	{0x1D476, "O"},
+	{0x1D477, "P"},
+	{0x1D478, "Q"},
+	{0x1D479, "R"},
+	{0x1D47A, "S"},
+	{0x1D47B, "T"},
+	{0x1D47C, "U"},
+	{0x1D47D, "V"},
+	{0x1D47E, "W"},
+	{0x1D47F, "X"},
+	{0x1D480, "Y"},
+	{0x1D481, "Z"},
+	{0x1D482, "a"},
+	{0x1D483, "b"},
+	{0x1D484, "c"},
+	{0x1D485, "d"},
+	{0x1D486, "e"},
+	{0x1D487, "f"},
+	{0x1D488, "g"},
+	{0x1D489, "h"},
+	{0x1D48A, "i"},
+	{0x1D48B, "j"},
+	{0x1D48C, "k"},
+	{0x1D48D, "l"},
+	{0x1D48E, "rn"},
+	{0x1D48F, "n"},
+	{0x1D490, "o"},
+	{0x1D491, "p"},
+	{0x1D492, "q"},
+	{0x1D493, "r"},
+	{0x1D494, "s"},
+	{0x1D495, "t"},
+	{0x1D496, "u"},
+	{0x1D497, "v"},
+	{0x1D498, "w"},
+	{0x1D499, "x"},
+	{0x1D49A, "y"},
+	{0x1D49B, "z"},
+	{0x1D49C, "A"},
+	{0x1D49E, "C"},
+	{0x1D49F, "D"},
+	{0x1D4A2, "G"},
+	{0x1D4A5, "J"},
+	{0x1D4A6, "K"},
+	{0x1D4A9, "N"},
+	{0x1D4AA, "O"},
+	{0x1D4AB, "P"},
+	{0x1D4AC, "Q"},
+	{0x1D4AE, "S"},
+	{0x1D4AF, "T"},
+	{0x1D4B0, "U"},
+	{0x1D4B1, "V"},
+	{0x1D4B2, "W"},
+	{0x1D4B3, "X"},
+	{0x1D4B4, "Y"},
+	{0x1D4B5, "Z"},
+	{0x1D4B6, "a"},
+	{0x1D4B7, "b"},
+	{0x1D4B8, "c"},
+	{0x1D4B9, "d"},
+	{0x1D4BB, "f"},
+	{0x1D4BD, "h"},
+	{0x1D4BE, "i"},
+	{0x1D4BF, "j"},
+	{0x1D4C0, "k"},
+	{0x1D4C1, "l"},
+	{0x1D4C2, "rn"},
+	{0x1D4C3, "n"},
+	{0x1D4C5, "p"},
+	{0x1D4C6, "q"},
+	{0x1D4C7, "r"},
+	{0x1D4C8, "s"},
+	{0x1D4C9, "t"},
+	{0x1D4CA, "u"},
+	{0x1D4CB, "v"},
+	{0x1D4CC, "w"},
+	{0x1D4CD, "x"},
+	{0x1D4CE, "y"},
+	{0x1D4CF, "z"},
+	{0x1D4D0, "A"},
+	{0x1D4D1, "B"},
+	{0x1D4D2, "C"},
+	{0x1D4D3, "D"},
+	{0x1D4D4, "E"},
+	{0x1D4D5, "F"},
+	{0x1D4D6, "G"},
+	{0x1D4D7, "H"},
+	{0x1D4D8, "l"},
+	{0x1D4D9, "J"},
+	{0x1D4DA, "K"},
+	{0x1D4DB, "L"},
+	{0x1D4DC, "M"},
+	{0x1D4DD, "N"},
+	{0x1D4DE, "O"},
+	{0x1D4DF, "P"},
+	{0x1D4E0, "Q"},
+	{0x1D4E1, "R"},
+	{0x1D4E2, "S"},
+	{0x1D4E3, "T"},
+	{0x1D4E4, "U"},
+	{0x1D4E5, "V"},
+	{0x1D4E6, "W"},
+	{0x1D4E7, "X"},
+	{0x1D4E8, "Y"},
+	{0x1D4E9, "Z"},
+	{0x1D4EA, "a"},
+	{0x1D4EB, "b"},
+	{0x1D4EC, "c"},
+	{0x1D4ED, "d"},
+	{0x1D4EE, "e"},
+	{0x1D4EF, "f"},
+	{0x1D4F0, "g"},
+	{0x1D4F1, "h"},
+	{0x1D4F2, "i"},
+	{0x1D4F3, "j"},
+	{0x1D4F4, "k"},
+	{0x1D4F5, "l"},
+	{0x1D4F6, "rn"},
+	{0x1D4F7, "n"},
+	{0x1D4F8, "o"},
+	{0x1D4F9, "p"},
+	{0x1D4FA, "q"},
+	{0x1D4FB, "r"},
+	{0x1D4FC, "s"},
+	{0x1D4FD, "t"},
+	{0x1D4FE, "u"},
+	{0x1D4FF, "v"},
+	{0x1D500, "w"},
+	{0x1D501, "x"},
+	{0x1D502, "y"},
+	{0x1D503, "z"},
+	{0x1D504, "A"},
+	{0x1D505, "B"},
+	{0x1D507, "D"},
+	{0x1D508, "E"},
+	{0x1D509, "F"},
+	{0x1D50A, "G"},
+	{0x1D50D, "J"},
+	{0x1D50E, "K"},
+	{0x1D50F, "L"},
+	{0x1D510, "M"},
+	{0x1D511, "N"},
+	{0x1D512, "O"},
+	{0x1D513, "P"},
+	{0x1D514, "Q"},
+	{0x1D516, "S"},
+	{0x1D517, "T"},
+	{0x1D518, "U"},
+	{0x1D519, "V"},
+	{0x1D51A, "W"},
+	{0x1D51B, "X"},
+	{0x1D51C, "Y"},
+	{0x1D51E, "a"},
+	{0x1D51F, "b"},
+	{0x1D520, "c"},
+	{0x1D521, "d"},
+	{0x1D522, "e"},
+	{0x1D523, "f"},
+	{0x1D524, "g"},
+	{0x1D525, "h"},
+	{0x1D526, "i"},
+	{0x1D527, "j"},
+	{0x1D528, "k"},
+	{0x1D529, "l"},
+	{0x1D52A, "rn"},
+	{0x1D52B, "n"},
+	{0x1D52C, "o"},
+	{0x1D52D, "p"},
+	{0x1D52E, "q"},
+	{0x1D52F, "r"},
+	{0x1D530, "s"},
+	{0x1D531, "t"},
+	{0x1D532, "u"},
+	{0x1D533, "v"},
+	{0x1D534, "w"},
+	{0x1D535, "x"},
+	{0x1D536, "y"},
+	{0x1D537, "z"},
+	{0x1D538, "A"},
+	{0x1D539, "B"},
+	{0x1D53B, "D"},
+	{0x1D53C, "E"},
+	{0x1D53D, "F"},
+	{0x1D53E, "G"},
+	{0x1D540, "l"},
+	{0x1D541, "J"},
+	{0x1D542, "K"},
+	{0x1D543, "L"},
+	{0x1D544, "M"},
+	{0x1D546, "O"},
+	{0x1D54A, "S"},
+	{0x1D54B, "T"},
+	{0x1D54C, "U"},
+	{0x1D54D, "V"},
+	{0x1D54E, "W"},
+	{0x1D54F, "X"},
+	{0x1D550, "Y"},
+	{0x1D552, "a"},
+	{0x1D553, "b"},
+	{0x1D554, "c"},
+	{0x1D555, "d"},
+	{0x1D556, "e"},
+	{0x1D557, "f"},
+	{0x1D558, "g"},
+	{0x1D559, "h"},
+	{0x1D55A, "i"},
+	{0x1D55B, "j"},
+	{0x1D55C, "k"},
+	{0x1D55D, "l"},
+	{0x1D55E, "rn"},
+	{0x1D55F, "n"},
+	{0x1D560, "o"},
+	{0x1D561, "p"},
+	{0x1D562, "q"},
+	{0x1D563, "r"},
+	{0x1D564, "s"},
+	{0x1D565, "t"},
+	{0x1D566, "u"},
+	{0x1D567, "v"},
+	{0x1D568, "w"},
+	{0x1D569, "x"},
+	{0x1D56A, "y"},
+	{0x1D56B, "z"},
+	{0x1D56C, "A"},
+	{0x1D56D, "B"},
+	{0x1D56E, "C"},
+	{0x1D56F, "D"},
+	{0x1D570, "E"},
+	{0x1D571, "F"},
+	{0x1D572, "G"},
+	{0x1D573, "H"},
+	{0x1D574, "l"},
+	{0x1D575, "J"},
+	{0x1D576, "K"},
+	{0x1D577, "L"},
+	{0x1D578, "M"},
+	{0x1D579, "N"},
+	{0x1D57A, "O"},
+	{0x1D57B, "P"},
+	{0x1D57C, "Q"},
+	{0x1D57D, "R"},
+	{0x1D57E, "S"},
+	{0x1D57F, "T"},
+	{0x1D580, "U"},
+	{0x1D581, "V"},
+	{0x1D582, "W"},
+	{0x1D583, "X"},
+	{0x1D584, "Y"},
+	{0x1D585, "Z"},
+	{0x1D586, "a"},
+	{0x1D587, "b"},
+	{0x1D588, "c"},
+	{0x1D589, "d"},
+	{0x1D58A, "e"},
+	{0x1D58B, "f"},
+	{0x1D58C, "g"},
+	{0x1D58D, "h"},
+	{0x1D58E, "i"},
+	{0x1D58F, "j"},
+	{0x1D590, "k"},
+	{0x1D591, "l"},
+	{0x1D592, "rn"},
+	{0x1D593, "n"},
+	{0x1D594, "o"},
+	{0x1D595, "p"},
+	{0x1D596, "q"},
+	{0x1D597, "r"},
+	{0x1D598, "s"},
+	{0x1D599, "t"},
+	{0x1D59A, "u"},
+	{0x1D59B, "v"},
+	{0x1D59C, "w"},
+	{0x1D59D, "x"},
+	{0x1D59E, "y"},
+	{0x1D59F, "z"},
+	{0x1D5A0, "A"},
+	{0x1D5A1, "B"},
+	{0x1D5A2, "C"},
+	{0x1D5A3, "D"},
+	{0x1D5A4, "E"},
+	{0x1D5A5, "F"},
+	{0x1D5A6, "G"},
+	{0x1D5A7, "H"},
+	{0x1D5A8, "l"},
+	{0x1D5A9, "J"},
+	{0x1D5AA, "K"},
+	{0x1D5AB, "L"},
+	{0x1D5AC, "M"},
+	{0x1D5AD, "N"},
+	{0x1D5AE, "O"},
+	{0x1D5AF, "P"},
+	{0x1D5B0, "Q"},
+	{0x1D5B1, "R"},
+	{0x1D5B2, "S"},
+	{0x1D5B3, "T"},
+	{0x1D5B4, "U"},
+	{0x1D5B5, "V"},
+	{0x1D5B6, "W"},
+	{0x1D5B7, "X"},
+	{0x1D5B8, "Y"},
+	{0x1D5B9, "Z"},
+	{0x1D5BA, "a"},
+	{0x1D5BB, "b"},
+	{0x1D5BC, "c"},
+	{0x1D5BD, "d"},
+	{0x1D5BE, "e"},
+	{0x1D5BF, "f"},
+	{0x1D5C0, "g"},
+	{0x1D5C1, "h"},
+	{0x1D5C2, "i"},
+	{0x1D5C3, "j"},
+	{0x1D5C4, "k"},
+	{0x1D5C5, "l"},
+	{0x1D5C6, "rn"},
+	{0x1D5C7, "n"},
+	{0x1D5C8, "o"},
+	{0x1D5C9, "p"},
+	{0x1D5CA, "q"},
+	{0x1D5CB, "r"},
+	{0x1D5CC, "s"},
+	{0x1D5CD, "t"},
+	{0x1D5CE, "u"},
+	{0x1D5CF, "v"},
+	{0x1D5D0, "w"},
+	{0x1D5D1, "x"},
+	{0x1D5D2, "y"},
+	{0x1D5D3, "z"},
+	{0x1D5D4, "A"},
+	{0x1D5D5, "B"},
+	{0x1D5D6, "C"},
+	{0x1D5D7, "D"},
+	{0x1D5D8, "E"},
+	{0x1D5D9, "F"},
+	{0x1D5DA, "G"},
+	{0x1D5DB, "H"},
+	{0x1D5DC, "l"},
+	{0x1D5DD, "J"},
+	{0x1D5DE, "K"},
+	{0x1D5DF, "L"},
+	{0x1D5E0, "M"},
+	{0x1D5E1, "N"},
+	{0x1D5E2, "O"},
+	{0x1D5E3, "P"},
+	{0x1D5E4, "Q"},
+	{0x1D5E5, "R"},
+	{0x1D5E6, "S"},
+	{0x1D5E7, "T"},
+	{0x1D5E8, "U"},
+	{0x1D5E9, "V"},
+	{0x1D5EA, "W"},
+	{0x1D5EB, "X"},
+	{0x1D5EC, "Y"},
+	{0x1D5ED, "Z"},
+	{0x1D5EE, "a"},
+	{0x1D5EF, "b"},
+	{0x1D5F0, "c"},
+	{0x1D5F1, "d"},
+	{0x1D5F2, "e"},
+	{0x1D5F3, "f"},
+	{0x1D5F4, "g"},
+	{0x1D5F5, "h"},
+	{0x1D5F6, "i"},
+	{0x1D5F7, "j"},
+	{0x1D5F8, "k"},
+	{0x1D5F9, "l"},
+	{0x1D5FA, "rn"},
+	{0x1D5FB, "n"},
+	{0x1D5FC, "o"},
+	{0x1D5FD, "p"},
+	{0x1D5FE, "q"},
+	{0x1D5FF, "r"},
+	{0x1D600, "s"},
+	{0x1D601, "t"},
+	{0x1D602, "u"},
+	{0x1D603, "v"},
+	{0x1D604, "w"},
+	{0x1D605, "x"},
+	{0x1D606, "y"},
+	{0x1D607, "z"},
+	{0x1D608, "A"},
+	{0x1D609, "B"},
+	{0x1D60A, "C"},
+	{0x1D60B, "D"},
+	{0x1D60C, "E"},
+	{0x1D60D, "F"},
+	{0x1D60E, "G"},
+	{0x1D60F, "H"},
+	{0x1D610, "l"},
+	{0x1D611, "J"},
+	{0x1D612, "K"},
+	{0x1D613, "L"},
+	{0x1D614, "M"},
+	{0x1D615, "N"},
+	{0x1D616, "O"},
+	{0x1D617, "P"},
+	{0x1D618, "Q"},
+	{0x1D619, "R"},
+	{0x1D61A, "S"},
+	{0x1D61B, "T"},
+	{0x1D61C, "U"},
+	{0x1D61D, "V"},
+	{0x1D61E, "W"},
+	{0x1D61F, "X"},
+	{0x1D620, "Y"},
+	{0x1D621, "Z"},
+	{0x1D622, "a"},
+	{0x1D623, "b"},
+	{0x1D624, "c"},
+	{0x1D625, "d"},
+	{0x1D626, "e"},
+	{0x1D627, "f"},
+	{0x1D628, "g"},
+	{0x1D629, "h"},
+	{0x1D62A, "i"},
+	{0x1D62B, "j"},
+	{0x1D62C, "k"},
+	{0x1D62D, "l"},
+	{0x1D62E, "rn"},
+	{0x1D62F, "n"},
+	{0x1D630, "o"},
+	{0x1D631, "p"},
+	{0x1D632, "q"},
+	{0x1D633, "r"},
+	{0x1D634, "s"},
+	{0x1D635, "t"},
+	{0x1D636, "u"},
+	{0x1D637, "v"},
+	{0x1D638, "w"},
+	{0x1D639, "x"},
+	{0x1D63A, "y"},
+	{0x1D63B, "z"},
+	{0x1D63C, "A"},
+	{0x1D63D, "B"},
+	{0x1D63E, "C"},
+	{0x1D63F, "D"},
+	{0x1D640, "E"},
+	{0x1D641, "F"},
+	{0x1D642, "G"},
+	{0x1D643, "H"},
+	{0x1D644, "l"},
+	{0x1D645, "J"},
+	{0x1D646, "K"},
+	{0x1D647, "L"},
+	{0x1D648, "M"},
+	{0x1D649, "N"},
+	{0x1D64A, "O"},
+	{0x1D64B, "P"},
+	{0x1D64C, "Q"},
+	{0x1D64D, "R"},
+	{0x1D64E, "S"},
+	{0x1D64F, "T"},
+	{0x1D650, "U"},
+	{0x1D651, "V"},
+	{0x1D652, "W"},
+	{0x1D653, "X"},
+	{0x1D654, "Y"},
+	{0x1D655, "Z"},
+	{0x1D656, "a"},
+	{0x1D657, "b"},
+	{0x1D658, "c"},
+	{0x1D659, "d"},
+	{0x1D65A, "e"},
+	{0x1D65B, "f"},
+	{0x1D65C, "g"},
+	{0x1D65D, "h"},
+	{0x1D65E, "i"},
+	{0x1D65F, "j"},
+	{0x1D660, "k"},
+	{0x1D661, "l"},
+	{0x1D662, "rn"},
+	{0x1D663, "n"},
+	{0x1D664, "o"},
+	{0x1D665, "p"},
+	{0x1D666, "q"},
+	{0x1D667, "r"},
+	{0x1D668, "s"},
+	{0x1D669, "t"},
+	{0x1D66A, "u"},
+	{0x1D66B, "v"},
+	{0x1D66C, "w"},
+	{0x1D66D, "x"},
+	{0x1D66E, "y"},
+	{0x1D66F, "z"},
+	{0x1D670, "A"},
+	{0x1D671, "B"},
+	{0x1D672, "C"},
+	{0x1D673, "D"},
+	{0x1D674, "E"},
+	{0x1D675, "F"},
+	{0x1D676, "G"},
+	{0x1D677, "H"},
+	{0x1D678, "l"},
+	{0x1D679, "J"},
+	{0x1D67A, "K"},
+	{0x1D67B, "L"},
+	{0x1D67C, "M"},
+	{0x1D67D, "N"},
+	{0x1D67E, "O"},
+	{0x1D67F, "P"},
+	{0x1D680, "Q"},
+	{0x1D681, "R"},
+	{0x1D682, "S"},
+	{0x1D683, "T"},
+	{0x1D684, "U"},
+	{0x1D685, "V"},
+	{0x1D686, "W"},
+	{0x1D687, "X"},
+	{0x1D688, "Y"},
+	{0x1D689, "Z"},
+	{0x1D68A, "a"},
+	{0x1D68B, "b"},
+	{0x1D68C, "c"},
+	{0x1D68D, "d"},
+	{0x1D68E, "e"},
+	{0x1D68F, "f"},
+	{0x1D690, "g"},
+	{0x1D691, "h"},
+	{0x1D692, "i"},
+	{0x1D693, "j"},
+	{0x1D694, "k"},
+	{0x1D695, "l"},
+	{0x1D696, "rn"},
+	{0x1D697, "n"},
+	{0x1D698, "o"},
+	{0x1D699, "p"},
+	{0x1D69A, "q"},
+	{0x1D69B, "r"},
+	{0x1D69C, "s"},
+	{0x1D69D, "t"},
+	{0x1D69E, "u"},
+	{0x1D69F, "v"},
+	{0x1D6A0, "w"},
+	{0x1D6A1, "x"},
+	{0x1D6A2, "y"},
+	{0x1D6A3, "z"},
+	{0x1D6A4, "i"},
+	{0x1D6A5, "\u0237"},
+	{0x1D6A8, "A"},
+	{0x1D6A9, "B"},
+	{0x1D6AA, "\u0393"},
+	{0x1D6AB, "\u0394"},
+	{0x1D6AC, "E"},
+	{0x1D6AD, "Z"},
+	{0x1D6AE, "H"},
+	{0x1D6AF, "O\u0335"},
+	{0x1D6B0, "l"},
+	{0x1D6B1, "K"},
+	{0x1D6B2, "\u0245"},
+	{0x1D6B3, "M"},
+	{0x1D6B4, "N"},
+	{0x1D6B5, "\u039e"},
+	{0x1D6B6, "O"},
+	{0x1D6B7, "\u03a0"},
+	{0x1D6B8, "P"},
+	{0x1D6B9, "O\u0335"},
+	{0x1D6BA, "\u01a9"},
+	{0x1D6BB, "T"},
+	{0x1D6BC, "Y"},
+	{0x1D6BD, "\u03a6"},
+	{0x1D6BE, "X"},
+	{0x1D6BF, "\u03a8"},
+	{0x1D6C0, "\u03a9"},
+	{0x1D6C1, "\u2207"},
+	{0x1D6C2, "a"},
+	{0x1D6C3, "\u00df"},
+	{0x1D6C4, "y"},
+	{0x1D6C5, "\u1e9f"},
+	{0x1D6C6, "\ua793"},
+	{0x1D6C7, "\u03b6"},
+	{0x1D6C8, "n\u0329"},
+	{0x1D6C9, "O\u0335"},
+	{0x1D6CA, "i"},
+	{0x1D6CB, "\u0138"},
+	{0x1D6CC, "\u03bb"},
+	{0x1D6CD, "\u03bc"},
+	{0x1D6CE, "v"},
+	{0x1D6CF, "\u03be"},
+	{0x1D6D0, "o"},
+	{0x1D6D1, "\u03c0"},
+	{0x1D6D2, "p"},
+	{0x1D6D3, "\u03c2"},
+	{0x1D6D4, "o"},
+	{0x1D6D5, "\u1d1b"},
+	{0x1D6D6, "u"},
+	{0x1D6D7, "\u0278"},
+	{0x1D6D8, "\u03c7"},
+	{0x1D6D9, "\u03c8"},
+	{0x1D6DA, "\u03c9"},
+	{0x1D6DB, "\u2202"},
+	{0x1D6DC, "\ua793"},
+	{0x1D6DD, "O\u0335"},
+	{0x1D6DE, "\u0138"},
+	{0x1D6DF, "\u0278"},
+	{0x1D6E0, "p"},
+	{0x1D6E1, "\u03c0"},
+	{0x1D6E2, "A"},
+	{0x1D6E3, "B"},
+	{0x1D6E4, "\u0393"},
+	{0x1D6E5, "\u0394"},
+	{0x1D6E6, "E"},
+	{0x1D6E7, "Z"},
+	{0x1D6E8, "H"},
+	{0x1D6E9, "O\u0335"},
+	{0x1D6EA, "l"},
+	{0x1D6EB, "K"},
+	{0x1D6EC, "\u0245"},
+	{0x1D6ED, "M"},
+	{0x1D6EE, "N"},
+	{0x1D6EF, "\u039e"},
+	{0x1D6F0, "O"},
+	{0x1D6F1, "\u03a0"},
+	{0x1D6F2, "P"},
+	{0x1D6F3, "O\u0335"},
+	{0x1D6F4, "\u01a9"},
+	{0x1D6F5, "T"},
+	{0x1D6F6, "Y"},
+	{0x1D6F7, "\u03a6"},
+	{0x1D6F8, "X"},
+	{0x1D6F9, "\u03a8"},
+	{0x1D6FA, "\u03a9"},
+	{0x1D6FB, "\u2207"},
+	{0x1D6FC, "a"},
+	{0x1D6FD, "\u00df"},
+	{0x1D6FE, "y"},
+	{0x1D6FF, "\u1e9f"},
+	{0x1D700, "\ua793"},
+	{0x1D701, "\u03b6"},
+	{0x1D702, "n\u0329"},
+	{0x1D703, "O\u0335"},
+	{0x1D704, "i"},
+	{0x1D705, "\u0138"},
+	{0x1D706, "\u03bb"},
+	{0x1D707, "\u03bc"},
+	{0x1D708, "v"},
+	{0x1D709, "\u03be"},
+	{0x1D70A, "o"},
+	{0x1D70B, "\u03c0"},
+	{0x1D70C, "p"},
+	{0x1D70D, "\u03c2"},
+	{0x1D70E, "o"},
+	{0x1D70F, "\u1d1b"},
+	{0x1D710, "u"},
+	{0x1D711, "\u0278"},
+	{0x1D712, "\u03c7"},
+	{0x1D713, "\u03c8"},
+	{0x1D714, "\u03c9"},
+	{0x1D715, "\u2202"},
+	{0x1D716, "\ua793"},
+	{0x1D717, "O\u0335"},
+	{0x1D718, "\u0138"},
+	{0x1D719, "\u0278"},
+	{0x1D71A, "p"},
+	{0x1D71B, "\u03c0"},
+	{0x1D71C, "A"},
+	{0x1D71D, "B"},
+	{0x1D71E, "\u0393"},
+	{0x1D71F, "\u0394"},
+	{0x1D720, "E"},
+	{0x1D721, "Z"},
+	{0x1D722, "H"},
+	{0x1D723, "O\u0335"},
+	{0x1D724, "l"},
+	{0x1D725, "K"},
+	{0x1D726, "\u0245"},
+	{0x1D727, "M"},
+	{0x1D728, "N"},
+	{0x1D729, "\u039e"},
+	{0x1D72A, "O"},
+	{0x1D72B, "\u03a0"},
+	{0x1D72C, "P"},
+	{0x1D72D, "O\u0335"},
+	{0x1D72E, "\u01a9"},
+	{0x1D72F, "T"},
+	{0x1D730, "Y"},
+	{0x1D731, "\u03a6"},
+	{0x1D732, "X"},
+	{0x1D733, "\u03a8"},
+	{0x1D734, "\u03a9"},
+	{0x1D735, "\u2207"},
+	{0x1D736, "a"},
+	{0x1D737, "\u00df"},
+	{0x1D738, "y"},
+	{0x1D739, "\u1e9f"},
+	{0x1D73A, "\ua793"},
+	{0x1D73B, "\u03b6"},
+	{0x1D73C, "n\u0329"},
+	{0x1D73D, "O\u0335"},
+	{0x1D73E, "i"},
+	{0x1D73F, "\u0138"},
+	{0x1D740, "\u03bb"},
+	{0x1D741, "\u03bc"},
+	{0x1D742, "v"},
+	{0x1D743, "\u03be"},
+	{0x1D744, "o"},
+	{0x1D745, "\u03c0"},
+	{0x1D746, "p"},
+	{0x1D747, "\u03c2"},
+	{0x1D748, "o"},
+	{0x1D749, "\u1d1b"},
+	{0x1D74A, "u"},
+	{0x1D74B, "\u0278"},
+	{0x1D74C, "\u03c7"},
+	{0x1D74D, "\u03c8"},
+	{0x1D74E, "\u03c9"},
+	{0x1D74F, "\u2202"},
+	{0x1D750, "\ua793"},
+	{0x1D751, "O\u0335"},
+	{0x1D752, "\u0138"},
+	{0x1D753, "\u0278"},
+	{0x1D754, "p"},
+	{0x1D755, "\u03c0"},
+	{0x1D756, "A"},
+	{0x1D757, "B"},
+	{0x1D758, "\u0393"},
+	{0x1D759, "\u0394"},
+	{0x1D75A, "E"},
+	{0x1D75B, "Z"},
+	{0x1D75C, "H"},
+	{0x1D75D, "O\u0335"},
+	{0x1D75E, "l"},
+	{0x1D75F, "K"},
+	{0x1D760, "\u0245"},
+	{0x1D761, "M"},
+	{0x1D762, "N"},
+	{0x1D763, "\u039e"},
+	{0x1D764, "O"},
+	{0x1D765, "\u03a0"},
+	{0x1D766, "P"},
+	{0x1D767, "O\u0335"},
+	{0x1D768, "\u01a9"},
+	{0x1D769, "T"},
+	{0x1D76A, "Y"},
+	{0x1D76B, "\u03a6"},
+	{0x1D76C, "X"},
+	{0x1D76D, "\u03a8"},
+	{0x1D76E, "\u03a9"},
+	{0x1D76F, "\u2207"},
+	{0x1D770, "a"},
+	{0x1D771, "\u00df"},
+	{0x1D772, "y"},
+	{0x1D773, "\u1e9f"},
+	{0x1D774, "\ua793"},
+	{0x1D775, "\u03b6"},
+	{0x1D776, "n\u0329"},
+	{0x1D777, "O\u0335"},
+	{0x1D778, "i"},
+	{0x1D779, "\u0138"},
+	{0x1D77A, "\u03bb"},
+	{0x1D77B, "\u03bc"},
+	{0x1D77C, "v"},
+	{0x1D77D, "\u03be"},
+	{0x1D77E, "o"},
+	{0x1D77F, "\u03c0"},
+	{0x1D780, "p"},
+	{0x1D781, "\u03c2"},
+	{0x1D782, "o"},
+	{0x1D783, "\u1d1b"},
+	{0x1D784, "u"},
+	{0x1D785, "\u0278"},
+	{0x1D786, "\u03c7"},
+	{0x1D787, "\u03c8"},
+	{0x1D788, "\u03c9"},
+	{0x1D789, "\u2202"},
+	{0x1D78A, "\ua793"},
+	{0x1D78B, "O\u0335"},
+	{0x1D78C, "\u0138"},
+	{0x1D78D, "\u0278"},
+	{0x1D78E, "p"},
+	{0x1D78F, "\u03c0"},
+	{0x1D790, "A"},
+	{0x1D791, "B"},
+	{0x1D792, "\u0393"},
+	{0x1D793, "\u0394"},
+	{0x1D794, "E"},
+	{0x1D795, "Z"},
+	{0x1D796, "H"},
+	{0x1D797, "O\u0335"},
+	{0x1D798, "l"},
+	{0x1D799, "K"},
+	{0x1D79A, "\u0245"},
+	{0x1D79B, "M"},
+	{0x1D79C, "N"},
+	{0x1D79D, "\u039e"},
+	{0x1D79E, "O"},
+	{0x1D79F, "\u03a0"},
+	{0x1D7A0, "P"},
+	{0x1D7A1, "O\u0335"},
+	{0x1D7A2, "\u01a9"},
+	{0x1D7A3, "T"},
+	{0x1D7A4, "Y"},
+	{0x1D7A5, "\u03a6"},
+	{0x1D7A6, "X"},
+	{0x1D7A7, "\u03a8"},
+	{0x1D7A8, "\u03a9"},
+	{0x1D7A9, "\u2207"},
+	{0x1D7AA, "a"},
+	{0x1D7AB, "\u00df"},
+	{0x1D7AC, "y"},
+	{0x1D7AD, "\u1e9f"},
+	{0x1D7AE, "\ua793"},
+	{0x1D7AF, "\u03b6"},
+	{0x1D7B0, "n\u0329"},
+	{0x1D7B1, "O\u0335"},
+	{0x1D7B2, "i"},
+	{0x1D7B3, "\u0138"},
+	{0x1D7B4, "\u03bb"},
+	{0x1D7B5, "\u03bc"},
+	{0x1D7B6, "v"},
+	{0x1D7B7, "\u03be"},
+	{0x1D7B8, "o"},
+	{0x1D7B9, "\u03c0"},
+	{0x1D7BA, "p"},
+	{0x1D7BB, "\u03c2"},
+	{0x1D7BC, "o"},
+	{0x1D7BD, "\u1d1b"},
+	{0x1D7BE, "u"},
+	{0x1D7BF, "\u0278"},
+	{0x1D7C0, "\u03c7"},
+	{0x1D7C1, "\u03c8"},
+	{0x1D7C2, "\u03c9"},
+	{0x1D7C3, "\u2202"},
+	{0x1D7C4, "\ua793"},
+	{0x1D7C5, "O\u0335"},
+	{0x1D7C6, "\u0138"},
+	{0x1D7C7, "\u0278"},
+	{0x1D7C8, "p"},
+	{0x1D7C9, "\u03c0"},
+	{0x1D7CA, "F"},
+	{0x1D7CB, "\u03dd"},
+	{0x1D7CE, "O"},
+	{0x1D7CF, "l"},
+	{0x1D7D0, "2"},
+	{0x1D7D1, "3"},
+	{0x1D7D2, "4"},
+	{0x1D7D3, "5"},
+	{0x1D7D4, "6"},
+	{0x1D7D5, "7"},
+	{0x1D7D6, "8"},
+	{0x1D7D7, "9"},
+	{0x1D7D8, "O"},
+	{0x1D7D9, "l"},
+	{0x1D7DA, "2"},
+	{0x1D7DB, "3"},
+	{0x1D7DC, "4"},
+	{0x1D7DD, "5"},
+	{0x1D7DE, "6"},
+	{0x1D7DF, "7"},
+	{0x1D7E0, "8"},
+	{0x1D7E1, "9"},
+	{0x1D7E2, "O"},
+	{0x1D7E3, "l"},
+	{0x1D7E4, "2"},
+	{0x1D7E5, "3"},
+	{0x1D7E6, "4"},
+	{0x1D7E7, "5"},
+	{0x1D7E8, "6"},
+	{0x1D7E9, "7"},
+	{0x1D7EA, "8"},
+	{0x1D7EB, "9"},
+	{0x1D7EC, "O"},
+	{0x1D7ED, "l"},
+	{0x1D7EE, "2"},
+	{0x1D7EF, "3"},
+	{0x1D7F0, "4"},
+	{0x1D7F1, "5"},
+	{0x1D7F2, "6"},
+	{0x1D7F3, "7"},
+	{0x1D7F4, "8"},
+	{0x1D7F5, "9"},
+	{0x1D7F6, "O"},
+	{0x1D7F7, "l"},
+	{0x1D7F8, "2"},
+	{0x1D7F9, "3"},
+	{0x1D7FA, "4"},
+	{0x1D7FB, "5"},
+	{0x1D7FC, "6"},
+	{0x1D7FD, "7"},
+	{0x1D7FE, "8"},
+	{0x1D7FF, "9"},
+	{0x1E8C7, "l"},
+	{0x1E8C8, "\u2220"},
+	{0x1E8C9, "\u0663"},
+	{0x1E8CB, "8"},
+	{0x1E8CC, "\u2202"},
+	{0x1E8CD, "\u2202\u0335"},
+	{0x1EE00, "l"},
+	{0x1EE01, "\u0628"},
+	{0x1EE02, "\u062c"},
+	{0x1EE03, "\u062f"},
+	{0x1EE05, "\u0648"},
+	{0x1EE06, "\u0632"},
+	{0x1EE07, "\u062d"},
+	{0x1EE08, "\u0637"},
+	{0x1EE09, "\u0649"},
+	{0x1EE0A, "\u0643"},
+	{0x1EE0B, "\u0644"},
+	{0x1EE0C, "\u0645"},
+	{0x1EE0D, "\u0646"},
+	{0x1EE0E, "\u0633"},
+	{0x1EE0F, "\u0639"},
+	{0x1EE10, "\u0641"},
+	{0x1EE11, "\u0635"},
+	{0x1EE12, "\u0642"},
+	{0x1EE13, "\u0631"},
+	{0x1EE14, "\u0633\u06db"},
+	{0x1EE15, "\u062a"},
+	{0x1EE16, "\u0649\u06db"},
+	{0x1EE17, "\u062e"},
+	{0x1EE18, "\u0630"},
+	{0x1EE19, "\u0636"},
+	{0x1EE1A, "\u0638"},
+	{0x1EE1B, "\u063a"},
+	{0x1EE1C, "\u0649"},
+	{0x1EE1D, "\u0649"},
+	{0x1EE1E, "\u06a1"},
+	{0x1EE1F, "\u06a1"},
+	{0x1EE21, "\u0628"},
+	{0x1EE22, "\u062c"},
+	{0x1EE24, "o"},
+	{0x1EE27, "\u062d"},
+	{0x1EE29, "\u0649"},
+	{0x1EE2A, "\u0643"},
+	{0x1EE2B, "\u0644"},
+	{0x1EE2C, "\u0645"},
+	{0x1EE2D, "\u0646"},
+	{0x1EE2E, "\u0633"},
+	{0x1EE2F, "\u0639"},
+	{0x1EE30, "\u0641"},
+	{0x1EE31, "\u0635"},
+	{0x1EE32, "\u0642"},
+	{0x1EE34, "\u0633\u06db"},
+	{0x1EE35, "\u062a"},
+	{0x1EE36, "\u0649\u06db"},
+	{0x1EE37, "\u062e"},
+	{0x1EE39, "\u0636"},
+	{0x1EE3B, "\u063a"},
+	{0x1EE42, "\u062c"},
+	{0x1EE47, "\u062d"},
+	{0x1EE49, "\u0649"},
+	{0x1EE4B, "\u0644"},
+	{0x1EE4D, "\u0646"},
+	{0x1EE4E, "\u0633"},
+	{0x1EE4F, "\u0639"},
+	{0x1EE51, "\u0635"},
+	{0x1EE52, "\u0642"},
+	{0x1EE54, "\u0633\u06db"},
+	{0x1EE57, "\u062e"},
+	{0x1EE59, "\u0636"},
+	{0x1EE5B, "\u063a"},
+	{0x1EE5D, "\u0649"},
+	{0x1EE5F, "\u06a1"},
+	{0x1EE61, "\u0628"},
+	{0x1EE62, "\u062c"},
+	{0x1EE64, "o"},
+	{0x1EE67, "\u062d"},
+	{0x1EE68, "\u0637"},
+	{0x1EE69, "\u0649"},
+	{0x1EE6A, "\u0643"},
+	{0x1EE6C, "\u0645"},
+	{0x1EE6D, "\u0646"},
+	{0x1EE6E, "\u0633"},
+	{0x1EE6F, "\u0639"},
+	{0x1EE70, "\u0641"},
+	{0x1EE71, "\u0635"},
+	{0x1EE72, "\u0642"},
+	{0x1EE74, "\u0633\u06db"},
+	{0x1EE75, "\u062a"},
+	{0x1EE76, "\u0649\u06db"},
+	{0x1EE77, "\u062e"},
+	{0x1EE79, "\u0636"},
+	{0x1EE7A, "\u0638"},
+	{0x1EE7B, "\u063a"},
+	{0x1EE7C, "\u0649"},
+	{0x1EE7E, "\u06a1"},
+	{0x1EE80, "l"},
+	{0x1EE81, "\u0628"},
+	{0x1EE82, "\u062c"},
+	{0x1EE83, "\u062f"},
+	{0x1EE84, "o"},
+	{0x1EE85, "\u0648"},
+	{0x1EE86, "\u0632"},
+	{0x1EE87, "\u062d"},
+	{0x1EE88, "\u0637"},
+	{0x1EE89, "\u0649"},
+	{0x1EE8B, "\u0644"},
+	{0x1EE8C, "\u0645"},
+	{0x1EE8D, "\u0646"},
+	{0x1EE8E, "\u0633"},
+	{0x1EE8F, "\u0639"},
+	{0x1EE90, "\u0641"},
+	{0x1EE91, "\u0635"},
+	{0x1EE92, "\u0642"},
+	{0x1EE93, "\u0631"},
+	{0x1EE94, "\u0633\u06db"},
+	{0x1EE95, "\u062a"},
+	{0x1EE96, "\u0649\u06db"},
+	{0x1EE97, "\u062e"},
+	{0x1EE98, "\u0630"},
+	{0x1EE99, "\u0636"},
+	{0x1EE9A, "\u0638"},
+	{0x1EE9B, "\u063a"},
+	{0x1EEA1, "\u0628"},
+	{0x1EEA2, "\u062c"},
+	{0x1EEA3, "\u062f"},
+	{0x1EEA5, "\u0648"},
+	{0x1EEA6, "\u0632"},
+	{0x1EEA7, "\u062d"},
+	{0x1EEA8, "\u0637"},
+	{0x1EEA9, "\u0649"},
+	{0x1EEAB, "\u0644"},
+	{0x1EEAC, "\u0645"},
+	{0x1EEAD, "\u0646"},
+	{0x1EEAE, "\u0633"},
+	{0x1EEAF, "\u0639"},
+	{0x1EEB0, "\u0641"},
+	{0x1EEB1, "\u0635"},
+	{0x1EEB2, "\u0642"},
+	{0x1EEB3, "\u0631"},
+	{0x1EEB4, "\u0633\u06db"},
+	{0x1EEB5, "\u062a"},
+	{0x1EEB6, "\u0649\u06db"},
+	{0x1EEB7, "\u062e"},
+	{0x1EEB8, "\u0630"},
+	{0x1EEB9, "\u0636"},
+	{0x1EEBA, "\u0638"},
+	{0x1EEBB, "\u063a"},
+	{0x1F100, "O."},
+	{0x1F101, "O,"},
+	{0x1F102, "l,"},
+	{0x1F103, "2,"},
+	{0x1F104, "3,"},
+	{0x1F105, "4,"},
+	{0x1F106, "5,"},
+	{0x1F107, "6,"},
+	{0x1F108, "7,"},
+	{0x1F109, "8,"},
+	{0x1F10A, "9,"},
+	{0x1F10F, "$\u20e0"},
+	{0x1F110, "(A)"},
+	{0x1F111, "(B)"},
+	{0x1F112, "(C)"},
+	{0x1F113, "(D)"},
+	{0x1F114, "(E)"},
+	{0x1F115, "(F)"},
+	{0x1F116, "(G)"},
+	{0x1F117, "(H)"},
+	{0x1F118, "(l)"},
+	{0x1F119, "(J)"},
+	{0x1F11A, "(K)"},
+	{0x1F11B, "(L)"},
+	{0x1F11C, "(M)"},
+	{0x1F11D, "(N)"},
+	{0x1F11E, "(O)"},
+	{0x1F11F, "(P)"},
+	{0x1F120, "(Q)"},
+	{0x1F121, "(R)"},
+	{0x1F122, "(S)"},
+	{0x1F123, "(T)"},
+	{0x1F124, "(U)"},
+	{0x1F125, "(V)"},
+	{0x1F126, "(W)"},
+	{0x1F127, "(X)"},
+	{0x1F128, "(Y)"},
+	{0x1F129, "(Z)"},
+	{0x1F12A, "(S)"},
+	{0x1F16D, "\u33c4\t\u20dd"},
+	{0x1F16E, "C\u20e0"},
+	{0x1F240, "(\u672c)"},
+	{0x1F241, "(\u4e09)"},
+	{0x1F242, "(\u4e8c)"},
+	{0x1F243, "(\u5b89)"},
+	{0x1F244, "(\u70b9)"},
+	{0x1F245, "(\u6253)"},
+	{0x1F246, "(\u76d7)"},
+	{0x1F247, "(\u52dd)"},
+	{0x1F248, "(\u6557)"},
+	{0x1F312, "\u263d"},
+	{0x1F318, "\u263e"},
+	{0x1F319, "\u263d"},
+	{0x1F700, "QE"},
+	{0x1F701, "\ua658"},
+	{0x1F702, "\u0394"},
+	{0x1F704, "\U000102bc"},
+	{0x1F707, "AR"},
+	{0x1F708, "V\u1de4"},
+	{0x1F70A, "\u2629"},
+	{0x1F714, "O\u0335"},
+	{0x1F728, "\U000102a8"},
+	{0x1F73A, "\u29df"},
+	{0x1F74C, "C"},
+	{0x1F754, "\u16dc"},
+	{0x1F755, "\u22a1"},
+	{0x1F75C, "sss"},
+	{0x1F75E, "\u224f"},
+	{0x1F768, "T"},
+	{0x1F76B, "MB"},
+	{0x1F76C, "VB"},
+	{0x1F771, "\u22a0"},
+	{0x1FBF0, "O"},
+	{0x1FBF1, "l"},
+	{0x1FBF2, "2"},
+	{0x1FBF3, "3"},
+	{0x1FBF4, "4"},
+	{0x1FBF5, "5"},
+	{0x1FBF6, "6"},
+	{0x1FBF7, "7"},
+	{0x1FBF8, "8"},
+	{0x1FBF9, "9"},
+	{0x21FE8, "\u276c"},
+	{0x2F800, "\u4e3d"},
+	{0x2F801, "\u4e38"},
+	{0x2F802, "\u4e41"},
+	{0x2F803, "\U00020122"},
+	{0x2F804, "\u4f60"},
+	{0x2F805, "\u4fae"},
+	{0x2F806, "\u4fbb"},
+	{0x2F807, "\u4f75"},
+	{0x2F808, "\u507a"},
+	{0x2F809, "\u5099"},
+	{0x2F80A, "\u50e7"},
+	{0x2F80B, "\u50cf"},
+	{0x2F80C, "\u349e"},
+	{0x2F80D, "\U0002063a"},
+	{0x2F80E, "\u514d"},
+	{0x2F80F, "\u5154"},
+	{0x2F810, "\u5164"},
+	{0x2F811, "\u5177"},
+	{0x2F812, "\U0002051c"},
+	{0x2F813, "\u34b9"},
+	{0x2F814, "\u5167"},
+	{0x2F815, "\u518d"},
+	{0x2F816, "\U0002054b"},
+	{0x2F817, "\u5197"},
+	{0x2F818, "\u51a4"},
+	{0x2F819, "\u4ecc"},
+	{0x2F81A, "\u51ac"},
+	{0x2F81B, "\u51b5"},
+	{0x2F81C, "\U000291df"},
+	{0x2F81D, "\u51f5"},
+	{0x2F81E, "\u5203"},
+	{0x2F81F, "\u34df"},
+	{0x2F820, "\u523b"},
+	{0x2F821, "\u5246"},
+	{0x2F822, "\u5272"},
+	{0x2F823, "\u5277"},
+	{0x2F824, "\u3515"},
+	{0x2F825, "\u52c7"},
+	{0x2F826, "\u52c9"},
+	{0x2F827, "\u52e4"},
+	{0x2F828, "\u52fa"},
+	{0x2F829, "\u5305"},
+	{0x2F82A, "\u5306"},
+	{0x2F82B, "\u5317"},
+	{0x2F82C, "\u5349"},
+	{0x2F82D, "\u5351"},
+	{0x2F82E, "\u535a"},
+	{0x2F82F, "\u5373"},
+	{0x2F830, "\u537d"},
+	{0x2F831, "\u537f"},
+	{0x2F832, "\u537f"},
+	{0x2F833, "\u537f"},
+	{0x2F834, "\U00020a2c"},
+	{0x2F835, "\u7070"},
+	{0x2F836, "\u53ca"},
+	{0x2F837, "\u53df"},
+	{0x2F838, "\U00020b63"},
+	{0x2F839, "\u53eb"},
+	{0x2F83A, "\u53f1"},
+	{0x2F83B, "\u5406"},
+	{0x2F83C, "\u549e"},
+	{0x2F83D, "\u5438"},
+	{0x2F83E, "\u5448"},
+	{0x2F83F, "\u5468"},
+	{0x2F840, "\u54a2"},
+	{0x2F841, "\u54f6"},
+	{0x2F842, "\u5510"},
+	{0x2F843, "\u5553"},
+	{0x2F844, "\u5563"},
+	{0x2F845, "\u5584"},
+	{0x2F846, "\u5584"},
+	{0x2F847, "\u5599"},
+	{0x2F848, "\u55ab"},
+	{0x2F849, "\u55b3"},
+	{0x2F84A, "\u55c2"},
+	{0x2F84B, "\u5716"},
+	{0x2F84C, "\u5606"},
+	{0x2F84D, "\u5717"},
+	{0x2F84E, "\u5651"},
+	{0x2F84F, "\u5674"},
+	{0x2F850, "\u5207"},
+	{0x2F851, "\u58ee"},
+	{0x2F852, "\u57ce"},
+	{0x2F853, "\u57f4"},
+	{0x2F854, "\u580d"},
+	{0x2F855, "\u578b"},
+	{0x2F856, "\u5832"},
+	{0x2F857, "\u5831"},
+	{0x2F858, "\u58ac"},
+	{0x2F859, "\U000214e4"},
+	{0x2F85A, "\u58f2"},
+	{0x2F85B, "\u58f7"},
+	{0x2F85C, "\u5906"},
+	{0x2F85D, "\u591a"},
+	{0x2F85E, "\u5922"},
+	{0x2F85F, "\u5962"},
+	{0x2F860, "\U000216a8"},
+	{0x2F861, "\U000216ea"},
+	{0x2F862, "\u59ec"},
+	{0x2F863, "\u5a1b"},
+	{0x2F864, "\u5a27"},
+	{0x2F865, "\u59d8"},
+	{0x2F866, "\u5a66"},
+	{0x2F867, "\u36ee"},
+	{0x2F868, "\u36fc"},
+	{0x2F869, "\u5b08"},
+	{0x2F86A, "\u5b3e"},
+	{0x2F86B, "\u5b3e"},
+	{0x2F86C, "\U000219c8"},
+	{0x2F86D, "\u5bc3"},
+	{0x2F86E, "\u5bd8"},
+	{0x2F86F, "\u5be7"},
+	{0x2F870, "\u5bf3"},
+	{0x2F871, "\U00021b18"},
+	{0x2F872, "\u5bff"},
+	{0x2F873, "\u5c06"},
+	{0x2F874, "\u5f53"},
+	{0x2F875, "\u5c22"},
+	{0x2F876, "\u3781"},
+	{0x2F877, "\u5c60"},
+	{0x2F878, "\u5c6e"},
+	{0x2F879, "\u5cc0"},
+	{0x2F87A, "\u5c8d"},
+	{0x2F87B, "\U00021de4"},
+	{0x2F87C, "\u5d43"},
+	{0x2F87D, "\U00021de6"},
+	{0x2F87E, "\u5d6e"},
+	{0x2F87F, "\u5d6b"},
+	{0x2F880, "\u5d7c"},
+	{0x2F881, "\u5de1"},
+	{0x2F882, "\u5de2"},
+	{0x2F883, "\u382f"},
+	{0x2F884, "\u5dfd"},
+	{0x2F885, "\u5e28"},
+	{0x2F886, "\u5e3d"},
+	{0x2F887, "\u5e69"},
+	{0x2F888, "\u3862"},
+	{0x2F889, "\U00022183"},
+	{0x2F88A, "\u387c"},
+	{0x2F88B, "\u5eb0"},
+	{0x2F88C, "\u5eb3"},
+	{0x2F88D, "\u5eb6"},
+	{0x2F88E, "\u5eca"},
+	{0x2F88F, "\U0002a392"},
+	{0x2F890, "\u5efe"},
+	{0x2F891, "\U00022331"},
+	{0x2F892, "\U00022331"},
+	{0x2F893, "\u8201"},
+	{0x2F894, "\u5f22"},
+	{0x2F895, "\u5f22"},
+	{0x2F896, "\u38c7"},
+	{0x2F897, "\U000232b8"},
+	{0x2F898, "\U000261da"},
+	{0x2F899, "\u5f62"},
+	{0x2F89A, "\u5f6b"},
+	{0x2F89B, "\u38e3"},
+	{0x2F89C, "\u5f9a"},
+	{0x2F89D, "\u5fcd"},
+	{0x2F89E, "\u5fd7"},
+	{0x2F89F, "\u5ff9"},
+	{0x2F8A0, "\u6081"},
+	{0x2F8A1, "\u393a"},
+	{0x2F8A2, "\u391c"},
+	{0x2F8A3, "\u6094"},
+	{0x2F8A4, "\U000226d4"},
+	{0x2F8A5, "\u60c7"},
+	{0x2F8A6, "\u6148"},
+	{0x2F8A7, "\u614c"},
+	{0x2F8A8, "\u614e"},
+	{0x2F8A9, "\u614c"},
+	{0x2F8AA, "\u617a"},
+	{0x2F8AB, "\u618e"},
+	{0x2F8AC, "\u61b2"},
+	{0x2F8AD, "\u61a4"},
+	{0x2F8AE, "\u61af"},
+	{0x2F8AF, "\u61de"},
+	{0x2F8B0, "\u61f2"},
+	{0x2F8B1, "\u61f6"},
+	{0x2F8B2, "\u6210"},
+	{0x2F8B3, "\u621b"},
+	{0x2F8B4, "\u625d"},
+	{0x2F8B5, "\u62b1"},
+	{0x2F8B6, "\u62d4"},
+	{0x2F8B7, "\u6350"},
+	{0x2F8B8, "\U00022b0c"},
+	{0x2F8B9, "\u633d"},
+	{0x2F8BA, "\u62fc"},
+	{0x2F8BB, "\u6368"},
+	{0x2F8BC, "\u6383"},
+	{0x2F8BD, "\u63e4"},
+	{0x2F8BE, "\U00022bf1"},
+	{0x2F8BF, "\u6422"},
+	{0x2F8C0, "\u63c5"},
+	{0x2F8C1, "\u63a9"},
+	{0x2F8C2, "\u3a2e"},
+	{0x2F8C3, "\u6469"},
+	{0x2F8C4, "\u647e"},
+	{0x2F8C5, "\u649d"},
+	{0x2F8C6, "\u6477"},
+	{0x2F8C7, "\u3a6c"},
+	{0x2F8C8, "\u654f"},
+	{0x2F8C9, "\u656c"},
+	{0x2F8CA, "\U0002300a"},
+	{0x2F8CB, "\u65e3"},
+	{0x2F8CC, "\u66f8"},
+	{0x2F8CD, "\u6649"},
+	{0x2F8CE, "\u3b19"},
+	{0x2F8CF, "\u6691"},
+	{0x2F8D0, "\u3b08"},
+	{0x2F8D1, "\u3ae4"},
+	{0x2F8D2, "\u5192"},
+	{0x2F8D3, "\u5195"},
+	{0x2F8D4, "\u6700"},
+	{0x2F8D5, "\u669c"},
+	{0x2F8D6, "\u80ad"},
+	{0x2F8D7, "\u43d9"},
+	{0x2F8D8, "\u6717"},
+	{0x2F8D9, "\u671b"},
+	{0x2F8DA, "\u6721"},
+	{0x2F8DB, "\u675e"},
+	{0x2F8DC, "\u6753"},
+	{0x2F8DD, "\U000233c3"},
+	{0x2F8DE, "\u3b49"},
+	{0x2F8DF, "\u67fa"},
+	{0x2F8E0, "\u6785"},
+	{0x2F8E1, "\u6852"},
+	{0x2F8E2, "\u6885"},
+	{0x2F8E3, "\U0002346d"},
+	{0x2F8E4, "\u688e"},
+	{0x2F8E5, "\u681f"},
+	{0x2F8E6, "\u6914"},
+	{0x2F8E7, "\u3b9d"},
+	{0x2F8E8, "\u6942"},
+	{0x2F8E9, "\u69a3"},
+	{0x2F8EA, "\u69ea"},
+	{0x2F8EB, "\u6aa8"},
+	{0x2F8EC, "\U000236a3"},
+	{0x2F8ED, "\u6adb"},
+	{0x2F8EE, "\u3c18"},
+	{0x2F8EF, "\u6b21"},
+	{0x2F8F0, "\U000238a7"},
+	{0x2F8F1, "\u6b54"},
+	{0x2F8F2, "\u3c4e"},
+	{0x2F8F3, "\u6b72"},
+	{0x2F8F4, "\u6b9f"},
+	{0x2F8F5, "\u6bba"},
+	{0x2F8F6, "\u6bbb"},
+	{0x2F8F7, "\U00023a8d"},
+	{0x2F8F8, "\U00021d0b"},
+	{0x2F8F9, "\U00023afa"},
+	{0x2F8FA, "\u6c4e"},
+	{0x2F8FB, "\U00023cbc"},
+	{0x2F8FC, "\u6cbf"},
+	{0x2F8FD, "\u6ccd"},
+	{0x2F8FE, "\u6c67"},
+	{0x2F8FF, "\u6d16"},
+	{0x2F900, "\u6d3e"},
+	{0x2F901, "\u6d77"},
+	{0x2F902, "\u6d41"},
+	{0x2F903, "\u6d69"},
+	{0x2F904, "\u6d78"},
+	{0x2F905, "\u6d85"},
+	{0x2F906, "\U00023d1e"},
+	{0x2F907, "\u6d34"},
+	{0x2F908, "\u6e2f"},
+	{0x2F909, "\u6e6e"},
+	{0x2F90A, "\u3d33"},
+	{0x2F90B, "\u6ecb"},
+	{0x2F90C, "\u6ec7"},
+	{0x2F90D, "\U00023ed1"},
+	{0x2F90E, "\u6df9"},
+	{0x2F90F, "\u6f6e"},
+	{0x2F910, "\U00023f5e"},
+	{0x2F911, "\U00023f8e"},
+	{0x2F912, "\u6fc6"},
+	{0x2F913, "\u7039"},
+	{0x2F914, "\u701e"},
+	{0x2F915, "\u701b"},
+	{0x2F916, "\u3d96"},
+	{0x2F917, "\u704a"},
+	{0x2F918, "\u707d"},
+	{0x2F919, "\u7077"},
+	{0x2F91A, "\u70ad"},
+	{0x2F91B, "\U00020525"},
+	{0x2F91C, "\u7145"},
+	{0x2F91D, "\U00024263"},
+	{0x2F91E, "\u719c"},
+	{0x2F91F, "\U000243ab"},
+	{0x2F920, "\u7228"},
+	{0x2F921, "\u7235"},
+	{0x2F922, "\u7250"},
+	{0x2F923, "\U00024608"},
+	{0x2F924, "\u7280"},
+	{0x2F925, "\u7295"},
+	{0x2F926, "\U00024735"},
+	{0x2F927, "\U00024814"},
+	{0x2F928, "\u737a"},
+	{0x2F929, "\u738b"},
+	{0x2F92A, "\u3eac"},
+	{0x2F92B, "\u73a5"},
+	{0x2F92C, "\u3eb8"},
+	{0x2F92D, "\u3eb8"},
+	{0x2F92E, "\u7447"},
+	{0x2F92F, "\u745c"},
+	{0x2F930, "\u7471"},
+	{0x2F931, "\u7485"},
+	{0x2F932, "\u74ca"},
+	{0x2F933, "\u3f1b"},
+	{0x2F934, "\u7524"},
+	{0x2F935, "\U00024c36"},
+	{0x2F936, "\u753e"},
+	{0x2F937, "\U00024c92"},
+	{0x2F938, "\u7570"},
+	{0x2F939, "\U0002219f"},
+	{0x2F93A, "\u7610"},
+	{0x2F93B, "\U00024fa1"},
+	{0x2F93C, "\U00024fb8"},
+	{0x2F93D, "\U00025044"},
+	{0x2F93E, "\u3ffc"},
+	{0x2F93F, "\u4008"},
+	{0x2F940, "\u76f4"},
+	{0x2F941, "\U000250f3"},
+	{0x2F942, "\U000250f2"},
+	{0x2F943, "\U00025119"},
+	{0x2F944, "\U00025133"},
+	{0x2F945, "\u771e"},
+	{0x2F946, "\u771f"},
+	{0x2F947, "\u771f"},
+	{0x2F948, "\u774a"},
+	{0x2F949, "\u4039"},
+	{0x2F94A, "\u778b"},
+	{0x2F94B, "\u4046"},
+	{0x2F94C, "\u4096"},
+	{0x2F94D, "\U0002541d"},
+	{0x2F94E, "\u784e"},
+	{0x2F94F, "\u788c"},
+	{0x2F950, "\u78cc"},
+	{0x2F951, "\u40e3"},
+	{0x2F952, "\U00025626"},
+	{0x2F953, "\u7956"},
+	{0x2F954, "\U0002569a"},
+	{0x2F955, "\U000256c5"},
+	{0x2F956, "\u798f"},
+	{0x2F957, "\u79eb"},
+	{0x2F958, "\u412f"},
+	{0x2F959, "\u7a40"},
+	{0x2F95A, "\u7a4a"},
+	{0x2F95B, "\u7a4f"},
+	{0x2F95C, "\U0002597c"},
+	{0x2F95D, "\U00025aa7"},
+	{0x2F95E, "\U00025aa7"},
+	{0x2F95F, "\u7aee"},
+	{0x2F960, "\u4202"},
+	{0x2F961, "\U00025bab"},
+	{0x2F962, "\u7bc6"},
+	{0x2F963, "\u7bc9"},
+	{0x2F964, "\u4227"},
+	{0x2F965, "\U00025c80"},
+	{0x2F966, "\u7cd2"},
+	{0x2F967, "\u42a0"},
+	{0x2F968, "\u7ce8"},
+	{0x2F969, "\u7ce3"},
+	{0x2F96A, "\u7d00"},
+	{0x2F96B, "\U00025f86"},
+	{0x2F96C, "\u7d63"},
+	{0x2F96D, "\u4301"},
+	{0x2F96E, "\u7dc7"},
+	{0x2F96F, "\u7e02"},
+	{0x2F970, "\u7e45"},
+	{0x2F971, "\u4334"},
+	{0x2F972, "\U00026228"},
+	{0x2F973, "\U00026247"},
+	{0x2F974, "\u4359"},
+	{0x2F975, "\U000262d9"},
+	{0x2F976, "\u7f7a"},
+	{0x2F977, "\U0002633e"},
+	{0x2F978, "\u7f95"},
+	{0x2F979, "\u7ffa"},
+	{0x2F97A, "\u8005"},
+	{0x2F97B, "\U000264da"},
+	{0x2F97C, "\U00026523"},
+	{0x2F97D, "\u8060"},
+	{0x2F97E, "\U000265a8"},
+	{0x2F97F, "\u8070"},
+	{0x2F980, "\U0002335f"},
+	{0x2F981, "\u43d5"},
+	{0x2F982, "\u80b2"},
+	{0x2F983, "\u8103"},
+	{0x2F984, "\u440b"},
+	{0x2F985, "\u813e"},
+	{0x2F986, "\u5ab5"},
+	{0x2F987, "\U000267a7"},
+	{0x2F988, "\U000267b5"},
+	{0x2F989, "\U00023393"},
+	{0x2F98A, "\U0002339c"},
+	{0x2F98B, "\u8201"},
+	{0x2F98C, "\u8204"},
+	{0x2F98D, "\u8f9e"},
+	{0x2F98E, "\u446b"},
+	{0x2F98F, "\u8291"},
+	{0x2F990, "\u828b"},
+	{0x2F991, "\u829d"},
+	{0x2F992, "\u52b3"},
+	{0x2F993, "\u82b1"},
+	{0x2F994, "\u82b3"},
+	{0x2F995, "\u82bd"},
+	{0x2F996, "\u82e6"},
+	{0x2F997, "\U00026b3c"},
+	{0x2F998, "\u82e5"},
+	{0x2F999, "\u831d"},
+	{0x2F99A, "\u8363"},
+	{0x2F99B, "\u83ad"},
+	{0x2F99C, "\u8323"},
+	{0x2F99D, "\u83bd"},
+	{0x2F99E, "\u83e7"},
+	{0x2F99F, "\u8457"},
+	{0x2F9A0, "\u8353"},
+	{0x2F9A1, "\u83ca"},
+	{0x2F9A2, "\u83cc"},
+	{0x2F9A3, "\u83dc"},
+	{0x2F9A4, "\U00026c36"},
+	{0x2F9A5, "\U00026d6b"},
+	{0x2F9A6, "\U00026cd5"},
+	{0x2F9A7, "\u452b"},
+	{0x2F9A8, "\u84f1"},
+	{0x2F9A9, "\u84f3"},
+	{0x2F9AA, "\u8516"},
+	{0x2F9AB, "\U000273ca"},
+	{0x2F9AC, "\u8564"},
+	{0x2F9AD, "\U00026f2c"},
+	{0x2F9AE, "\u455d"},
+	{0x2F9AF, "\u4561"},
+	{0x2F9B0, "\U00026fb1"},
+	{0x2F9B1, "\U000270d2"},
+	{0x2F9B2, "\u456b"},
+	{0x2F9B3, "\u8650"},
+	{0x2F9B4, "\u865c"},
+	{0x2F9B5, "\u8667"},
+	{0x2F9B6, "\u8669"},
+	{0x2F9B7, "\u86a9"},
+	{0x2F9B8, "\u8688"},
+	{0x2F9B9, "\u870e"},
+	{0x2F9BA, "\u86e2"},
+	{0x2F9BB, "\u8779"},
+	{0x2F9BC, "\u8728"},
+	{0x2F9BD, "\u876b"},
+	{0x2F9BE, "\u8786"},
+	{0x2F9BF, "\u45d7"},
+	{0x2F9C0, "\u87e1"},
+	{0x2F9C1, "\u8801"},
+	{0x2F9C2, "\u45f9"},
+	{0x2F9C3, "\u8860"},
+	{0x2F9C4, "\u8863"},
+	{0x2F9C5, "\U00027667"},
+	{0x2F9C6, "\u88d7"},
+	{0x2F9C7, "\u88de"},
+	{0x2F9C8, "\u4635"},
+	{0x2F9C9, "\u88fa"},
+	{0x2F9CA, "\u34bb"},
+	{0x2F9CB, "\U000278ae"},
+	{0x2F9CC, "\U00027966"},
+	{0x2F9CD, "\u46be"},
+	{0x2F9CE, "\u46c7"},
+	{0x2F9CF, "\u8aa0"},
+	{0x2F9D0, "\u8aed"},
+	{0x2F9D1, "\u8b8a"},
+	{0x2F9D2, "\u8c55"},
+	{0x2F9D3, "\U00027ca8"},
+	{0x2F9D4, "\u8cab"},
+	{0x2F9D5, "\u8cc1"},
+	{0x2F9D6, "\u8d1b"},
+	{0x2F9D7, "\u8d77"},
+	{0x2F9D8, "\U00027f2f"},
+	{0x2F9D9, "\U00020804"},
+	{0x2F9DA, "\u8dcb"},
+	{0x2F9DB, "\u8dbc"},
+	{0x2F9DC, "\u8df0"},
+	{0x2F9DD, "\U000208de"},
+	{0x2F9DE, "\u8ed4"},
+	{0x2F9DF, "\u8f38"},
+	{0x2F9E0, "\U000285d2"},
+	{0x2F9E1, "\U000285ed"},
+	{0x2F9E2, "\u9094"},
+	{0x2F9E3, "\u90f1"},
+	{0x2F9E4, "\u9111"},
+	{0x2F9E5, "\U0002872e"},
+	{0x2F9E6, "\u911b"},
+	{0x2F9E7, "\u9238"},
+	{0x2F9E8, "\u92d7"},
+	{0x2F9E9, "\u92d8"},
+	{0x2F9EA, "\u927c"},
+	{0x2F9EB, "\u93f9"},
+	{0x2F9EC, "\u9415"},
+	{0x2F9ED, "\U00028bfa"},
+	{0x2F9EE, "\u958b"},
+	{0x2F9EF, "\u4995"},
+	{0x2F9F0, "\u95b7"},
+	{0x2F9F1, "\U00028d77"},
+	{0x2F9F2, "\u49e6"},
+	{0x2F9F3, "\u96c3"},
+	{0x2F9F4, "\u5db2"},
+	{0x2F9F5, "\u9723"},
+	{0x2F9F6, "\U00029145"},
+	{0x2F9F7, "\U0002921a"},
+	{0x2F9F8, "\u4a6e"},
+	{0x2F9F9, "\u4a76"},
+	{0x2F9FA, "\u97e0"},
+	{0x2F9FB, "\U0002940a"},
+	{0x2F9FC, "\u4ab2"},
+	{0x2F9FD, "\U00029496"},
+	{0x2F9FE, "\u980b"},
+	{0x2F9FF, "\u980b"},
+	{0x2FA00, "\u9829"},
+	{0x2FA01, "\U000295b6"},
+	{0x2FA02, "\u98e2"},
+	{0x2FA03, "\u4b33"},
+	{0x2FA04, "\u9929"},
+	{0x2FA05, "\u99a7"},
+	{0x2FA06, "\u99c2"},
+	{0x2FA07, "\u99fe"},
+	{0x2FA08, "\u4bce"},
+	{0x2FA09, "\U00029b30"},
+	{0x2FA0A, "\u9b12"},
+	{0x2FA0B, "\u9c40"},
+	{0x2FA0C, "\u9cfd"},
+	{0x2FA0D, "\u4cce"},
+	{0x2FA0E, "\u4ced"},
+	{0x2FA0F, "\u9d67"},
+	{0x2FA10, "\U0002a0ce"},
+	{0x2FA11, "\u4cf8"},
+	{0x2FA12, "\U0002a105"},
+	{0x2FA13, "\U0002a20e"},
+	{0x2FA14, "\U0002a291"},
+	{0x2FA15, "\u9ebb"},
+	{0x2FA16, "\u4d56"},
+	{0x2FA17, "\u9ef9"},
+	{0x2FA18, "\u9efe"},
+	{0x2FA19, "\u9f05"},
+	{0x2FA1A, "\u9f0f"},
+	{0x2FA1B, "\u9f16"},
+	{0x2FA1C, "\u9f3b"},
+	{0x2FA1D, "\U0002a600"},
+}
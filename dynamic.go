@@ -0,0 +1,435 @@
+package ahocorasick
+
+import "sort"
+
+// Add returns a Matcher finding the patterns of m along with the new
+// patterns, without compiling them all again. The new patterns are inserted
+// into a copy of the double array of m, in the slots of its free list. Only
+// the states whose path ends with the path of a new state get a new failure
+// link, and only the states whose path ends with a new pattern get a new
+// output set, so inserting a pattern costs little more than walking it. m
+// itself is left untouched, so searches may go on with it while Add runs, and
+// a Registry may swap the result in. Copying the arrays of m still takes time
+// linear in its size, as does the first Add to a compiled Matcher, which
+// indexes its failure links.
+//
+// The key of patterns[i] is first+i, where first is one more than the largest
+// key m was ever given, so that the keys of removed patterns are never given
+// again. Empty patterns are ignored, but still take a key.
+func (m *Matcher) Add(patterns []Pattern) (added *Matcher, first int) {
+	a := m.clone()
+	if a.failIndex == nil {
+		a.failIndex = a.indexFail()
+	}
+	first = int(m.nextKey)
+	a.nextKey += uint64(len(patterns))
+	a.addOptions(patterns, first)
+
+	for i, p := range patterns {
+		if len(p.Word) == 0 {
+			continue
+		}
+		s := 0
+		for _, c := range p.Word {
+			s = a.child(s, int(c))
+		}
+		a.addOutput(s, SWord{uint64(len(p.Word)), uint64(first + i)})
+		if len(p.Word) > a.maxLen {
+			a.maxLen = len(p.Word)
+		}
+	}
+	return a, first
+}
+
+// clone returns a copy of m whose arrays may be modified without changing m.
+// The output sets are shared, and have to be copied before being modified.
+func (m *Matcher) clone() *Matcher {
+	c := *m
+	c.base = append([]int{}, m.base...)
+	c.check = append([]int{}, m.check...)
+	c.fail = append([]int{}, m.fail...)
+	c.output = append([][]SWord{}, m.output...)
+	if m.anchors != nil {
+		c.anchors = append([]Anchor{}, m.anchors...)
+	}
+	if m.boundaries != nil {
+		c.boundaries = append([]Boundary{}, m.boundaries...)
+	}
+	if m.failIndex != nil {
+		c.failIndex = &failIndex{
+			first: append([]int{}, m.failIndex.first...),
+			next:  append([]int{}, m.failIndex.next...),
+			prev:  append([]int{}, m.failIndex.prev...),
+		}
+	}
+	return &c
+}
+
+// addOptions records the anchors and boundaries of the patterns added with
+// keys from first, if m or the patterns use them.
+func (m *Matcher) addOptions(patterns []Pattern, first int) {
+	anchored, bounded := m.anchors != nil, m.boundaries != nil
+	for _, p := range patterns {
+		anchored = anchored || p.Anchor != 0
+		bounded = bounded || p.Boundary != NoBoundary
+	}
+	size := first + len(patterns)
+	if anchored {
+		m.anchors = append(m.anchors, make([]Anchor, size-len(m.anchors))...)
+		for i, p := range patterns {
+			m.anchors[first+i] = p.Anchor & anchorMask
+		}
+	}
+	if bounded {
+		m.boundaries = append(m.boundaries, make([]Boundary, size-len(m.boundaries))...)
+		for i, p := range patterns {
+			if p.Boundary < boundaryCount {
+				m.boundaries[first+i] = p.Boundary
+			}
+		}
+	}
+}
+
+// failIndex lists the states failing to each state, so that Add relinks and
+// updates the states under a new one without scanning the whole trie. The
+// lists are linked through the slots of their states, 0 ending them since the
+// root fails to no state.
+type failIndex struct {
+	first []int // the first state failing to s
+	next  []int // the next state failing to the same state as s
+	prev  []int // the previous state failing to the same state as s, or 0
+}
+
+// indexFail returns the failIndex of the states of m.
+func (m *Matcher) indexFail() *failIndex {
+	x := &failIndex{}
+	x.grow(len(m.check))
+	for s := len(m.check) - 1; s > 0; s-- {
+		if m.check[s] >= 0 {
+			x.push(m.fail[s], s)
+		}
+	}
+	return x
+}
+
+// grow extends the lists to n slots.
+func (x *failIndex) grow(n int) {
+	if n > len(x.first) {
+		more := make([]int, n-len(x.first))
+		x.first = append(x.first, more...)
+		x.next = append(x.next, more...)
+		x.prev = append(x.prev, more...)
+	}
+}
+
+// push inserts s at the head of the states failing to f.
+func (x *failIndex) push(f, s int) {
+	x.next[s], x.prev[s] = x.first[f], 0
+	if x.first[f] != 0 {
+		x.prev[x.first[f]] = s
+	}
+	x.first[f] = s
+}
+
+// unlink takes s out of the states failing to f.
+func (x *failIndex) unlink(f, s int) {
+	if x.prev[s] != 0 {
+		x.next[x.prev[s]] = x.next[s]
+	} else {
+		x.first[f] = x.next[s]
+	}
+	if x.next[s] != 0 {
+		x.prev[x.next[s]] = x.prev[s]
+	}
+	x.next[s], x.prev[s] = 0, 0
+}
+
+// children returns the states failing to f.
+func (x *failIndex) children(f int) []int {
+	var states []int
+	for s := x.first[f]; s != 0; s = x.next[s] {
+		states = append(states, s)
+	}
+	return states
+}
+
+// child returns the child of s by c, creating it if needed.
+func (m *Matcher) child(s, c int) int {
+	if m.hasEdge(s, c) {
+		return m.base[s] + c
+	}
+	t := m.base[s] + c
+	if t > 0 && t >= len(m.check) {
+		m.increaseSize(t - len(m.check) + 1)
+	}
+	if t <= 0 || m.check[t] >= 0 {
+		// The slot is taken, or out of the array: move the children of s
+		// to a base where they fit along with the new one.
+		labels := append(m.labels(s), c)
+		sort.Ints(labels)
+		m.relocate(s, m.findBase(labels))
+		t = m.base[s] + c
+	}
+	m.occupyState(t, s)
+	x := m.failIndex
+	x.grow(len(m.check))
+
+	m.fail[t] = 0
+	if s != 0 {
+		m.setFailState(t, s, c)
+	}
+	f := m.fail[t]
+	m.output[t] = append([]SWord(nil), m.output[f]...)
+
+	// The states failing to f which are longer than t fail to t if their
+	// path ends with the path of t. The states failing to them keep their
+	// failure link, which still ends with the path of t.
+	for _, u := range x.children(f) {
+		if m.endsWith(u, t) {
+			x.unlink(f, u)
+			x.push(t, u)
+			m.fail[u] = t
+		}
+	}
+	x.push(f, t)
+	return t
+}
+
+// endsWith reports whether the path of u ends with the path of t.
+func (m *Matcher) endsWith(u, t int) bool {
+	for t != 0 {
+		if u == 0 || u-m.base[m.check[u]] != t-m.base[m.check[t]] {
+			return false
+		}
+		u, t = m.check[u], m.check[t]
+	}
+	return true
+}
+
+// relocate moves the children of s to base.
+func (m *Matcher) relocate(s, base int) {
+	x := m.failIndex
+	for _, c := range m.labels(s) {
+		from, to := m.base[s]+c, base+c
+		m.occupyState(to, s)
+		x.grow(len(m.check))
+		m.base[to] = m.base[from]
+		m.fail[to] = m.fail[from]
+		m.output[to] = m.output[from]
+		for _, g := range m.labels(from) {
+			m.check[m.base[from]+g] = to
+		}
+
+		// to takes the place of from among the states failing to its
+		// failure state, and the states failing to from fail to to.
+		x.next[to], x.prev[to] = x.next[from], x.prev[from]
+		if x.prev[to] != 0 {
+			x.next[x.prev[to]] = to
+		} else {
+			x.first[m.fail[to]] = to
+		}
+		if x.next[to] != 0 {
+			x.prev[x.next[to]] = to
+		}
+		x.first[to] = x.first[from]
+		for u := x.first[to]; u != 0; u = x.next[u] {
+			m.fail[u] = to
+		}
+		x.first[from], x.next[from], x.prev[from] = 0, 0, 0
+		m.freeState(from)
+	}
+	m.base[s] = base
+}
+
+// addOutput adds item to the output set of s, and to the output sets of the
+// states failing to s, directly or not. An output set is the output set of
+// the failure state followed by the patterns ending at the state, as build
+// makes it, so the item is inserted after the inherited ones.
+func (m *Matcher) addOutput(s int, item SWord) {
+	// The output set may be shared with m, so it is copied first.
+	out := make([]SWord, len(m.output[s]), len(m.output[s])+1)
+	copy(out, m.output[s])
+	m.output[s] = append(out, item)
+
+	x := m.failIndex
+	queue := x.children(s)
+	for i := 0; i < len(queue); i++ {
+		u := queue[i]
+		inherited := m.output[m.fail[u]]
+		own := m.output[u][len(inherited)-1:]
+		out := make([]SWord, 0, len(inherited)+len(own))
+		m.output[u] = append(append(out, inherited...), own...)
+		queue = append(queue, x.children(u)...)
+	}
+}
+
+// Remove returns a Matcher finding the patterns of m but those of key. Their
+// states are pruned from a copy of the trie, where failure links leading to
+// them skip to the next state along the failure chain, and their key is taken
+// out of the output sets. m itself is left untouched, and returned with ok
+// false if no pattern has the key. The key is not given again to patterns
+// added to the result afterwards.
+//
+// Disabling patterns for some searches only is cheaper, see EnabledSet.
+func (m *Matcher) Remove(key int) (removed *Matcher, ok bool) {
+	depth := m.depths()
+	var ends []int
+	for s, out := range m.output {
+		for _, item := range out {
+			if item.Key == uint64(key) && int(item.Len) == depth[s] {
+				ends = append(ends, s)
+				break
+			}
+		}
+	}
+	if len(ends) == 0 {
+		return m, false
+	}
+
+	// The failure links are relinked by a scan of the trie below, and
+	// indexed again by the next Add.
+	r := m.clone()
+	r.failIndex = nil
+	r.maxLen = 0
+	for s, out := range r.output {
+		r.output[s] = withoutKey(out, uint64(key))
+		for _, item := range r.output[s] {
+			if int(item.Len) > r.maxLen {
+				r.maxLen = int(item.Len)
+			}
+		}
+	}
+
+	// Prune the states which lead to no pattern anymore, from the ends of the
+	// removed patterns up.
+	pruned := make(map[int]bool)
+	prunable := func(s int) bool {
+		for _, c := range r.labels(s) {
+			if !pruned[r.base[s]+c] {
+				return false
+			}
+		}
+		for _, item := range r.output[s] {
+			if int(item.Len) == depth[s] {
+				return false
+			}
+		}
+		return true
+	}
+	var parents []int
+	for _, s := range ends {
+		for s != 0 && !pruned[s] && prunable(s) {
+			pruned[s] = true
+			s = r.check[s]
+		}
+		parents = append(parents, s)
+	}
+	for s := 1; s < len(r.check); s++ {
+		if r.check[s] >= 0 && !pruned[s] {
+			f := r.fail[s]
+			for pruned[f] {
+				f = r.fail[f]
+			}
+			r.fail[s] = f
+		}
+	}
+	for _, s := range sortedByDepth(pruned, depth) {
+		r.freeState(s)
+	}
+	for _, s := range parents {
+		if !pruned[s] && len(r.labels(s)) == 0 {
+			r.base[s] = leaf
+		}
+	}
+	return r, true
+}
+
+// withoutKey returns the output set out without the patterns of key, or out
+// itself if it holds none.
+func withoutKey(out []SWord, key uint64) []SWord {
+	for i, item := range out {
+		if item.Key == key {
+			kept := append([]SWord{}, out[:i]...)
+			for _, item := range out[i+1:] {
+				if item.Key != key {
+					kept = append(kept, item)
+				}
+			}
+			return kept
+		}
+	}
+	return out
+}
+
+// labels returns the labels of the edges from s.
+func (m *Matcher) labels(s int) []int {
+	var labels []int
+	for c := 0; c < 256; c++ {
+		if m.hasEdge(s, c) {
+			labels = append(labels, c)
+		}
+	}
+	return labels
+}
+
+// freeState returns state to the free list, see increaseSize. Since -1 ends
+// the list, slot 1 can only be its first slot, and is inserted there; other
+// slots are appended.
+func (m *Matcher) freeState(state int) {
+	m.base[state], m.fail[state], m.output[state] = 0, 0, nil
+	first, last := m.firstFreeState(), m.lastFreeState()
+	switch {
+	case first == -1:
+		m.check[0] = -state
+		m.base[state] = -state
+		m.check[state] = -1
+	case state == 1:
+		m.check[state] = -first
+		m.base[state] = -last
+		m.base[first] = -state
+		m.check[0] = -state
+	default:
+		m.base[state] = -last
+		m.check[state] = -1
+		m.base[first] = -state
+		m.check[last] = -state
+	}
+}
+
+// depths returns the depth of each state, and -1 for free slots.
+func (m *Matcher) depths() []int {
+	depth := make([]int, len(m.check))
+	for s := range depth {
+		depth[s] = -1
+	}
+	depth[0] = 0
+	var chain []int
+	for s := 1; s < len(m.check); s++ {
+		chain = chain[:0]
+		t := s
+		for depth[t] < 0 && m.check[t] >= 0 {
+			chain = append(chain, t)
+			t = m.check[t]
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			depth[chain[i]] = depth[t] + 1
+			t = chain[i]
+		}
+	}
+	return depth
+}
+
+// sortedByDepth returns the states of a set, the shallowest first.
+func sortedByDepth(set map[int]bool, depth []int) []int {
+	states := make([]int, 0, len(set))
+	for s := range set {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if depth[states[i]] != depth[states[j]] {
+			return depth[states[i]] < depth[states[j]]
+		}
+		return states[i] < states[j]
+	})
+	return states
+}
@@ -0,0 +1,296 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// readerMatches returns the matches of m in text by end position and key.
+func readerMatches(m *Matcher, text []byte) []MatchKey {
+	var matches MatchesKeys
+	m.FindAllByteReader(bytes.NewReader(text), &matches)
+	sortMatchKeys(matches.matches)
+	return matches.matches
+}
+
+func sortMatchKeys(matches []MatchKey) {
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		return a.Index < b.Index || a.Index == b.Index && a.Key < b.Key
+	})
+}
+
+func TestAdd(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	word := func(alphabet int) []byte {
+		w := make([]byte, 1+r.Intn(6))
+		for i := range w {
+			w[i] = byte('a' + r.Intn(alphabet))
+		}
+		return w
+	}
+	for i := 0; i < 200; i++ {
+		alphabet := 1 + r.Intn(26)
+		var patterns []Pattern
+		for j := r.Intn(100); j > 0; j-- {
+			patterns = append(patterns, Pattern{Word: word(alphabet)})
+		}
+		m := CompilePatterns(patterns)
+		before := m.Serialize()
+
+		// Add the patterns in a few batches, checking each step.
+		added := m
+		for batch := 1 + r.Intn(3); batch > 0; batch-- {
+			var more []Pattern
+			for j := 1 + r.Intn(50); j > 0; j-- {
+				more = append(more, Pattern{Word: word(alphabet)})
+			}
+			var first int
+			added, first = added.Add(more)
+			if first != len(patterns) {
+				t.Fatalf("%d: Expected: first key %d\nGot:      %d", i, len(patterns), first)
+			}
+			patterns = append(patterns, more...)
+
+			if err := added.Validate(); err != nil {
+				t.Fatalf("%d: %v", i, err)
+			}
+			compiled := CompilePatterns(patterns)
+			for k := 0; k < 5; k++ {
+				text := make([]byte, 200)
+				for l := range text {
+					text[l] = byte('a' + r.Intn(alphabet))
+				}
+				expected, got := readerMatches(compiled, text), readerMatches(added, text)
+				if !reflect.DeepEqual(expected, got) {
+					t.Fatalf("%d: %q\nExpected: %v\nGot:      %v", i, text, expected, got)
+				}
+			}
+		}
+		if !bytes.Equal(m.Serialize(), before) {
+			t.Errorf("%d: Expected: the original matcher unchanged", i)
+		}
+	}
+}
+
+func TestAddFreesSlotOne(t *testing.T) {
+	// Inserting "cbc" moves the child of the root away from slot 1, which
+	// then heads the free list, until "cb" takes it.
+	added, _ := CompileStrings([]string{"dab"}).Add([]Pattern{{Word: []byte("cbc")}, {Word: []byte("d")}})
+	if err := added.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cb := added.base[added.base[0]+'c'] + 'b'; cb != 1 {
+		t.Errorf("Expected: cb in slot 1\nGot:      %d", cb)
+	}
+	text := []byte("dabcbcd")
+	expected := readerMatches(CompileStrings([]string{"cbc", "d", "dab"}), text)
+	// Keys differ: "dab" is 0 in added and 2 in the compiled Matcher.
+	for i := range expected {
+		expected[i].Key = []int{1, 2, 0}[expected[i].Key]
+	}
+	sortMatchKeys(expected)
+	if got := readerMatches(added, text); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+}
+
+func TestAddOptions(t *testing.T) {
+	m := CompileStrings([]string{"he", "she"})
+	added, first := m.Add([]Pattern{
+		{Word: []byte("his"), Anchor: AnchorStartText},
+		{},
+		{Word: []byte("hers"), Boundary: ASCIIWordBoundary},
+	})
+	if first != 2 {
+		t.Errorf("Expected: first key 2\nGot:      %d", first)
+	}
+	var got []string
+	for _, match := range added.FindAllString("his she hers, ushers") {
+		got = append(got, string(match.Word))
+	}
+	expected := []string{"his", "he", "she", "he", "hers", "he", "she"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+
+	d, err := DeserializeWithOptions(added.Serialize(), &DeserializeOptions{Validate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(d.FindAllString("his she hers, ushers")); got != len(expected) {
+		t.Errorf("Expected: %d matches\nGot:      %d", len(expected), got)
+	}
+}
+
+func TestRegistryAdd(t *testing.T) {
+	r := NewRegistry(nil, nil)
+	if version, first := r.Add([]Pattern{{Word: []byte("foo")}}); version != 1 || first != 0 {
+		t.Errorf("Expected: version 1, key 0\nGot:      %d, %d", version, first)
+	}
+	old := r.Matcher()
+	if version, first := r.Add([]Pattern{{Word: []byte("bar")}}); version != 2 || first != 1 {
+		t.Errorf("Expected: version 2, key 1\nGot:      %d, %d", version, first)
+	}
+	if got := len(r.Matcher().FindAllString("foo bar")); got != 2 {
+		t.Errorf("Expected: 2 matches\nGot:      %d", got)
+	}
+	if got := len(old.FindAllString("foo bar")); got != 1 {
+		t.Errorf("Expected: 1 match\nGot:      %d", got)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		alphabet := 1 + r.Intn(26)
+		patterns := make([]Pattern, 1+r.Intn(100))
+		for j := range patterns {
+			patterns[j].Word = make([]byte, 1+r.Intn(6))
+			for k := range patterns[j].Word {
+				patterns[j].Word[k] = byte('a' + r.Intn(alphabet))
+			}
+		}
+		m := CompilePatterns(patterns)
+		before := m.Serialize()
+
+		// Remove some patterns, and add one back, checking each step.
+		removed := m
+		kept := make(map[int]bool)
+		for j := range patterns {
+			kept[j] = true
+		}
+		for _, key := range r.Perm(len(patterns))[:r.Intn(len(patterns)+1)] {
+			var ok bool
+			if removed, ok = removed.Remove(key); !ok {
+				t.Fatalf("%d: Expected: key %d removed", i, key)
+			}
+			delete(kept, key)
+		}
+		if _, ok := removed.Remove(len(patterns)); ok {
+			t.Errorf("%d: Expected: no key %d", i, len(patterns))
+		}
+		if err := removed.Validate(); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		var rest []Pattern
+		for j, p := range patterns {
+			if kept[j] {
+				rest = append(rest, p)
+			}
+		}
+		if got, expected := removed.Stats().States, CompilePatterns(rest).Stats().States; got != expected {
+			t.Errorf("%d: Expected: %d states\nGot:      %d", i, expected, got)
+		}
+		readded, first := removed.Add(patterns[:1])
+		if err := readded.Validate(); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if first != len(patterns) {
+			t.Errorf("%d: Expected: first key %d\nGot:      %d", i, len(patterns), first)
+		}
+
+		compiled := CompilePatterns(patterns)
+		for k := 0; k < 5; k++ {
+			text := make([]byte, 200)
+			for l := range text {
+				text[l] = byte('a' + r.Intn(alphabet))
+			}
+			var expected, expectedReadded []MatchKey
+			for _, match := range readerMatches(compiled, text) {
+				if kept[match.Key] {
+					expected = append(expected, match)
+					expectedReadded = append(expectedReadded, match)
+				}
+				if match.Key == 0 {
+					expectedReadded = append(expectedReadded, MatchKey{match.Index, first})
+				}
+			}
+			if got := readerMatches(removed, text); !reflect.DeepEqual(got, expected) {
+				t.Fatalf("%d: %q\nExpected: %v\nGot:      %v", i, text, expected, got)
+			}
+			// The pattern added back has the key first, which is no
+			// earlier key.
+			sortMatchKeys(expectedReadded)
+			if got := readerMatches(readded, text); !reflect.DeepEqual(got, expectedReadded) {
+				t.Fatalf("%d: %q\nExpected: %v\nGot:      %v", i, text, expectedReadded, got)
+			}
+		}
+		if !bytes.Equal(m.Serialize(), before) {
+			t.Errorf("%d: Expected: the original matcher unchanged", i)
+		}
+	}
+}
+
+func TestRemoveThenAdd(t *testing.T) {
+	m := CompileStrings([]string{"a", "b"})
+	removed, _ := m.Remove(1)
+	added, first := removed.Add([]Pattern{{Word: []byte("c")}})
+	if first != 2 {
+		t.Errorf("Expected: first key 2\nGot:      %d", first)
+	}
+
+	// The retired key survives serialization, where no output holds it.
+	d, err := Deserialize(removed.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, first := d.Add([]Pattern{{Word: []byte("c")}}); first != 2 {
+		t.Errorf("Expected: first key 2 after Deserialize\nGot:      %d", first)
+	}
+	if !bytes.Equal(m.Serialize(), CompileStrings([]string{"a", "b"}).Serialize()) {
+		t.Error("Expected: no next key section for a compiled Matcher")
+	}
+
+	// A user enabling the removed key sees none of the added patterns.
+	if got := added.FindAllEnabled([]byte("abc"), NewEnabledSet(1)); len(got) != 0 {
+		t.Errorf("Expected: no match\nGot:      %v", got)
+	}
+}
+
+func TestRegistryRemove(t *testing.T) {
+	r := NewRegistry(CompileStrings([]string{"bar", "foo"}), nil)
+	if version, ok := r.Remove(1); !ok || version != 2 {
+		t.Errorf("Expected: version 2\nGot:      %d, %v", version, ok)
+	}
+	if version, ok := r.Remove(1); ok || version != 2 {
+		t.Errorf("Expected: version 2 kept\nGot:      %d, %v", version, ok)
+	}
+	if got := r.Matcher().FindAllString("foo bar"); len(got) != 1 || string(got[0].Word) != "bar" {
+		t.Errorf("Expected: bar\nGot:      %v", got)
+	}
+}
+
+// dictionary returns n random words of 4 to 12 lowercase letters.
+func dictionary(n int) []Pattern {
+	r := rand.New(rand.NewSource(1))
+	patterns := make([]Pattern, n)
+	for i := range patterns {
+		patterns[i].Word = make([]byte, 4+r.Intn(9))
+		for j := range patterns[i].Word {
+			patterns[i].Word[j] = byte('a' + r.Intn(26))
+		}
+	}
+	return patterns
+}
+
+func BenchmarkCompile300k(b *testing.B) {
+	patterns := dictionary(300000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CompilePatterns(patterns)
+	}
+}
+
+func BenchmarkAdd10To300k(b *testing.B) {
+	patterns := dictionary(300010)
+	// The first Add indexes the failure links, later ones reuse the index.
+	m, _ := CompilePatterns(patterns[10:]).Add(nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Add(patterns[:10])
+	}
+}
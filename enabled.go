@@ -0,0 +1,78 @@
+package ahocorasick
+
+// EnabledSet is a set of pattern keys, which restricts a search to the
+// patterns it holds. It lets a pattern be disabled without compiling or
+// copying the Matcher, and several users share one Matcher while each of them
+// only sees its own patterns. The zero EnabledSet holds no key.
+//
+// An EnabledSet may be used by concurrent searches, but must not be modified
+// while it is in use.
+type EnabledSet struct {
+	bits []uint64
+}
+
+// NewEnabledSet returns an EnabledSet holding keys.
+func NewEnabledSet(keys ...int) *EnabledSet {
+	s := &EnabledSet{}
+	for _, key := range keys {
+		s.Enable(key)
+	}
+	return s
+}
+
+// Enable adds key to the set. Negative keys are ignored.
+func (s *EnabledSet) Enable(key int) {
+	if key < 0 {
+		return
+	}
+	if i := key / 64; i >= len(s.bits) {
+		s.bits = append(s.bits, make([]uint64, i+1-len(s.bits))...)
+	}
+	s.bits[key/64] |= 1 << (key % 64)
+}
+
+// Disable removes key from the set.
+func (s *EnabledSet) Disable(key int) {
+	if key >= 0 && key/64 < len(s.bits) {
+		s.bits[key/64] &^= 1 << (key % 64)
+	}
+}
+
+// Has reports whether key is in the set.
+func (s *EnabledSet) Has(key int) bool {
+	return key >= 0 && s.has(uint64(key))
+}
+
+func (s *EnabledSet) has(key uint64) bool {
+	return key/64 < uint64(len(s.bits)) && s.bits[key/64]&(1<<(key%64)) != 0
+}
+
+// Filter returns Matches appending to matches only the keys of the set, so
+// that the searches reporting to Matches, such as FindAllByteReader, can be
+// restricted to the set.
+func (s *EnabledSet) Filter(matches Matches) Matches {
+	return enabledMatches{s, matches}
+}
+
+type enabledMatches struct {
+	set     *EnabledSet
+	matches Matches
+}
+
+// Append receives the end position of a match first, then its key, as the
+// searches call it.
+func (e enabledMatches) Append(position int, key int) {
+	if e.set.Has(key) {
+		e.matches.Append(position, key)
+	}
+}
+
+func (e enabledMatches) Count() int {
+	return e.matches.Count()
+}
+
+// FindAllEnabled finds the instances of the patterns of enabled in the text,
+// like FindAllByteSlice. A nil EnabledSet enables every pattern.
+func (m *Matcher) FindAllEnabled(text []byte, enabled *EnabledSet) []*Match {
+	return m.findEnabled(text, enabled)
+}
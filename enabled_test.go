@@ -0,0 +1,57 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEnabledSet(t *testing.T) {
+	s := NewEnabledSet(0, 3, 64, 200)
+	s.Disable(3)
+	s.Disable(1000)
+	s.Enable(-1)
+	for key, expected := range map[int]bool{-1: false, 0: true, 1: false, 3: false, 64: true, 200: true, 201: false, 1000: false} {
+		if got := s.Has(key); got != expected {
+			t.Errorf("%d: Expected: %v\nGot:      %v", key, expected, got)
+		}
+	}
+	if (&EnabledSet{}).Has(0) {
+		t.Error("Expected: the zero EnabledSet empty")
+	}
+}
+
+func TestFindAllEnabled(t *testing.T) {
+	// Keys follow sorted order: he 0, hers 1, his 2, she 3.
+	m := CompileStrings([]string{"he", "she", "his", "hers"})
+	anchored := CompileStringsAnchored([]string{"he", "she", "his", "hers"})
+	tests := []struct {
+		matcher  *Matcher
+		enabled  *EnabledSet
+		text     string
+		expected []string
+	}{
+		{m, nil, "ushers", []string{"he", "she", "hers"}},
+		{m, NewEnabledSet(), "ushers", nil},
+		{m, NewEnabledSet(0, 1), "ushers", []string{"he", "hers"}},
+		{m, NewEnabledSet(3), "ushers his", []string{"she"}},
+		{anchored, NewEnabledSet(1), "hers", []string{"hers"}},
+		{anchored, NewEnabledSet(3), "hers", nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, match := range test.matcher.FindAllEnabled([]byte(test.text), test.enabled) {
+			got = append(got, string(match.Word))
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q %v\nExpected: %v\nGot:      %v", test.text, test.enabled, test.expected, got)
+		}
+	}
+
+	var matches MatchesKeys
+	m.FindAllByteReader(bytes.NewReader([]byte("ushers")), NewEnabledSet(0, 1).Filter(&matches))
+	expected := []MatchKey{{4, 0}, {6, 1}}
+	if !reflect.DeepEqual(matches.matches, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, matches.matches)
+	}
+}
@@ -0,0 +1,193 @@
+package ahocorasick
+
+import (
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is a set of text encodings in which patterns are searched for.
+type Encoding uint8
+
+const (
+	EncodingUTF8        Encoding = 1 << iota // the patterns as given
+	EncodingUTF16LE                          // UTF-16, little endian, as in Windows memory
+	EncodingUTF16BE                          // UTF-16, big endian
+	EncodingLatin1                           // ISO-8859-1, for patterns of runes up to U+00FF
+	EncodingWindows1252                      // Windows-1252, Latin-1 with printable characters in 0x80-0x9F
+
+	encodingMask = 1<<iota - 1
+)
+
+var encodingNames = []string{"UTF-8", "UTF-16LE", "UTF-16BE", "Latin-1", "Windows-1252"}
+
+// String returns the names of the encodings in e separated by "|".
+func (e Encoding) String() string {
+	var names []string
+	for i, name := range encodingNames {
+		if e&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// windows1252 holds the runes of the bytes 0x80 to 0x9F in Windows-1252. The
+// bytes left undefined by the code page are zero.
+var windows1252 = [32]rune{
+	0x20AC, 0, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017D, 0,
+	0, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0, 0x017E, 0x0178,
+}
+
+// EncodedMatcher finds patterns written in several encodings. The forms of
+// every pattern in the requested encodings are compiled into a single Matcher,
+// so the text is scanned once without being transcoded.
+type EncodedMatcher struct {
+	matcher  *Matcher
+	variants []encodedVariant // variants by key
+}
+
+type encodedVariant struct {
+	pattern  int
+	encoding Encoding
+}
+
+// EncodedMatch represents a pattern found in the text in some encoding.
+type EncodedMatch struct {
+	Word     []byte   // the matched bytes of the text
+	Index    int      // the start index of the match
+	Key      int      // the index of the pattern
+	Encoding Encoding // the encodings in which the pattern is written as Word
+}
+
+// CompileEncoded compiles an EncodedMatcher finding the UTF-8 words in each
+// of the encodings. A word is left out of the encodings which cannot represent
+// it: the UTF-16 forms need valid UTF-8, and Latin-1 and Windows-1252 runes
+// their code page has. When several encodings write a word the same way, as
+// UTF-8 and Latin-1 do for ASCII, a single match reports all of them. If
+// encodings is zero, the words are searched for in UTF-8 only.
+func CompileEncoded(words []string, encodings Encoding) *EncodedMatcher {
+	if encodings&encodingMask == 0 {
+		encodings = EncodingUTF8
+	}
+	e := new(EncodedMatcher)
+	var forms [][]byte
+	var keys []uint64
+	for i, word := range words {
+		first := len(e.variants)
+		for enc := EncodingUTF8; enc&encodingMask != 0; enc <<= 1 {
+			if encodings&enc == 0 {
+				continue
+			}
+			form, ok := encode(word, enc)
+			if !ok || len(form) == 0 {
+				continue
+			}
+			merged := false
+			for j := first; j < len(e.variants); j++ {
+				if string(forms[j]) == string(form) {
+					e.variants[j].encoding |= enc
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				keys = append(keys, uint64(len(e.variants)))
+				forms = append(forms, form)
+				e.variants = append(e.variants, encodedVariant{i, enc})
+			}
+		}
+	}
+	e.matcher = compileKeyed(forms, keys)
+	return e
+}
+
+// encode returns word in the encoding enc, or false if enc cannot represent
+// it.
+func encode(word string, enc Encoding) ([]byte, bool) {
+	if enc == EncodingUTF8 {
+		return []byte(word), true
+	}
+	if !utf8.ValidString(word) {
+		return nil, false
+	}
+	var form []byte
+	switch enc {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		for _, u := range utf16.Encode([]rune(word)) {
+			if enc == EncodingUTF16LE {
+				form = append(form, byte(u), byte(u>>8))
+			} else {
+				form = append(form, byte(u>>8), byte(u))
+			}
+		}
+	case EncodingLatin1:
+		for _, r := range word {
+			if r > 0xFF {
+				return nil, false
+			}
+			form = append(form, byte(r))
+		}
+	case EncodingWindows1252:
+		for _, r := range word {
+			b, ok := windows1252Byte(r)
+			if !ok {
+				return nil, false
+			}
+			form = append(form, b)
+		}
+	}
+	return form, true
+}
+
+// windows1252Byte returns the Windows-1252 byte of r.
+func windows1252Byte(r rune) (byte, bool) {
+	if r < 0x80 || r >= 0xA0 && r <= 0xFF {
+		return byte(r), true
+	}
+	for i, c := range windows1252 {
+		if c == r && c != 0 {
+			return byte(0x80 + i), true
+		}
+	}
+	return 0, false
+}
+
+// Variant returns the index of the pattern and the encodings of the form
+// compiled under key, which FindAllByteReader reports.
+func (e *EncodedMatcher) Variant(key int) (pattern int, encoding Encoding) {
+	v := e.variants[key]
+	return v.pattern, v.encoding
+}
+
+// FindAllByteSlice finds all instances of the patterns, in any of their
+// encodings, in the text.
+func (e *EncodedMatcher) FindAllByteSlice(text []byte) []*EncodedMatch {
+	var matches []*EncodedMatch
+	state := 0
+	for i, b := range text {
+		state = e.matcher.advance(state, b)
+		for _, item := range e.matcher.output[state] {
+			start := i - int(item.Len) + 1
+			v := e.variants[item.Key]
+			matches = append(matches, &EncodedMatch{text[start : i+1], start, v.pattern, v.encoding})
+		}
+	}
+	return matches
+}
+
+// FindAllString finds all instances of the patterns, in any of their
+// encodings, in the text.
+func (e *EncodedMatcher) FindAllString(text string) []*EncodedMatch {
+	return e.FindAllByteSlice([]byte(text))
+}
+
+// FindAllByteReader finds all instances of the patterns in the stream and
+// appends the end position and the key of each of them to matches. The key
+// identifies the pattern and encodings through Variant.
+func (e *EncodedMatcher) FindAllByteReader(reader io.Reader, matches Matches) {
+	e.matcher.FindAllByteReader(reader, matches)
+}
@@ -0,0 +1,98 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+type encodedResult struct {
+	word     string
+	index    int
+	key      int
+	encoding Encoding
+}
+
+func convertEncoded(got []*EncodedMatch) []encodedResult {
+	var converted []encodedResult
+	for _, m := range got {
+		converted = append(converted, encodedResult{string(m.Word), m.Index, m.Key, m.Encoding})
+	}
+	return converted
+}
+
+func TestFindAllEncoded(t *testing.T) {
+	all := EncodingUTF8 | EncodingUTF16LE | EncodingUTF16BE | EncodingLatin1 | EncodingWindows1252
+	tests := []struct {
+		words     []string
+		encodings Encoding
+		text      string
+		expected  []encodedResult
+	}{
+		{
+			[]string{"ab"}, all,
+			"ab a\x00b\x00 \x00a\x00b",
+			[]encodedResult{
+				{"ab", 0, 0, EncodingUTF8 | EncodingLatin1 | EncodingWindows1252},
+				{"a\x00b\x00", 3, 0, EncodingUTF16LE},
+				{"\x00a\x00b", 8, 0, EncodingUTF16BE},
+			},
+		},
+		{
+			[]string{"café", "€1"}, all,
+			"café caf\xe9 \x801",
+			[]encodedResult{
+				{"café", 0, 0, EncodingUTF8},
+				{"caf\xe9", 6, 0, EncodingLatin1 | EncodingWindows1252},
+				{"\x801", 11, 1, EncodingWindows1252},
+			},
+		},
+		{
+			// Runes beyond the BMP are encoded as surrogate pairs.
+			[]string{"😀"}, EncodingUTF16LE,
+			"\x3d\xd8\x00\xde",
+			[]encodedResult{{"\x3d\xd8\x00\xde", 0, 0, EncodingUTF16LE}},
+		},
+		{
+			// Latin-1 has no "€", invalid UTF-8 has no UTF-16 form.
+			[]string{"€", "\xff"}, EncodingLatin1 | EncodingUTF16LE,
+			"\x80\xff\xac\x20",
+			[]encodedResult{{"\xac\x20", 2, 0, EncodingUTF16LE}},
+		},
+		{
+			[]string{"ab"}, 0,
+			"ab",
+			[]encodedResult{{"ab", 0, 0, EncodingUTF8}},
+		},
+	}
+	for _, test := range tests {
+		e := CompileEncoded(test.words, test.encodings)
+		got := convertEncoded(e.FindAllString(test.text))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q in %v\nExpected: %v\nGot:      %v", test.words, test.encodings, test.expected, got)
+		}
+	}
+}
+
+func TestFindAllEncodedReader(t *testing.T) {
+	e := CompileEncoded([]string{"key", "pass"}, EncodingUTF8|EncodingUTF16LE)
+	text := []byte("p\x00a\x00s\x00s\x00 key")
+	matches := &MatchesKeys{}
+	e.FindAllByteReader(iotest.OneByteReader(bytes.NewReader(text)), matches)
+	var got []encodedResult
+	for _, m := range matches.matches {
+		pattern, encoding := e.Variant(m.Key)
+		got = append(got, encodedResult{"", m.Index, pattern, encoding})
+	}
+	expected := []encodedResult{{"", 8, 1, EncodingUTF16LE}, {"", 12, 0, EncodingUTF8}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+}
+
+func TestEncodingString(t *testing.T) {
+	if s := (EncodingUTF8 | EncodingWindows1252).String(); s != "UTF-8|Windows-1252" {
+		t.Errorf("Got %q", s)
+	}
+}
@@ -0,0 +1,157 @@
+package ahocorasick
+
+import "unicode/utf8"
+
+// filtered reports whether some matches of the automaton have to be checked
+// against their surroundings before being reported.
+func (m *Matcher) filtered() bool {
+	return m.anchors != nil || m.boundaries != nil || m.boundary != NoBoundary
+}
+
+// acceptAt reports whether the match of key at text[start:end] satisfies the
+// anchors and the boundary of its pattern.
+func (m *Matcher) acceptAt(key uint64, text []byte, start, end int) bool {
+	before := text[:start]
+	if len(before) > utf8.UTFMax {
+		before = before[len(before)-utf8.UTFMax:]
+	}
+	after := text[end:]
+	if len(after) > utf8.UTFMax {
+		after = after[:utf8.UTFMax]
+	}
+	return m.accept(key, before, text[start:end], after, start == 0, end == len(text))
+}
+
+// accept reports whether the match of key satisfies the anchors and the
+// boundary of its pattern. before and after hold up to utf8.UTFMax bytes
+// around the matched word, and atStart and atEnd tell whether the word begins
+// or ends the text.
+func (m *Matcher) accept(key uint64, before, word, after []byte, atStart, atEnd bool) bool {
+	if m.anchors != nil {
+		a := m.anchors[key]
+		if a&AnchorStartText != 0 && !atStart {
+			return false
+		}
+		if a&AnchorEndText != 0 && !atEnd {
+			return false
+		}
+		if a&AnchorStartLine != 0 && !atStart && before[len(before)-1] != '\n' {
+			return false
+		}
+		if a&AnchorEndLine != 0 && !atEnd && after[0] != '\n' {
+			return false
+		}
+	}
+	if b := m.boundaryOf(key); b != NoBoundary {
+		return m.delimited(b, before, word, after)
+	}
+	return true
+}
+
+// needsAfter reports whether accepting a match of key depends on the bytes
+// following it.
+func (m *Matcher) needsAfter(key uint64) bool {
+	if m.anchors != nil && m.anchors[key]&(AnchorEndText|AnchorEndLine) != 0 {
+		return true
+	}
+	return m.boundaryOf(key) != NoBoundary
+}
+
+// streamFilter applies accept while scanning a stream, where the bytes before
+// a match may already have left the read buffer and the bytes after it may
+// not have been read yet. The most recent bytes are kept in a ring large
+// enough to hold the longest pattern and a rune on either side of it. Matches
+// which depend on what follows them are held back until a full rune or the
+// end of the stream arrives, along with every match after them so that the
+// order of the reported matches is kept.
+type streamFilter struct {
+	m       *Matcher
+	ring    []byte
+	pos     int // number of bytes seen so far
+	pending []pendingMatch
+	scratch []byte
+}
+
+type pendingMatch struct {
+	end  int
+	item SWord
+}
+
+func (m *Matcher) newStreamFilter() *streamFilter {
+	size := m.maxLen + 2*utf8.UTFMax
+	return &streamFilter{m: m, ring: make([]byte, size), scratch: make([]byte, 0, size)}
+}
+
+// next records the byte b and reports the pending matches it resolves. It must
+// be called for every byte before the matches ending with it.
+func (s *streamFilter) next(b byte, matches Matches) {
+	s.ring[s.pos%len(s.ring)] = b
+	s.pos++
+	s.resolve(false, matches)
+}
+
+// match reports item ending at position end, or holds it back until enough of
+// the following bytes are known.
+func (s *streamFilter) match(end int, item SWord, matches Matches) {
+	if len(s.pending) > 0 || s.m.needsAfter(item.Key) {
+		s.pending = append(s.pending, pendingMatch{end, item})
+		return
+	}
+	if s.accept(end, item, false) {
+		matches.Append(end, int(item.Key))
+	}
+}
+
+// close resolves the matches still waiting at the end of the stream.
+func (s *streamFilter) close(matches Matches) {
+	s.resolve(true, matches)
+}
+
+func (s *streamFilter) resolve(eof bool, matches Matches) {
+	n := 0
+	for _, p := range s.pending {
+		if !eof && !s.ready(p.end) {
+			break
+		}
+		if s.accept(p.end, p.item, eof) {
+			matches.Append(p.end, int(p.item.Key))
+		}
+		n++
+	}
+	s.pending = append(s.pending[:0], s.pending[n:]...)
+}
+
+// ready reports whether enough bytes after end have been seen to decide on a
+// match ending there.
+func (s *streamFilter) ready(end int) bool {
+	if s.pos-end >= utf8.UTFMax {
+		return true
+	}
+	return s.pos > end && utf8.FullRune(s.bytes(end, s.pos))
+}
+
+func (s *streamFilter) accept(end int, item SWord, eof bool) bool {
+	start := end - int(item.Len)
+	from := start - utf8.UTFMax
+	if from < 0 {
+		from = 0
+	}
+	to := end + utf8.UTFMax
+	if to > s.pos {
+		to = s.pos
+	}
+	around := s.bytes(from, to)
+	before := around[:start-from]
+	word := around[start-from : end-from]
+	after := around[end-from:]
+	return s.m.accept(item.Key, before, word, after, start == 0, eof && end == s.pos)
+}
+
+// bytes copies the stream bytes in [from, to) out of the ring.
+func (s *streamFilter) bytes(from, to int) []byte {
+	s.scratch = s.scratch[:0]
+	for i := from; i < to; i++ {
+		s.scratch = append(s.scratch, s.ring[i%len(s.ring)])
+	}
+	return s.scratch
+}
@@ -0,0 +1,174 @@
+package ahocorasick
+
+//go:generate go run gen_confusables.go
+
+import (
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// FoldTable maps runes to the string they are folded to before matching.
+// Runes missing from the table are kept as they are.
+type FoldTable map[rune]string
+
+// Confusables returns a new FoldTable mapping every rune to its prototype in
+// the confusables data of Unicode Technical Standard #39, such as the
+// Cyrillic "а" to the Latin "a". Folding with it computes the skeletons of
+// that standard, which are equal for strings which can be mistaken for one
+// another. The table can be extended or trimmed to build custom tables.
+func Confusables() FoldTable {
+	t := make(FoldTable, len(confusables))
+	for _, c := range confusables {
+		t[c.from] = c.to
+	}
+	return t
+}
+
+// Fold returns the folded form of s: s decomposed to NFD, with every rune
+// replaced through the table.
+func (t FoldTable) Fold(s string) string {
+	return string(t.fold(nil, []byte(s)))
+}
+
+// fold appends the folded form of the runes of text to dst.
+func (t FoldTable) fold(dst, text []byte) []byte {
+	for i := 0; i < len(text); {
+		var size int
+		dst, size = t.foldRune(dst, text[i:])
+		i += size
+	}
+	return dst
+}
+
+// foldRune appends the folded form of the first rune of text to dst, and
+// returns the number of bytes of text it took. Bytes of invalid UTF-8 are
+// kept as they are.
+func (t FoldTable) foldRune(dst, text []byte) ([]byte, int) {
+	r, size := utf8.DecodeRune(text)
+	if r == utf8.RuneError && size <= 1 {
+		return append(dst, text[0]), 1
+	}
+	if r >= utf8.RuneSelf {
+		if d := norm.NFD.Properties(text).Decomposition(); d != nil {
+			for len(d) > 0 {
+				r, n := utf8.DecodeRune(d)
+				dst = t.foldDecomposed(dst, r, d[:n])
+				d = d[n:]
+			}
+			return dst, size
+		}
+	}
+	return t.foldDecomposed(dst, r, text[:size]), size
+}
+
+// foldDecomposed appends the mapping of r, encoded as b, to dst.
+func (t FoldTable) foldDecomposed(dst []byte, r rune, b []byte) []byte {
+	if to, ok := t[r]; ok {
+		return append(dst, to...)
+	}
+	return append(dst, b...)
+}
+
+// FoldedMatcher finds patterns in text after folding both through a
+// FoldTable, and reports matches at their place in the original text. A
+// match starting or ending inside the folded form of a rune spans the whole
+// rune.
+type FoldedMatcher struct {
+	matcher *Matcher
+	table   FoldTable
+}
+
+// CompileFolded compiles a FoldedMatcher from a slice of strings. The key of
+// a match reported by FindAllByteReader is the index of its word.
+func CompileFolded(words []string, table FoldTable) *FoldedMatcher {
+	fm := &FoldedMatcher{table: table}
+	var folded [][]byte
+	var keys []uint64
+	for i, word := range words {
+		w := table.fold(nil, []byte(word))
+		if len(w) == 0 {
+			continue
+		}
+		folded = append(folded, w)
+		keys = append(keys, uint64(i))
+	}
+	fm.matcher = compileKeyed(folded, keys)
+	return fm
+}
+
+// foldScanner runs the automaton over folded runes, remembering for the last
+// bytes of folded text the original rune they come from.
+type foldScanner struct {
+	fm     *FoldedMatcher
+	state  int
+	n      int // folded bytes scanned
+	ring   []segmentSpan
+	buffer []byte
+}
+
+func (fm *FoldedMatcher) newScanner() *foldScanner {
+	return &foldScanner{fm: fm, ring: make([]segmentSpan, fm.matcher.maxLen+1)}
+}
+
+// scan runs the automaton over the runes of text, whose first byte is at
+// position offset of the original text, and calls found with the original
+// span and the key of every match.
+func (s *foldScanner) scan(text []byte, offset int, found func(start, end int, key uint64)) {
+	for i := 0; i < len(text); {
+		var size int
+		s.buffer, size = s.fm.table.foldRune(s.buffer[:0], text[i:])
+		span := segmentSpan{offset + i, offset + i + size}
+		i += size
+		for _, b := range s.buffer {
+			s.ring[s.n%len(s.ring)] = span
+			s.n++
+			s.state = s.fm.matcher.advance(s.state, b)
+			for _, item := range s.fm.matcher.output[s.state] {
+				first := s.ring[(s.n-int(item.Len))%len(s.ring)]
+				found(first.start, span.end, item.Key)
+			}
+		}
+	}
+}
+
+// FindAllByteSlice finds all instances of the patterns in the folded text.
+// The Word of a match is the original text it was found in.
+func (fm *FoldedMatcher) FindAllByteSlice(text []byte) []*Match {
+	var matches []*Match
+	fm.newScanner().scan(text, 0, func(start, end int, key uint64) {
+		matches = append(matches, &Match{text[start:end], start})
+	})
+	return matches
+}
+
+// FindAllString finds all instances of the patterns in the folded text.
+func (fm *FoldedMatcher) FindAllString(text string) []*Match {
+	return fm.FindAllByteSlice([]byte(text))
+}
+
+// FindAllByteReader finds all instances of the patterns in the folded stream
+// and appends the end position in the original stream and the key of each of
+// them to matches.
+func (fm *FoldedMatcher) FindAllByteReader(reader io.Reader, matches Matches) {
+	s := fm.newScanner()
+	found := func(start, end int, key uint64) {
+		matches.Append(end, int(key))
+	}
+	var pending []byte // a rune split across reads
+	offset := 0
+	chunk := make([]byte, 4096)
+	for {
+		n, err := reader.Read(chunk)
+		pending = append(pending, chunk[:n]...)
+		if err != nil {
+			s.scan(pending, offset, found)
+			return
+		}
+		complete := fullRunes(pending)
+		s.scan(pending[:complete], offset, found)
+		offset += complete
+		pending = append(pending[:0], pending[complete:]...)
+	}
+}
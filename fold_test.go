@@ -0,0 +1,85 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFindAllFolded(t *testing.T) {
+	confusables := Confusables()
+	custom := FoldTable{'0': "o", '@': "a"}
+	tests := []struct {
+		words    []string
+		table    FoldTable
+		text     string
+		expected []Match
+	}{
+		{
+			// The first "а" is Cyrillic.
+			[]string{"paypal"}, confusables,
+			"log in to pаypal or paypal",
+			[]Match{{[]byte("pаypal"), 10}, {[]byte("paypal"), 21}},
+		},
+		{
+			[]string{"amazon"}, confusables,
+			"arnazon",
+			[]Match{{[]byte("arnazon"), 0}},
+		},
+		{
+			// Decomposed runes are folded too: "é" is "e" and a mark.
+			[]string{"e"}, confusables,
+			"é",
+			[]Match{{[]byte("é"), 0}},
+		},
+		{
+			[]string{"foo", "pass"}, custom,
+			"f00 p@ss \xff",
+			[]Match{{[]byte("f00"), 0}, {[]byte("p@ss"), 4}},
+		},
+		{
+			[]string{"foo"}, custom,
+			"fοο", // Greek omicrons are not in the custom table
+			nil,
+		},
+	}
+	for _, test := range tests {
+		fm := CompileFolded(test.words, test.table)
+		got := convert(fm.FindAllString(test.text))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q in %q\nExpected: %q\nGot:      %q", test.words, test.text, test.expected, got)
+		}
+
+		var ends []MatchKey
+		for _, m := range got {
+			ends = append(ends, MatchKey{m.Index + len(m.Word), 0})
+		}
+		matches := &MatchesKeys{}
+		fm.FindAllByteReader(iotest.OneByteReader(bytes.NewReader([]byte(test.text))), matches)
+		for i := range matches.matches {
+			matches.matches[i].Key = 0
+		}
+		if !reflect.DeepEqual(matches.matches, ends) {
+			t.Errorf("%q reader\nExpected: %v\nGot:      %v", test.text, ends, matches.matches)
+		}
+	}
+}
+
+func TestConfusablesFold(t *testing.T) {
+	confusables := Confusables()
+	pairs := [][2]string{
+		{"pаypal", "paypal"},
+		{"ΑΡΡLЕ", "APPLE"},
+		{"rn", "m"},
+		{"1", "l"},
+	}
+	for _, p := range pairs {
+		if a, b := confusables.Fold(p[0]), confusables.Fold(p[1]); a != b {
+			t.Errorf("%q and %q fold to %q and %q", p[0], p[1], a, b)
+		}
+	}
+	if a, b := confusables.Fold("paypal"), confusables.Fold("paypai"); a == b {
+		t.Errorf("%q and %q both fold to %q", "paypal", "paypai", a)
+	}
+}
@@ -0,0 +1,139 @@
+//go:build ignore
+
+// This program generates confusables_table.go from confusables.txt, the data
+// file of Unicode Technical Standard #39 which maps every character to its
+// prototype, the character it can be mistaken for. It is run by go generate:
+//
+//	go run gen_confusables.go [-url URL | -file confusables.txt]
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	url    = flag.String("url", "https://www.unicode.org/Public/security/latest/confusables.txt", "URL of confusables.txt")
+	file   = flag.String("file", "", "read confusables.txt from this file instead of downloading it")
+	output = flag.String("output", "confusables_table.go", "file to write")
+)
+
+type mapping struct {
+	from rune
+	to   string
+}
+
+func main() {
+	flag.Parse()
+	input, err := open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer input.Close()
+
+	var header []string
+	var mappings []mapping
+	scanner := bufio.NewScanner(input)
+	for inHeader := true; scanner.Scan(); {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if strings.HasPrefix(line, "#") {
+			if inHeader {
+				header = append(header, "//"+strings.TrimPrefix(line, "#"))
+			}
+			continue
+		}
+		inHeader = false
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m, err := parse(line)
+		if err != nil {
+			log.Fatalf("%q: %v", line, err)
+		}
+		mappings = append(mappings, m)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].from < mappings[j].from })
+
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "// Code generated by gen_confusables.go; DO NOT EDIT.")
+	fmt.Fprintln(&b, "//")
+	fmt.Fprintln(&b, "// Source header:")
+	fmt.Fprintln(&b, "//")
+	for _, line := range header {
+		fmt.Fprintln(&b, line)
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "package ahocorasick")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "// confusables maps runes to their prototype in confusables.txt, decomposed")
+	fmt.Fprintln(&b, "// to NFD.")
+	fmt.Fprintln(&b, "var confusables = []struct {")
+	fmt.Fprintln(&b, "\tfrom rune")
+	fmt.Fprintln(&b, "\tto   string")
+	fmt.Fprintln(&b, "}{")
+	for _, m := range mappings {
+		fmt.Fprintf(&b, "\t{0x%04X, %+q},\n", m.from, m.to)
+	}
+	fmt.Fprintln(&b, "}")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func open() (io.ReadCloser, error) {
+	if *file != "" {
+		return os.Open(*file)
+	}
+	resp, err := http.Get(*url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", *url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// parse parses a line of the form
+//
+//	0430 ;	0061 ;	MA	# ( а → a ) CYRILLIC SMALL LETTER A → LATIN SMALL LETTER A
+func parse(line string) (mapping, error) {
+	fields := strings.Split(line, ";")
+	if len(fields) < 2 {
+		return mapping{}, fmt.Errorf("expected source and target fields")
+	}
+	from, err := strconv.ParseUint(strings.TrimSpace(fields[0]), 16, 32)
+	if err != nil {
+		return mapping{}, err
+	}
+	var to []rune
+	for _, point := range strings.Fields(fields[1]) {
+		r, err := strconv.ParseUint(point, 16, 32)
+		if err != nil {
+			return mapping{}, err
+		}
+		to = append(to, rune(r))
+	}
+	return mapping{rune(from), norm.NFD.String(string(to))}, nil
+}
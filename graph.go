@@ -0,0 +1,217 @@
+package ahocorasick
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// GraphOptions limit the part of the trie written by WriteDOT and WriteJSON.
+type GraphOptions struct {
+	// FailLinks makes WriteDOT draw the failure links, as dashed edges.
+	// WriteJSON always writes them.
+	FailLinks bool
+	// MaxDepth is the depth of the deepest states written, if not zero.
+	MaxDepth int
+	// MaxStates is the number of states written, the shallowest first, if
+	// not zero.
+	MaxStates int
+}
+
+// trieEdge is a goto edge of the trie.
+type trieEdge struct {
+	label int
+	to    int
+}
+
+// trieGraph is the logical trie held by the double array, the part of it
+// within some limits.
+type trieGraph struct {
+	states    []int              // states in breadth first order
+	depth     map[int]int        // depth by state of the graph
+	parent    map[int]int        // parent by state of the graph
+	edges     map[int][]trieEdge // edges by state, to the states of the graph
+	cut       map[int]bool       // states with edges to states left out
+	truncated bool
+}
+
+// graph walks the trie from its root breadth first. It only relies on the
+// arrays of the Matcher, so it works on deserialized matchers, and never
+// indexes them out of range even if they are inconsistent.
+func (m *Matcher) graph(opts *GraphOptions) *trieGraph {
+	if opts == nil {
+		opts = &GraphOptions{}
+	}
+	children := make(map[int][]trieEdge)
+	for t := 1; t < len(m.check); t++ {
+		if p := m.check[t]; p >= 0 && p < len(m.base) && p != t {
+			children[p] = append(children[p], trieEdge{t - m.base[p], t})
+		}
+	}
+
+	g := &trieGraph{
+		depth:  map[int]int{0: 0},
+		parent: make(map[int]int),
+		edges:  make(map[int][]trieEdge),
+		cut:    make(map[int]bool),
+	}
+	queue := []int{0}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if opts.MaxStates > 0 && len(g.states) == opts.MaxStates {
+			g.truncated = true
+			break
+		}
+		g.states = append(g.states, s)
+		for _, e := range children[s] {
+			if _, seen := g.depth[e.to]; seen {
+				continue
+			}
+			if opts.MaxDepth > 0 && g.depth[s] == opts.MaxDepth {
+				g.truncated = true
+				continue
+			}
+			g.depth[e.to] = g.depth[s] + 1
+			g.parent[e.to] = s
+			queue = append(queue, e.to)
+		}
+	}
+	// Only keep the depths of the states of the graph, since the states
+	// queued but left out by MaxStates have one too.
+	in := make(map[int]int, len(g.states))
+	for _, s := range g.states {
+		in[s] = g.depth[s]
+	}
+	g.depth = in
+	for _, s := range g.states {
+		for _, e := range children[s] {
+			if _, ok := in[e.to]; !ok {
+				g.cut[s] = true
+			} else if g.parent[e.to] == s {
+				g.edges[s] = append(g.edges[s], e)
+			}
+		}
+	}
+	return g
+}
+
+// word returns the last n bytes read from the root to reach s, or nil if the
+// path is shorter.
+func (g *trieGraph) word(m *Matcher, s, n int) []byte {
+	if n < 0 || n > g.depth[s] {
+		return nil
+	}
+	word := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		p, ok := g.parent[s]
+		if !ok {
+			return nil
+		}
+		word[i] = byte(s - m.base[p])
+		s = p
+	}
+	return word
+}
+
+func (m *Matcher) failOf(s int) int {
+	if s < len(m.fail) {
+		return m.fail[s]
+	}
+	return 0
+}
+
+func (m *Matcher) outputOf(s int) []SWord {
+	if s < len(m.output) {
+		return m.output[s]
+	}
+	return nil
+}
+
+// WriteDOT writes the trie in the Graphviz DOT language: its states, labelled
+// with the keys and words of their outputs, and its goto edges, labelled with
+// their bytes. States with outputs are drawn as double circles, and states
+// whose children are left out by the limits of opts, which may be nil, are
+// drawn dashed.
+func (m *Matcher) WriteDOT(w io.Writer, opts *GraphOptions) error {
+	g := m.graph(opts)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph trie {")
+	for _, s := range g.states {
+		label := strconv.Itoa(s)
+		for _, item := range m.outputOf(s) {
+			label += fmt.Sprintf("\n%d: %q", item.Key, g.word(m, s, int(item.Len)))
+		}
+		attrs := "shape=circle"
+		if len(m.outputOf(s)) > 0 {
+			attrs = "shape=doublecircle"
+		}
+		if g.cut[s] {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(bw, "\t%d [label=%s, %s];\n", s, strconv.Quote(label), attrs)
+	}
+	for _, s := range g.states {
+		for _, e := range g.edges[s] {
+			label := strconv.Quote(string([]byte{byte(e.label)}))
+			fmt.Fprintf(bw, "\t%d -> %d [label=%s];\n", s, e.to, strconv.Quote(label[1:len(label)-1]))
+		}
+	}
+	if opts != nil && opts.FailLinks {
+		for _, s := range g.states {
+			if f := m.failOf(s); s != 0 && f != 0 {
+				if _, ok := g.depth[f]; ok {
+					fmt.Fprintf(bw, "\t%d -> %d [style=dashed, color=gray];\n", s, f)
+				}
+			}
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+type jsonTrie struct {
+	States    []jsonState `json:"states"`
+	Truncated bool        `json:"truncated"`
+}
+
+type jsonState struct {
+	State   int          `json:"state"`
+	Depth   int          `json:"depth"`
+	Fail    int          `json:"fail"`
+	Edges   []jsonEdge   `json:"edges,omitempty"`
+	Outputs []jsonOutput `json:"outputs,omitempty"`
+}
+
+type jsonEdge struct {
+	Byte int `json:"byte"`
+	To   int `json:"to"`
+}
+
+type jsonOutput struct {
+	Key    uint64 `json:"key"`
+	Length uint64 `json:"length"`
+}
+
+// WriteJSON writes the trie as a JSON object. Its "states" are listed breadth
+// first, each with its "state" number, "depth", "fail" state, goto "edges"
+// to the states listed, as "byte" and "to" state, and "outputs", as the "key"
+// and "length" of the patterns ending there. "truncated" tells whether the
+// limits of opts, which may be nil, left states out.
+func (m *Matcher) WriteJSON(w io.Writer, opts *GraphOptions) error {
+	g := m.graph(opts)
+	trie := jsonTrie{States: make([]jsonState, 0, len(g.states)), Truncated: g.truncated}
+	for _, s := range g.states {
+		state := jsonState{State: s, Depth: g.depth[s], Fail: m.failOf(s)}
+		for _, e := range g.edges[s] {
+			state.Edges = append(state.Edges, jsonEdge{e.label, e.to})
+		}
+		for _, item := range m.outputOf(s) {
+			state.Outputs = append(state.Outputs, jsonOutput{item.Key, item.Len})
+		}
+		trie.States = append(trie.States, state)
+	}
+	return json.NewEncoder(w).Encode(trie)
+}
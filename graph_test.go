@@ -0,0 +1,134 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	m := CompileStrings([]string{"he", "she", "his", "hers"})
+
+	var full bytes.Buffer
+	if err := m.WriteDOT(&full, &GraphOptions{FailLinks: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"digraph trie {\n",
+		"\t0 -> 1 [label=\"h\"];\n",
+		"\t0 -> 12 [label=\"s\"];\n",
+		"\t7 [label=\"7\\n0: \\\"he\\\"\\n3: \\\"she\\\"\", shape=doublecircle];\n",
+		"\t7 -> 2 [style=dashed, color=gray];\n",
+	} {
+		if !strings.Contains(full.String(), line) {
+			t.Errorf("%q not in\n%s", line, full.String())
+		}
+	}
+	if strings.Count(full.String(), " -> ") != 13 {
+		t.Errorf("Expected: 9 goto edges and 4 failure links\nGot:\n%s", full.String())
+	}
+
+	var limited bytes.Buffer
+	m.WriteDOT(&limited, &GraphOptions{MaxDepth: 1})
+	expected := "digraph trie {\n" +
+		"\t0 [label=\"0\", shape=circle];\n" +
+		"\t1 [label=\"1\", shape=circle, style=dashed];\n" +
+		"\t12 [label=\"12\", shape=circle, style=dashed];\n" +
+		"\t0 -> 1 [label=\"h\"];\n" +
+		"\t0 -> 12 [label=\"s\"];\n" +
+		"}\n"
+	if limited.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, limited.String())
+	}
+
+	d, err := Deserialize(m.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deserialized bytes.Buffer
+	d.WriteDOT(&deserialized, &GraphOptions{FailLinks: true})
+	if deserialized.String() != full.String() {
+		t.Errorf("Expected:\n%s\nGot:\n%s", full.String(), deserialized.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	m := CompileStrings([]string{"he", "she", "his", "hers"})
+	var trie struct {
+		States []struct {
+			State, Depth, Fail int
+			Edges              []struct{ Byte, To int }
+			Outputs            []struct{ Key, Length int }
+		}
+		Truncated bool
+	}
+
+	var out bytes.Buffer
+	if err := m.WriteJSON(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &trie); err != nil {
+		t.Fatal(err)
+	}
+	if len(trie.States) != 10 || trie.Truncated {
+		t.Fatalf("Expected: 10 states\nGot:      %s", out.String())
+	}
+	states := make(map[int]int)
+	for i, s := range trie.States {
+		states[s.State] = i
+	}
+	// Walk "she" from the root, and check where it ends.
+	s := 0
+	for _, c := range []byte("she") {
+		next := -1
+		for _, e := range trie.States[states[s]].Edges {
+			if e.Byte == int(c) {
+				next = e.To
+			}
+		}
+		if next < 0 {
+			t.Fatalf("no edge %q from state %d", c, s)
+		}
+		s = next
+	}
+	end := trie.States[states[s]]
+	if end.Depth != 3 || len(end.Outputs) != 2 || end.Outputs[1].Length != 3 {
+		t.Errorf("Expected: state of depth 3 with outputs he and she\nGot:      %+v", end)
+	}
+	if fail := trie.States[states[end.Fail]]; fail.Depth != 2 || len(fail.Outputs) != 1 {
+		t.Errorf("Expected: fail to the state of he\nGot:      %+v", fail)
+	}
+
+	d, _ := Deserialize(m.Serialize())
+	var deserialized bytes.Buffer
+	d.WriteJSON(&deserialized, nil)
+	if deserialized.String() != out.String() {
+		t.Errorf("Expected:\n%s\nGot:\n%s", out.String(), deserialized.String())
+	}
+
+	out.Reset()
+	m.WriteJSON(&out, &GraphOptions{MaxStates: 3})
+	if err := json.Unmarshal(out.Bytes(), &trie); err != nil {
+		t.Fatal(err)
+	}
+	if len(trie.States) != 3 || !trie.Truncated {
+		t.Errorf("Expected: 3 states, truncated\nGot:      %s", out.String())
+	}
+}
+
+func TestGraphInconsistent(t *testing.T) {
+	m := &Matcher{
+		base:   []int{0, 5, leaf},
+		check:  []int{0, 0, 2},
+		fail:   []int{0, 7},
+		output: [][]SWord{nil, {{Len: 9, Key: 0}}},
+	}
+	var out bytes.Buffer
+	if err := m.WriteDOT(&out, &GraphOptions{FailLinks: true}); err != nil {
+		t.Error(err)
+	}
+	if err := m.WriteJSON(&out, nil); err != nil {
+		t.Error(err)
+	}
+}
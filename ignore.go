@@ -0,0 +1,105 @@
+package ahocorasick
+
+import "io"
+
+// IgnoringMatcher finds patterns in text where some bytes, such as spaces or
+// punctuation, may have been inserted anywhere: with " " and "-" ignorable,
+// "password" also matches "p a s s w o r d" and "pass-word". Ignorable bytes
+// are removed from the patterns and skipped in the text without moving the
+// automaton, and matches span the original bytes, including the ones skipped
+// inside them.
+type IgnoringMatcher struct {
+	matcher *Matcher
+	ignore  ByteClass
+}
+
+// CompileIgnoring compiles an IgnoringMatcher from a slice of byte slices.
+// The key of a match reported by FindAllByteReader is the index of its word.
+// Words made of ignorable bytes only are never found.
+func CompileIgnoring(words [][]byte, ignore ByteClass) *IgnoringMatcher {
+	im := &IgnoringMatcher{ignore: ignore}
+	var stripped [][]byte
+	var keys []uint64
+	for i, word := range words {
+		var w []byte
+		for _, b := range word {
+			if !ignore.Has(b) {
+				w = append(w, b)
+			}
+		}
+		if len(w) == 0 {
+			continue
+		}
+		stripped = append(stripped, w)
+		keys = append(keys, uint64(i))
+	}
+	im.matcher = compileKeyed(stripped, keys)
+	return im
+}
+
+// CompileStringsIgnoring compiles an IgnoringMatcher from a slice of strings.
+func CompileStringsIgnoring(words []string, ignore ByteClass) *IgnoringMatcher {
+	wordByteSlices := make([][]byte, len(words))
+	for i, word := range words {
+		wordByteSlices[i] = []byte(word)
+	}
+	return CompileIgnoring(wordByteSlices, ignore)
+}
+
+// Ignorable returns the class of the bytes skipped in the text.
+func (im *IgnoringMatcher) Ignorable() ByteClass {
+	return im.ignore
+}
+
+// FindAllByteSlice finds all instances of the patterns in the text. A match
+// starts and ends with bytes which are not ignorable.
+func (im *IgnoringMatcher) FindAllByteSlice(text []byte) []*Match {
+	var matches []*Match
+	// Positions of the last bytes which moved the automaton, enough to find
+	// where the longest pattern starts.
+	starts := make([]int, im.matcher.maxLen+1)
+	n := 0
+	state := 0
+	for i, b := range text {
+		if im.ignore.Has(b) {
+			continue
+		}
+		starts[n%len(starts)] = i
+		n++
+		state = im.matcher.advance(state, b)
+		for _, item := range im.matcher.output[state] {
+			start := starts[(n-int(item.Len))%len(starts)]
+			matches = append(matches, &Match{text[start : i+1], start})
+		}
+	}
+	return matches
+}
+
+// FindAllString finds all instances of the patterns in the text.
+func (im *IgnoringMatcher) FindAllString(text string) []*Match {
+	return im.FindAllByteSlice([]byte(text))
+}
+
+// FindAllByteReader finds all instances of the patterns in the stream and
+// appends the end position and the key of each of them to matches.
+func (im *IgnoringMatcher) FindAllByteReader(reader io.Reader, matches Matches) {
+	b := make([]byte, 4096)
+	state := 0
+	pos := 0
+	for {
+		n, err := reader.Read(b)
+		for _, c := range b[:n] {
+			pos++
+			if im.ignore.Has(c) {
+				continue
+			}
+			state = im.matcher.advance(state, c)
+			for _, item := range im.matcher.output[state] {
+				matches.Append(pos, int(item.Key))
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFindAllIgnoring(t *testing.T) {
+	ignore, err := ParseByteClass("[ ._-]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	im := CompileStringsIgnoring([]string{"password", "se cret", "--"}, ignore)
+	tests := []struct {
+		text     string
+		expected []Match
+	}{
+		{"my p a s s w o r d!", []Match{{[]byte("p a s s w o r d"), 3}}},
+		{"pass-word s.e.c-r-e-t", []Match{{[]byte("pass-word"), 0}, {[]byte("s.e.c-r-e-t"), 10}}},
+		{" secret ", []Match{{[]byte("secret"), 1}}},
+		{"pass/word", nil},
+		{"-- . --", nil},
+	}
+	for _, test := range tests {
+		got := convert(im.FindAllString(test.text))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q\nExpected: %q\nGot:      %q", test.text, test.expected, got)
+		}
+
+		var ends []MatchKey
+		for _, m := range got {
+			ends = append(ends, MatchKey{m.Index + len(m.Word), 0})
+		}
+		matches := &MatchesKeys{}
+		im.FindAllByteReader(iotest.OneByteReader(bytes.NewReader([]byte(test.text))), matches)
+		for i := range matches.matches {
+			matches.matches[i].Key = 0
+		}
+		if !reflect.DeepEqual(matches.matches, ends) {
+			t.Errorf("%q reader\nExpected: %v\nGot:      %v", test.text, ends, matches.matches)
+		}
+	}
+}
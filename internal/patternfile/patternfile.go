@@ -0,0 +1,91 @@
+// Package patternfile parses the pattern files of the commands, which hold
+// one pattern per line. In a pattern, \\, \t, \n, \r and \xHH stand for a
+// backslash, a tab, a newline, a carriage return and the byte of hexadecimal
+// value HH. Empty lines are ignored.
+package patternfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Parse parses the lines of a pattern file.
+func Parse(data []byte) ([][]byte, error) {
+	var patterns [][]byte
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			continue
+		}
+		pattern, err := unescape(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	if len(patterns) == 0 {
+		return nil, errors.New("no patterns")
+	}
+	return patterns, nil
+}
+
+func unescape(line []byte) ([]byte, error) {
+	var pattern []byte
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' {
+			pattern = append(pattern, line[i])
+			continue
+		}
+		i++
+		if i == len(line) {
+			return nil, errors.New("trailing backslash")
+		}
+		switch line[i] {
+		case '\\':
+			pattern = append(pattern, '\\')
+		case 't':
+			pattern = append(pattern, '\t')
+		case 'n':
+			pattern = append(pattern, '\n')
+		case 'r':
+			pattern = append(pattern, '\r')
+		case 'x':
+			if i+2 >= len(line) {
+				return nil, errors.New("incomplete \\x escape")
+			}
+			v, err := strconv.ParseUint(string(line[i+1:i+3]), 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid \\x escape %q", line[i-1:i+3])
+			}
+			pattern = append(pattern, byte(v))
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown escape \\%c", line[i])
+		}
+	}
+	return pattern, nil
+}
+
+// Escape returns pattern written the way Parse reads it.
+func Escape(pattern []byte) string {
+	var b bytes.Buffer
+	for _, c := range pattern {
+		switch {
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package patternfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse([]byte("foo\r\n\na\\tb\\\\\n\\x41\\n\n"))
+	expected := [][]byte{[]byte("foo"), []byte("a\tb\\"), []byte("A\n")}
+	if err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %q\nGot:      %q, %v", expected, got, err)
+	}
+	for _, bad := range []string{"a\\", "\\x4", "\\xZZ", "\\q", "\n\n"} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	pattern := []byte("a\tb\\c\n\x00\xff")
+	escaped := Escape(pattern)
+	if escaped != `a\tb\\c\n\x00\xff` {
+		t.Errorf("Expected: %s\nGot:      %s", `a\tb\\c\n\x00\xff`, escaped)
+	}
+	got, err := Parse([]byte(escaped))
+	if err != nil || !reflect.DeepEqual(got, [][]byte{pattern}) {
+		t.Errorf("Expected: %q\nGot:      %q, %v", [][]byte{pattern}, got, err)
+	}
+}
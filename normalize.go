@@ -0,0 +1,193 @@
+package ahocorasick
+
+import (
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization selects how patterns and text are folded to a canonical form
+// before they are matched.
+type Normalization struct {
+	Form       norm.Form // such as norm.NFC, or norm.NFKC to also fold compatibility characters
+	StripMarks bool      // remove nonspacing marks, so that "résumé" matches "resume"
+}
+
+// NormalizedMatcher finds patterns in text after normalizing both, while
+// reporting matches at their place in the original text. The text is cut
+// into normalization segments, which are runs of runes starting with a
+// starter, and each segment is normalized on its own: a match starting or
+// ending inside the normalized form of a segment spans the whole original
+// segment.
+type NormalizedMatcher struct {
+	matcher *Matcher
+	norm    Normalization
+}
+
+// maxNormBuffer bounds the bytes the streaming path buffers while waiting for
+// the end of a segment.
+const maxNormBuffer = 4096
+
+// CompileNormalized compiles a NormalizedMatcher from a slice of strings. The
+// key of a match reported by FindAllByteReader is the index of its word.
+func CompileNormalized(words []string, n Normalization) *NormalizedMatcher {
+	nm := &NormalizedMatcher{norm: n}
+	normalized := make([][]byte, 0, len(words))
+	keys := make([]uint64, 0, len(words))
+	for i, word := range words {
+		var it norm.Iter
+		it.InitString(nm.iterForm(), word)
+		var w []byte
+		for !it.Done() {
+			w = nm.normalize(w, it.Next())
+		}
+		if len(w) == 0 {
+			continue
+		}
+		normalized = append(normalized, w)
+		keys = append(keys, uint64(i))
+	}
+	nm.matcher = compileKeyed(normalized, keys)
+	return nm
+}
+
+// iterForm returns the form segments are iterated in: a decomposed form when
+// marks are stripped, so that they can be removed from their base runes.
+func (nm *NormalizedMatcher) iterForm() norm.Form {
+	if !nm.norm.StripMarks {
+		return nm.norm.Form
+	}
+	if nm.norm.Form == norm.NFKC || nm.norm.Form == norm.NFKD {
+		return norm.NFKD
+	}
+	return norm.NFD
+}
+
+// normalize appends the segment seg, as returned by an iterator in iterForm,
+// to dst in the final form.
+func (nm *NormalizedMatcher) normalize(dst, seg []byte) []byte {
+	if !nm.norm.StripMarks {
+		return append(dst, seg...)
+	}
+	var stripped [64]byte
+	kept := stripped[:0]
+	for i := 0; i < len(seg); {
+		r, size := utf8.DecodeRune(seg[i:])
+		if !unicode.Is(unicode.Mn, r) {
+			kept = append(kept, seg[i:i+size]...)
+		}
+		i += size
+	}
+	return nm.norm.Form.Append(dst, kept...)
+}
+
+// normScanner runs the automaton over normalized segments, remembering for
+// the last bytes of normalized text the original segment they come from.
+type normScanner struct {
+	nm     *NormalizedMatcher
+	state  int
+	n      int // normalized bytes scanned
+	ring   []segmentSpan
+	buffer []byte
+}
+
+// segmentSpan is the span of a segment in the original text.
+type segmentSpan struct {
+	start, end int
+}
+
+func (nm *NormalizedMatcher) newScanner() *normScanner {
+	return &normScanner{nm: nm, ring: make([]segmentSpan, nm.matcher.maxLen+1)}
+}
+
+// scan runs the automaton over the text, whose first byte is at position
+// offset of the original text, and calls found with the original span and the
+// key of every match.
+func (s *normScanner) scan(text []byte, offset int, found func(start, end int, key uint64)) {
+	var it norm.Iter
+	it.Init(s.nm.iterForm(), text)
+	for !it.Done() {
+		segStart := offset + it.Pos()
+		seg := it.Next()
+		span := segmentSpan{segStart, offset + it.Pos()}
+		s.buffer = s.nm.normalize(s.buffer[:0], seg)
+		for _, b := range s.buffer {
+			s.ring[s.n%len(s.ring)] = span
+			s.n++
+			s.state = s.nm.matcher.advance(s.state, b)
+			for _, item := range s.nm.matcher.output[s.state] {
+				first := s.ring[(s.n-int(item.Len))%len(s.ring)]
+				found(first.start, span.end, item.Key)
+			}
+		}
+	}
+}
+
+// FindAllByteSlice finds all instances of the patterns in the normalized
+// text. The Word of a match is the original text it was found in.
+func (nm *NormalizedMatcher) FindAllByteSlice(text []byte) []*Match {
+	var matches []*Match
+	nm.newScanner().scan(text, 0, func(start, end int, key uint64) {
+		matches = append(matches, &Match{text[start:end], start})
+	})
+	return matches
+}
+
+// FindAllString finds all instances of the patterns in the normalized text.
+func (nm *NormalizedMatcher) FindAllString(text string) []*Match {
+	return nm.FindAllByteSlice([]byte(text))
+}
+
+// FindAllByteReader finds all instances of the patterns in the normalized
+// stream and appends the end position in the original stream and the key of
+// each of them to matches. The stream is normalized one segment at a time, so
+// only the current segment is buffered.
+func (nm *NormalizedMatcher) FindAllByteReader(reader io.Reader, matches Matches) {
+	s := nm.newScanner()
+	found := func(start, end int, key uint64) {
+		matches.Append(end, int(key))
+	}
+	form := nm.iterForm()
+	var pending []byte // the text from position offset on, not scanned yet
+	offset := 0
+	chunk := make([]byte, 4096)
+	for {
+		n, err := reader.Read(chunk)
+		pending = append(pending, chunk[:n]...)
+		if err != nil {
+			s.scan(pending, offset, found)
+			return
+		}
+		// A segment is only complete once the next one has started, and
+		// a rune once all of its bytes were read.
+		complete := fullRunes(pending)
+		cut := form.LastBoundary(pending[:complete])
+		if cut <= 0 && len(pending) > maxNormBuffer {
+			cut = len(pending) - utf8.UTFMax
+			for cut > 0 && !utf8.RuneStart(pending[cut]) {
+				cut--
+			}
+		}
+		if cut > 0 {
+			s.scan(pending[:cut], offset, found)
+			offset += cut
+			pending = append(pending[:0], pending[cut:]...)
+		}
+	}
+}
+
+// fullRunes returns the length of the longest prefix of b which does not end
+// with an incomplete rune.
+func fullRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,104 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestFindAllNormalized(t *testing.T) {
+	tests := []struct {
+		words    []string
+		n        Normalization
+		text     string
+		expected []Match
+	}{
+		{
+			[]string{"café"}, Normalization{Form: norm.NFC},
+			"café café cafe",
+			[]Match{{[]byte("café"), 0}, {[]byte("café"), 7}},
+		},
+		{
+			// Patterns are normalized too.
+			[]string{"café"}, Normalization{Form: norm.NFC},
+			"café",
+			[]Match{{[]byte("café"), 0}},
+		},
+		{
+			[]string{"resume"}, Normalization{Form: norm.NFC, StripMarks: true},
+			"my résumé, my resume",
+			[]Match{{[]byte("résumé"), 3}, {[]byte("resume"), 16}},
+		},
+		{
+			[]string{"résumé"}, Normalization{Form: norm.NFC, StripMarks: true},
+			"resume",
+			[]Match{{[]byte("resume"), 0}},
+		},
+		{
+			// A match inside the normalized form of a segment spans all of it.
+			[]string{"fi", "i"}, Normalization{Form: norm.NFKC},
+			"ﬁle",
+			[]Match{{[]byte("ﬁ"), 0}, {[]byte("ﬁ"), 0}},
+		},
+		{
+			[]string{"fi"}, Normalization{Form: norm.NFC},
+			"ﬁle",
+			nil,
+		},
+	}
+	for _, test := range tests {
+		nm := CompileNormalized(test.words, test.n)
+		got := convert(nm.FindAllString(test.text))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q in %q\nExpected: %q\nGot:      %q", test.words, test.text, test.expected, got)
+		}
+	}
+}
+
+func TestFindAllNormalizedReader(t *testing.T) {
+	words := []string{"café", "resume", "e", "ü"}
+	nm := CompileNormalized(words, Normalization{Form: norm.NFKC, StripMarks: true})
+
+	r := rand.New(rand.NewSource(1))
+	pieces := []string{"caf", "e", "\u0301", "é", "r", "su", "m", "u\u0308", "ü", " ", "ﬁ"}
+	var b strings.Builder
+	for i := 0; i < 10000; i++ {
+		b.WriteString(pieces[r.Intn(len(pieces))])
+	}
+	text := []byte(b.String())
+
+	var expected []MatchKey
+	nm.newScanner().scan(text, 0, func(start, end int, key uint64) {
+		expected = append(expected, MatchKey{end, int(key)})
+	})
+	if len(expected) < 1000 {
+		t.Fatalf("Got only %d matches", len(expected))
+	}
+	got := &MatchesKeys{}
+	nm.FindAllByteReader(bytes.NewReader(text), got)
+	if !reflect.DeepEqual(got.matches, expected) {
+		t.Errorf("Got %d matches, expected %d", len(got.matches), len(expected))
+	}
+	got = &MatchesKeys{}
+	nm.FindAllByteReader(iotest.OneByteReader(bytes.NewReader(text)), got)
+	if !reflect.DeepEqual(got.matches, expected) {
+		t.Errorf("One byte at a time: got %d matches, expected %d", len(got.matches), len(expected))
+	}
+}
+
+func TestFindAllNormalizedReaderLongSegment(t *testing.T) {
+	nm := CompileNormalized([]string{"café"}, Normalization{Form: norm.NFC, StripMarks: true})
+	// A run of marks longer than the buffer has no segment boundary.
+	text := "e" + strings.Repeat("\u0301", 3000) + " café"
+	got := &MatchesKeys{}
+	nm.FindAllByteReader(iotest.OneByteReader(strings.NewReader(text)), got)
+	expected := []MatchKey{{len(text), 0}}
+	if !reflect.DeepEqual(got.matches, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got.matches)
+	}
+}
@@ -0,0 +1,113 @@
+package ahocorasick
+
+import "unicode/utf8"
+
+// Offsets is a position in a text counted in bytes, in runes and in UTF-16
+// code units, as used by JavaScript and Java strings. Runes are decoded like
+// a range loop over a string does: every byte of invalid UTF-8 counts as one
+// rune and one UTF-16 code unit, like the U+FFFD it decodes to.
+type Offsets struct {
+	Byte  int
+	Rune  int
+	UTF16 int
+}
+
+// OffsetMatch represents a matched pattern in the text along with its bounds
+// in bytes, runes and UTF-16 code units.
+type OffsetMatch struct {
+	Word  []byte  // the matched pattern
+	Start Offsets // the start of the match
+	End   Offsets // the end of the match, exclusive
+}
+
+// FindAllOffsets finds all instances of the patterns in the text like
+// FindAllByteSlice, and counts their bounds in runes and UTF-16 code units
+// during the same scan. A match starting or ending inside a multi-byte rune
+// is widened to the whole rune in these units, so that it can always be
+// highlighted.
+func (m *Matcher) FindAllOffsets(text []byte) []*OffsetMatch {
+	var matches []*OffsetMatch
+	if m.anchored {
+		// Matches all start at 0 and end within the longest pattern, so
+		// counting them does not need to walk the whole text.
+		found := m.findAnchored(text, 0)
+		t := newOffsetTracker(m.maxLen)
+		for i := 0; len(found) > 0 && i < len(text); i++ {
+			t.next(text, i)
+			for len(found) > 0 && len(found[0].Word) == i+1 {
+				matches = append(matches, t.match(found[0].Word, 0, i+1))
+				found = found[1:]
+			}
+		}
+		return matches
+	}
+
+	filtered := m.filtered()
+	t := newOffsetTracker(m.maxLen)
+	state := 0
+	for i, b := range text {
+		t.next(text, i)
+		state = m.advance(state, b)
+		for _, item := range m.output[state] {
+			start := i - int(item.Len) + 1
+			if filtered && !m.acceptAt(item.Key, text, start, i+1) {
+				continue
+			}
+			matches = append(matches, t.match(text[start:i+1], start, i+1))
+		}
+	}
+	return matches
+}
+
+// FindAllStringOffsets finds all instances of the patterns in the text along
+// with their bounds in bytes, runes and UTF-16 code units.
+func (m *Matcher) FindAllStringOffsets(text string) []*OffsetMatch {
+	return m.FindAllOffsets([]byte(text))
+}
+
+// offsetTracker decodes the text as it is scanned, and remembers for the
+// last maxLen bytes the offsets of the rune each of them belongs to.
+type offsetTracker struct {
+	ring     []runeOffsets
+	runeEnd  int         // byte position where the next rune starts
+	next16   int         // UTF-16 offset of the next rune
+	nextRune int         // rune offset of the next rune
+	cur      runeOffsets // the rune being scanned
+}
+
+type runeOffsets struct {
+	rune, utf16, utf16Len int
+}
+
+func newOffsetTracker(maxLen int) *offsetTracker {
+	return &offsetTracker{ring: make([]runeOffsets, maxLen+1)}
+}
+
+// next records the byte at position i of text. It must be called for every
+// byte in order.
+func (t *offsetTracker) next(text []byte, i int) {
+	if i == t.runeEnd {
+		r, size := utf8.DecodeRune(text[i:])
+		n := 1
+		if r > 0xFFFF {
+			n = 2 // a surrogate pair
+		}
+		t.cur = runeOffsets{t.nextRune, t.next16, n}
+		t.runeEnd += size
+		t.nextRune++
+		t.next16 += n
+	}
+	t.ring[i%len(t.ring)] = t.cur
+}
+
+// match returns the match of word between the byte positions start and end,
+// which must be one of the last len(ring) bytes recorded.
+func (t *offsetTracker) match(word []byte, start, end int) *OffsetMatch {
+	first := t.ring[start%len(t.ring)]
+	last := t.ring[(end-1)%len(t.ring)]
+	return &OffsetMatch{
+		Word:  word,
+		Start: Offsets{start, first.rune, first.utf16},
+		End:   Offsets{end, last.rune + 1, last.utf16 + last.utf16Len},
+	}
+}
@@ -0,0 +1,129 @@
+package ahocorasick
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+type offsetResult struct {
+	word       string
+	start, end Offsets
+}
+
+func convertOffsets(got []*OffsetMatch) []offsetResult {
+	var converted []offsetResult
+	for _, m := range got {
+		converted = append(converted, offsetResult{string(m.Word), m.Start, m.End})
+	}
+	return converted
+}
+
+func TestFindAllOffsets(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		text     string
+		expected []offsetResult
+	}{
+		{
+			[]string{"é", "😀", "world"},
+			"héllo 😀 world",
+			[]offsetResult{
+				{"é", Offsets{1, 1, 1}, Offsets{3, 2, 2}},
+				{"😀", Offsets{7, 6, 6}, Offsets{11, 7, 8}},
+				{"world", Offsets{12, 8, 9}, Offsets{17, 13, 14}},
+			},
+		},
+		{
+			// Every byte of invalid UTF-8 counts as one rune.
+			[]string{"b", "c"},
+			"a\xffb\xe2\x82c",
+			[]offsetResult{
+				{"b", Offsets{2, 2, 2}, Offsets{3, 3, 3}},
+				{"c", Offsets{5, 5, 5}, Offsets{6, 6, 6}},
+			},
+		},
+		{
+			// Matches inside a rune are widened to the whole rune.
+			[]string{"\xa9", "\x80"},
+			"é😀",
+			[]offsetResult{
+				{"\xa9", Offsets{1, 0, 0}, Offsets{2, 1, 1}},
+				{"\x80", Offsets{5, 1, 1}, Offsets{6, 2, 3}},
+			},
+		},
+	}
+	for _, test := range tests {
+		m := CompileStrings(test.patterns)
+		got := convertOffsets(m.FindAllStringOffsets(test.text))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q\nExpected: %v\nGot:      %v", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestFindAllOffsetsAnchored(t *testing.T) {
+	m := CompileStringsAnchored([]string{"日", "日本", "本"})
+	expected := []offsetResult{
+		{"日", Offsets{0, 0, 0}, Offsets{3, 1, 1}},
+		{"日本", Offsets{0, 0, 0}, Offsets{6, 2, 2}},
+	}
+	got := convertOffsets(m.FindAllStringOffsets("日本語"))
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+}
+
+// naiveOffsets counts the runes and UTF-16 code units ending before the byte
+// position p, or starting before it if end is set.
+func naiveOffsets(text []byte, p int, end bool) Offsets {
+	o := Offsets{Byte: p}
+	for i := 0; i < p; {
+		r, size := utf8.DecodeRune(text[i:])
+		if !end && i+size > p {
+			break
+		}
+		o.Rune++
+		o.UTF16++
+		if r > 0xFFFF {
+			o.UTF16++
+		}
+		i += size
+	}
+	return o
+}
+
+func TestFindAllOffsetsAgainstNaive(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	alphabet := [][]byte{[]byte("a"), []byte("b"), []byte("é"), []byte("😀"), {0xff}, {0xe2, 0x82}}
+	random := func(n int) []byte {
+		var b []byte
+		for i := 0; i < n; i++ {
+			b = append(b, alphabet[r.Intn(len(alphabet))]...)
+		}
+		return b
+	}
+	var words [][]byte
+	for i := 0; i < 20; i++ {
+		word := random(1 + r.Intn(3))
+		words = append(words, word[r.Intn(len(word)):])
+	}
+	m := CompileByteSlices(words)
+	for i := 0; i < 200; i++ {
+		text := random(r.Intn(40))
+		var expected []offsetResult
+		for _, match := range m.FindAllByteSlice(text) {
+			end := match.Index + len(match.Word)
+			expected = append(expected, offsetResult{
+				string(match.Word),
+				naiveOffsets(text, match.Index, false),
+				naiveOffsets(text, end, true),
+			})
+		}
+		got := convertOffsets(m.FindAllOffsets(text))
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%q\nExpected: %v\nGot:      %v", text, expected, got)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package ahocorasick
+
+// Pattern is a pattern along with the options restricting its matches. A
+// Boundary other than NoBoundary overrides the one set by SetBoundary.
+type Pattern struct {
+	Word     []byte
+	Anchor   Anchor
+	Boundary Boundary
+}
+
+// CompilePatterns compiles a Matcher from a slice of patterns. Unlike
+// CompileByteSlices the slice is left untouched, and the key reported for a
+// match by FindAllByteReader is the index of its pattern in patterns.
+func CompilePatterns(patterns []Pattern) *Matcher {
+	words := make([][]byte, len(patterns))
+	keys := make([]uint64, len(patterns))
+	for i, p := range patterns {
+		words[i] = p.Word
+		keys[i] = uint64(i)
+	}
+
+	m := compileKeyed(words, keys)
+	m.setOptions(patterns)
+	return m
+}
+
+// setOptions records the anchors and boundaries of patterns by key. Tables
+// are only allocated when some pattern uses them.
+func (m *Matcher) setOptions(patterns []Pattern) {
+	anchored, bounded := false, false
+	for _, p := range patterns {
+		anchored = anchored || p.Anchor != 0
+		bounded = bounded || p.Boundary != NoBoundary
+	}
+
+	if anchored {
+		m.anchors = make([]Anchor, len(patterns))
+		for i, p := range patterns {
+			m.anchors[i] = p.Anchor & anchorMask
+		}
+	}
+	if bounded {
+		m.boundaries = make([]Boundary, len(patterns))
+		for i, p := range patterns {
+			if p.Boundary < boundaryCount {
+				m.boundaries[i] = p.Boundary
+			}
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package ahocorasick
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxRegexLiterals bounds the number of alternative literals kept for a
+// single subexpression while extracting the literals of a regexp.
+const maxRegexLiterals = 64
+
+// RegexSet finds which of many regexps match a text. Literals which every
+// match of a regexp has to contain are extracted from its syntax tree and
+// compiled into a single Matcher, so that the full regexp only runs on texts
+// containing one of them. Regexps without such literals, like `\d+`, always
+// run.
+type RegexSet struct {
+	regexps []*regexp.Regexp
+	matcher *Matcher // literals keyed by the index of their regexp
+	always  []int    // regexps without literals
+}
+
+// CompileRegexSet compiles a RegexSet from expressions in the syntax of
+// package regexp. It returns the error of the first expression which does not
+// compile.
+func CompileRegexSet(exprs []string) (*RegexSet, error) {
+	s := &RegexSet{regexps: make([]*regexp.Regexp, len(exprs))}
+	var words [][]byte
+	var keys []uint64
+	for i, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		s.regexps[i] = re
+		tree, err := syntax.Parse(expr, syntax.Perl)
+		if err != nil {
+			return nil, err
+		}
+		lits, ok := requiredLiterals(tree.Simplify())
+		if !ok {
+			s.always = append(s.always, i)
+			continue
+		}
+		for _, lit := range lits {
+			words = append(words, []byte(lit))
+			keys = append(keys, uint64(i))
+		}
+	}
+	s.matcher = compileKeyed(words, keys)
+	return s, nil
+}
+
+// Match returns the indexes of the regexps matching text, in increasing
+// order.
+func (s *RegexSet) Match(text []byte) []int {
+	candidate := make([]bool, len(s.regexps))
+	for _, i := range s.always {
+		candidate[i] = true
+	}
+	state := 0
+	for _, b := range text {
+		state = s.matcher.advance(state, b)
+		for _, item := range s.matcher.output[state] {
+			candidate[item.Key] = true
+		}
+	}
+
+	var matched []int
+	for i, ok := range candidate {
+		if ok && s.regexps[i].Match(text) {
+			matched = append(matched, i)
+		}
+	}
+	return matched
+}
+
+// MatchString returns the indexes of the regexps matching text, in increasing
+// order.
+func (s *RegexSet) MatchString(text string) []int {
+	return s.Match([]byte(text))
+}
+
+// Len returns the number of regexps in the set.
+func (s *RegexSet) Len() int {
+	return len(s.regexps)
+}
+
+// Always returns the indexes of the regexps which run on every text because
+// no literal could be extracted from them.
+func (s *RegexSet) Always() []int {
+	return append([]int{}, s.always...)
+}
+
+// literals describes the strings matched by a subexpression. When exact is
+// set, every match is one of lits. Otherwise every match contains one of lits,
+// and nil lits means nothing is known.
+type literals struct {
+	lits  []string
+	exact bool
+}
+
+var (
+	unknownLiterals = literals{}
+	emptyLiterals   = literals{[]string{""}, true}
+)
+
+// requiredLiterals returns literals of which every match of re contains at
+// least one. It fails when some match may contain none of them.
+func requiredLiterals(re *syntax.Regexp) ([]string, bool) {
+	l := analyzeLiterals(re)
+	if len(l.lits) == 0 {
+		return nil, false
+	}
+	for _, lit := range l.lits {
+		if lit == "" {
+			return nil, false
+		}
+	}
+	return l.lits, true
+}
+
+// analyzeLiterals returns the literals of re. Since regexp reads every byte of
+// invalid UTF-8 as utf8.RuneError, a match of that rune does not contain its
+// encoding, and nothing is known of the runes or classes holding it.
+func analyzeLiterals(re *syntax.Regexp) literals {
+	switch re.Op {
+	case syntax.OpLiteral:
+		fold := re.Flags&syntax.FoldCase != 0
+		var parts []literals
+		start := 0
+		for i, r := range re.Rune {
+			if r == utf8.RuneError {
+				if i > start {
+					parts = append(parts, literalLiterals(re.Rune[start:i], fold))
+				}
+				parts = append(parts, unknownLiterals)
+				start = i + 1
+			}
+		}
+		if parts == nil {
+			return literalLiterals(re.Rune, fold)
+		}
+		if start < len(re.Rune) {
+			parts = append(parts, literalLiterals(re.Rune[start:], fold))
+		}
+		return concatLiterals(parts)
+	case syntax.OpCharClass:
+		var lits []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= utf8.RuneError && utf8.RuneError <= re.Rune[i+1] {
+				return unknownLiterals
+			}
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if len(lits) == maxRegexLiterals {
+					return unknownLiterals
+				}
+				lits = append(lits, string(r))
+			}
+		}
+		return literals{lits, true}
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText,
+		syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return emptyLiterals
+	case syntax.OpCapture:
+		return analyzeLiterals(re.Sub[0])
+	case syntax.OpPlus:
+		l := analyzeLiterals(re.Sub[0])
+		l.exact = false
+		return l
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return unknownLiterals
+		}
+		l := analyzeLiterals(re.Sub[0])
+		l.exact = false
+		return l
+	case syntax.OpConcat:
+		parts := make([]literals, len(re.Sub))
+		for i, sub := range re.Sub {
+			parts[i] = analyzeLiterals(sub)
+		}
+		return concatLiterals(parts)
+	case syntax.OpAlternate:
+		return alternateLiterals(re.Sub)
+	}
+	// OpStar, OpQuest, OpAnyChar, OpAnyCharNotNL and OpNoMatch.
+	return unknownLiterals
+}
+
+// literalLiterals returns the exact literals of a run of runes. With fold
+// set, every rune stands for all of its case variants, and the run is joined
+// like a concatenation so that long runs keep their most selective part.
+func literalLiterals(runes []rune, fold bool) literals {
+	if !fold {
+		return literals{[]string{string(runes)}, true}
+	}
+	parts := make([]literals, len(runes))
+	for i, r := range runes {
+		variants := []string{string(r)}
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			variants = append(variants, string(f))
+		}
+		parts[i] = literals{variants, true}
+	}
+	return concatLiterals(parts)
+}
+
+// concatLiterals joins the exact literals of adjacent subexpressions for as
+// long as their product stays small. Once it cannot, the best of the
+// candidate literal sets is kept as required.
+func concatLiterals(parts []literals) literals {
+	cur := emptyLiterals
+	var best []string
+	broken := false
+	for _, l := range parts {
+		if l.exact && len(cur.lits)*len(l.lits) <= maxRegexLiterals {
+			cur.lits = crossLiterals(cur.lits, l.lits)
+			continue
+		}
+		broken = true
+		best = betterLiterals(best, cur.lits)
+		if !l.exact {
+			best = betterLiterals(best, l.lits)
+			cur = emptyLiterals
+		} else {
+			cur = l
+		}
+	}
+	if !broken {
+		return cur
+	}
+	return literals{betterLiterals(best, cur.lits), false}
+}
+
+// alternateLiterals unions the literals of alternatives. Any alternative with
+// nothing known makes the whole alternation unknown.
+func alternateLiterals(subs []*syntax.Regexp) literals {
+	union := literals{exact: true}
+	for _, sub := range subs {
+		l := analyzeLiterals(sub)
+		if len(l.lits) == 0 {
+			return unknownLiterals
+		}
+		union.lits = append(union.lits, l.lits...)
+		union.exact = union.exact && l.exact
+	}
+	if len(union.lits) > maxRegexLiterals {
+		return unknownLiterals
+	}
+	sort.Strings(union.lits)
+	lits := union.lits[:0]
+	for i, lit := range union.lits {
+		if i == 0 || lit != union.lits[i-1] {
+			lits = append(lits, lit)
+		}
+	}
+	union.lits = lits
+	return union
+}
+
+func crossLiterals(a, b []string) []string {
+	lits := make([]string, 0, len(a)*len(b))
+	for _, x := range a {
+		for _, y := range b {
+			lits = append(lits, x+y)
+		}
+	}
+	return lits
+}
+
+// betterLiterals returns whichever of a and b is the more selective required
+// set: the one whose shortest literal is longer, then the smaller one.
+func betterLiterals(a, b []string) []string {
+	score := func(lits []string) int {
+		if len(lits) == 0 {
+			return 0
+		}
+		shortest := len(lits[0])
+		for _, lit := range lits[1:] {
+			if len(lit) < shortest {
+				shortest = len(lit)
+			}
+		}
+		return shortest
+	}
+	sa, sb := score(a), score(b)
+	if sb > sa || sb == sa && sb > 0 && len(b) < len(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,158 @@
+package ahocorasick
+
+import (
+	"math/rand"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"testing"
+)
+
+var regexSetRules = []string{
+	`hello`,
+	`(?i)hello world`,
+	`foo(bar|baz)+qux`,
+	`\d+`,
+	`a.*b`,
+	`colou?r`,
+	`(cat|dog)s?\b`,
+	`^start`,
+	`end$`,
+	`x{2,}y`,
+	`[0-9a-f]{4}-dead`,
+	`(?:ab|cd)(?:ef|gh)(?:ij|kl)`,
+	`(a|)bc`,
+	`(?s)b.c`,
+	`[^a]`,
+	`foo|bar|\w+z`,
+	`(?i)ÉTÉ`,
+	`q[uv][wx]`,
+	`\x{FFFD}`,
+	`a\x{FFFD}b`,
+	`[x\x{FFFD}]y`,
+}
+
+func bruteForceMatch(rules []string, text string) []int {
+	var matched []int
+	for i, rule := range rules {
+		if regexp.MustCompile(rule).MatchString(text) {
+			matched = append(matched, i)
+		}
+	}
+	return matched
+}
+
+func TestRegexSetAgainstBruteForce(t *testing.T) {
+	s, err := CompileRegexSet(regexSetRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{
+		"",
+		"hello",
+		"HeLLo WoRLD",
+		"foobarbazqux",
+		"start and end",
+		"my colour, my color",
+		"dogs cats",
+		"xxy 12ab-dead",
+		"abghij cdefkl",
+		"bc",
+		"aaa",
+		"été",
+		"quw qvx",
+		// Invalid UTF-8, which regexp reads as U+FFFD.
+		"a\xffb",
+		"\xffy",
+		"\xef\xbf",
+		"a\uFFFDb",
+	}
+	alphabet := []byte("abcdefghijklmnopqrstuvwxyz0123456789 -ÉTé\xff\xef\xbf\xbd")
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		text := make([]byte, r.Intn(30))
+		for j := range text {
+			text[j] = alphabet[r.Intn(len(alphabet))]
+		}
+		texts = append(texts, string(text))
+	}
+
+	for _, text := range texts {
+		got := s.MatchString(text)
+		expected := bruteForceMatch(regexSetRules, text)
+		if !(len(got) == 0 && len(expected) == 0) && !reflect.DeepEqual(got, expected) {
+			t.Errorf("%q\nExpected: %v\nGot:      %v", text, expected, got)
+		}
+	}
+}
+
+func TestRegexSetAlways(t *testing.T) {
+	s, err := CompileRegexSet(regexSetRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only [^a] and \x{FFFD}, which matches invalid UTF-8, have no literal:
+	// \d+ requires a digit, a.*b an "a", (a|)bc a "bc" and \w+z a "z".
+	expected := []int{14, 18}
+	if got := s.Always(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+	if s.Len() != len(regexSetRules) {
+		t.Errorf("Got %d rules", s.Len())
+	}
+}
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{`hello`, []string{"hello"}},
+		{`(?i)ab`, []string{"AB", "Ab", "aB", "ab"}},
+		{`foo(bar|baz)+qux`, []string{"foo"}},
+		{`(bar|baz)+`, []string{"bar", "baz"}},
+		{`colou?r`, []string{"colo"}},
+		{`[ab]c`, []string{"ac", "bc"}},
+		{`\d+`, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}},
+		{`(?i)ab.`, []string{"AB", "Ab", "aB", "ab"}},
+		{`[^a]+`, nil},
+		{`a*`, nil},
+		{`\x{FFFD}`, nil},
+		{`ab\x{FFFD}c`, []string{"ab"}},
+		{`[a\x{FFFD}]b`, []string{"b"}},
+	}
+	for _, test := range tests {
+		tree, err := syntax.Parse(test.expr, syntax.Perl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := requiredLiterals(tree.Simplify())
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s\nExpected: %q\nGot:      %q", test.expr, test.expected, got)
+		}
+	}
+}
+
+func TestRequiredLiteralsLimit(t *testing.T) {
+	tree, err := syntax.Parse(`(?i)hello world`, syntax.Perl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := requiredLiterals(tree.Simplify())
+	if !ok || len(got) != maxRegexLiterals {
+		t.Fatalf("Got %d literals", len(got))
+	}
+	for _, lit := range got {
+		if len(lit) != len("hello w") {
+			t.Errorf("Got %q", lit)
+		}
+	}
+}
+
+func TestCompileRegexSetError(t *testing.T) {
+	if _, err := CompileRegexSet([]string{"ok", "(unclosed"}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -0,0 +1,191 @@
+package ahocorasick
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/AlexanderZh/ahocorasick/internal/patternfile"
+)
+
+// Registry holds the current Matcher of a set of patterns which is reloaded
+// while it is searched. Searches read the current Matcher without locking,
+// and a reload compiles the new patterns aside and only swaps them in once
+// compiled: searches in flight keep using the Matcher they started with.
+// Matchers given to or compiled by a Registry must not be modified, such as
+// by SetBoundary, once they are current.
+type Registry struct {
+	current atomic.Pointer[registryVersion]
+	reload  sync.Mutex // serializes reloads, so that versions increase
+	opts    RegistryOptions
+}
+
+type registryVersion struct {
+	matcher *Matcher
+	version uint64
+}
+
+// RegistryOptions configure a Registry.
+type RegistryOptions struct {
+	// Compile compiles the patterns of Reload and ReloadFromFile. If nil,
+	// the patterns are compiled by CompilePatterns, so the key of a match is
+	// the index of its pattern.
+	Compile func(patterns [][]byte) (*Matcher, error)
+	// OnReload, if not nil, is called after each reload with the version of
+	// the new Matcher, or with the current version and the error which kept
+	// the reload from happening. It is called with reloads blocked, and must
+	// not reload the Registry itself.
+	OnReload func(version uint64, err error)
+}
+
+// NewRegistry returns a Registry holding m, which may be nil until the first
+// reload, configured by opts, which may be nil.
+func NewRegistry(m *Matcher, opts *RegistryOptions) *Registry {
+	r := &Registry{}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if m != nil {
+		r.current.Store(&registryVersion{m, 1})
+	}
+	return r
+}
+
+// Current returns the current Matcher and its version, which increases by
+// one with every successful reload. It returns nil and 0 before the first
+// Matcher.
+func (r *Registry) Current() (*Matcher, uint64) {
+	if v := r.current.Load(); v != nil {
+		return v.matcher, v.version
+	}
+	return nil, 0
+}
+
+// Matcher returns the current Matcher.
+func (r *Registry) Matcher() *Matcher {
+	m, _ := r.Current()
+	return m
+}
+
+// Version returns the version of the current Matcher.
+func (r *Registry) Version() uint64 {
+	_, version := r.Current()
+	return version
+}
+
+// Swap makes m the current Matcher, and returns its version.
+func (r *Registry) Swap(m *Matcher) uint64 {
+	r.reload.Lock()
+	defer r.reload.Unlock()
+	version := r.swap(m)
+	r.notify(version, nil)
+	return version
+}
+
+func (r *Registry) swap(m *Matcher) uint64 {
+	version := r.Version() + 1
+	r.current.Store(&registryVersion{m, version})
+	return version
+}
+
+func (r *Registry) notify(version uint64, err error) {
+	if r.opts.OnReload != nil {
+		r.opts.OnReload(version, err)
+	}
+}
+
+// Reload compiles the patterns and makes them current. If they fail to
+// compile, the current Matcher is kept and the error returned. It returns the
+// version of the current Matcher.
+func (r *Registry) Reload(patterns [][]byte) (uint64, error) {
+	r.reload.Lock()
+	defer r.reload.Unlock()
+	return r.compile(patterns)
+}
+
+// ReloadFromFile reads the patterns from a file holding one per line and
+// makes them current, as Reload does. In a pattern, \\, \t, \n, \r and \xHH
+// stand for a backslash, a tab, a newline, a carriage return and the byte of
+// hexadecimal value HH, and empty lines are ignored, as in the patterns files
+// of the acgrep command.
+func (r *Registry) ReloadFromFile(path string) (uint64, error) {
+	r.reload.Lock()
+	defer r.reload.Unlock()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r.failed(err)
+	}
+	patterns, err := patternfile.Parse(data)
+	if err != nil {
+		return r.failed(fmt.Errorf("ahocorasick: %s: %w", path, err))
+	}
+	return r.compile(patterns)
+}
+
+func (r *Registry) compile(patterns [][]byte) (uint64, error) {
+	var m *Matcher
+	var err error
+	if r.opts.Compile != nil {
+		m, err = r.opts.Compile(patterns)
+	} else {
+		compiled := make([]Pattern, len(patterns))
+		for i, p := range patterns {
+			compiled[i].Word = p
+		}
+		m = CompilePatterns(compiled)
+	}
+	if err == nil && m == nil {
+		err = errors.New("ahocorasick: no matcher compiled")
+	}
+	if err != nil {
+		return r.failed(err)
+	}
+	version := r.swap(m)
+	r.notify(version, nil)
+	return version, nil
+}
+
+// Add inserts patterns into a copy of the current Matcher, as Matcher.Add does,
+// and makes the copy current. It returns its version and the key of the first
+// pattern added. Without a current Matcher, the patterns are added to an empty
+// one, so their keys start at 0.
+func (r *Registry) Add(patterns []Pattern) (version uint64, first int) {
+	r.reload.Lock()
+	defer r.reload.Unlock()
+	m := r.Matcher()
+	if m == nil {
+		m = CompilePatterns(nil)
+	}
+	added, first := m.Add(patterns)
+	version = r.swap(added)
+	r.notify(version, nil)
+	return version, first
+}
+
+// Remove removes the patterns of key from a copy of the current Matcher, as
+// Matcher.Remove does, and makes the copy current. It returns the version of
+// the current Matcher, which is kept if no pattern has the key.
+func (r *Registry) Remove(key int) (version uint64, ok bool) {
+	r.reload.Lock()
+	defer r.reload.Unlock()
+	m, version := r.Current()
+	if m == nil {
+		return version, false
+	}
+	removed, ok := m.Remove(key)
+	if !ok {
+		return version, false
+	}
+	version = r.swap(removed)
+	r.notify(version, nil)
+	return version, true
+}
+
+// failed reports a reload which failed with err.
+func (r *Registry) failed(err error) (uint64, error) {
+	version := r.Version()
+	r.notify(version, err)
+	return version, err
+}
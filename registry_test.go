@@ -0,0 +1,114 @@
+package ahocorasick
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	type event struct {
+		version uint64
+		err     error
+	}
+	var events []event
+	r := NewRegistry(nil, &RegistryOptions{
+		OnReload: func(version uint64, err error) { events = append(events, event{version, err}) },
+	})
+	if m, version := r.Current(); m != nil || version != 0 {
+		t.Errorf("Expected: no matcher\nGot:      %v, %d", m, version)
+	}
+
+	version, err := r.Reload([][]byte{[]byte("foo"), []byte("bar")})
+	if err != nil || version != 1 || r.Version() != 1 {
+		t.Fatalf("Expected: version 1\nGot:      %d, %v", version, err)
+	}
+	old := r.Matcher()
+	if got := len(old.FindAllString("foo bar baz")); got != 2 {
+		t.Errorf("Expected: 2 matches\nGot:      %d", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "patterns.txt")
+	if err := os.WriteFile(path, []byte("baz\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := r.ReloadFromFile(path); err != nil || version != 2 {
+		t.Fatalf("Expected: version 2\nGot:      %d, %v", version, err)
+	}
+	if got := r.Matcher().FindAllString("foo bar baz"); len(got) != 1 || got[0].Index != 8 {
+		t.Errorf("Expected: baz at 8\nGot:      %v", got)
+	}
+	// The Matcher taken before the reload is unchanged.
+	if got := len(old.FindAllString("foo bar baz")); got != 2 {
+		t.Errorf("Expected: 2 matches\nGot:      %d", got)
+	}
+
+	// Failed reloads keep the current Matcher.
+	current := r.Matcher()
+	if _, err := r.ReloadFromFile(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected: %v\nGot:      %v", os.ErrNotExist, err)
+	}
+	os.WriteFile(path, []byte("bad\\q\n"), 0o644)
+	if _, err := r.ReloadFromFile(path); err == nil {
+		t.Error("Expected: an error")
+	}
+	if r.Matcher() != current || r.Version() != 2 {
+		t.Errorf("Expected: version 2 kept\nGot:      %d", r.Version())
+	}
+
+	if version := r.Swap(CompileStrings([]string{"qux"})); version != 3 {
+		t.Errorf("Expected: version 3\nGot:      %d", version)
+	}
+	if len(events) != 5 || events[0] != (event{1, nil}) || events[1] != (event{2, nil}) ||
+		events[2].version != 2 || events[2].err == nil || events[3].err == nil || events[4] != (event{3, nil}) {
+		t.Errorf("Expected: 5 reload events\nGot:      %v", events)
+	}
+
+	failing := NewRegistry(CompileStrings([]string{"a"}), &RegistryOptions{
+		Compile: func(patterns [][]byte) (*Matcher, error) { return nil, errors.New("rejected") },
+	})
+	if version, err := failing.Reload([][]byte{[]byte("b")}); err == nil || version != 1 {
+		t.Errorf("Expected: an error at version 1\nGot:      %d, %v", version, err)
+	}
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	sets := [][][]byte{
+		{[]byte("alpha"), []byte("beta")},
+		{[]byte("gamma")},
+	}
+	r := NewRegistry(nil, nil)
+	r.Reload(sets[0])
+	text := "alpha beta gamma"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				m, version := r.Current()
+				// Odd versions hold the first set, even ones the second.
+				expected := 2
+				if version%2 == 0 {
+					expected = 1
+				}
+				if got := len(m.FindAllString(text)); got != expected {
+					t.Errorf("version %d\nExpected: %d matches\nGot:      %d", version, expected, got)
+					return
+				}
+			}
+		}()
+	}
+	for i := 1; i <= 100; i++ {
+		if _, err := r.Reload(sets[i%2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if r.Version() != 101 {
+		t.Errorf("Expected: version 101\nGot:      %d", r.Version())
+	}
+}
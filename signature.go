@@ -0,0 +1,378 @@
+package ahocorasick
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// MaxSignatureGap is the largest upper bound accepted for a [n-m] gap.
+const MaxSignatureGap = 1 << 16
+
+// SignatureSet finds hex byte signatures in binary data, such as
+//
+//	4D 5A ?? 00 [2-4] FF
+//
+// where each signature is a sequence of
+//
+//	4D     the byte 0x4D
+//	??     any byte
+//	4? ?D  a byte with the given high or low nibble
+//	[n-m]  a gap of n to m arbitrary bytes
+//	[n]    a gap of exactly n arbitrary bytes
+//
+// Whitespace is optional between bytes. The longest run of fully specified
+// bytes of every signature is compiled into a Matcher, and the rest of the
+// signature is only verified around the hits of that run.
+type SignatureSet struct {
+	matcher    *Matcher
+	signatures []signature
+	maxLen     int // largest number of bytes a signature can span
+}
+
+// signature is a parsed signature split around its atom, the run of fully
+// specified bytes searched for with the automaton.
+type signature struct {
+	before []sigElem // elements preceding the atom
+	atom   []byte
+	after  []sigElem // elements following the atom
+	maxLen int
+}
+
+// sigElem is either a byte which matches data when data&mask == value, or a
+// gap of min to max arbitrary bytes.
+type sigElem struct {
+	value, mask byte
+	gap         bool
+	min, max    int
+}
+
+// CompileSignatures compiles a SignatureSet. The key reported for a match by
+// FindAllByteReader is the index of its signature. A signature which cannot
+// be parsed, or which has no fully specified byte, is rejected with a
+// *PatternError.
+func CompileSignatures(signatures []string) (*SignatureSet, error) {
+	s := &SignatureSet{signatures: make([]signature, len(signatures))}
+	atoms := make([][]byte, len(signatures))
+	keys := make([]uint64, len(signatures))
+	for i, text := range signatures {
+		sig, err := parseSignature(text)
+		if err != nil {
+			return nil, &PatternError{i, []byte(text), err.Error()}
+		}
+		s.signatures[i] = sig
+		atoms[i] = sig.atom
+		keys[i] = uint64(i)
+		if sig.maxLen > s.maxLen {
+			s.maxLen = sig.maxLen
+		}
+	}
+	s.matcher = compileKeyed(atoms, keys)
+	return s, nil
+}
+
+// parseSignature parses the text of a signature and picks its atom.
+func parseSignature(text string) (signature, error) {
+	var elems []sigElem
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '[':
+			end := i + 1
+			for end < len(text) && text[end] != ']' {
+				end++
+			}
+			if end == len(text) {
+				return signature{}, fmt.Errorf("missing closing ]")
+			}
+			gap, err := parseGap(text[i+1 : end])
+			if err != nil {
+				return signature{}, err
+			}
+			if len(elems) > 0 && elems[len(elems)-1].gap {
+				last := &elems[len(elems)-1]
+				last.min += gap.min
+				last.max += gap.max
+				if last.max > MaxSignatureGap {
+					return signature{}, fmt.Errorf("invalid gap [%d-%d]", last.min, last.max)
+				}
+			} else {
+				elems = append(elems, gap)
+			}
+			i = end + 1
+		default:
+			if i+1 >= len(text) {
+				return signature{}, fmt.Errorf("incomplete byte %q", text[i:])
+			}
+			elem, err := parseSigByte(text[i], text[i+1])
+			if err != nil {
+				return signature{}, err
+			}
+			elems = append(elems, elem)
+			i += 2
+		}
+	}
+	if len(elems) == 0 {
+		return signature{}, fmt.Errorf("empty signature")
+	}
+	if elems[0].gap || elems[len(elems)-1].gap {
+		return signature{}, fmt.Errorf("signature starts or ends with a gap")
+	}
+
+	// The atom is the longest run of fully specified bytes.
+	atomStart, atomLen := 0, 0
+	for i := 0; i < len(elems); {
+		j := i
+		for j < len(elems) && !elems[j].gap && elems[j].mask == 0xFF {
+			j++
+		}
+		if j-i > atomLen {
+			atomStart, atomLen = i, j-i
+		}
+		if j == i {
+			j++
+		}
+		i = j
+	}
+	if atomLen == 0 {
+		return signature{}, fmt.Errorf("no fully specified byte")
+	}
+
+	sig := signature{
+		before: elems[:atomStart],
+		after:  elems[atomStart+atomLen:],
+	}
+	for _, e := range elems {
+		if e.gap {
+			sig.maxLen += e.max
+		} else {
+			sig.maxLen++
+		}
+	}
+	for _, e := range elems[atomStart : atomStart+atomLen] {
+		sig.atom = append(sig.atom, e.value)
+	}
+	return sig, nil
+}
+
+// parseGap parses the inside of "[n-m]" or "[n]".
+func parseGap(text string) (sigElem, error) {
+	lo, hi := text, text
+	for i := 0; i < len(text); i++ {
+		if text[i] == '-' {
+			lo, hi = text[:i], text[i+1:]
+			break
+		}
+	}
+	min, err := strconv.Atoi(lo)
+	if err != nil || min < 0 {
+		return sigElem{}, fmt.Errorf("invalid gap [%s]", text)
+	}
+	max, err := strconv.Atoi(hi)
+	if err != nil || max < min || max > MaxSignatureGap {
+		return sigElem{}, fmt.Errorf("invalid gap [%s]", text)
+	}
+	return sigElem{gap: true, min: min, max: max}, nil
+}
+
+// parseSigByte parses a byte written as two hex digits, either of which may
+// be a ? wildcard.
+func parseSigByte(hi, lo byte) (sigElem, error) {
+	var e sigElem
+	for _, c := range []byte{hi, lo} {
+		e.value <<= 4
+		e.mask <<= 4
+		if c == '?' {
+			continue
+		}
+		v, err := strconv.ParseUint(string(c), 16, 8)
+		if err != nil {
+			return sigElem{}, fmt.Errorf("invalid byte %q", []byte{hi, lo})
+		}
+		e.value |= byte(v)
+		e.mask |= 0xF
+	}
+	return e, nil
+}
+
+// matchForward matches elems against data starting at pos and returns the
+// nearest end of a match.
+func matchForward(elems []sigElem, data []byte, pos int) (int, bool) {
+	n, ok := matchLength(len(elems), func(i int) sigElem { return elems[i] },
+		len(data)-pos, func(i int) byte { return data[pos+i] })
+	return pos + n, ok
+}
+
+// matchBackward matches elems against data ending right before pos and
+// returns the nearest start of a match.
+func matchBackward(elems []sigElem, data []byte, pos int) (int, bool) {
+	n, ok := matchLength(len(elems), func(i int) sigElem { return elems[len(elems)-1-i] },
+		pos, func(i int) byte { return data[pos-1-i] })
+	return pos - n, ok
+}
+
+// matchLength returns the smallest number of bytes the n elements given by
+// elem match, out of the available bytes given by at. Rather than trying
+// every length of every gap in turn, which takes exponential time, it keeps
+// the set of lengths the elements so far can match, so that each element
+// costs at most one pass over the bytes a signature spans.
+func matchLength(n int, elem func(int) sigElem, available int, at func(int) byte) (int, bool) {
+	span := 0
+	for i := 0; i < n; i++ {
+		if e := elem(i); e.gap {
+			span += e.max
+		} else {
+			span++
+		}
+	}
+	if span > available {
+		span = available
+	}
+	// reach[i] reports whether the elements so far can match i bytes. It
+	// is false outside reach[lo:hi+1].
+	reach, next := make([]bool, span+1), make([]bool, span+1)
+	reach[0] = true
+	lo, hi := 0, 0
+	for k := 0; k < n; k++ {
+		e := elem(k)
+		if e.gap {
+			// i bytes are matched if any of i-max to i-min were.
+			from, to := lo+e.min, hi+e.max
+			if from > span {
+				return 0, false
+			}
+			if to > span {
+				to = span
+			}
+			count := 0
+			for i := from; i <= to; i++ {
+				if j := i - e.min; j <= hi && reach[j] {
+					count++
+				}
+				if j := i - e.max - 1; j >= lo && reach[j] {
+					count--
+				}
+				next[i] = count > 0
+			}
+			for i := lo; i <= hi; i++ {
+				reach[i] = false
+			}
+			reach, next = next, reach
+			lo, hi = from, to
+		} else {
+			to := hi + 1
+			if to > span {
+				to = span
+			}
+			for i := to - 1; i >= lo; i-- {
+				reach[i+1] = reach[i] && at(i)&e.mask == e.value
+			}
+			reach[lo] = false
+			lo, hi = lo+1, to
+		}
+		for lo <= hi && !reach[lo] {
+			lo++
+		}
+		for hi >= lo && !reach[hi] {
+			hi--
+		}
+		if lo > hi {
+			return 0, false
+		}
+	}
+	return lo, true
+}
+
+// verify checks the signature around its atom found at data[atomStart:] and
+// returns the bounds of the whole match.
+func (sig *signature) verify(data []byte, atomStart int) (start, end int, ok bool) {
+	start, ok = matchBackward(sig.before, data, atomStart)
+	if !ok {
+		return 0, 0, false
+	}
+	end, ok = matchForward(sig.after, data, atomStart+len(sig.atom))
+	return start, end, ok
+}
+
+// FindAllByteSlice finds all instances of the signatures in data, in the
+// order their atoms are found. Where gaps allow several matches around the
+// same atom, the one starting and ending nearest to the atom is reported.
+func (s *SignatureSet) FindAllByteSlice(data []byte) []*Match {
+	var matches []*Match
+	state := 0
+	for i, b := range data {
+		state = s.matcher.advance(state, b)
+		for _, item := range s.matcher.output[state] {
+			sig := &s.signatures[item.Key]
+			if start, end, ok := sig.verify(data, i+1-len(sig.atom)); ok {
+				matches = append(matches, &Match{data[start:end], start})
+			}
+		}
+	}
+	return matches
+}
+
+// FindAllString finds all instances of the signatures in the text.
+func (s *SignatureSet) FindAllString(text string) []*Match {
+	return s.FindAllByteSlice([]byte(text))
+}
+
+// FindAllByteReader finds all instances of the signatures in the stream and
+// appends the end position and the key of each of them to matches. Only the
+// bytes which a signature can still span are kept in memory.
+func (s *SignatureSet) FindAllByteReader(reader io.Reader, matches Matches) {
+	type candidate struct {
+		key       uint64
+		atomStart int // absolute position of the atom
+	}
+	var pending []candidate
+
+	var window []byte // the stream from position offset on
+	offset := 0
+	chunk := make([]byte, 4096)
+	state := 0
+	eof := false
+
+	for !eof {
+		n, err := reader.Read(chunk)
+		eof = err != nil
+		scanned := offset + len(window)
+		window = append(window, chunk[:n]...)
+		for pos := scanned; pos < offset+len(window); pos++ {
+			state = s.matcher.advance(state, window[pos-offset])
+			for _, item := range s.matcher.output[state] {
+				pending = append(pending, candidate{item.Key, pos + 1 - int(item.Len)})
+			}
+		}
+
+		// Verify the candidates whose signatures cannot span beyond the
+		// bytes read so far, keeping the order in which they were found. A
+		// signature spans at most maxLen bytes on either side of its atom.
+		end := offset + len(window)
+		done := 0
+		for _, c := range pending {
+			sig := &s.signatures[c.key]
+			if !eof && c.atomStart+sig.maxLen > end {
+				break
+			}
+			if _, stop, ok := sig.verify(window, c.atomStart-offset); ok {
+				matches.Append(offset+stop, int(c.key))
+			}
+			done++
+		}
+		pending = pending[done:]
+
+		// Drop the bytes no candidate, present or future, can reach.
+		keep := end - s.maxLen
+		for _, c := range pending {
+			if c.atomStart-s.maxLen < keep {
+				keep = c.atomStart - s.maxLen
+			}
+		}
+		if keep > offset {
+			window = append(window[:0], window[keep-offset:]...)
+			offset = keep
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSignatureSet(t *testing.T) {
+	s, err := CompileSignatures([]string{
+		"4D 5A ?? 00 [2-4] FF",
+		"DEADBEEF",
+		"C3 [1] 9? ?0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("\x00MZ\x90\x00abFF\xffMZ\x01\x00abcde\xff\xde\xad\xbe\xef\xc3x\x95\x10\xc3\x95\x10")
+	expected := []Match{
+		{[]byte("MZ\x90\x00abFF\xff"), 1},
+		{[]byte("\xde\xad\xbe\xef"), 20},
+		{[]byte("\xc3x\x95\x10"), 24},
+	}
+	got := convert(s.FindAllByteSlice(data))
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %q\nGot:      %q", expected, got)
+	}
+
+	Ms := &MatchesKeys{}
+	s.FindAllByteReader(iotest.OneByteReader(bytes.NewReader(data)), Ms)
+	keys := []MatchKey{{10, 0}, {24, 1}, {28, 2}}
+	if !reflect.DeepEqual(Ms.matches, keys) {
+		t.Errorf("Expected: %v\nGot:      %v", keys, Ms.matches)
+	}
+}
+
+func TestSignatureSetReaderAgreesWithSlice(t *testing.T) {
+	signatures := []string{"01 02 [0-8] 03", "?? 04 05 [3] 06 0?", "07 08 09"}
+	s, err := CompileSignatures(signatures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(r.Intn(10))
+	}
+
+	var ends []MatchKey
+	for _, match := range s.FindAllByteSlice(data) {
+		ends = append(ends, MatchKey{Index: match.Index + len(match.Word)})
+	}
+	if len(ends) == 0 {
+		t.Fatal("no matches in random data")
+	}
+	Ms := &MatchesKeys{}
+	s.FindAllByteReader(bytes.NewReader(data), Ms)
+	if len(Ms.matches) != len(ends) {
+		t.Fatalf("Got %d matches from the reader, %d from the slice", len(Ms.matches), len(ends))
+	}
+	for i := range ends {
+		if Ms.matches[i].Index != ends[i].Index {
+			t.Fatalf("match %d ends at %d in the reader, %d in the slice", i, Ms.matches[i].Index, ends[i].Index)
+		}
+	}
+}
+
+func TestSignatureSetGapsBacktrack(t *testing.T) {
+	// Trying every length of every gap in turn would take 256^3 steps
+	// for each AA before failing on the BB.
+	s, err := CompileSignatures([]string{"AA [0-255] AA [0-255] AA [0-255] BB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte{0xAA}, 4096)
+	if got := s.FindAllByteSlice(data); len(got) != 0 {
+		t.Errorf("Expected: no match\nGot:      %d", len(got))
+	}
+	data[1000] = 0xBB
+	got := convert(s.FindAllByteSlice(data))
+	// Every AA from 1000-3-3*255 to 1000-3 starts a match up to the BB.
+	if len(got) != 766 || got[0].Index != 232 || len(got[0].Word) != 769 || got[len(got)-1].Index != 997 {
+		t.Errorf("Expected: 766 matches from 232 to 997\nGot:      %d", len(got))
+	}
+}
+
+func TestCompileSignaturesErrors(t *testing.T) {
+	tests := []struct {
+		signature string
+		reason    string
+	}{
+		{"", "empty signature"},
+		{"4D 5", `incomplete byte "5"`},
+		{"4D XZ", `invalid byte "XZ"`},
+		{"[2] 4D", "signature starts or ends with a gap"},
+		{"4D [4-2] 5A", "invalid gap [4-2]"},
+		{"4D [40000] [30000-40000] 5A", "invalid gap [70000-80000]"},
+		{"4D [2-4 5A", "missing closing ]"},
+		{"?? 4? ??", "no fully specified byte"},
+	}
+	for _, test := range tests {
+		_, err := CompileSignatures([]string{test.signature})
+		var perr *PatternError
+		if !errors.As(err, &perr) || perr.Reason != test.reason {
+			t.Errorf("%q: got %v, expected %q", test.signature, err, test.reason)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"sort"
+	"unsafe"
+)
+
+// Stats describes the size of a Matcher.
+type Stats struct {
+	States        int       // states of the trie, the root included
+	FreeSlots     int       // slots of the double array holding no state
+	LoadFactor    float64   // States over the slots of the double array
+	Patterns      int       // distinct keys of the outputs
+	OutputEntries int       // entries of the output sets, inherited ones included
+	MaxDepth      int       // length of the longest path from the root
+	Alphabet      ByteClass // bytes on the edges of the trie
+	Anchored      bool      // only patterns starting the text are reported, see CompileStringsAnchored
+	Anchors       int       // patterns with anchors, see Pattern
+	Boundaries    int       // patterns with a boundary of their own, see Pattern
+	Boundary      Boundary  // boundary of the other patterns, see SetBoundary
+	Memory        MemoryStats
+}
+
+// MemoryStats are the heap bytes held by the arrays of a Matcher, counted by
+// capacity.
+type MemoryStats struct {
+	Base   int
+	Check  int
+	Fail   int // the failure links, and their index once patterns are added
+	Output int // the slice of output sets and the sets themselves
+	Tables int // the anchors and boundaries of the patterns
+}
+
+// Total returns the heap bytes held by all the arrays.
+func (s MemoryStats) Total() int {
+	return s.Base + s.Check + s.Fail + s.Output + s.Tables
+}
+
+const (
+	intSize   = int(unsafe.Sizeof(int(0)))
+	sliceSize = int(unsafe.Sizeof([]SWord(nil)))
+	swordSize = int(unsafe.Sizeof(SWord{}))
+)
+
+// Stats returns the statistics of the Matcher. They are computed from its
+// arrays alone, so they are the same for a deserialized Matcher, apart from
+// the memory which depends on the spare capacity of the arrays.
+func (m *Matcher) Stats() Stats {
+	var s Stats
+	for t := range m.check {
+		if t == 0 || m.check[t] >= 0 {
+			s.States++
+		}
+	}
+	s.FreeSlots = len(m.check) - s.States
+	if len(m.check) > 0 {
+		s.LoadFactor = float64(s.States) / float64(len(m.check))
+	}
+
+	keys := make(map[uint64]bool)
+	for _, out := range m.output {
+		s.OutputEntries += len(out)
+		for _, item := range out {
+			keys[item.Key] = true
+		}
+	}
+	s.Patterns = len(keys)
+	s.Anchored, s.Boundary = m.anchored, m.boundary
+	for key := range keys {
+		if m.anchors != nil && key < uint64(len(m.anchors)) && m.anchors[key] != 0 {
+			s.Anchors++
+		}
+		if m.boundaries != nil && key < uint64(len(m.boundaries)) && m.boundaries[key] != NoBoundary {
+			s.Boundaries++
+		}
+	}
+
+	g := m.graph(nil)
+	for _, state := range g.states {
+		if g.depth[state] > s.MaxDepth {
+			s.MaxDepth = g.depth[state]
+		}
+		for _, e := range g.edges[state] {
+			s.Alphabet.Add(byte(e.label))
+		}
+	}
+
+	s.Memory = MemoryStats{
+		Base:   intSize * cap(m.base),
+		Check:  intSize * cap(m.check),
+		Fail:   intSize * cap(m.fail),
+		Output: sliceSize * cap(m.output),
+		Tables: int(unsafe.Sizeof(Anchor(0)))*cap(m.anchors) + int(unsafe.Sizeof(Boundary(0)))*cap(m.boundaries),
+	}
+	if x := m.failIndex; x != nil {
+		s.Memory.Fail += intSize * (cap(x.first) + cap(x.next) + cap(x.prev))
+	}
+	for _, out := range m.output {
+		s.Memory.Output += swordSize * cap(out)
+	}
+	return s
+}
+
+// EstimateMemory estimates the heap bytes of the Matcher compiled from
+// patterns, without compiling it. The number of states of the trie and of the
+// entries of their output sets are exact, but the number of slots of the
+// double array and the spare capacity of the arrays are estimated. It holds
+// two int32 per state, far less than the Matcher.
+func EstimateMemory(patterns [][]byte) int {
+	sorted := append([][]byte{}, patterns...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+
+	// The states are the distinct prefixes of the patterns: each pattern
+	// adds those it does not share with the previous one in sorted order.
+	type prefix struct{ word, end int32 }
+	var states []prefix
+	for i, p := range sorted {
+		common := 0
+		if i > 0 {
+			prev := sorted[i-1]
+			for common < len(p) && common < len(prev) && p[common] == prev[common] {
+				common++
+			}
+		}
+		for end := common + 1; end <= len(p); end++ {
+			states = append(states, prefix{int32(i), int32(end)})
+		}
+	}
+	text := func(x prefix) []byte { return sorted[x.word][:x.end] }
+
+	// The output set of a state holds the patterns which are suffixes of
+	// its path. Reversed, they are its prefixes: with the states and the
+	// patterns sorted by their reversed bytes, the patterns which are
+	// suffixes of the current state are on a stack.
+	sort.Slice(states, func(i, j int) bool { return compareReversed(text(states[i]), text(states[j])) < 0 })
+	reversed := append([][]byte{}, sorted...)
+	sort.Slice(reversed, func(i, j int) bool { return compareReversed(reversed[i], reversed[j]) < 0 })
+	type suffix struct {
+		word     []byte
+		all, own int // the patterns which are suffixes of word, and equal to it
+	}
+	var stack []suffix
+	pop := func(x []byte) {
+		for len(stack) > 0 && !bytes.HasSuffix(x, stack[len(stack)-1].word) {
+			stack = stack[:len(stack)-1]
+		}
+	}
+	entries := 0
+	for i, j := 0, 0; j < len(states); {
+		if i < len(reversed) && compareReversed(reversed[i], text(states[j])) <= 0 {
+			p := reversed[i]
+			i++
+			if n := len(stack); n > 0 && bytes.Equal(stack[n-1].word, p) {
+				stack[n-1].all++
+				stack[n-1].own++
+				continue
+			}
+			pop(p)
+			below := 0
+			if len(stack) > 0 {
+				below = stack[len(stack)-1].all
+			}
+			stack = append(stack, suffix{p, below + 1, 1})
+			continue
+		}
+		x := text(states[j])
+		j++
+		pop(x)
+		all, own := 0, 0
+		if n := len(stack); n > 0 {
+			all = stack[n-1].all
+			if len(stack[n-1].word) == len(x) {
+				own = stack[n-1].own
+			}
+		}
+		// The set is a copy of the set of the fail state, to which the
+		// patterns ending at the state are appended. The spare capacity
+		// this leaves depends on the runtime: half of the copy is a fair
+		// average.
+		entries += all
+		if inherited := all - own; own > 0 {
+			entries += inherited / 2
+		}
+	}
+
+	// The double array is usually close to full, and arrays grown by
+	// append have on average an eighth of spare capacity.
+	n := 1 + len(states)
+	capacity := n + n/8
+	if capacity < 2048 {
+		capacity = 2048
+	}
+	return capacity*(3*intSize+sliceSize) + entries*swordSize
+}
+
+// compareReversed compares a and b read from their ends.
+func compareReversed(a, b []byte) int {
+	for i := 1; i <= len(a) && i <= len(b); i++ {
+		if a[len(a)-i] != b[len(b)-i] {
+			if a[len(a)-i] < b[len(b)-i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
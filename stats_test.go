@@ -0,0 +1,73 @@
+package ahocorasick
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	m := CompileStrings([]string{"he", "she", "his", "hers"})
+	s := m.Stats()
+
+	if s.States != 10 || s.Patterns != 4 || s.OutputEntries != 5 || s.MaxDepth != 4 {
+		t.Errorf("Expected: 10 states, 4 patterns, 5 outputs, depth 4\nGot:      %+v", s)
+	}
+	if s.States+s.FreeSlots != len(m.check) || s.LoadFactor != float64(s.States)/float64(len(m.check)) {
+		t.Errorf("Expected: %d slots\nGot:      %+v", len(m.check), s)
+	}
+	if alphabet := string(s.Alphabet.Bytes()); alphabet != "ehirs" {
+		t.Errorf("Expected: ehirs\nGot:      %s", alphabet)
+	}
+	if s.Memory.Base != 8*cap(m.base) || s.Memory.Tables != 0 || s.Memory.Total() < s.Memory.Output {
+		t.Errorf("Expected: %d bytes of base\nGot:      %+v", 8*cap(m.base), s.Memory)
+	}
+
+	d, err := Deserialize(m.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := d.Stats()
+	ds.Memory, s.Memory = MemoryStats{}, MemoryStats{}
+	if ds != s {
+		t.Errorf("Expected: %+v\nGot:      %+v", s, ds)
+	}
+
+	p := CompilePatterns([]Pattern{{Word: []byte("a"), Anchor: AnchorStartText}, {Word: []byte("b")}})
+	if ps := p.Stats(); ps.Memory.Tables != 2 {
+		t.Errorf("Expected: 2 bytes of tables\nGot:      %+v", ps.Memory)
+	}
+	if ps := p.Stats(); ps.Anchors != 1 || ps.Boundaries != 0 || ps.Anchored {
+		t.Errorf("Expected: 1 anchored pattern\nGot:      %+v", ps)
+	}
+	if as := CompileStringsAnchored([]string{"a"}).Stats(); !as.Anchored {
+		t.Errorf("Expected: an anchored matcher\nGot:      %+v", as)
+	}
+}
+
+func TestEstimateMemory(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tests := []struct {
+		n, length, alphabet int
+	}{
+		{100, 8, 256},
+		{10000, 8, 256},
+		{10000, 10, 26},
+		{5000, 12, 4},
+		{3000, 3, 26},
+		{2000, 16, 2},
+	}
+	for _, test := range tests {
+		words := make([][]byte, test.n)
+		for i := range words {
+			words[i] = make([]byte, 1+r.Intn(test.length))
+			for j := range words[i] {
+				words[i][j] = byte('a' + r.Intn(test.alphabet))
+			}
+		}
+		actual := CompileByteSlices(words).Stats().Memory.Total()
+		estimate := EstimateMemory(words)
+		if ratio := float64(estimate) / float64(actual); ratio < 0.8 || ratio > 1.25 {
+			t.Errorf("%+v\nExpected: about %d bytes\nGot:      %d", test, actual, estimate)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package ahocorasick
+
+import "sort"
+
+// Symbol is the type of the elements of the texts and patterns a
+// SymbolMatcher works on, such as token IDs or runes.
+type Symbol interface {
+	~uint8 | ~uint16 | ~uint32 | ~int32
+}
+
+// denseAlphabet bounds the symbols looked up in a table rather than a map.
+const denseAlphabet = 1 << 16
+
+// SymbolMatcher is the pattern matching state machine over an alphabet of
+// arbitrary symbols. The symbols occurring in the patterns are remapped to
+// dense codes, the most frequent first, and the double array trie is built
+// over these codes exactly as for bytes. Symbols of the text which occur in no
+// pattern take the automaton back to its root.
+type SymbolMatcher[T Symbol] struct {
+	matcher  *Matcher
+	alphabet []T         // symbols by code
+	dense    []int32     // code+1 of the symbols below denseAlphabet, 0 if absent
+	sparse   map[T]int32 // code of the other symbols
+}
+
+// SymbolMatch represents a matched pattern in a text of symbols.
+type SymbolMatch[T Symbol] struct {
+	Word  []T // the matched pattern
+	Index int // the start index of the match
+	Key   int // the index of the pattern
+}
+
+// CompileSymbols compiles a SymbolMatcher from a slice of patterns. The key
+// of a match is the index of its pattern in patterns.
+func CompileSymbols[T Symbol](patterns [][]T) *SymbolMatcher[T] {
+	s := new(SymbolMatcher[T])
+
+	count := make(map[T]int)
+	for _, p := range patterns {
+		for _, c := range p {
+			if count[c] == 0 {
+				s.alphabet = append(s.alphabet, c)
+			}
+			count[c]++
+		}
+	}
+	sort.SliceStable(s.alphabet, func(i, j int) bool {
+		return count[s.alphabet[i]] > count[s.alphabet[j]]
+	})
+	for code, c := range s.alphabet {
+		s.setCode(c, int32(code))
+	}
+
+	words := make([][]int32, len(patterns))
+	keys := make([]uint64, len(patterns))
+	for i, p := range patterns {
+		words[i] = make([]int32, len(p))
+		for j, c := range p {
+			words[i][j], _ = s.code(c)
+		}
+		keys[i] = uint64(i)
+	}
+	sort.Sort(keyedCodes{words, keys})
+	s.matcher = build(words, keys)
+	return s
+}
+
+// CompileRunes compiles a SymbolMatcher over the runes of words, whose
+// matches are counted in runes rather than bytes.
+func CompileRunes(words []string) *SymbolMatcher[rune] {
+	patterns := make([][]rune, len(words))
+	for i, word := range words {
+		patterns[i] = []rune(word)
+	}
+	return CompileSymbols(patterns)
+}
+
+func (s *SymbolMatcher[T]) setCode(c T, code int32) {
+	if c >= 0 && uint64(c) < denseAlphabet {
+		if int(c) >= len(s.dense) {
+			s.dense = append(s.dense, make([]int32, int(c)+1-len(s.dense))...)
+		}
+		s.dense[c] = code + 1
+		return
+	}
+	if s.sparse == nil {
+		s.sparse = make(map[T]int32)
+	}
+	s.sparse[c] = code
+}
+
+// code returns the code of c, or false if c occurs in no pattern.
+func (s *SymbolMatcher[T]) code(c T) (int32, bool) {
+	if c >= 0 && uint64(c) < denseAlphabet {
+		if int(c) < len(s.dense) && s.dense[c] != 0 {
+			return s.dense[c] - 1, true
+		}
+		return 0, false
+	}
+	code, ok := s.sparse[c]
+	return code, ok
+}
+
+// Alphabet returns the symbols occurring in the patterns, the most frequent
+// first.
+func (s *SymbolMatcher[T]) Alphabet() []T {
+	return append([]T{}, s.alphabet...)
+}
+
+// FindAll finds all instances of the patterns in the text.
+func (s *SymbolMatcher[T]) FindAll(text []T) []*SymbolMatch[T] {
+	var matches []*SymbolMatch[T]
+	state := 0
+	for i, c := range text {
+		code, ok := s.code(c)
+		if !ok {
+			state = 0
+			continue
+		}
+		state = s.matcher.step(state, int(code))
+		for _, item := range s.matcher.output[state] {
+			start := i - int(item.Len) + 1
+			matches = append(matches, &SymbolMatch[T]{text[start : i+1], start, int(item.Key)})
+		}
+	}
+	return matches
+}
+
+// keyedCodes sorts coded words together with their keys.
+type keyedCodes struct {
+	words [][]int32
+	keys  []uint64
+}
+
+func (kc keyedCodes) Len() int { return len(kc.words) }
+func (kc keyedCodes) Less(i, j int) bool {
+	a, b := kc.words[i], kc.words[j]
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+func (kc keyedCodes) Swap(i, j int) {
+	kc.words[i], kc.words[j] = kc.words[j], kc.words[i]
+	kc.keys[i], kc.keys[j] = kc.keys[j], kc.keys[i]
+}
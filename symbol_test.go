@@ -0,0 +1,92 @@
+package ahocorasick
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+type symbolResult[T Symbol] struct {
+	word  []T
+	index int
+	key   int
+}
+
+func convertSymbols[T Symbol](got []*SymbolMatch[T]) []symbolResult[T] {
+	var converted []symbolResult[T]
+	for _, m := range got {
+		converted = append(converted, symbolResult[T]{m.Word, m.Index, m.Key})
+	}
+	return converted
+}
+
+func TestCompileSymbolsTokens(t *testing.T) {
+	patterns := [][]uint32{
+		{7, 4000000000, 12},
+		{4000000000, 12},
+		{70000, 70001},
+		{7},
+	}
+	m := CompileSymbols(patterns)
+	text := []uint32{1, 7, 4000000000, 12, 70000, 70001, 99, 7}
+	got := convertSymbols(m.FindAll(text))
+	expected := []symbolResult[uint32]{
+		{[]uint32{7}, 1, 3},
+		{[]uint32{4000000000, 12}, 2, 1},
+		{[]uint32{7, 4000000000, 12}, 1, 0},
+		{[]uint32{70000, 70001}, 4, 2},
+		{[]uint32{7}, 7, 3},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+	if alphabet := m.Alphabet(); len(alphabet) != 5 || alphabet[0] != 7 {
+		t.Errorf("Got alphabet %v", alphabet)
+	}
+}
+
+func TestCompileRunes(t *testing.T) {
+	m := CompileRunes([]string{"持有人", "有", "hé"})
+	got := convertSymbols(m.FindAll([]rune("hé 持有人")))
+	expected := []symbolResult[rune]{
+		{[]rune("hé"), 0, 2},
+		{[]rune("有"), 4, 1},
+		{[]rune("持有人"), 3, 0},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected: %v\nGot:      %v", expected, got)
+	}
+}
+
+func TestCompileSymbolsAgainstBytes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var words [][]byte
+	var patterns [][]uint16
+	for i := 0; i < 500; i++ {
+		word := make([]byte, 1+r.Intn(5))
+		pattern := make([]uint16, len(word))
+		for j := range word {
+			word[j] = byte('a' + r.Intn(6))
+			pattern[j] = uint16(word[j]) * 300
+		}
+		words = append(words, word)
+		patterns = append(patterns, pattern)
+	}
+	text := make([]byte, 5000)
+	symbols := make([]uint16, len(text))
+	for i := range text {
+		text[i] = byte('a' + r.Intn(7))
+		symbols[i] = uint16(text[i]) * 300
+	}
+
+	expected := CompileByteSlices(append([][]byte{}, words...)).FindAllByteSlice(text)
+	got := CompileSymbols(patterns).FindAll(symbols)
+	if len(got) != len(expected) {
+		t.Fatalf("Got %d matches, expected %d", len(got), len(expected))
+	}
+	for i := range got {
+		if got[i].Index != expected[i].Index || len(got[i].Word) != len(expected[i].Word) {
+			t.Fatalf("match %d: got %d+%d, expected %d+%d", i, got[i].Index, len(got[i].Word), expected[i].Index, len(expected[i].Word))
+		}
+	}
+}
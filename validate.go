@@ -0,0 +1,160 @@
+package ahocorasick
+
+import "fmt"
+
+// ValidationError describes the first inconsistency found by Validate.
+type ValidationError struct {
+	Slot   int // the slot of the double array, or -1 for the whole Matcher
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Slot < 0 {
+		return "ahocorasick: invalid matcher: " + e.Reason
+	}
+	return fmt.Sprintf("ahocorasick: invalid matcher: slot %d: %s", e.Slot, e.Reason)
+}
+
+func invalid(slot int, format string, args ...any) error {
+	return &ValidationError{slot, fmt.Sprintf(format, args...)}
+}
+
+// Validate checks the invariants of the double array trie, which a search
+// relies on not to index its arrays out of range. Every state is reached from
+// the root by a byte, so that check[base[s]+c] == s for its parent s; the
+// failure link of a state leads to a shallower state; the patterns of an
+// output set are no longer than the depth of their state; and the free slots
+// are linked in a sound list. A Matcher which fails validation has been
+// corrupted, typically in its serialized form.
+func (m *Matcher) Validate() error {
+	n := len(m.check)
+	if n == 0 {
+		return invalid(-1, "no root state")
+	}
+	if len(m.base) != n || len(m.fail) != n || len(m.output) != n {
+		return invalid(-1, "arrays of different lengths: base %d, check %d, fail %d, output %d",
+			len(m.base), n, len(m.fail), len(m.output))
+	}
+
+	occupied := func(s int) bool { return s == 0 || m.check[s] >= 0 }
+	for t := 1; t < n; t++ {
+		if !occupied(t) {
+			continue
+		}
+		p := m.check[t]
+		if p >= n || p == t || !occupied(p) {
+			return invalid(t, "parent %d is no state", p)
+		}
+		if c := t - m.base[p]; c < 0 || c > 255 {
+			return invalid(t, "no byte leads from parent %d", p)
+		}
+	}
+
+	// The depth of a state is one more than the depth of its parent. A
+	// parent chain which never reaches the root is a cycle.
+	depth := make([]int, n)
+	for i := range depth {
+		depth[i] = -1
+	}
+	depth[0] = 0
+	var chain []int
+	for t := 1; t < n; t++ {
+		if !occupied(t) {
+			continue
+		}
+		chain = chain[:0]
+		s := t
+		for depth[s] < 0 {
+			if len(chain) == n {
+				return invalid(t, "unreachable from the root")
+			}
+			chain = append(chain, s)
+			s = m.check[s]
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			depth[chain[i]] = depth[s] + 1
+			s = chain[i]
+		}
+	}
+
+	for s := 0; s < n; s++ {
+		if !occupied(s) {
+			if len(m.output[s]) > 0 {
+				return invalid(s, "output on a free slot")
+			}
+			continue
+		}
+		f := m.fail[s]
+		if s == 0 && f != 0 {
+			return invalid(s, "failure link of the root to %d", f)
+		}
+		if s != 0 && (f < 0 || f >= n || !occupied(f) || depth[f] >= depth[s]) {
+			return invalid(s, "failure link to %d is not a shallower state", f)
+		}
+		if f != 0 && s-m.base[m.check[s]] != f-m.base[m.check[f]] {
+			return invalid(s, "failure link to %d reached by another byte", f)
+		}
+		for _, item := range m.output[s] {
+			if item.Len < 1 || item.Len > uint64(depth[s]) {
+				return invalid(s, "output of length %d at depth %d", item.Len, depth[s])
+			}
+			if m.anchors != nil && item.Key >= uint64(len(m.anchors)) ||
+				m.boundaries != nil && item.Key >= uint64(len(m.boundaries)) {
+				return invalid(s, "output key %d has no options", item.Key)
+			}
+		}
+	}
+	return m.validateFreeList()
+}
+
+// validateFreeList checks the doubly linked list of the free slots, see
+// increaseSize: check[0] is minus the first free slot, and each free slot
+// holds minus the next one in check and minus the previous one in base, the
+// first one holding the last one and the last one -1. Slot 1, freed by Add,
+// may only be the first one.
+func (m *Matcher) validateFreeList() error {
+	free := 0
+	for s := 1; s < len(m.check); s++ {
+		if m.check[s] < 0 {
+			free++
+		}
+	}
+	first := m.firstFreeState()
+	if first == -1 {
+		if free > 0 {
+			return invalid(0, "%d free slots out of the free list", free)
+		}
+		return nil
+	}
+	if first < 1 || first >= len(m.check) {
+		return invalid(0, "first free slot %d out of range", first)
+	}
+	last := -m.base[first]
+	prev := last
+	s := first
+	for count := 1; ; count++ {
+		if count > free {
+			return invalid(s, "free list longer than the %d free slots", free)
+		}
+		if m.check[s] >= 0 {
+			return invalid(s, "state in the free list")
+		}
+		if -m.base[s] != prev {
+			return invalid(s, "previous free slot %d instead of %d", -m.base[s], prev)
+		}
+		next := -m.check[s]
+		if next == 1 {
+			if s != last {
+				return invalid(s, "free list ends before its last slot %d", last)
+			}
+			if count != free {
+				return invalid(s, "%d free slots out of the free list", free-count)
+			}
+			return nil
+		}
+		if next <= 1 || next >= len(m.check) {
+			return invalid(s, "next free slot %d out of range", next)
+		}
+		prev, s = s, next
+	}
+}
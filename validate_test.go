@@ -0,0 +1,99 @@
+package ahocorasick
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var matchers []*Matcher
+	for i := 0; i < 50; i++ {
+		words := make([][]byte, 1+r.Intn(300))
+		for j := range words {
+			words[j] = make([]byte, 1+r.Intn(8))
+			alphabet := 1 + r.Intn(256)
+			for k := range words[j] {
+				words[j][k] = byte(r.Intn(alphabet))
+			}
+		}
+		matchers = append(matchers, CompileByteSlices(words))
+	}
+	matchers = append(matchers,
+		CompileStrings(nil),
+		CompileStringsAnchored([]string{"he", "she"}),
+		CompilePatterns([]Pattern{{Word: []byte("a"), Anchor: AnchorStartLine}, {Word: []byte("ab"), Boundary: ASCIIWordBoundary}}),
+	)
+	for i, m := range matchers {
+		if err := m.Validate(); err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+		d, err := DeserializeWithOptions(m.Serialize(), &DeserializeOptions{Validate: true})
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+		} else if err := d.Validate(); err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+	}
+}
+
+func TestValidateCorrupted(t *testing.T) {
+	// The states of "he", "she", "his" and "hers" by path.
+	compiled := CompileStrings([]string{"he", "she", "his", "hers"})
+	state := func(m *Matcher, path string) int {
+		s := 0
+		for _, c := range []byte(path) {
+			s = m.base[s] + int(c)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(m *Matcher)
+	}{
+		{"short fail", func(m *Matcher) { m.fail = m.fail[:len(m.fail)-1] }},
+		{"own parent", func(m *Matcher) { m.check[state(m, "she")] = state(m, "she") }},
+		{"parent cycle", func(m *Matcher) {
+			m.check[state(m, "h")] = state(m, "he")
+		}},
+		{"parent out of range", func(m *Matcher) { m.check[state(m, "hi")] = len(m.check) }},
+		{"root fail", func(m *Matcher) { m.fail[0] = state(m, "h") }},
+		{"deeper fail", func(m *Matcher) { m.fail[state(m, "sh")] = state(m, "her") }},
+		{"fail by another byte", func(m *Matcher) { m.fail[state(m, "she")] = state(m, "hi") }},
+		{"long output", func(m *Matcher) { m.output[state(m, "he")][0].Len = 3 }},
+		{"output on free slot", func(m *Matcher) {
+			m.output[m.firstFreeState()] = []SWord{{Len: 1, Key: 0}}
+		}},
+		{"free list loop", func(m *Matcher) {
+			first := m.firstFreeState()
+			m.check[first] = -first
+		}},
+		{"free slot out of the list", func(m *Matcher) { m.check[0] = 0 }},
+	}
+	for _, test := range tests {
+		m, err := Deserialize(compiled.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.corrupt(m)
+		err = m.Validate()
+		var validationError *ValidationError
+		if !errors.As(err, &validationError) {
+			t.Errorf("%s: Expected: a ValidationError\nGot:      %v", test.name, err)
+		}
+	}
+
+	// A serialized Matcher whose "she" is its own parent still loads, unless
+	// it is validated.
+	m, _ := Deserialize(compiled.Serialize())
+	m.check[state(m, "she")] = state(m, "she")
+	data := m.Serialize()
+	if _, err := Deserialize(data); err != nil {
+		t.Errorf("Expected: no error\nGot:      %v", err)
+	}
+	if _, err := DeserializeWithOptions(data, &DeserializeOptions{Validate: true}); err == nil {
+		t.Error("Expected: an error")
+	}
+}